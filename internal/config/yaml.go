@@ -5,18 +5,33 @@ import (
 	"io/ioutil"
 	"os"
 
+	"salome-be/internal/money"
+
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Database DatabaseConfig `yaml:"database"`
-	JWT      JWTConfig      `yaml:"jwt"`
-	Server   ServerConfig   `yaml:"server"`
-	Email    EmailConfig    `yaml:"email"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Security SecurityConfig `yaml:"security"`
-	Features FeatureConfig  `yaml:"features"`
-	Midtrans MidtransConfig `yaml:"midtrans"`
+	Database       DatabaseConfig       `yaml:"database"`
+	JWT            JWTConfig            `yaml:"jwt"`
+	Server         ServerConfig         `yaml:"server"`
+	Email          EmailConfig          `yaml:"email"`
+	Redis          RedisConfig          `yaml:"redis"`
+	Security       SecurityConfig       `yaml:"security"`
+	Features       FeatureConfig        `yaml:"features"`
+	Midtrans       MidtransConfig       `yaml:"midtrans"`
+	Payment        PaymentConfig        `yaml:"payment"`
+	Renewal        RenewalConfig        `yaml:"renewal"`
+	Refund         RefundConfig         `yaml:"refund"`
+	Payout         PayoutConfig         `yaml:"payout"`
+	Pricing        PricingConfig        `yaml:"pricing"`
+	Reconciliation ReconciliationConfig `yaml:"reconciliation"`
+	Invoice        InvoiceConfig        `yaml:"invoice"`
+	Dunning        DunningConfig        `yaml:"dunning"`
+	Waitlist       WaitlistConfig       `yaml:"waitlist"`
+	Matchmaking    MatchmakingConfig    `yaml:"matchmaking"`
+	Invitations    InvitationConfig     `yaml:"invitations"`
+	Disputes       DisputeConfig        `yaml:"disputes"`
+	Scheduler      SchedulerConfig      `yaml:"scheduler"`
 }
 
 type DatabaseConfig struct {
@@ -106,6 +121,113 @@ type MidtransWebhookConfig struct {
 	Timeout   string `yaml:"timeout"`
 }
 
+// PaymentConfig selects the payment gateway: "midtrans" or "fake" (local simulation, for development only)
+type PaymentConfig struct {
+	Gateway string `yaml:"gateway"`
+}
+
+// RenewalConfig controls the billing cycle of paid groups
+type RenewalConfig struct {
+	InvoiceDaysBefore int  `yaml:"invoice_days_before"` // create renewal invoices this many days before period end
+	PeriodMonths      int  `yaml:"period_months"`       // length of one billing period
+	GraceDays         int  `yaml:"grace_days"`          // members expire this many days after period end when unpaid
+	AutoChargeWallet  bool `yaml:"auto_charge_wallet"`  // pay new invoices from the wallet when the balance covers them
+}
+
+// RefundConfig controls refunds to members who leave or are removed from a group
+type RefundConfig struct {
+	ApprovalThreshold money.Amount `yaml:"approval_threshold"` // refunds above this amount wait for admin approval
+	GatewayRefunds    bool         `yaml:"gateway_refunds"`    // refund payment link payments through the gateway instead of the wallet
+}
+
+// PayoutConfig controls withdrawals to bank accounts. The fee is Fee plus FeePercentage of the amount.
+type PayoutConfig struct {
+	MinimumAmount money.Amount `yaml:"minimum_amount"`
+	Fee           money.Amount `yaml:"fee"`            // fixed fee per payout
+	FeePercentage float64      `yaml:"fee_percentage"` // percentage of the amount, e.g. 1 for 1%
+}
+
+// PricingConfig controls seat prices. The admin fee is the app's fee percentage of the seat price plus
+// FixedFee, rounded to a multiple of RoundTo and then kept between MinFee and MaxFee. Apps can override the
+// percentage, fixed fee and caps.
+type PricingConfig struct {
+	DefaultFeePercentage float64                    `yaml:"default_fee_percentage"` // for apps without admin_fee_percentage
+	FixedFee             money.Amount               `yaml:"fixed_fee"`
+	MinFee               money.Amount               `yaml:"min_fee"`    // 0 = no minimum
+	MaxFee               money.Amount               `yaml:"max_fee"`    // 0 = no maximum
+	RoundTo              money.Amount               `yaml:"round_to"`   // e.g. 100 for whole hundreds of rupiah
+	Rounding             string                     `yaml:"rounding"`   // 'up', 'down' or 'nearest'
+	Surcharges           map[string]SurchargeConfig `yaml:"surcharges"` // by payment method, 'default' when unknown
+}
+
+// SurchargeConfig is the extra charged for a payment method: Percentage of the amount plus Fixed
+type SurchargeConfig struct {
+	Percentage float64      `yaml:"percentage"`
+	Fixed      money.Amount `yaml:"fixed"`
+}
+
+// ReconciliationConfig controls the comparison of gateway transactions with the payment provider
+type ReconciliationConfig struct {
+	LookbackDays    int  `yaml:"lookback_days"`     // window checked by the scheduled run
+	StaleAfterHours int  `yaml:"stale_after_hours"` // an unpaid link older than this is treated as expired
+	AutoFix         bool `yaml:"auto_fix"`          // fix safe discrepancies in scheduled runs
+}
+
+// InvoiceConfig controls the numbered receipts issued for successful payments
+type InvoiceConfig struct {
+	Prefix         string `yaml:"prefix"`          // invoice numbers look like PREFIX/2026/000042
+	CompanyName    string `yaml:"company_name"`    // issuer printed on the PDF
+	CompanyAddress string `yaml:"company_address"` // optional second issuer line
+	EmailEnabled   bool   `yaml:"email_enabled"`   // send a confirmation email with the PDF attached
+}
+
+// DunningConfig controls the reminders sent to pending members before their payment deadline
+type DunningConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Reminders []string `yaml:"reminders"` // time before the deadline, time.ParseDuration format, e.g. ["12h", "2h"]
+	Email     bool     `yaml:"email"`     // also send reminders by email, not only as in-app notifications
+}
+
+// WaitlistConfig controls the queues of full groups
+type WaitlistConfig struct {
+	ClaimWindowHours int `yaml:"claim_window_hours"` // how long an offered seat is held for the next user in line
+	MaxSize          int `yaml:"max_size"`           // waiting users per group, 0 = no limit
+}
+
+// MatchmakingConfig controls placing users into open public groups of an app
+type MatchmakingConfig struct {
+	PriceTolerancePercentage float64 `yaml:"price_tolerance_percentage"` // a group may cost this much more than the current seat price
+	CreateGroups             bool    `yaml:"create_groups"`              // create a platform-owned group when none fits
+	PlatformOwnerID          string  `yaml:"platform_owner_id"`          // owner of created groups, the first admin when empty
+}
+
+// InvitationConfig controls the email invitations owners send to join their group
+type InvitationConfig struct {
+	ExpiryDays int    `yaml:"expiry_days"` // how long an invitation can be accepted
+	JoinURL    string `yaml:"join_url"`    // sign-up-and-join page of the web app, the invitation token is added as ?token=
+}
+
+// DisputeConfig controls the disputes members open about a billing period of a group
+type DisputeConfig struct {
+	WindowDays int `yaml:"window_days"` // how long after the end of a billing period it can still be disputed
+}
+
+// SchedulerConfig controls the in-process background jobs. Intervals use time.ParseDuration format.
+type SchedulerConfig struct {
+	Enabled                 bool   `yaml:"enabled"`
+	PaymentDeadlineInterval string `yaml:"payment_deadline_interval"`
+	PendingPaymentsInterval string `yaml:"pending_payments_interval"`
+	BroadcastsInterval      string `yaml:"broadcasts_interval"`
+	RenewalsInterval        string `yaml:"renewals_interval"`
+	ReconcileInterval       string `yaml:"reconcile_interval"`
+	InvoicesInterval        string `yaml:"invoices_interval"`
+	DunningInterval         string `yaml:"dunning_interval"`
+	WaitlistInterval        string `yaml:"waitlist_interval"`
+	InvitationsInterval     string `yaml:"invitations_interval"`
+	ReputationInterval      string `yaml:"reputation_interval"`
+	GatewayRefundsInterval  string `yaml:"gateway_refunds_interval"`
+}
+
 var AppConfig *Config
 
 func LoadConfig() error {
@@ -261,12 +383,6 @@ func setDefaults(config *Config) {
 	config.Features.EnableNotifications = true
 
 	// Midtrans defaults
-	if config.Midtrans.ServerKey == "" {
-		config.Midtrans.ServerKey = "SB-Mid-server-i1dcC7yEZ88t9ojPby0wej3n"
-	}
-	if config.Midtrans.ClientKey == "" {
-		config.Midtrans.ClientKey = "SB-Mid-client-F4GiAgWMbYRqrHzr"
-	}
 	if config.Midtrans.BaseURL == "" {
 		config.Midtrans.BaseURL = "https://api.sandbox.midtrans.com"
 	}
@@ -296,6 +412,119 @@ func setDefaults(config *Config) {
 		config.Midtrans.Webhook.Timeout = "10s"
 	}
 	config.Midtrans.Webhook.Enabled = true
+
+	// Payment gateway defaults
+	if config.Payment.Gateway == "" {
+		config.Payment.Gateway = "midtrans"
+	}
+
+	// Renewal defaults
+	if config.Renewal.InvoiceDaysBefore == 0 {
+		config.Renewal.InvoiceDaysBefore = 3
+	}
+	if config.Renewal.PeriodMonths == 0 {
+		config.Renewal.PeriodMonths = 1
+	}
+
+	// Refund defaults
+	if config.Refund.ApprovalThreshold == 0 {
+		config.Refund.ApprovalThreshold = money.Whole(100000)
+	}
+
+	// Payout defaults
+	if config.Payout.MinimumAmount == 0 {
+		config.Payout.MinimumAmount = money.Whole(50000)
+	}
+
+	// Pricing defaults
+	if config.Pricing.DefaultFeePercentage == 0 {
+		config.Pricing.DefaultFeePercentage = 10
+	}
+	if config.Pricing.RoundTo == 0 {
+		config.Pricing.RoundTo = money.Whole(100)
+	}
+	if config.Pricing.Rounding == "" {
+		config.Pricing.Rounding = "up"
+	}
+
+	// Reconciliation defaults
+	if config.Reconciliation.LookbackDays == 0 {
+		config.Reconciliation.LookbackDays = 3
+	}
+	if config.Reconciliation.StaleAfterHours == 0 {
+		config.Reconciliation.StaleAfterHours = 24
+	}
+
+	// Invoice defaults
+	if config.Invoice.Prefix == "" {
+		config.Invoice.Prefix = "INV"
+	}
+	if config.Invoice.CompanyName == "" {
+		config.Invoice.CompanyName = "SALOME"
+	}
+
+	// Dunning defaults
+	if len(config.Dunning.Reminders) == 0 {
+		config.Dunning.Reminders = []string{"12h", "2h"}
+	}
+
+	// Waitlist defaults
+	if config.Waitlist.ClaimWindowHours == 0 {
+		config.Waitlist.ClaimWindowHours = 12
+	}
+
+	// Matchmaking defaults
+	if config.Matchmaking.PriceTolerancePercentage == 0 {
+		config.Matchmaking.PriceTolerancePercentage = 10
+	}
+
+	// Invitation defaults
+	if config.Invitations.ExpiryDays == 0 {
+		config.Invitations.ExpiryDays = 7
+	}
+	if config.Invitations.JoinURL == "" {
+		config.Invitations.JoinURL = "https://salome.cloudfren.id/invitations"
+	}
+
+	// Dispute defaults
+	if config.Disputes.WindowDays == 0 {
+		config.Disputes.WindowDays = 7
+	}
+
+	// Scheduler defaults
+	if config.Scheduler.PaymentDeadlineInterval == "" {
+		config.Scheduler.PaymentDeadlineInterval = "5m"
+	}
+	if config.Scheduler.PendingPaymentsInterval == "" {
+		config.Scheduler.PendingPaymentsInterval = "10m"
+	}
+	if config.Scheduler.BroadcastsInterval == "" {
+		config.Scheduler.BroadcastsInterval = "1m"
+	}
+	if config.Scheduler.RenewalsInterval == "" {
+		config.Scheduler.RenewalsInterval = "1h"
+	}
+	if config.Scheduler.ReconcileInterval == "" {
+		config.Scheduler.ReconcileInterval = "6h"
+	}
+	if config.Scheduler.InvoicesInterval == "" {
+		config.Scheduler.InvoicesInterval = "5m"
+	}
+	if config.Scheduler.DunningInterval == "" {
+		config.Scheduler.DunningInterval = "5m"
+	}
+	if config.Scheduler.WaitlistInterval == "" {
+		config.Scheduler.WaitlistInterval = "1m"
+	}
+	if config.Scheduler.InvitationsInterval == "" {
+		config.Scheduler.InvitationsInterval = "1h"
+	}
+	if config.Scheduler.ReputationInterval == "" {
+		config.Scheduler.ReputationInterval = "1h"
+	}
+	if config.Scheduler.GatewayRefundsInterval == "" {
+		config.Scheduler.GatewayRefundsInterval = "5m"
+	}
 }
 
 func GetConfig() *Config {
@@ -11,3 +11,10 @@ type Database interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	Begin() (*sql.Tx, error)
 }
+
+// Queryer is implemented by both *sql.DB and *sql.Tx so helpers can run inside or outside a transaction
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
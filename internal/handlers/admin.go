@@ -2,27 +2,50 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
 
 	// "strings"
 
+	"salome-be/internal/config"
+	"salome-be/internal/ledger"
 	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+	"salome-be/internal/scheduler"
+	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type AdminHandler struct {
-	db *sql.DB
+	db             *sql.DB
+	jobs           *scheduler.Scheduler
+	refunds        *services.RefundService
+	disputes       *services.DisputeService
+	reconciliation *services.ReconciliationService
+	stateMachine   *services.StateMachineService
+	pricing        *pricing.Engine
 }
 
-func NewAdminHandler(db *sql.DB) *AdminHandler {
-	return &AdminHandler{db: db}
+// NewAdminHandler returns the admin handler. jobs is the scheduler the server runs, so manual runs and job
+// listings see the same jobs.
+func NewAdminHandler(db *sql.DB, jobs *scheduler.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		db:             db,
+		jobs:           jobs,
+		refunds:        services.NewRefundService(db),
+		disputes:       services.NewDisputeService(db),
+		reconciliation: services.NewReconciliationService(db),
+		stateMachine:   services.NewStateMachineService(db),
+		pricing:        pricing.Default(),
+	}
 }
 
 // GetUsers - Get all users with pagination and filters
@@ -259,7 +282,7 @@ func (h *AdminHandler) GetGroups(c *gin.Context) {
 		var group models.Group
 		var ownerName, ownerEmail, appName, appIcon sql.NullString
 		var membersCount int
-		var totalRevenue float64
+		var totalRevenue money.Amount
 
 		err := rows.Scan(
 			&group.ID, &group.Name, &group.Description, &group.AppID, &group.OwnerID,
@@ -338,15 +361,15 @@ func (h *AdminHandler) GetGroups(c *gin.Context) {
 		WHERE g.is_deleted IS NULL OR g.is_deleted = false
 	`
 	var stats struct {
-		Total        int     `json:"total"`
-		Active       int     `json:"active"`
-		Pending      int     `json:"pending"`
-		Closed       int     `json:"closed"`
-		Full         int     `json:"full"`
-		GroupPaid    int     `json:"group_paid"`
-		Public       int     `json:"public"`
-		Private      int     `json:"private"`
-		TotalRevenue float64 `json:"total_revenue"`
+		Total        int          `json:"total"`
+		Active       int          `json:"active"`
+		Pending      int          `json:"pending"`
+		Closed       int          `json:"closed"`
+		Full         int          `json:"full"`
+		GroupPaid    int          `json:"group_paid"`
+		Public       int          `json:"public"`
+		Private      int          `json:"private"`
+		TotalRevenue money.Amount `json:"total_revenue"`
 	}
 
 	err = h.db.QueryRow(statsQuery).Scan(&stats.Total, &stats.Active, &stats.Pending, &stats.Closed, &stats.Full, &stats.GroupPaid, &stats.Public, &stats.Private, &stats.TotalRevenue)
@@ -392,14 +415,15 @@ func (h *AdminHandler) UpdateGroupStatus(c *gin.Context) {
 		return
 	}
 
-	// Update group status
-	_, err := h.db.Exec(`
-		UPDATE groups 
-		SET group_status = $1, updated_at = NOW() 
-		WHERE id = $2
-	`, req.NewStatus, req.GroupID)
-
+	// Update group status, recorded as an override when the state machine would not allow it
+	adminID, _ := c.Get("user_id")
+	err := h.stateMachine.AdminUpdateGroupStatus(adminID.(uuid.UUID).String(), req.GroupID, req.NewStatus, req.RemovedReason)
+	if errors.Is(err, services.ErrGroupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
 	if err != nil {
+		log.Printf("Error updating group status: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group status"})
 		return
 	}
@@ -423,6 +447,7 @@ func (h *AdminHandler) GetApps(c *gin.Context) {
 		SELECT 
 			a.id, a.name, a.description, a.icon_url, a.category, 
 			a.how_it_works, a.total_price, a.max_group_members, a.admin_fee_percentage,
+			a.admin_fee_fixed, a.admin_fee_min, a.admin_fee_max,
 			a.is_active, a.created_at, a.updated_at,
 			COALESCE(COUNT(DISTINCT g.id), 0) as groups_count,
 			COALESCE(SUM(g.total_price), 0) as total_revenue,
@@ -478,11 +503,12 @@ func (h *AdminHandler) GetApps(c *gin.Context) {
 	for rows.Next() {
 		var app models.App
 		var groupsCount int
-		var totalRevenue, avgPrice float64
+		var totalRevenue, avgPrice money.Amount
 
 		err := rows.Scan(
 			&app.ID, &app.Name, &app.Description, &app.IconURL, &app.Category,
 			&app.HowItWorks, &app.TotalPrice, &app.MaxGroupMembers, &app.AdminFeePercentage,
+			&app.AdminFeeFixed, &app.AdminFeeMin, &app.AdminFeeMax,
 			&app.IsActive, &app.CreatedAt, &app.UpdatedAt,
 			&groupsCount, &totalRevenue, &avgPrice,
 		)
@@ -492,7 +518,7 @@ func (h *AdminHandler) GetApps(c *gin.Context) {
 		}
 
 		// Debug logging
-		fmt.Printf("DEBUG: App %s - IsActive: %v, TotalPrice: %.2f, MaxGroupMembers: %d\n",
+		fmt.Printf("DEBUG: App %s - IsActive: %v, TotalPrice: %s, MaxGroupMembers: %d\n",
 			app.Name, app.IsActive, app.TotalPrice, app.MaxGroupMembers)
 
 		// Add additional fields for admin view
@@ -547,13 +573,13 @@ func (h *AdminHandler) GetApps(c *gin.Context) {
 		FROM apps a
 	`
 	var stats struct {
-		Total        int     `json:"total"`
-		Active       int     `json:"active"`
-		Inactive     int     `json:"inactive"`
-		Available    int     `json:"available"`
-		Unavailable  int     `json:"unavailable"`
-		TotalRevenue float64 `json:"total_revenue"`
-		AvgPrice     float64 `json:"avg_price"`
+		Total        int          `json:"total"`
+		Active       int          `json:"active"`
+		Inactive     int          `json:"inactive"`
+		Available    int          `json:"available"`
+		Unavailable  int          `json:"unavailable"`
+		TotalRevenue money.Amount `json:"total_revenue"`
+		AvgPrice     money.Amount `json:"avg_price"`
 	}
 
 	err = h.db.QueryRow(statsQuery).Scan(&stats.Total, &stats.Active, &stats.Inactive, &stats.Available, &stats.Unavailable, &stats.TotalRevenue, &stats.AvgPrice)
@@ -612,15 +638,18 @@ func (h *AdminHandler) UpdateAppStatus(c *gin.Context) {
 // CreateApp - Create a new app
 func (h *AdminHandler) CreateApp(c *gin.Context) {
 	var req struct {
-		Name               string  `json:"name" binding:"required"`
-		Description        string  `json:"description" binding:"required"`
-		Category           string  `json:"category" binding:"required"`
-		IconURL            string  `json:"icon_url"`
-		HowItWorks         string  `json:"how_it_works"`
-		TotalPrice         float64 `json:"total_price" binding:"required"`
-		MaxGroupMembers    int     `json:"max_group_members" binding:"required"`
-		AdminFeePercentage int     `json:"admin_fee_percentage" binding:"required"`
-		IsActive           *bool   `json:"is_active"`
+		Name               string        `json:"name" binding:"required"`
+		Description        string        `json:"description" binding:"required"`
+		Category           string        `json:"category" binding:"required"`
+		IconURL            string        `json:"icon_url"`
+		HowItWorks         string        `json:"how_it_works"`
+		TotalPrice         money.Amount  `json:"total_price" binding:"required"`
+		MaxGroupMembers    int           `json:"max_group_members" binding:"required"`
+		AdminFeePercentage int           `json:"admin_fee_percentage" binding:"required"`
+		AdminFeeFixed      *money.Amount `json:"admin_fee_fixed" binding:"omitempty,min=0"` // optional, defaults to pricing.fixed_fee
+		AdminFeeMin        *money.Amount `json:"admin_fee_min" binding:"omitempty,min=0"`   // optional, defaults to pricing.min_fee
+		AdminFeeMax        *money.Amount `json:"admin_fee_max" binding:"omitempty,min=0"`   // optional, defaults to pricing.max_fee
+		IsActive           *bool         `json:"is_active"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -639,28 +668,35 @@ func (h *AdminHandler) CreateApp(c *gin.Context) {
 
 	// Insert new app
 	query := `
-		INSERT INTO apps (id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
-		RETURNING id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage, is_active, created_at, updated_at
+		INSERT INTO apps (id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage,
+		                  admin_fee_fixed, admin_fee_min, admin_fee_max, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())
+		RETURNING id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage,
+		          admin_fee_fixed, admin_fee_min, admin_fee_max, is_active, created_at, updated_at
 	`
 
 	var app struct {
-		ID                 string    `json:"id"`
-		Name               string    `json:"name"`
-		Description        string    `json:"description"`
-		Category           string    `json:"category"`
-		IconURL            *string   `json:"icon_url"`
-		HowItWorks         *string   `json:"how_it_works"`
-		TotalPrice         float64   `json:"total_price"`
-		MaxGroupMembers    int       `json:"max_group_members"`
-		AdminFeePercentage int       `json:"admin_fee_percentage"`
-		IsActive           bool      `json:"is_active"`
-		CreatedAt          time.Time `json:"created_at"`
-		UpdatedAt          time.Time `json:"updated_at"`
-	}
-
-	err := h.db.QueryRow(query, appID, req.Name, req.Description, req.Category, req.IconURL, req.HowItWorks, req.TotalPrice, req.MaxGroupMembers, req.AdminFeePercentage, isActive).Scan(
-		&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.HowItWorks, &app.TotalPrice, &app.MaxGroupMembers, &app.AdminFeePercentage, &app.IsActive, &app.CreatedAt, &app.UpdatedAt,
+		ID                 string        `json:"id"`
+		Name               string        `json:"name"`
+		Description        string        `json:"description"`
+		Category           string        `json:"category"`
+		IconURL            *string       `json:"icon_url"`
+		HowItWorks         *string       `json:"how_it_works"`
+		TotalPrice         money.Amount  `json:"total_price"`
+		MaxGroupMembers    int           `json:"max_group_members"`
+		AdminFeePercentage int           `json:"admin_fee_percentage"`
+		AdminFeeFixed      *money.Amount `json:"admin_fee_fixed,omitempty"`
+		AdminFeeMin        *money.Amount `json:"admin_fee_min,omitempty"`
+		AdminFeeMax        *money.Amount `json:"admin_fee_max,omitempty"`
+		IsActive           bool          `json:"is_active"`
+		CreatedAt          time.Time     `json:"created_at"`
+		UpdatedAt          time.Time     `json:"updated_at"`
+	}
+
+	err := h.db.QueryRow(query, appID, req.Name, req.Description, req.Category, req.IconURL, req.HowItWorks, req.TotalPrice, req.MaxGroupMembers, req.AdminFeePercentage,
+		req.AdminFeeFixed, req.AdminFeeMin, req.AdminFeeMax, isActive).Scan(
+		&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.HowItWorks, &app.TotalPrice, &app.MaxGroupMembers, &app.AdminFeePercentage,
+		&app.AdminFeeFixed, &app.AdminFeeMin, &app.AdminFeeMax, &app.IsActive, &app.CreatedAt, &app.UpdatedAt,
 	)
 
 	if err != nil {
@@ -675,16 +711,19 @@ func (h *AdminHandler) CreateApp(c *gin.Context) {
 // UpdateApp - Update an existing app
 func (h *AdminHandler) UpdateApp(c *gin.Context) {
 	var req struct {
-		AppID              string  `json:"app_id" binding:"required"`
-		Name               string  `json:"name" binding:"required"`
-		Description        string  `json:"description" binding:"required"`
-		Category           string  `json:"category" binding:"required"`
-		IconURL            string  `json:"icon_url"`
-		HowItWorks         string  `json:"how_it_works"`
-		TotalPrice         float64 `json:"total_price" binding:"required"`
-		MaxGroupMembers    int     `json:"max_group_members" binding:"required"`
-		AdminFeePercentage int     `json:"admin_fee_percentage" binding:"required"`
-		IsActive           *bool   `json:"is_active"`
+		AppID              string        `json:"app_id" binding:"required"`
+		Name               string        `json:"name" binding:"required"`
+		Description        string        `json:"description" binding:"required"`
+		Category           string        `json:"category" binding:"required"`
+		IconURL            string        `json:"icon_url"`
+		HowItWorks         string        `json:"how_it_works"`
+		TotalPrice         money.Amount  `json:"total_price" binding:"required"`
+		MaxGroupMembers    int           `json:"max_group_members" binding:"required"`
+		AdminFeePercentage int           `json:"admin_fee_percentage" binding:"required"`
+		AdminFeeFixed      *money.Amount `json:"admin_fee_fixed" binding:"omitempty,min=0"` // optional, defaults to pricing.fixed_fee
+		AdminFeeMin        *money.Amount `json:"admin_fee_min" binding:"omitempty,min=0"`   // optional, defaults to pricing.min_fee
+		AdminFeeMax        *money.Amount `json:"admin_fee_max" binding:"omitempty,min=0"`   // optional, defaults to pricing.max_fee
+		IsActive           *bool         `json:"is_active"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -703,28 +742,35 @@ func (h *AdminHandler) UpdateApp(c *gin.Context) {
 		UPDATE apps 
 		SET name = $1, description = $2, category = $3, icon_url = $4, how_it_works = $5, 
 		    total_price = $6, max_group_members = $7, admin_fee_percentage = $8,
-		    is_active = $9, updated_at = NOW()
-		WHERE id = $10
-		RETURNING id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage, is_active, created_at, updated_at
+		    admin_fee_fixed = $9, admin_fee_min = $10, admin_fee_max = $11,
+		    is_active = $12, updated_at = NOW()
+		WHERE id = $13
+		RETURNING id, name, description, category, icon_url, how_it_works, total_price, max_group_members, admin_fee_percentage,
+		          admin_fee_fixed, admin_fee_min, admin_fee_max, is_active, created_at, updated_at
 	`
 
 	var app struct {
-		ID                 string    `json:"id"`
-		Name               string    `json:"name"`
-		Description        string    `json:"description"`
-		Category           string    `json:"category"`
-		IconURL            *string   `json:"icon_url"`
-		HowItWorks         *string   `json:"how_it_works"`
-		TotalPrice         float64   `json:"total_price"`
-		MaxGroupMembers    int       `json:"max_group_members"`
-		AdminFeePercentage int       `json:"admin_fee_percentage"`
-		IsActive           bool      `json:"is_active"`
-		CreatedAt          time.Time `json:"created_at"`
-		UpdatedAt          time.Time `json:"updated_at"`
-	}
-
-	err := h.db.QueryRow(query, req.Name, req.Description, req.Category, req.IconURL, req.HowItWorks, req.TotalPrice, req.MaxGroupMembers, req.AdminFeePercentage, isActive, req.AppID).Scan(
-		&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.HowItWorks, &app.TotalPrice, &app.MaxGroupMembers, &app.AdminFeePercentage, &app.IsActive, &app.CreatedAt, &app.UpdatedAt,
+		ID                 string        `json:"id"`
+		Name               string        `json:"name"`
+		Description        string        `json:"description"`
+		Category           string        `json:"category"`
+		IconURL            *string       `json:"icon_url"`
+		HowItWorks         *string       `json:"how_it_works"`
+		TotalPrice         money.Amount  `json:"total_price"`
+		MaxGroupMembers    int           `json:"max_group_members"`
+		AdminFeePercentage int           `json:"admin_fee_percentage"`
+		AdminFeeFixed      *money.Amount `json:"admin_fee_fixed,omitempty"`
+		AdminFeeMin        *money.Amount `json:"admin_fee_min,omitempty"`
+		AdminFeeMax        *money.Amount `json:"admin_fee_max,omitempty"`
+		IsActive           bool          `json:"is_active"`
+		CreatedAt          time.Time     `json:"created_at"`
+		UpdatedAt          time.Time     `json:"updated_at"`
+	}
+
+	err := h.db.QueryRow(query, req.Name, req.Description, req.Category, req.IconURL, req.HowItWorks, req.TotalPrice, req.MaxGroupMembers, req.AdminFeePercentage,
+		req.AdminFeeFixed, req.AdminFeeMin, req.AdminFeeMax, isActive, req.AppID).Scan(
+		&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.HowItWorks, &app.TotalPrice, &app.MaxGroupMembers, &app.AdminFeePercentage,
+		&app.AdminFeeFixed, &app.AdminFeeMin, &app.AdminFeeMax, &app.IsActive, &app.CreatedAt, &app.UpdatedAt,
 	)
 
 	if err != nil {
@@ -799,36 +845,52 @@ func (h *AdminHandler) CreateGroup(c *gin.Context) {
 	}
 
 	// Generate invite code
-	inviteCode := h.generateInviteCode()
+	inviteCode, err := services.NewInviteCode(h.db)
+	if err != nil {
+		log.Printf("Error generating invite code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
 
 	// Get app information to calculate pricing
 	var app struct {
-		ID              string  `json:"id"`
-		TotalPrice      float64 `json:"total_price"`
-		MaxGroupMembers int     `json:"max_group_members"`
+		ID              string       `json:"id"`
+		TotalPrice      money.Amount `json:"total_price"`
+		MaxGroupMembers int          `json:"max_group_members"`
 	}
 	appQuery := `
 		SELECT id, total_price, max_group_members
 		FROM apps WHERE id = $1 AND is_active = true
 	`
-	err := h.db.QueryRow(appQuery, req.AppID).Scan(&app.ID, &app.TotalPrice, &app.MaxGroupMembers)
+	err = h.db.QueryRow(appQuery, req.AppID).Scan(&app.ID, &app.TotalPrice, &app.MaxGroupMembers)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app ID or app not available"})
 		return
 	}
 
+	// Use app's max_group_members if not specified or exceeds app limit
+	if req.MaxMembers == 0 || req.MaxMembers > app.MaxGroupMembers {
+		req.MaxMembers = app.MaxGroupMembers
+	}
+
 	// Calculate pricing
-	pricePerMember := app.TotalPrice / float64(app.MaxGroupMembers)
-	adminFee := pricePerMember * 0.1 // 10% admin fee
-	totalPrice := pricePerMember + adminFee
+	price, err := services.AppSeatPrice(h.db, h.pricing, app.ID, req.MaxMembers)
+	if err != nil {
+		log.Printf("Error pricing group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate group price"})
+		return
+	}
+	pricePerMember := price.PricePerMember
+	adminFee := price.AdminFee
+	totalPrice := app.TotalPrice
 
 	// Insert new group
 	query := `
 		INSERT INTO groups (id, name, description, app_id, owner_id, invite_code, max_members, 
-		                   price_per_member, admin_fee, total_price, 
+		                   price_per_member, admin_fee, total_price, price_breakdown,
 		                   group_status, is_public, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())
 		RETURNING id, name, description, app_id, owner_id, invite_code, max_members, 
 		          price_per_member, admin_fee, total_price, 
 		          group_status, is_public, created_at, updated_at
@@ -836,25 +898,34 @@ func (h *AdminHandler) CreateGroup(c *gin.Context) {
 
 	groupID := uuid.New().String()
 	var group struct {
-		ID             string    `json:"id"`
-		Name           string    `json:"name"`
-		Description    *string   `json:"description"`
-		AppID          string    `json:"app_id"`
-		OwnerID        string    `json:"owner_id"`
-		InviteCode     string    `json:"invite_code"`
-		MaxMembers     int       `json:"max_members"`
-		CurrentMembers int       `json:"current_members"`
-		PricePerMember float64   `json:"price_per_member"`
-		AdminFee       float64   `json:"admin_fee"`
-		TotalPrice     float64   `json:"total_price"`
-		GroupStatus    string    `json:"group_status"`
-		IsPublic       bool      `json:"is_public"`
-		CreatedAt      time.Time `json:"created_at"`
-		UpdatedAt      time.Time `json:"updated_at"`
-	}
-
-	err = h.db.QueryRow(query, groupID, req.Name, req.Description, req.AppID, req.OwnerID,
-		inviteCode, req.MaxMembers, pricePerMember, adminFee, totalPrice, "open", isPublic).Scan(
+		ID             string             `json:"id"`
+		Name           string             `json:"name"`
+		Description    *string            `json:"description"`
+		AppID          string             `json:"app_id"`
+		OwnerID        string             `json:"owner_id"`
+		InviteCode     string             `json:"invite_code"`
+		MaxMembers     int                `json:"max_members"`
+		CurrentMembers int                `json:"current_members"`
+		PricePerMember money.Amount       `json:"price_per_member"`
+		AdminFee       money.Amount       `json:"admin_fee"`
+		TotalPrice     money.Amount       `json:"total_price"`
+		PriceBreakdown *pricing.Breakdown `json:"price_breakdown"`
+		GroupStatus    string             `json:"group_status"`
+		IsPublic       bool               `json:"is_public"`
+		CreatedAt      time.Time          `json:"created_at"`
+		UpdatedAt      time.Time          `json:"updated_at"`
+	}
+
+	// The group, its owner, its history and its invite link are created together
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(query, groupID, req.Name, req.Description, req.AppID, req.OwnerID,
+		inviteCode, req.MaxMembers, pricePerMember, adminFee, totalPrice, *price, "open", isPublic).Scan(
 		&group.ID, &group.Name, &group.Description, &group.AppID, &group.OwnerID,
 		&group.InviteCode, &group.MaxMembers, &group.PricePerMember,
 		&group.AdminFee, &group.TotalPrice, &group.GroupStatus,
@@ -863,6 +934,7 @@ func (h *AdminHandler) CreateGroup(c *gin.Context) {
 
 	// Set current_members to 0 for new group
 	group.CurrentMembers = 0
+	group.PriceBreakdown = price
 
 	if err != nil {
 		log.Printf("Error creating group: %v", err)
@@ -871,14 +943,23 @@ func (h *AdminHandler) CreateGroup(c *gin.Context) {
 	}
 
 	// Add owner as member
-	_, err = h.db.Exec(`
+	_, err = tx.Exec(`
 		INSERT INTO group_members (id, group_id, user_id, role, joined_at, user_status, payment_amount)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`, uuid.New().String(), groupID, req.OwnerID, "owner", time.Now(), "active", 0)
-
+	if err == nil {
+		err = services.RecordGroupCreated(tx, adminActor(c), groupID, group.GroupStatus, req.OwnerID, models.UserStatusActive)
+	}
+	if err == nil {
+		err = services.CreatePrimaryInvite(tx, groupID, inviteCode, req.OwnerID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		log.Printf("Error adding owner to group: %v", err)
-		// Don't fail the group creation, just log the error
+		log.Printf("Error creating group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"data": group})
@@ -907,42 +988,66 @@ func (h *AdminHandler) UpdateGroup(c *gin.Context) {
 		isPublic = *req.IsPublic
 	}
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// A status change goes through the state machine so it is recorded in the group's history
+	if req.GroupStatus != "" {
+		err = h.stateMachine.AdminSetGroupStatusTx(tx, adminActor(c), req.GroupID, req.GroupStatus, "Group updated by admin")
+		if errors.Is(err, services.ErrGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error updating group status: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+			return
+		}
+	}
+
 	// Update group
 	query := `
 		UPDATE groups 
 		SET name = $1, description = $2, app_id = $3, max_members = $4, 
-		    is_public = $5, group_status = $6, updated_at = NOW()
-		WHERE id = $7
+		    is_public = $5, updated_at = NOW()
+		WHERE id = $6
 		RETURNING id, name, description, app_id, owner_id, invite_code, max_members, 
 		          price_per_member, admin_fee, total_price, group_status, 
 		          is_public, created_at, updated_at
 	`
 
 	var group struct {
-		ID             string    `json:"id"`
-		Name           string    `json:"name"`
-		Description    *string   `json:"description"`
-		AppID          string    `json:"app_id"`
-		OwnerID        string    `json:"owner_id"`
-		InviteCode     string    `json:"invite_code"`
-		MaxMembers     int       `json:"max_members"`
-		CurrentMembers int       `json:"current_members"`
-		PricePerMember float64   `json:"price_per_member"`
-		AdminFee       float64   `json:"admin_fee"`
-		TotalPrice     float64   `json:"total_price"`
-		GroupStatus    string    `json:"group_status"`
-		IsPublic       bool      `json:"is_public"`
-		CreatedAt      time.Time `json:"created_at"`
-		UpdatedAt      time.Time `json:"updated_at"`
-	}
-
-	err := h.db.QueryRow(query, req.Name, req.Description, req.AppID, req.MaxMembers,
-		isPublic, req.GroupStatus, req.GroupID).Scan(
+		ID             string       `json:"id"`
+		Name           string       `json:"name"`
+		Description    *string      `json:"description"`
+		AppID          string       `json:"app_id"`
+		OwnerID        string       `json:"owner_id"`
+		InviteCode     string       `json:"invite_code"`
+		MaxMembers     int          `json:"max_members"`
+		CurrentMembers int          `json:"current_members"`
+		PricePerMember money.Amount `json:"price_per_member"`
+		AdminFee       money.Amount `json:"admin_fee"`
+		TotalPrice     money.Amount `json:"total_price"`
+		GroupStatus    string       `json:"group_status"`
+		IsPublic       bool         `json:"is_public"`
+		CreatedAt      time.Time    `json:"created_at"`
+		UpdatedAt      time.Time    `json:"updated_at"`
+	}
+
+	err = tx.QueryRow(query, req.Name, req.Description, req.AppID, req.MaxMembers,
+		isPublic, req.GroupID).Scan(
 		&group.ID, &group.Name, &group.Description, &group.AppID, &group.OwnerID,
 		&group.InviteCode, &group.MaxMembers, &group.PricePerMember,
 		&group.AdminFee, &group.TotalPrice, &group.GroupStatus,
 		&group.IsPublic, &group.CreatedAt, &group.UpdatedAt,
 	)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	// Get current_members from real-time count
 	var currentMemberCount int
@@ -1002,13 +1107,19 @@ func (h *AdminHandler) DeleteGroup(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	actor := adminActor(c)
+	if err := h.stateMachine.AdminSetGroupStatusTx(tx, actor, req.GroupID, models.GroupStatusClosed, "Group deleted by admin"); err != nil {
+		log.Printf("Error closing group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+
 	// Soft delete: Mark group as deleted instead of hard delete
 	// This preserves transaction history and allows for data recovery
 	_, err = tx.Exec(`
 		UPDATE groups 
 		SET deleted_at = NOW(), 
 		    is_deleted = true,
-		    group_status = 'closed',
 		    updated_at = NOW()
 		WHERE id = $1
 	`, req.GroupID)
@@ -1018,6 +1129,12 @@ func (h *AdminHandler) DeleteGroup(c *gin.Context) {
 		return
 	}
 
+	if err := services.RecordMembersRemoved(tx, actor, req.GroupID, "Group deleted by admin"); err != nil {
+		log.Printf("Error recording member removal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group members"})
+		return
+	}
+
 	// Remove all members from the group (but keep transaction history)
 	_, err = tx.Exec("DELETE FROM group_members WHERE group_id = $1", req.GroupID)
 	if err != nil {
@@ -1210,9 +1327,10 @@ func (h *AdminHandler) ChangeGroupOwner(c *gin.Context) {
 // RemoveGroupMember - Remove a member from group (admin only)
 func (h *AdminHandler) RemoveGroupMember(c *gin.Context) {
 	var req struct {
-		GroupID string `json:"group_id" binding:"required"`
-		UserID  string `json:"user_id" binding:"required"`
-		Reason  string `json:"reason"`
+		GroupID    string `json:"group_id" binding:"required"`
+		UserID     string `json:"user_id" binding:"required"`
+		Reason     string `json:"reason"`
+		SkipRefund bool   `json:"skip_refund"` // keep what the member paid for the current period
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1274,21 +1392,15 @@ func (h *AdminHandler) RemoveGroupMember(c *gin.Context) {
 		return
 	}
 
-	// Delete member from group completely
-	_, err = h.db.Exec(`
-		DELETE FROM group_members 
-		WHERE group_id = $1 AND user_id = $2
-	`, req.GroupID, req.UserID)
-
+	// Delete member from group completely, refunding the unused part of their payment
+	refund, err := h.refunds.RemoveMember(adminActor(c), req.UserID, req.GroupID, models.RefundReasonAdminRemove, !req.SkipRefund)
 	if err != nil {
 		log.Printf("Error removing member: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
 		return
 	}
 
-	// Group status will be updated automatically by CheckAndUpdateGroupStatus
-
-	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully", "refund": refund})
 }
 
 // AddGroupMember - Add a member to group (admin only)
@@ -1393,7 +1505,19 @@ func (h *AdminHandler) AddGroupMember(c *gin.Context) {
 		return
 	}
 
-	// Group status will be updated automatically by CheckAndUpdateGroupStatus
+	actor := adminActor(c)
+	if err = services.RecordMemberAdded(tx, actor, req.GroupID, req.UserID, models.UserStatusActive); err != nil {
+		log.Printf("Error recording member addition: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member to group"})
+		return
+	}
+
+	// An admin can add members beyond capacity; the group still becomes full once its seats are taken
+	if err = h.stateMachine.SyncGroupCapacity(tx, actor, req.GroupID); err != nil {
+		log.Printf("Error updating group status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group status"})
+		return
+	}
 
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
@@ -1404,14 +1528,84 @@ func (h *AdminHandler) AddGroupMember(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Member added successfully"})
 }
 
-// generateInviteCode - Generate a random invite code
-func (h *AdminHandler) generateInviteCode() string {
-	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	code := make([]byte, 8)
-	for i := range code {
-		code[i] = charset[rand.Intn(len(charset))]
+// adminActor is the admin making the request, as recorded in the status event history
+func adminActor(c *gin.Context) services.Actor {
+	adminID, _ := c.Get("user_id")
+	id, _ := adminID.(uuid.UUID)
+	return services.AdminActor(id.String()).WithCorrelation(services.NewCorrelationID())
+}
+
+// CheckLedger - Verify that the wallet ledger balances and users.balance matches it
+func (h *AdminHandler) CheckLedger(c *gin.Context) {
+	report, err := ledger.New(h.db).Check()
+	if err != nil {
+		log.Printf("Ledger check failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetJobs - List background jobs with their interval and latest run
+func (h *AdminHandler) GetJobs(c *gin.Context) {
+	jobs, err := h.jobs.Jobs()
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJobRuns - List background job runs, filtered by ?job= and ?status=
+func (h *AdminHandler) GetJobRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	runs, total, err := h.jobs.ListRuns(c.Query("job"), c.Query("status"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed to list job runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":      runs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RunJob - Run a background job now
+func (h *AdminHandler) RunJob(c *gin.Context) {
+	run, err := h.jobs.RunNow(c.Param("name"))
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+		return
+	case err != nil && run == nil:
+		log.Printf("Failed to run job %s: %v", c.Param("name"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run job"})
+		return
 	}
-	return string(code)
+
+	// A failed run is still recorded, return it with its error
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Job finished",
+		"run":     run,
+	})
 }
 
 // Helper function to check if slice contains string
@@ -1423,3 +1617,189 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// GetRefunds - List refunds, e.g. ?status=pending_approval for the approval queue
+func (h *AdminHandler) GetRefunds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	refunds, total, err := h.refunds.ListRefunds(c.Query("status"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed to list refunds: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds":   refunds,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ApproveRefund - Execute a refund that is waiting for approval
+func (h *AdminHandler) ApproveRefund(c *gin.Context) {
+	h.reviewRefund(c, true)
+}
+
+// RejectRefund - Decline a refund that is waiting for approval
+func (h *AdminHandler) RejectRefund(c *gin.Context) {
+	h.reviewRefund(c, false)
+}
+
+func (h *AdminHandler) reviewRefund(c *gin.Context, approve bool) {
+	var req models.RefundReviewRequest
+	// The note is optional, an empty body is fine
+	_ = c.ShouldBindJSON(&req)
+
+	adminID, _ := c.Get("user_id")
+	review := h.refunds.Reject
+	if approve {
+		review = h.refunds.Approve
+	}
+
+	refund, err := review(c.Param("id"), adminID.(uuid.UUID).String(), req.Note)
+	switch {
+	case errors.Is(err, services.ErrRefundNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	case errors.Is(err, services.ErrRefundNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Refund is not waiting for approval"})
+		return
+	case errors.Is(err, services.ErrRefundNoEarnings):
+		c.JSON(http.StatusConflict, gin.H{"error": "The group's earnings no longer cover this refund"})
+		return
+	case err != nil:
+		log.Printf("Failed to review refund %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review refund"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"refund": refund})
+}
+
+// RunReconciliation - Compare gateway transactions in a date range with the payment provider
+func (h *AdminHandler) RunReconciliation(c *gin.Context) {
+	var req models.ReconciliationRunRequest
+	// All fields are optional, an empty body reconciles the default window
+	_ = c.ShouldBindJSON(&req)
+
+	cfg := config.GetConfig().Reconciliation
+	now := time.Now()
+	opts := services.ReconcileOptions{
+		From:    now.AddDate(0, 0, -cfg.LookbackDays),
+		To:      now,
+		AutoFix: true,
+	}
+	if req.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", req.From, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+			return
+		}
+		opts.From = from
+	}
+	if req.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", req.To, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+			return
+		}
+		opts.To = to.AddDate(0, 0, 1)
+	}
+	if !opts.From.Before(opts.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if req.AutoFix != nil {
+		opts.AutoFix = *req.AutoFix
+	}
+	if adminID, ok := c.Get("user_id"); ok {
+		opts.TriggeredBy = adminID.(uuid.UUID).String()
+	}
+
+	report, err := h.reconciliation.Run(opts)
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run reconciliation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// GetReconciliationReports - List reconciliation reports, newest first
+func (h *AdminHandler) GetReconciliationReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	reports, total, err := h.reconciliation.ListReports(pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed to list reconciliation reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports":   reports,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetReconciliationReport - Get a reconciliation report with its discrepancies, as JSON or ?format=csv
+func (h *AdminHandler) GetReconciliationReport(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	report, err := h.reconciliation.GetReport(c.Param("id"))
+	if errors.Is(err, services.ErrReconciliationReportNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation report not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get reconciliation report %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation report"})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"report": report})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=reconciliation-%s.csv", report.ID))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"transaction_id", "payment_reference", "transaction_type", "kind", "local_status", "provider_status",
+		"local_amount", "provider_amount", "local_method", "provider_method", "fixed", "action"})
+	for _, item := range report.Items {
+		transactionID, providerAmount := "", ""
+		if item.TransactionID != nil {
+			transactionID = *item.TransactionID
+		}
+		if item.ProviderAmount != nil {
+			providerAmount = item.ProviderAmount.String()
+		}
+		w.Write([]string{transactionID, item.PaymentReference, item.TransactionType, item.Kind, item.LocalStatus,
+			item.ProviderStatus, item.LocalAmount.String(), providerAmount, item.LocalMethod,
+			item.ProviderMethod, strconv.FormatBool(item.Fixed), item.Action})
+	}
+	w.Flush()
+}
@@ -7,16 +7,18 @@ import (
 	"strconv"
 
 	"salome-be/internal/models"
+	"salome-be/internal/pricing"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AppHandler struct {
-	db *sql.DB
+	db      *sql.DB
+	pricing *pricing.Engine
 }
 
 func NewAppHandler(db *sql.DB) *AppHandler {
-	return &AppHandler{db: db}
+	return &AppHandler{db: db, pricing: pricing.Default()}
 }
 
 func (h *AppHandler) GetApps(c *gin.Context) {
@@ -80,7 +82,8 @@ func (h *AppHandler) GetApps(c *gin.Context) {
 	}
 
 	appsQuery := `
-		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active, how_it_works
+		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active, how_it_works,
+		       admin_fee_percentage, admin_fee_fixed, admin_fee_min, admin_fee_max
 		FROM apps 
 		` + whereClause + `
 		` + orderBy + `
@@ -102,18 +105,16 @@ func (h *AppHandler) GetApps(c *gin.Context) {
 	var apps []models.AppResponse
 	for rows.Next() {
 		var app models.AppResponse
-		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.WebsiteURL, &app.MaxGroupMembers, &app.TotalPrice, &app.IsPopular, &app.IsActive, &app.HowItWorks)
+		var fees pricing.AppFees
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.WebsiteURL, &app.MaxGroupMembers, &app.TotalPrice, &app.IsPopular, &app.IsActive, &app.HowItWorks,
+			&fees.Percentage, &fees.Fixed, &fees.Min, &fees.Max)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan app"})
 			return
 		}
 
-		// Calculate price per user: (total_price / max_group_members) + 3500 (admin fee)
-		if app.MaxGroupMembers > 0 {
-			app.PricePerUser = app.TotalPrice/float64(app.MaxGroupMembers) + 3500
-		} else {
-			app.PricePerUser = app.TotalPrice + 3500
-		}
+		// Price per user: seat price plus admin fee
+		app.PricePerUser = h.pricing.Seat(app.TotalPrice, app.MaxGroupMembers, fees).Total
 
 		apps = append(apps, app)
 	}
@@ -167,7 +168,8 @@ func (h *AppHandler) GetPopularApps(c *gin.Context) {
 	}
 
 	rows, err := h.db.Query(`
-		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active, how_it_works
+		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active, how_it_works,
+		       admin_fee_percentage, admin_fee_fixed, admin_fee_min, admin_fee_max
 		FROM apps 
 		WHERE is_popular = true
 		ORDER BY name ASC
@@ -182,18 +184,16 @@ func (h *AppHandler) GetPopularApps(c *gin.Context) {
 	var apps []models.AppResponse
 	for rows.Next() {
 		var app models.AppResponse
-		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.WebsiteURL, &app.MaxGroupMembers, &app.TotalPrice, &app.IsPopular, &app.IsActive, &app.HowItWorks)
+		var fees pricing.AppFees
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.WebsiteURL, &app.MaxGroupMembers, &app.TotalPrice, &app.IsPopular, &app.IsActive, &app.HowItWorks,
+			&fees.Percentage, &fees.Fixed, &fees.Min, &fees.Max)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan app"})
 			return
 		}
 
-		// Calculate price per user: (total_price / max_group_members) + 3500 (admin fee)
-		if app.MaxGroupMembers > 0 {
-			app.PricePerUser = app.TotalPrice/float64(app.MaxGroupMembers) + 3500
-		} else {
-			app.PricePerUser = app.TotalPrice + 3500
-		}
+		// Price per user: seat price plus admin fee
+		app.PricePerUser = h.pricing.Seat(app.TotalPrice, app.MaxGroupMembers, fees).Total
 
 		apps = append(apps, app)
 	}
@@ -209,14 +209,16 @@ func (h *AppHandler) GetAppByID(c *gin.Context) {
 	}
 
 	var app models.App
+	var fees pricing.AppFees
 	err := h.db.QueryRow(`
-		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active, admin_fee_percentage, how_it_works
+		SELECT id, name, description, category, icon_url, website_url, max_group_members, total_price, is_popular, is_active,
+		       COALESCE(admin_fee_percentage, 0), how_it_works, admin_fee_percentage, admin_fee_fixed, admin_fee_min, admin_fee_max
 		FROM apps 
 		WHERE id = $1 AND is_active = true
 	`, appID).Scan(
 		&app.ID, &app.Name, &app.Description, &app.Category, &app.IconURL, &app.WebsiteURL,
 		&app.MaxGroupMembers, &app.TotalPrice, &app.IsPopular, &app.IsActive,
-		&app.AdminFeePercentage, &app.HowItWorks,
+		&app.AdminFeePercentage, &app.HowItWorks, &fees.Percentage, &fees.Fixed, &fees.Min, &fees.Max,
 	)
 
 	if err != nil {
@@ -228,17 +230,13 @@ func (h *AppHandler) GetAppByID(c *gin.Context) {
 		return
 	}
 
-	// Calculate pricing details
-	var pricePerUser float64
-	if app.MaxGroupMembers > 0 {
-		pricePerUser = app.TotalPrice/float64(app.MaxGroupMembers) + 3500
-	} else {
-		pricePerUser = app.TotalPrice + 3500
-	}
+	// Calculate pricing details for a full group
+	price := h.pricing.Seat(app.TotalPrice, app.MaxGroupMembers, fees)
 
 	response := models.AppDetailResponse{
-		App:          app,
-		PricePerUser: pricePerUser,
+		App:            app,
+		PricePerUser:   price.Total,
+		PriceBreakdown: price,
 	}
 
 	c.JSON(http.StatusOK, response)
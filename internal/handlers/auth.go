@@ -10,7 +10,9 @@ import (
 
 	"salome-be/internal/config"
 	"salome-be/internal/models"
+	"salome-be/internal/money"
 	"salome-be/internal/service"
+	"salome-be/internal/services"
 	"salome-be/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +23,7 @@ import (
 type AuthHandler struct {
 	db           *sql.DB
 	emailService *service.MultiProviderEmailService
+	reputation   *services.ReputationService
 }
 
 func NewAuthHandler(db *sql.DB) *AuthHandler {
@@ -56,6 +59,7 @@ func NewAuthHandler(db *sql.DB) *AuthHandler {
 	return &AuthHandler{
 		db:           db,
 		emailService: emailService,
+		reputation:   services.NewReputationService(db),
 	}
 }
 
@@ -311,6 +315,9 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		IsAdmin:        user.IsAdmin,
 		CreatedAt:      user.CreatedAt,
 	}
+	if userResponse.Reputation, err = h.reputation.Get(user.ID.String()); err != nil {
+		fmt.Printf("Warning: Failed to get reputation of user %s: %v\n", user.ID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"user": userResponse})
 }
@@ -405,7 +412,7 @@ func (h *AuthHandler) GetUserStats(c *gin.Context) {
 	}
 
 	// Get user's total savings (simplified calculation)
-	var totalSavings float64
+	var totalSavings money.Amount
 	err = h.db.QueryRow(`
 		SELECT COALESCE(SUM(g.price_per_member), 0) FROM group_members gm
 		JOIN groups g ON gm.group_id = g.id
@@ -417,7 +424,7 @@ func (h *AuthHandler) GetUserStats(c *gin.Context) {
 	}
 
 	// Get user's balance
-	var balance float64
+	var balance money.Amount
 	err = h.db.QueryRow(`
 		SELECT COALESCE(balance, 0) FROM users WHERE id = $1
 	`, userID.(uuid.UUID)).Scan(&balance)
@@ -587,7 +594,7 @@ func (h *AuthHandler) VerifyEmailChange(c *gin.Context) {
 	})
 }
 
-// RecalculateTotalSpent - Recalculate total_spent for all users based on successful transactions
+// RecalculateTotalSpent - Recalculate total_spent for all users from settled group payments minus refunds
 func (h *AuthHandler) RecalculateTotalSpent(c *gin.Context) {
 	// Check if user is admin
 	userID, exists := c.Get("user_id")
@@ -607,15 +614,16 @@ func (h *AuthHandler) RecalculateTotalSpent(c *gin.Context) {
 	// Update total_spent for all users
 	_, err = h.db.Exec(`
 		UPDATE users 
-		SET total_spent = COALESCE(
+		SET total_spent = GREATEST(COALESCE(
 			(
-				SELECT SUM(amount) 
+				SELECT SUM(CASE WHEN type = 'refund' THEN -amount ELSE amount END)
 				FROM transactions 
 				WHERE transactions.user_id = users.id 
-				AND transactions.status = 'success'
+				AND transactions.type IN ('group_payment', 'refund')
+				AND transactions.status IN ('success', 'completed')
 			), 
 			0
-		),
+		), 0),
 		updated_at = NOW()
 	`)
 
@@ -648,15 +656,16 @@ func (h *AuthHandler) RecalculateUserTotalSpent(c *gin.Context) {
 	// Update total_spent for the current user
 	_, err := h.db.Exec(`
 		UPDATE users 
-		SET total_spent = COALESCE(
+		SET total_spent = GREATEST(COALESCE(
 			(
-				SELECT SUM(amount) 
+				SELECT SUM(CASE WHEN type = 'refund' THEN -amount ELSE amount END)
 				FROM transactions 
 				WHERE transactions.user_id = users.id 
-				AND transactions.status = 'success'
+				AND transactions.type IN ('group_payment', 'refund')
+				AND transactions.status IN ('success', 'completed')
 			), 
 			0
-		),
+		), 0),
 		updated_at = NOW()
 		WHERE id = $1
 	`, userID.(uuid.UUID))
@@ -667,7 +676,7 @@ func (h *AuthHandler) RecalculateUserTotalSpent(c *gin.Context) {
 	}
 
 	// Get updated total_spent
-	var newTotalSpent float64
+	var newTotalSpent money.Amount
 	err = h.db.QueryRow("SELECT total_spent FROM users WHERE id = $1", userID.(uuid.UUID)).Scan(&newTotalSpent)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated total_spent"})
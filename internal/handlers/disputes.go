@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OpenGroupDispute reports that the shared subscription did not work for the current user's billing period.
+// The owner's earnings for the period are held until the dispute closes.
+func (h *GroupHandler) OpenGroupDispute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.DisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.disputes.Open(userID.(uuid.UUID).String(), groupID, req)
+	if err != nil {
+		h.respondDisputeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Dispute opened",
+		"data":    dispute,
+	})
+}
+
+// GetGroupDisputes lists the disputes of the group for the owner, or the current user's own disputes
+func (h *GroupHandler) GetGroupDisputes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	disputes, err := h.disputes.ListForGroup(userID.(uuid.UUID).String(), groupID)
+	if err != nil {
+		h.respondDisputeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    disputes,
+	})
+}
+
+// GetGroupDispute shows a dispute to the member who opened it and the group owner
+func (h *GroupHandler) GetGroupDispute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dispute, err := h.disputes.Get(userID.(uuid.UUID).String(), c.Param("dispute_id"))
+	if err == nil && dispute.GroupID != c.Param("id") {
+		err = services.ErrDisputeNotFound
+	}
+	if err != nil {
+		h.respondDisputeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    dispute,
+	})
+}
+
+// RespondToDispute records the owner's answer to a dispute (owner only)
+func (h *GroupHandler) RespondToDispute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DisputeResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.disputeInGroup(c)
+	if err == nil {
+		dispute, err = h.disputes.Respond(userID.(uuid.UUID).String(), dispute.ID, req.Response)
+	}
+	if err != nil {
+		h.respondDisputeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Response saved",
+		"data":    dispute,
+	})
+}
+
+// WithdrawDispute closes a dispute at the request of the member who opened it
+func (h *GroupHandler) WithdrawDispute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dispute, err := h.disputeInGroup(c)
+	if err == nil {
+		dispute, err = h.disputes.Withdraw(userID.(uuid.UUID).String(), dispute.ID)
+	}
+	if err != nil {
+		h.respondDisputeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dispute withdrawn",
+		"data":    dispute,
+	})
+}
+
+// disputeInGroup loads the dispute of the :dispute_id param, making sure it belongs to the :id group
+func (h *GroupHandler) disputeInGroup(c *gin.Context) (*models.GroupDispute, error) {
+	dispute, err := h.disputes.Get("", c.Param("dispute_id"))
+	if err != nil {
+		return nil, err
+	}
+	if dispute.GroupID != c.Param("id") {
+		return nil, services.ErrDisputeNotFound
+	}
+	return dispute, nil
+}
+
+func (h *GroupHandler) respondDisputeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrDisputeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDisputeNotMember), errors.Is(err, services.ErrDisputeOwnerOnly),
+		errors.Is(err, services.ErrDisputeMemberOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDisputeExists), errors.Is(err, services.ErrDisputeClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDisputeNoPeriod), errors.Is(err, services.ErrDisputeWindowClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error handling dispute: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process dispute"})
+	}
+}
+
+// GetDisputes - List disputes, e.g. ?status=responded for the ones waiting for a decision
+func (h *AdminHandler) GetDisputes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	disputes, total, err := h.disputes.ListDisputes(c.Query("status"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed to list disputes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disputes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes":  disputes,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetDispute - Show a dispute with the member's evidence and the owner's response
+func (h *AdminHandler) GetDispute(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID"})
+		return
+	}
+
+	dispute, err := h.disputes.Get("", c.Param("id"))
+	if errors.Is(err, services.ErrDisputeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get dispute %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispute"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dispute": dispute})
+}
+
+// ResolveDispute - Close a dispute with a refund, the removal of the member, the suspension of the owner
+// or by dismissing it. The held earnings are released either way.
+func (h *AdminHandler) ResolveDispute(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID"})
+		return
+	}
+
+	var req models.DisputeResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.disputes.Resolve(adminActor(c), c.Param("id"), req)
+	switch {
+	case errors.Is(err, services.ErrDisputeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	case errors.Is(err, services.ErrDisputeClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Dispute is already closed"})
+		return
+	case err != nil:
+		log.Printf("Failed to resolve dispute %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dispute": dispute})
+}
@@ -2,14 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
 
 	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
 	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -19,12 +21,32 @@ import (
 type GroupHandler struct {
 	db              *sql.DB
 	stateMachineSvc *services.StateMachineService
+	refundSvc       *services.RefundService
+	seats           *services.SeatService
+	waitlist        *services.WaitlistService
+	matchmaking     *services.MatchmakingService
+	lifecycle       *services.GroupLifecycleService
+	invites         *services.InviteService
+	invitations     *services.InvitationService
+	reputation      *services.ReputationService
+	disputes        *services.DisputeService
+	pricing         *pricing.Engine
 }
 
 func NewGroupHandler(db *sql.DB) *GroupHandler {
 	return &GroupHandler{
 		db:              db,
 		stateMachineSvc: services.NewStateMachineService(db),
+		refundSvc:       services.NewRefundService(db),
+		seats:           services.NewSeatService(db),
+		waitlist:        services.NewWaitlistService(db),
+		matchmaking:     services.NewMatchmakingService(db),
+		lifecycle:       services.NewGroupLifecycleService(db),
+		invites:         services.NewInviteService(db),
+		invitations:     services.NewInvitationService(db),
+		reputation:      services.NewReputationService(db),
+		disputes:        services.NewDisputeService(db),
+		pricing:         pricing.Default(),
 	}
 }
 
@@ -95,22 +117,41 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 	}
 
 	// Calculate pricing
-	pricePerMember := float64(app.TotalPrice) / float64(req.MaxMembers)
-	adminFee := 3500.0 // Fixed admin fee
-	totalPrice := float64(app.TotalPrice)
+	price, err := services.AppSeatPrice(h.db, h.pricing, app.ID, req.MaxMembers)
+	if err != nil {
+		fmt.Printf("Error pricing group: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate group price"})
+		return
+	}
+	pricePerMember := price.PricePerMember
+	adminFee := price.AdminFee
+	totalPrice := app.TotalPrice
 
 	// Generate unique invite code
-	inviteCode := h.generateInviteCode()
+	inviteCode, err := services.NewInviteCode(h.db)
+	if err != nil {
+		fmt.Printf("Error generating invite code: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
+
+	// The group, its owner, its history and its invite link are created together
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
 
 	groupID := uuid.New()
-	_, err = h.db.Exec(`
+	_, err = tx.Exec(`
 		INSERT INTO groups (
 			id, name, description, app_id, owner_id, invite_code, max_members, 
-			price_per_member, admin_fee, total_price, group_status, is_public, is_deleted, created_at, updated_at
+			price_per_member, admin_fee, total_price, price_breakdown, group_status, is_public, is_deleted, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`, groupID, req.Name, req.Description, req.AppID, userID.(uuid.UUID), inviteCode, req.MaxMembers,
-		pricePerMember, adminFee, totalPrice, "open", req.IsPublic, false, time.Now(), time.Now())
+		pricePerMember, adminFee, totalPrice, *price, "open", req.IsPublic, false, time.Now(), time.Now())
 
 	if err != nil {
 		fmt.Printf("Error creating group: %v\n", err)
@@ -119,7 +160,7 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 	}
 
 	// Add owner as member
-	_, err = h.db.Exec(`
+	_, err = tx.Exec(`
 		INSERT INTO group_members (id, group_id, user_id, role, joined_at, user_status, payment_amount)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`, uuid.New().String(), groupID, userID.(uuid.UUID), "owner", time.Now(), "active", 0)
@@ -130,6 +171,20 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 		return
 	}
 
+	ownerID := userID.(uuid.UUID).String()
+	err = services.RecordGroupCreated(tx, services.UserActor(ownerID), groupID.String(), models.GroupStatusOpen, ownerID, models.UserStatusActive)
+	if err == nil {
+		err = services.CreatePrimaryInvite(tx, groupID.String(), inviteCode, ownerID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		fmt.Printf("Error creating group: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
+
 	now := time.Now()
 	// Get actual member count
 	var actualMemberCount int
@@ -155,6 +210,7 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 		PricePerMember: pricePerMember,
 		AdminFee:       adminFee,
 		TotalPrice:     totalPrice,
+		PriceBreakdown: price,
 		GroupStatus:    "open",
 		IsPublic:       req.IsPublic,
 		CreatedAt:      now,
@@ -245,83 +301,47 @@ func (h *GroupHandler) JoinGroup(c *gin.Context) {
 		return
 	}
 
-	// Find group by invite code
-	var group models.Group
-	err := h.db.QueryRow(`
-		SELECT id, name, description, owner_id, invite_code, max_members, created_at, updated_at
-		FROM groups WHERE invite_code = $1 AND (is_deleted IS NULL OR is_deleted = false)
-	`, req.InviteCode).Scan(&group.ID, &group.Name, &group.Description, &group.OwnerID, &group.InviteCode, &group.MaxMembers, &group.CreatedAt, &group.UpdatedAt)
-
-	if err != nil {
+	// Hold a pending seat through the invite link; the member becomes paid once they pay for it
+	invite, reservation, err := h.invites.Join(userID.(uuid.UUID).String(), req.InviteCode)
+	switch {
+	case errors.Is(err, services.ErrInviteNotFound), errors.Is(err, services.ErrGroupNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid invite code"})
 		return
-	}
-
-	// Check if group is full
-	var currentMemberCount int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM group_members 
-		WHERE group_id = $1 AND user_status IN ('active', 'paid')
-	`, group.ID).Scan(&currentMemberCount)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group capacity"})
+	case errors.Is(err, services.ErrInviteExpired), errors.Is(err, services.ErrInviteRevoked),
+		errors.Is(err, services.ErrInviteExhausted):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
 		return
-	}
-
-	if currentMemberCount >= group.MaxMembers {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is full"})
+	case errors.Is(err, services.ErrInviteWrongEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
-	}
-
-	// Check if user is already a member
-	var existingMember models.GroupMember
-	err = h.db.QueryRow(`
-		SELECT id FROM group_members WHERE group_id = $1 AND user_id = $2
-	`, group.ID, userID.(uuid.UUID)).Scan(&existingMember.ID)
-
-	if err == nil {
+	case errors.Is(err, services.ErrAlreadyMember):
 		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this group"})
 		return
-	}
-
-	// Check if group is full
-	var memberCount int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM group_members WHERE group_id = $1
-	`, group.ID).Scan(&memberCount)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group capacity"})
+	case errors.Is(err, services.ErrGroupFull):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is full", "group_id": invite.GroupID, "waitlist_available": true})
 		return
-	}
-
-	if memberCount >= group.MaxMembers {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is full"})
+	case errors.Is(err, services.ErrGroupNotJoinable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is not accepting new members"})
 		return
-	}
-
-	// Add user to group
-	_, err = h.db.Exec(`
-		INSERT INTO group_members (id, group_id, user_id, role, joined_at, user_status, payment_amount)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, uuid.New().String(), group.ID, userID.(uuid.UUID), "member", time.Now(), "active", 0)
-
-	if err != nil {
+	case err != nil:
+		fmt.Printf("Error reserving seat: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join group"})
 		return
 	}
 
-	// Update group status based on new member count
-	err = h.CheckAndUpdateGroupStatus(group.ID.String())
+	var group models.Group
+	err = h.db.QueryRow(`
+		SELECT id, name, description, owner_id, invite_code, max_members, created_at, updated_at
+		FROM groups WHERE id = $1
+	`, invite.GroupID).Scan(&group.ID, &group.Name, &group.Description, &group.OwnerID, &group.InviteCode, &group.MaxMembers, &group.CreatedAt, &group.UpdatedAt)
 	if err != nil {
-		// Log error but don't fail the join operation
-		fmt.Printf("Warning: Failed to update group status: %v\n", err)
+		fmt.Printf("Error getting joined group: %v\n", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Successfully joined group",
-		"group":   group,
+		"message":     "Successfully joined group",
+		"group":       group,
+		"reservation": reservation,
 	})
 }
 
@@ -446,46 +466,6 @@ func (h *GroupHandler) UpdateGroupStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Group status updated successfully"})
 }
 
-// CheckAndUpdateGroupStatus automatically updates group status based on member count
-func (h *GroupHandler) CheckAndUpdateGroupStatus(groupID string) error {
-	// Get actual member count from group_members table
-	var actualMemberCount, maxMembers int
-	var currentStatus string
-	err := h.db.QueryRow(`
-		SELECT 
-			COALESCE(COUNT(gm.id), 0) as actual_member_count,
-			g.max_members,
-			g.group_status
-		FROM groups g
-		LEFT JOIN group_members gm ON g.id = gm.group_id AND gm.user_status IN ('active', 'paid')
-		WHERE g.id = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)
-		GROUP BY g.max_members, g.group_status
-	`, groupID).Scan(&actualMemberCount, &maxMembers, &currentStatus)
-
-	if err != nil {
-		return err
-	}
-
-	// Update status based on actual member count
-	var newStatus string
-	if actualMemberCount >= maxMembers {
-		newStatus = "full"
-	} else if actualMemberCount > 0 {
-		newStatus = "open"
-	} else {
-		newStatus = "open"
-	}
-
-	// Update group_status if needed
-	_, err = h.db.Exec(`
-		UPDATE groups 
-		SET group_status = $1, updated_at = $2 
-		WHERE id = $3
-	`, newStatus, time.Now(), groupID)
-
-	return err
-}
-
 // GetGroupByInviteCode retrieves a group by its invite code
 func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 	inviteCode := c.Param("code")
@@ -494,6 +474,21 @@ func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 		return
 	}
 
+	invite, err := h.invites.Resolve(inviteCode)
+	switch {
+	case errors.Is(err, services.ErrInviteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	case errors.Is(err, services.ErrInviteExpired), errors.Is(err, services.ErrInviteRevoked),
+		errors.Is(err, services.ErrInviteExhausted):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		fmt.Printf("Error resolving invite link: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group"})
+		return
+	}
+
 	// Query group with app and owner information
 	query := `
 		SELECT 
@@ -507,7 +502,7 @@ func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 		JOIN apps a ON g.app_id = a.id
 		JOIN users u ON g.owner_id = u.id
 		LEFT JOIN group_members gm ON g.id = gm.group_id
-		WHERE g.invite_code = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)
+		WHERE g.id = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)
 		GROUP BY g.id, g.name, g.description, g.app_id, g.max_members,
 		         g.price_per_member, g.admin_fee, g.total_price, g.group_status, g.invite_code,
 		         g.owner_id, g.expires_at, g.created_at, g.updated_at,
@@ -519,7 +514,7 @@ func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 	var app models.App
 	var ownerName, ownerEmail string
 	var currentMembers int
-	err := h.db.QueryRow(query, inviteCode).Scan(
+	err = h.db.QueryRow(query, invite.GroupID).Scan(
 		&group.ID, &group.Name, &group.Description, &group.AppID, &group.MaxMembers,
 		&group.PricePerMember, &group.AdminFee, &group.TotalPrice,
 		&group.GroupStatus, &group.InviteCode, &group.OwnerID, &group.ExpiresAt,
@@ -537,6 +532,9 @@ func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 		return
 	}
 
+	// The page shows the link it was opened with, not the group's primary one
+	group.InviteCode = invite.Code
+
 	// Set current members count
 	group.CurrentMembers = currentMembers
 	group.MemberCount = currentMembers
@@ -547,8 +545,17 @@ func (h *GroupHandler) GetGroupByInviteCode(c *gin.Context) {
 		FullName: ownerName,
 		Email:    ownerEmail,
 	}
+	if group.Owner.Reputation, err = h.reputation.Get(group.OwnerID.String()); err != nil {
+		fmt.Printf("Warning: Failed to get reputation of owner %s: %v\n", group.OwnerID, err)
+	}
+
+	// Lets the invite page offer the waitlist when the group is full
+	waiting, err := h.waitlist.WaitingCount(group.ID.String())
+	if err != nil {
+		fmt.Printf("Warning: Failed to count waitlist of group %s: %v\n", group.ID, err)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"group": group})
+	c.JSON(http.StatusOK, gin.H{"group": group, "waitlist_count": waiting, "invite": services.InvitePreview(invite)})
 }
 
 func (h *GroupHandler) GetGroupDetails(c *gin.Context) {
@@ -594,11 +601,11 @@ func (h *GroupHandler) GetGroupDetails(c *gin.Context) {
 
 	// Get group details with app pricing and real-time member count
 	var group models.Group
-	var appTotalPrice int
+	var appTotalPrice money.Amount
 	var currentMemberCount int
 	err = h.db.QueryRow(`
 		SELECT g.id, g.name, g.description, g.app_id, g.owner_id, g.invite_code, g.max_members, 
-		       g.price_per_member, g.admin_fee, g.total_price, g.group_status, 
+		       g.price_per_member, g.admin_fee, g.total_price, g.price_breakdown, g.group_status, 
 		       g.expires_at, g.created_at, g.updated_at, a.total_price,
 		       COUNT(DISTINCT CASE WHEN gm.user_status IN ('active', 'paid') THEN gm.id END) as current_members
 		FROM groups g
@@ -610,7 +617,7 @@ func (h *GroupHandler) GetGroupDetails(c *gin.Context) {
 		         g.expires_at, g.created_at, g.updated_at, a.total_price
 	`, groupID).Scan(&group.ID, &group.Name, &group.Description, &group.AppID, &group.OwnerID,
 		&group.InviteCode, &group.MaxMembers, &group.PricePerMember,
-		&group.AdminFee, &group.TotalPrice, &group.GroupStatus, &group.ExpiresAt, &group.CreatedAt, &group.UpdatedAt, &appTotalPrice, &currentMemberCount)
+		&group.AdminFee, &group.TotalPrice, &group.PriceBreakdown, &group.GroupStatus, &group.ExpiresAt, &group.CreatedAt, &group.UpdatedAt, &appTotalPrice, &currentMemberCount)
 
 	// Set current_members from real-time count
 	group.CurrentMembers = currentMemberCount
@@ -627,20 +634,26 @@ func (h *GroupHandler) GetGroupDetails(c *gin.Context) {
 
 	// Recalculate pricing if stored values are 0 (for existing groups)
 	if group.PricePerMember == 0 || group.AdminFee == 0 || group.TotalPrice == 0 {
-		group.PricePerMember = float64(appTotalPrice) / float64(group.MaxMembers)
-		group.AdminFee = 3500.0
-		group.TotalPrice = float64(appTotalPrice)
-
-		// Update the group with correct pricing
-		_, err = h.db.Exec(`
-			UPDATE groups 
-			SET price_per_member = $1, admin_fee = $2, total_price = $3, updated_at = $4
-			WHERE id = $5
-		`, group.PricePerMember, group.AdminFee, group.TotalPrice, time.Now(), groupID)
-
+		price, err := services.AppSeatPrice(h.db, h.pricing, group.AppID, group.MaxMembers)
 		if err != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Warning: Failed to update group pricing: %v\n", err)
+			fmt.Printf("Warning: Failed to price group %s: %v\n", groupID, err)
+		} else {
+			group.PricePerMember = price.PricePerMember
+			group.AdminFee = price.AdminFee
+			group.TotalPrice = appTotalPrice
+			group.PriceBreakdown = price
+
+			// Update the group with correct pricing
+			_, err = h.db.Exec(`
+				UPDATE groups 
+				SET price_per_member = $1, admin_fee = $2, total_price = $3, price_breakdown = $4, updated_at = $5
+				WHERE id = $6
+			`, group.PricePerMember, group.AdminFee, group.TotalPrice, *price, time.Now(), groupID)
+
+			if err != nil {
+				// Log error but don't fail the request
+				fmt.Printf("Warning: Failed to update group pricing: %v\n", err)
+			}
 		}
 	}
 
@@ -712,6 +725,7 @@ func (h *GroupHandler) GetGroupDetails(c *gin.Context) {
 		PricePerMember: group.PricePerMember,
 		AdminFee:       group.AdminFee,
 		TotalPrice:     group.TotalPrice,
+		PriceBreakdown: group.PriceBreakdown,
 		GroupStatus:    group.GroupStatus,
 		ExpiresAt:      group.ExpiresAt,
 		MemberCount:    len(members),
@@ -732,6 +746,12 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 	appID := c.Query("app_id") // Get app_id filter
 
+	// Newest groups first, or the groups of the most reputable owners with sort=reputation
+	orderBy := "g.created_at DESC"
+	if c.Query("sort") == "reputation" {
+		orderBy = services.OwnerReputationOrder + ", g.created_at DESC"
+	}
+
 	if page < 1 {
 		page = 1
 	}
@@ -754,23 +774,25 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 				a.name as app_name, a.description as app_description, a.category, a.icon_url,
 				COALESCE(a.total_price, 0) as total_price,
 				u.full_name as owner_name,
-				-- Calculate pricing dynamically
-				(COALESCE(a.total_price, 0) / g.max_members) as price_per_member,
-				3500 as admin_fee,
-				(COALESCE(a.total_price, 0) / g.max_members) + 3500 as total_per_user,
+				` + services.OwnerReputationColumns + `,
+				-- Stored seat price, from the app price for groups created before pricing was stored
+				COALESCE(NULLIF(g.price_per_member, 0), COALESCE(a.total_price, 0) / g.max_members) as price_per_member,
+				COALESCE(g.admin_fee, 0) as admin_fee,
+				COALESCE(NULLIF(g.price_per_member, 0), COALESCE(a.total_price, 0) / g.max_members) + COALESCE(g.admin_fee, 0) as total_per_user,
 				COALESCE(a.total_price, 0) as total_price,
 				COUNT(DISTINCT CASE WHEN gm.user_status IN ('active', 'paid') THEN gm.id END) as current_members
 			FROM groups g
 			JOIN apps a ON g.app_id = a.id
 			JOIN users u ON g.owner_id = u.id
+			LEFT JOIN user_reputation ur ON ur.user_id = g.owner_id
 			LEFT JOIN group_members gm ON g.id = gm.group_id
 			WHERE g.group_status = 'open' AND a.is_active = true 
 			AND g.app_id = $1 AND g.is_public = true AND (g.is_deleted IS NULL OR g.is_deleted = false)
 			GROUP BY g.id, g.name, g.description, g.app_id, g.max_members,
 			         g.group_status, g.invite_code, g.owner_id, g.is_public, g.expires_at, g.created_at, g.updated_at,
-			         a.name, a.description, a.category, a.icon_url, a.total_price, u.full_name
+			         a.name, a.description, a.category, a.icon_url, a.total_price, u.full_name, ur.user_id
 			HAVING COUNT(DISTINCT CASE WHEN gm.user_status IN ('active', 'paid') THEN gm.id END) < g.max_members
-			ORDER BY g.created_at DESC
+			ORDER BY ` + orderBy + `
 			LIMIT $2 OFFSET $3
 		`
 		args = []interface{}{appID, pageSize, offset}
@@ -782,23 +804,25 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 				a.name as app_name, a.description as app_description, a.category, a.icon_url,
 				COALESCE(a.total_price, 0) as total_price,
 				u.full_name as owner_name,
-				-- Calculate pricing dynamically
-				(COALESCE(a.total_price, 0) / g.max_members) as price_per_member,
-				3500 as admin_fee,
-				(COALESCE(a.total_price, 0) / g.max_members) + 3500 as total_per_user,
+				` + services.OwnerReputationColumns + `,
+				-- Stored seat price, from the app price for groups created before pricing was stored
+				COALESCE(NULLIF(g.price_per_member, 0), COALESCE(a.total_price, 0) / g.max_members) as price_per_member,
+				COALESCE(g.admin_fee, 0) as admin_fee,
+				COALESCE(NULLIF(g.price_per_member, 0), COALESCE(a.total_price, 0) / g.max_members) + COALESCE(g.admin_fee, 0) as total_per_user,
 				COALESCE(a.total_price, 0) as total_price,
 				COUNT(DISTINCT CASE WHEN gm.user_status IN ('active', 'paid') THEN gm.id END) as current_members
 			FROM groups g
 			JOIN apps a ON g.app_id = a.id
 			JOIN users u ON g.owner_id = u.id
+			LEFT JOIN user_reputation ur ON ur.user_id = g.owner_id
 			LEFT JOIN group_members gm ON g.id = gm.group_id
 			WHERE g.group_status = 'open' AND a.is_active = true 
 			AND g.is_public = true AND (g.is_deleted IS NULL OR g.is_deleted = false)
 			GROUP BY g.id, g.name, g.description, g.app_id, g.max_members,
 			         g.group_status, g.invite_code, g.owner_id, g.is_public, g.expires_at, g.created_at, g.updated_at,
-			         a.name, a.description, a.category, a.icon_url, a.total_price, u.full_name
+			         a.name, a.description, a.category, a.icon_url, a.total_price, u.full_name, ur.user_id
 			HAVING COUNT(DISTINCT CASE WHEN gm.user_status IN ('active', 'paid') THEN gm.id END) < g.max_members
-			ORDER BY g.created_at DESC
+			ORDER BY ` + orderBy + `
 			LIMIT $1 OFFSET $2
 		`
 		args = []interface{}{pageSize, offset}
@@ -820,7 +844,8 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 		var group models.GroupResponse
 		var app models.App
 		var ownerName string
-		var totalPerUser float64
+		var ownerReputation models.UserReputation
+		var totalPerUser money.Amount
 		var currentMembers int
 		err := rows.Scan(
 			&group.ID, &group.Name, &group.Description, &group.AppID, &group.MaxMembers,
@@ -829,6 +854,7 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 			&app.Name, &app.Description, &app.Category, &app.IconURL,
 			&app.TotalPrice,
 			&ownerName,
+			&ownerReputation.Score, &ownerReputation.OwnerRating, &ownerReputation.OwnerRatingCount,
 			&group.PricePerMember, &group.AdminFee, &totalPerUser, &group.TotalPrice,
 			&currentMembers,
 		)
@@ -846,8 +872,9 @@ func (h *GroupHandler) GetPublicGroups(c *gin.Context) {
 
 		// Set owner information
 		group.Owner = &models.UserResponse{
-			ID:       group.OwnerID,
-			FullName: ownerName,
+			ID:         group.OwnerID,
+			FullName:   ownerName,
+			Reputation: &ownerReputation,
 		}
 
 		groups = append(groups, group)
@@ -991,7 +1018,7 @@ func (h *GroupHandler) GetGroupMembers(c *gin.Context) {
 	for memberRows.Next() {
 		var member models.GroupMember
 		var user models.UserResponse
-		var pricePerMember float64
+		var pricePerMember money.Amount
 		err := memberRows.Scan(&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.UserStatus, &member.PaymentAmount, &member.JoinedAt, &pricePerMember, &user.FullName, &user.Email, &user.AvatarURL)
 		if err != nil {
 			log.Printf("Error scanning member: %v", err)
@@ -1011,6 +1038,21 @@ func (h *GroupHandler) GetGroupMembers(c *gin.Context) {
 
 	log.Printf("Found %d members for group %s", len(members), groupID)
 
+	// Lets the owner see how reliable each member is
+	memberIDs := make([]string, len(members))
+	for i := range members {
+		memberIDs[i] = members[i].UserID.String()
+	}
+	reputations, err := h.reputation.ForUsers(memberIDs)
+	if err != nil {
+		log.Printf("Error fetching member reputation for group %s: %v", groupID, err)
+	}
+	for i := range members {
+		if rep, ok := reputations[memberIDs[i]]; ok {
+			members[i].User.Reputation = &rep
+		}
+	}
+
 	// Get group information with real-time member count
 	var group models.Group
 	var actualMemberCount int
@@ -1086,7 +1128,7 @@ func (h *GroupHandler) AdminUpdateUserStatus(c *gin.Context) {
 	}
 
 	err := h.stateMachineSvc.AdminUpdateUserStatus(
-		adminID.(string),
+		adminID.(uuid.UUID).String(),
 		req.UserID,
 		req.GroupID,
 		req.NewStatus,
@@ -1115,9 +1157,10 @@ func (h *GroupHandler) AdminUpdateGroupStatus(c *gin.Context) {
 	}
 
 	err := h.stateMachineSvc.AdminUpdateGroupStatus(
-		adminID.(string),
+		adminID.(uuid.UUID).String(),
 		req.GroupID,
 		req.NewStatus,
+		req.Reason,
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -1214,26 +1257,17 @@ func (h *GroupHandler) LeaveGroup(c *gin.Context) {
 		return
 	}
 
-	// Remove user from group
-	_, err = h.db.Exec(`
-		DELETE FROM group_members 
-		WHERE group_id = $1 AND user_id = $2
-	`, groupID, userID.(uuid.UUID))
-
+	// Remove user from group and refund the unused part of their payment
+	refund, err := h.refundSvc.RemoveMember(services.UserActor(userID.(uuid.UUID).String()), userID.(uuid.UUID).String(), groupID, models.RefundReasonLeave, true)
 	if err != nil {
+		fmt.Printf("[SALOME BE] ERROR: Failed to leave group %s: %v\n", groupID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave group"})
 		return
 	}
 
-	// Update group status based on new member count
-	err = h.CheckAndUpdateGroupStatus(groupID)
-	if err != nil {
-		// Log error but don't fail the leave operation
-		fmt.Printf("Warning: Failed to update group status: %v\n", err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Successfully left group",
+		"refund":  refund,
 	})
 }
 
@@ -1351,10 +1385,10 @@ func (h *GroupHandler) DeleteGroup(c *gin.Context) {
 	groupID := c.Param("id")
 
 	// Check if current user is the owner
-	var currentOwnerID string
+	var currentOwnerID, groupStatus string
 	err := h.db.QueryRow(`
-		SELECT owner_id FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
-	`, groupID).Scan(&currentOwnerID)
+		SELECT owner_id, group_status FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+	`, groupID).Scan(&currentOwnerID, &groupStatus)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
@@ -1374,15 +1408,18 @@ func (h *GroupHandler) DeleteGroup(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Update group status to removed
-	_, err = tx.Exec(`
-		UPDATE groups 
-		SET group_status = 'closed', updated_at = NOW() 
-		WHERE id = $1
-	`, groupID)
+	// Update group status to closed
+	actor := services.UserActor(currentOwnerID).WithCorrelation(services.NewCorrelationID())
+	if groupStatus != models.GroupStatusClosed {
+		err = h.stateMachineSvc.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusClosed, "Group deleted by owner")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+			return
+		}
+	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+	if err = services.RecordMembersRemoved(tx, actor, groupID, "Group deleted by owner"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove members"})
 		return
 	}
 
@@ -1455,21 +1492,21 @@ func (h *GroupHandler) UpdateGroup(c *gin.Context) {
 	`
 
 	var group struct {
-		ID             string    `json:"id"`
-		Name           string    `json:"name"`
-		Description    *string   `json:"description"`
-		AppID          string    `json:"app_id"`
-		OwnerID        string    `json:"owner_id"`
-		InviteCode     string    `json:"invite_code"`
-		MaxMembers     int       `json:"max_members"`
-		CurrentMembers int       `json:"current_members"`
-		PricePerMember float64   `json:"price_per_member"`
-		AdminFee       float64   `json:"admin_fee"`
-		TotalPrice     float64   `json:"total_price"`
-		GroupStatus    string    `json:"group_status"`
-		IsPublic       bool      `json:"is_public"`
-		CreatedAt      time.Time `json:"created_at"`
-		UpdatedAt      time.Time `json:"updated_at"`
+		ID             string       `json:"id"`
+		Name           string       `json:"name"`
+		Description    *string      `json:"description"`
+		AppID          string       `json:"app_id"`
+		OwnerID        string       `json:"owner_id"`
+		InviteCode     string       `json:"invite_code"`
+		MaxMembers     int          `json:"max_members"`
+		CurrentMembers int          `json:"current_members"`
+		PricePerMember money.Amount `json:"price_per_member"`
+		AdminFee       money.Amount `json:"admin_fee"`
+		TotalPrice     money.Amount `json:"total_price"`
+		GroupStatus    string       `json:"group_status"`
+		IsPublic       bool         `json:"is_public"`
+		CreatedAt      time.Time    `json:"created_at"`
+		UpdatedAt      time.Time    `json:"updated_at"`
 	}
 
 	err = h.db.QueryRow(query, req.Name, req.Description, groupID).Scan(
@@ -1486,12 +1523,3 @@ func (h *GroupHandler) UpdateGroup(c *gin.Context) {
 
 	c.JSON(http.StatusOK, group)
 }
-
-func (h *GroupHandler) generateInviteCode() string {
-	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	code := make([]byte, 8)
-	for i := range code {
-		code[i] = charset[rand.Intn(len(charset))]
-	}
-	return string(code)
-}
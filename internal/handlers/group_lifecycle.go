@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PauseGroup stops billing of a paid group while members keep their seat
+func (h *GroupHandler) PauseGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GroupLifecycleRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	lifecycle, err := h.lifecycle.Pause(userID.(uuid.UUID).String(), c.Param("id"), req.Reason)
+	if err != nil {
+		h.respondLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Group paused",
+		"data":    lifecycle,
+	})
+}
+
+// ResumeGroup restarts billing of a paused group with a new period for its members
+func (h *GroupHandler) ResumeGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	lifecycle, err := h.lifecycle.Resume(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Group resumed",
+		"data":    lifecycle,
+	})
+}
+
+// ScheduleGroupClosure closes a paid group at the end of the current period without renewing it
+func (h *GroupHandler) ScheduleGroupClosure(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GroupLifecycleRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	lifecycle, err := h.lifecycle.ScheduleClosure(userID.(uuid.UUID).String(), c.Param("id"), req.Reason)
+	if err != nil {
+		h.respondLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Group closure scheduled",
+		"data":    lifecycle,
+	})
+}
+
+// CancelGroupClosure keeps a group scheduled for closure running
+func (h *GroupHandler) CancelGroupClosure(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	lifecycle, err := h.lifecycle.CancelClosure(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Group closure cancelled",
+		"data":    lifecycle,
+	})
+}
+
+func (h *GroupHandler) respondLifecycleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrLifecycleOwnerOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGroupNotActive), errors.Is(err, services.ErrGroupNotPaused),
+		errors.Is(err, services.ErrGroupNotClosing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error changing group lifecycle: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+	}
+}
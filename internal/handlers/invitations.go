@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InviteToGroup invites email addresses to the group. Existing users get an in-app notification, other
+// addresses an email with a sign-up-and-join link (owner only).
+func (h *GroupHandler) InviteToGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GroupInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.invitations.Send(userID.(uuid.UUID).String(), c.Param("id"), req.Emails)
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Invitations processed",
+		"data":    results,
+	})
+}
+
+// GetGroupInvitations lists the invitations of a group with their status (owner only)
+func (h *GroupHandler) GetGroupInvitations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invitations, err := h.invitations.ListForGroup(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invitations,
+	})
+}
+
+// GetMyInvitations lists the pending invitations sent to the current user's email address
+func (h *GroupHandler) GetMyInvitations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invitations, err := h.invitations.ListForUser(userID.(uuid.UUID).String())
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invitations,
+	})
+}
+
+// GetInvitation shows an invitation by the token of its link, for the sign-up-and-join page
+func (h *GroupHandler) GetInvitation(c *gin.Context) {
+	invitation, err := h.invitations.Get(c.Param("token"))
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invitation,
+	})
+}
+
+// AcceptInvitation reserves a pending seat in the group the current user was invited to
+func (h *GroupHandler) AcceptInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invitation, reservation, err := h.invitations.Accept(userID.(uuid.UUID).String(), c.Param("token"))
+	if errors.Is(err, services.ErrGroupFull) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is full", "group_id": invitation.GroupID, "waitlist_available": true})
+		return
+	}
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "Invitation accepted",
+		"data":        invitation,
+		"reservation": reservation,
+	})
+}
+
+// DeclineInvitation turns down an invitation sent to the current user
+func (h *GroupHandler) DeclineInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invitation, err := h.invitations.Decline(userID.(uuid.UUID).String(), c.Param("token"))
+	if err != nil {
+		h.respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Invitation declined",
+		"data":    invitation,
+	})
+}
+
+func (h *GroupHandler) respondInvitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationOwnerOnly), errors.Is(err, services.ErrInvitationWrongUser):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationNotPending), errors.Is(err, services.ErrAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGroupNotJoinable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group is not accepting new members"})
+	default:
+		fmt.Printf("Error handling invitation: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process invitation"})
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListGroupInvites lists the invite links of a group with their status (owner only)
+func (h *GroupHandler) ListGroupInvites(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invites, err := h.invites.List(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invites,
+	})
+}
+
+// CreateGroupInvite adds an invite link with an optional expiry, usage cap and target email (owner only)
+func (h *GroupHandler) CreateGroupInvite(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GroupInviteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	invite, err := h.invites.Create(userID.(uuid.UUID).String(), c.Param("id"), req)
+	if err != nil {
+		h.respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Invite link created",
+		"data":    invite,
+	})
+}
+
+// RevokeGroupInvite stops an invite link from being used (owner only)
+func (h *GroupHandler) RevokeGroupInvite(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invite, err := h.invites.Revoke(userID.(uuid.UUID).String(), c.Param("id"), c.Param("invite_id"))
+	if err != nil {
+		h.respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Invite link revoked",
+		"data":    invite,
+	})
+}
+
+// RegenerateGroupInvite replaces an invite link with a new code and the same settings (owner only)
+func (h *GroupHandler) RegenerateGroupInvite(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	invite, err := h.invites.Regenerate(userID.(uuid.UUID).String(), c.Param("id"), c.Param("invite_id"))
+	if err != nil {
+		h.respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Invite link regenerated",
+		"data":    invite,
+	})
+}
+
+// GetGroupInviteUses lists who took a seat through an invite link (owner only)
+func (h *GroupHandler) GetGroupInviteUses(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	uses, err := h.invites.Uses(userID.(uuid.UUID).String(), c.Param("id"), c.Param("invite_id"))
+	if err != nil {
+		h.respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    uses,
+	})
+}
+
+func (h *GroupHandler) respondInviteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrInviteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteOwnerOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error managing invite links: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process invite link request"})
+	}
+}
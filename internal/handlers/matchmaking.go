@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MatchGroup places the user into the best open public group of an app, creating a platform-owned group
+// when none fits, and holds a pending seat for them
+func (h *GroupHandler) MatchGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GroupMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.matchmaking.Match(userID.(uuid.UUID).String(), req.AppID, req.MaxPrice)
+	switch {
+	case errors.Is(err, services.ErrAppNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found or not available"})
+		return
+	case errors.Is(err, services.ErrAlreadyInAppGroup):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoMatchingGroup):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		fmt.Printf("Error matching group: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find a group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Seat reserved, complete the payment before the deadline",
+		"data":    result,
+	})
+}
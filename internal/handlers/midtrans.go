@@ -7,19 +7,24 @@ import (
 	"time"
 
 	"salome-be/internal/service"
+	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type MidtransHandler struct {
 	db              *sql.DB
-	midtransService *service.MidtransService
+	gateway         service.PaymentGateway
+	settlement      *services.PaymentSettlement
+	pendingPayments *services.PendingPaymentPoller
 }
 
 func NewMidtransHandler(db *sql.DB) *MidtransHandler {
 	return &MidtransHandler{
 		db:              db,
-		midtransService: service.NewMidtransService(),
+		gateway:         service.NewPaymentGateway(),
+		settlement:      services.NewPaymentSettlement(db),
+		pendingPayments: services.NewPendingPaymentPoller(db),
 	}
 }
 
@@ -53,13 +58,14 @@ func (h *MidtransHandler) CheckTransactionStatus(c *gin.Context) {
 	var transactionID string
 	var currentStatus string
 	var paymentReference string
+	var paymentLinkID *string
 
 	// Cari transaksi di database dengan order ID tanpa suffix
 	err := h.db.QueryRow(`
-		SELECT id, status, payment_reference
+		SELECT id, status, payment_reference, payment_link_id
 		FROM transactions 
 		WHERE payment_reference = $1
-	`, req.OrderID).Scan(&transactionID, &currentStatus, &paymentReference)
+	`, req.OrderID).Scan(&transactionID, &currentStatus, &paymentReference, &paymentLinkID)
 
 	// Jika tidak ditemukan dengan order ID asli, coba cari dengan pattern yang dimulai dengan order ID asli
 	if err != nil {
@@ -67,12 +73,12 @@ func (h *MidtransHandler) CheckTransactionStatus(c *gin.Context) {
 
 		// Cari transaksi yang payment_reference dimulai dengan order ID asli
 		err = h.db.QueryRow(`
-			SELECT id, status, payment_reference
+			SELECT id, status, payment_reference, payment_link_id
 			FROM transactions 
 			WHERE payment_reference LIKE $1
 			ORDER BY created_at DESC
 			LIMIT 1
-		`, req.OrderID+"%").Scan(&transactionID, &currentStatus, &paymentReference)
+		`, req.OrderID+"%").Scan(&transactionID, &currentStatus, &paymentReference, &paymentLinkID)
 
 		if err != nil {
 			fmt.Printf("❌ [HANDLER DEBUG] Transaction not found with pattern either: %v\n", err)
@@ -111,15 +117,15 @@ func (h *MidtransHandler) CheckTransactionStatus(c *gin.Context) {
 		return
 	}
 
-	// Cek status di Midtrans menggunakan payment_reference sebagai payment_link_id
-	var midtransStatus *service.MidtransTransactionStatus
+	// Cek status di Midtrans dengan payment_link_id (payment_reference untuk transaksi lama tanpa link ID)
+	var midtransStatus *service.PaymentStatus
 	var midtransErr error
 
+	lookupID := services.StatusLookupID(paymentReference, paymentLinkID)
 	fmt.Printf("🔍 [HANDLER DEBUG] Checking Midtrans status...\n")
-	fmt.Printf("🔍 [HANDLER DEBUG] Using payment_reference as payment_link_id: %s\n", paymentReference)
+	fmt.Printf("🔍 [HANDLER DEBUG] Using payment link lookup ID: %s\n", lookupID)
 
-	// Gunakan payment_reference sebagai payment_link_id untuk cek ke Midtrans Payment Link API
-	midtransStatus, midtransErr = h.midtransService.GetTransactionStatus(paymentReference)
+	midtransStatus, midtransErr = h.gateway.GetTransactionStatus(lookupID)
 
 	if midtransErr != nil {
 		fmt.Printf("❌ [HANDLER DEBUG] Midtrans API failed: %v\n", midtransErr)
@@ -149,9 +155,9 @@ func (h *MidtransHandler) CheckTransactionStatus(c *gin.Context) {
 	}
 
 	// Cek apakah transaksi sudah settlement
-	isSettled := h.midtransService.IsTransactionSettled(midtransStatus.TransactionStatus)
-	isPending := h.midtransService.IsTransactionPending(midtransStatus.TransactionStatus)
-	isFailed := h.midtransService.IsTransactionFailed(midtransStatus.TransactionStatus)
+	isSettled := service.IsTransactionSettled(midtransStatus.TransactionStatus)
+	isPending := service.IsTransactionPending(midtransStatus.TransactionStatus)
+	isFailed := service.IsTransactionFailed(midtransStatus.TransactionStatus)
 
 	fmt.Printf("🔍 [HANDLER DEBUG] Status check results:\n")
 	fmt.Printf("   - TransactionStatus: %s\n", midtransStatus.TransactionStatus)
@@ -219,89 +225,44 @@ func (h *MidtransHandler) CheckTransactionStatus(c *gin.Context) {
 }
 
 // updateTransactionStatus mengupdate status transaksi di database
-func (h *MidtransHandler) updateTransactionStatus(orderID, status string, midtransStatus *service.MidtransTransactionStatus) error {
-	// Cari transaksi berdasarkan order_id
-	var transactionID string
-	var currentStatus string
-	var userID string
-	var groupID string
-	var amount int
-
-	err := h.db.QueryRow(`
-		SELECT id, status, user_id, group_id, amount 
-		FROM transactions 
-		WHERE payment_reference = $1
-	`, orderID).Scan(&transactionID, &currentStatus, &userID, &groupID, &amount)
-
-	if err != nil {
-		return fmt.Errorf("transaction not found: %v", err)
-	}
-
-	// Jangan update jika sudah completed
-	if currentStatus == "completed" {
-		return nil
-	}
-
-	// Update status transaksi dan payment method
+func (h *MidtransHandler) updateTransactionStatus(orderID, status string, midtransStatus *service.PaymentStatus) error {
 	fmt.Printf("🔄 [HANDLER DEBUG] Updating transaction status: %s, payment_method: %s\n", status, midtransStatus.PaymentType)
-	_, err = h.db.Exec(`
-		UPDATE transactions 
-		SET status = $1, payment_method = $2, updated_at = $3
-		WHERE id = $4
-	`, status, midtransStatus.PaymentType, time.Now(), transactionID)
 
+	result, err := h.applySettlement(orderID, status, midtransStatus.PaymentType)
 	if err != nil {
-		return fmt.Errorf("failed to update transaction: %v", err)
+		return err
 	}
-	fmt.Printf("✅ [HANDLER DEBUG] Transaction updated successfully\n")
-
-	// Jika status success (SETTLEMENT), update saldo user dan group member status
-	if status == "success" {
-		fmt.Printf("💰 [HANDLER DEBUG] Processing SETTLEMENT - updating user balance and group member status\n")
-
-		// Update saldo user dan total_spent
-		fmt.Printf("🔄 [HANDLER DEBUG] Updating user balance: -%d for user_id: %s\n", amount, userID)
-		_, err = h.db.Exec(`
-			UPDATE users 
-			SET balance = balance - $1, total_spent = total_spent + $1, updated_at = $2
-			WHERE id = $3
-		`, amount, time.Now(), userID)
+	fmt.Printf("✅ [HANDLER DEBUG] Transaction updated successfully (applied: %t)\n", result.Applied)
 
+	// Check apakah semua member dalam group sudah paid
+	if result.Applied && status == "success" && result.TransactionType == "group_payment" && result.GroupID != nil {
+		err = h.checkAndUpdateGroupStatus(services.SystemActor(orderID), *result.GroupID)
 		if err != nil {
-			return fmt.Errorf("failed to update user balance and total_spent: %v", err)
+			fmt.Printf("⚠️ [HANDLER DEBUG] Failed to check group status: %v\n", err)
+			// Tidak return error karena ini bukan critical
 		}
-		fmt.Printf("✅ [HANDLER DEBUG] User balance and total_spent updated successfully\n")
+	}
 
-		// Update group member status menjadi paid
-		if groupID != "" {
-			fmt.Printf("🔄 [HANDLER DEBUG] Updating group member status to 'paid' for user_id: %s, group_id: %s\n", userID, groupID)
-			_, err = h.db.Exec(`
-				UPDATE group_members 
-				SET user_status = 'paid'
-				WHERE user_id = $1 AND group_id = $2
-			`, userID, groupID)
+	return nil
+}
 
-			if err != nil {
-				return fmt.Errorf("failed to update group member status: %v", err)
-			}
-			fmt.Printf("✅ [HANDLER DEBUG] Group member status updated to 'paid' successfully\n")
+// applySettlement menerapkan status Midtrans ke transaksi (saldo & ledger) dalam satu DB transaction
+func (h *MidtransHandler) applySettlement(paymentReference, status, paymentType string) (*services.SettlementResult, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-			// Check apakah semua member dalam group sudah paid
-			err = h.checkAndUpdateGroupStatus(groupID)
-			if err != nil {
-				fmt.Printf("⚠️ [HANDLER DEBUG] Failed to check group status: %v\n", err)
-				// Tidak return error karena ini bukan critical
-			}
-		} else {
-			fmt.Printf("⚠️ [HANDLER DEBUG] No group_id found, skipping group member update\n")
-		}
-	} else if status == "expired" {
-		// Untuk expired, tidak perlu update balance atau group member
-		// Hanya update status transaksi saja
-		fmt.Printf("✅ [HANDLER DEBUG] Transaction expired, no balance or group member update needed\n")
+	result, err := h.settlement.Apply(tx, services.SystemActor(paymentReference), paymentReference, status, paymentType)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
 }
 
 // GetTransactionPaymentLink mengambil payment link untuk transaksi yang masih pending
@@ -343,7 +304,7 @@ func (h *MidtransHandler) GetTransactionPaymentLink(c *gin.Context) {
 	// Construct payment link URL dari payment_reference
 	var paymentLinkURL string
 	if paymentReference != "" {
-		paymentLinkURL = fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", paymentReference)
+		paymentLinkURL = h.gateway.PaymentLinkURL(paymentReference)
 		fmt.Printf("🔗 [HANDLER DEBUG] Payment link URL: %s\n", paymentLinkURL)
 	} else {
 		fmt.Printf("⚠️ [HANDLER DEBUG] No payment_reference found for order: %s\n", orderID)
@@ -361,68 +322,13 @@ func (h *MidtransHandler) GetTransactionPaymentLink(c *gin.Context) {
 
 // getPaymentLinkURL helper function untuk construct payment link URL
 func (h *MidtransHandler) getPaymentLinkURL(paymentLinkID string) string {
-	if paymentLinkID == "" {
-		return ""
-	}
-	return fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", paymentLinkID)
+	return h.gateway.PaymentLinkURL(paymentLinkID)
 }
 
 // checkAndUpdateGroupStatus mengecek apakah semua member dalam group sudah paid
 // dan mengupdate group_status menjadi "paid" jika semua sudah bayar
-func (h *MidtransHandler) checkAndUpdateGroupStatus(groupID string) error {
-	fmt.Printf("🔍 [GROUP STATUS DEBUG] Checking group completion for group_id: %s\n", groupID)
-
-	// Cek total member dalam group
-	var totalMembers int
-	err := h.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM group_members 
-		WHERE group_id = $1
-	`, groupID).Scan(&totalMembers)
-
-	if err != nil {
-		return fmt.Errorf("failed to count group members: %v", err)
-	}
-
-	// Cek berapa banyak member yang sudah paid
-	var paidMembers int
-	err = h.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM group_members 
-		WHERE group_id = $1 AND user_status = 'paid'
-	`, groupID).Scan(&paidMembers)
-
-	if err != nil {
-		return fmt.Errorf("failed to count paid members: %v", err)
-	}
-
-	fmt.Printf("📊 [GROUP STATUS DEBUG] Group %s: %d/%d members paid\n", groupID, paidMembers, totalMembers)
-
-	// Jika semua member sudah paid, update group_status menjadi "paid"
-	if paidMembers == totalMembers && totalMembers > 0 {
-		fmt.Printf("🎉 [GROUP STATUS DEBUG] All members paid! Updating group_status to 'paid_group'\n")
-
-		// Use Asia/Jakarta timezone
-		loc, _ := time.LoadLocation("Asia/Jakarta")
-		now := time.Now().In(loc)
-
-		_, err = h.db.Exec(`
-			UPDATE groups 
-			SET group_status = 'paid_group', all_paid_at = $1, updated_at = $1
-			WHERE id = $2
-		`, now, groupID)
-
-		if err != nil {
-			return fmt.Errorf("failed to update group status to paid: %v", err)
-		}
-
-		fmt.Printf("✅ [GROUP STATUS DEBUG] Group %s status updated to 'paid_group' successfully\n", groupID)
-		fmt.Printf("📅 [GROUP STATUS DEBUG] all_paid_at set to: %s\n", now.Format("2006-01-02 15:04:05"))
-	} else {
-		fmt.Printf("⏳ [GROUP STATUS DEBUG] Group %s still waiting for %d more members to pay\n", groupID, totalMembers-paidMembers)
-	}
-
-	return nil
+func (h *MidtransHandler) checkAndUpdateGroupStatus(actor services.Actor, groupID string) error {
+	return h.pendingPayments.MarkGroupPaidIfComplete(actor, groupID)
 }
 
 // resetGroupStatusIfNeeded mereset group_status menjadi "pending" jika ada member yang belum paid
@@ -484,163 +390,25 @@ func (h *MidtransHandler) resetGroupStatusIfNeeded(groupID string) error {
 func (h *MidtransHandler) UpdateAllPendingTransactions(c *gin.Context) {
 	fmt.Printf("🚀 [HANDLER DEBUG] UpdateAllPendingTransactions called\n")
 
-	// Ambil semua transaksi dengan status pending
-	query := `
-		SELECT id, payment_reference, payment_link_id, group_id, user_id, amount, status
-		FROM transactions 
-		WHERE status = 'pending' AND payment_reference IS NOT NULL
-		ORDER BY created_at ASC
-	`
-
-	rows, err := h.db.Query(query)
+	stats, err := h.pendingPayments.PollAll()
 	if err != nil {
-		fmt.Printf("❌ [ERROR] Failed to fetch pending transactions: %v\n", err)
+		fmt.Printf("❌ [ERROR] %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch pending transactions",
 			"details": err.Error(),
 		})
 		return
 	}
-	defer rows.Close()
-
-	var pendingTransactions []struct {
-		ID               string
-		PaymentReference string
-		PaymentLinkID    *string
-		GroupID          string
-		UserID           string
-		Amount           float64
-		Status           string
-	}
-
-	for rows.Next() {
-		var tx struct {
-			ID               string
-			PaymentReference string
-			PaymentLinkID    *string
-			GroupID          string
-			UserID           string
-			Amount           float64
-			Status           string
-		}
-
-		err := rows.Scan(
-			&tx.ID,
-			&tx.PaymentReference,
-			&tx.PaymentLinkID,
-			&tx.GroupID,
-			&tx.UserID,
-			&tx.Amount,
-			&tx.Status,
-		)
-		if err != nil {
-			fmt.Printf("❌ [ERROR] Failed to scan transaction: %v\n", err)
-			continue
-		}
-
-		pendingTransactions = append(pendingTransactions, tx)
-	}
-
-	fmt.Printf("📊 [STATS] Found %d pending transactions to process\n", len(pendingTransactions))
-
-	if len(pendingTransactions) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message":   "No pending transactions found",
-			"processed": 0,
-			"updated":   0,
-			"failed":    0,
-		})
-		return
-	}
-
-	processed := 0
-	updated := 0
-	failed := 0
-
-	// Proses setiap transaksi pending
-	for _, tx := range pendingTransactions {
-		processed++
-		fmt.Printf("🔄 [PROCESSING] Transaction %s (Payment Ref: %s)\n", tx.ID, tx.PaymentReference)
-
-		// Cek status di Midtrans menggunakan payment_reference
-		fmt.Printf("🔍 [DEBUG] Checking Midtrans status for payment_reference: %s\n", tx.PaymentReference)
-
-		midtransStatus, err := h.midtransService.GetTransactionStatus(tx.PaymentReference)
-		if err != nil {
-			fmt.Printf("❌ [ERROR] Failed to check Midtrans status for payment_reference %s: %v\n", tx.PaymentReference, err)
-			failed++
-			continue
-		}
-
-		// Map status Midtrans ke status internal
-		var newStatus string
-		switch midtransStatus.TransactionStatus {
-		case "settlement":
-			newStatus = "success"
-		case "expire":
-			newStatus = "expired"
-		case "deny", "cancel", "failed":
-			newStatus = "failed"
-		default:
-			newStatus = "pending"
-		}
-
-		fmt.Printf("📋 [STATUS] Transaction %s: %s -> %s\n", tx.ID, tx.Status, newStatus)
-
-		// Update status transaksi
-		_, err = h.db.Exec(`
-			UPDATE transactions 
-			SET status = $1, updated_at = $2
-			WHERE id = $3
-		`, newStatus, time.Now(), tx.ID)
-
-		if err != nil {
-			fmt.Printf("❌ [ERROR] Failed to update transaction %s: %v\n", tx.ID, err)
-			failed++
-			continue
-		}
-
-		// Jika status berubah menjadi success, update group members dan balance
-		if newStatus == "success" {
-			// Update user balance dan total_spent
-			_, err = h.db.Exec(`
-				UPDATE users 
-				SET balance = balance - $1, total_spent = total_spent + $1, updated_at = $2
-				WHERE id = $3
-			`, tx.Amount, time.Now(), tx.UserID)
-
-			if err != nil {
-				fmt.Printf("❌ [ERROR] Failed to update user balance and total_spent for %s: %v\n", tx.UserID, err)
-			}
-
-			// Update group member status
-			_, err = h.db.Exec(`
-				UPDATE group_members 
-				SET user_status = 'paid', updated_at = $1
-				WHERE group_id = $2 AND user_id = $3
-			`, time.Now(), tx.GroupID, tx.UserID)
-
-			if err != nil {
-				fmt.Printf("❌ [ERROR] Failed to update group member status: %v\n", err)
-			}
-
-			// Cek dan update group status
-			err = h.checkAndUpdateGroupStatus(tx.GroupID)
-			if err != nil {
-				fmt.Printf("❌ [ERROR] Failed to update group status: %v\n", err)
-			}
-		}
 
-		updated++
-		fmt.Printf("✅ [SUCCESS] Transaction %s updated to %s\n", tx.ID, newStatus)
+	message := "All pending transactions processed"
+	if stats.Processed == 0 {
+		message = "No pending transactions found"
 	}
 
-	fmt.Printf("🎉 [COMPLETE] Processed %d transactions: %d updated, %d failed\n", processed, updated, failed)
-
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "All pending transactions processed",
-		"processed": processed,
-		"updated":   updated,
-		"failed":    failed,
+		"message":   message,
+		"processed": stats.Processed,
+		"updated":   stats.Updated,
+		"failed":    stats.Failed,
 	})
 }
@@ -1,47 +1,45 @@
 package handlers
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
-	"salome-be/internal/config"
+	"salome-be/internal/ledger"
 	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/orderref"
+	"salome-be/internal/pricing"
+	"salome-be/internal/service"
+	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type PaymentHandler struct {
-	db *sql.DB
-}
-
-// formatCurrency formats amount to Indonesian Rupiah format
-func formatCurrency(amount int64) string {
-	amountStr := strconv.FormatInt(amount, 10)
-	if len(amountStr) <= 3 {
-		return "Rp " + amountStr
-	}
-
-	// Add thousand separators
-	result := ""
-	for i, digit := range amountStr {
-		if i > 0 && (len(amountStr)-i)%3 == 0 {
-			result += "."
-		}
-		result += string(digit)
-	}
-	return "Rp " + result
+	db            *sql.DB
+	gateway       service.PaymentGateway
+	verifier      *services.WebhookVerifier
+	processor     *services.WebhookProcessor
+	groupPayments *services.GroupPaymentService
+	renewals      *services.RenewalService
+	refunds       *services.RefundService
 }
 
 func NewPaymentHandler(db *sql.DB) *PaymentHandler {
-	return &PaymentHandler{db: db}
+	return &PaymentHandler{
+		db:            db,
+		gateway:       service.NewPaymentGateway(),
+		verifier:      services.NewWebhookVerifier(db),
+		processor:     services.NewWebhookProcessor(db),
+		groupPayments: services.NewGroupPaymentService(db),
+		renewals:      services.NewRenewalService(db),
+		refunds:       services.NewRefundService(db),
+	}
 }
 
 func (h *PaymentHandler) CreatePayment(c *gin.Context) {
@@ -125,21 +123,31 @@ func (h *PaymentHandler) CreateGroupPaymentLink(c *gin.Context) {
 	}
 
 	var req struct {
-		GroupID     *string `json:"group_id"` // Optional for top-up
-		Amount      float64 `json:"amount" binding:"required,min=0"`
-		Description string  `json:"description"` // Optional custom description
+		GroupID       *string      `json:"group_id"` // Optional for top-up
+		Amount        money.Amount `json:"amount" binding:"required,min=0"`
+		Description   string       `json:"description"`    // Optional custom description
+		PromoCode     string       `json:"promo_code"`     // Optional, group payments only
+		PaymentMethod string       `json:"payment_method"` // Optional, group payments only; restricts the link to one method
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	amount := req.Amount
+	isGroupPayment := req.GroupID != nil && *req.GroupID != ""
+	if req.PromoCode != "" && !isGroupPayment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Promo codes only apply to group payments"})
+		return
+	}
+
 	var groupName, userName, userEmail string
 	var transactionType string
 	var description string
+	var price *services.SeatPrice
 
 	// Check if this is a group payment or top-up
-	if req.GroupID != nil && *req.GroupID != "" {
+	if isGroupPayment {
 		// Group payment - check if user is member of the group
 		var isMember bool
 		err := h.db.QueryRow(`
@@ -169,6 +177,25 @@ func (h *PaymentHandler) CreateGroupPaymentLink(c *gin.Context) {
 		if description == "" {
 			description = fmt.Sprintf("Pembayaran grup %s, order_id: %s", groupName, "")
 		}
+
+		// The seat is always charged at the server-side price, with the promo code and surcharge applied
+		price, err = h.groupPayments.QuoteSeatPrice(userID.(uuid.UUID).String(), *req.GroupID, req.PromoCode, req.PaymentMethod)
+		if err != nil {
+			if services.IsPromoError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "promo_code": req.PromoCode})
+				return
+			}
+			fmt.Printf("Error pricing group payment: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group price"})
+			return
+		}
+		amount = price.Total
+
+		// Refuse before a gateway link is created for a seat that is already paid or being paid
+		if err := h.groupPayments.CheckLinkPayment(userID.(uuid.UUID).String(), *req.GroupID); err != nil {
+			h.respondGroupPaymentError(c, err, 0, price)
+			return
+		}
 	} else {
 		// Top-up - get user details only
 		err := h.db.QueryRow(`
@@ -182,94 +209,74 @@ func (h *PaymentHandler) CreateGroupPaymentLink(c *gin.Context) {
 			return
 		}
 
+		// The gateway charges whole rupiah and the webhook must match the stored amount exactly
+		if amount != amount.Ceil() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Top-up amount must be in whole rupiah"})
+			return
+		}
+
 		transactionType = "top_up"
 		description = req.Description
 		if description == "" {
-			description = fmt.Sprintf("Top Up Saldo SALOME - Rp %s", formatCurrency(int64(req.Amount)))
+			description = fmt.Sprintf("Top Up Saldo SALOME - %s", amount.Format())
 		}
 	}
 
-	// Load Midtrans configuration
-	cfg := config.GetConfig()
-	midtransConf := cfg.Midtrans
-
 	// Generate order ID (max 36 characters for Midtrans)
-	// Generate 6-digit random ID
-	randomID := fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
-	var orderID string
-	if req.GroupID != nil && *req.GroupID != "" {
-		// Group payment
-		orderID = fmt.Sprintf("SALO-GRP-%s", randomID)
-	} else {
-		// Top-up
-		orderID = fmt.Sprintf("SALO-TOPUP-%s", randomID)
-	}
-	startTime := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-
-	// Prepare payment request body
-	body := map[string]interface{}{
-		"transaction_details": map[string]interface{}{
-			"order_id":     orderID,
-			"gross_amount": int64(req.Amount),
-		},
-		"item_details": []map[string]interface{}{
-			{
-				"id":       fmt.Sprintf("item-%s", orderID),
-				"name":     description,
-				"price":    int64(req.Amount),
-				"quantity": 1,
-			},
-		},
-		"customer_details": map[string]interface{}{
-			"first_name": userName,
-			"last_name":  "User",
-			"email":      userEmail,
-			"phone":      "081234567890",
-		},
-		"expiry": map[string]interface{}{
-			"start_time": startTime,
-			"duration":   24, // 24 hours
-			"unit":       "hours",
-		},
-	}
-
-	// Make request to Midtrans
-	jsonBody, _ := json.Marshal(body)
-	url := midtransConf.BaseURL + "/v1/payment-links"
-	reqHttp, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	reqHttp.Header.Set("Content-Type", "application/json")
-	auth := base64.StdEncoding.EncodeToString([]byte(midtransConf.ServerKey + ":"))
-	reqHttp.Header.Set("Authorization", "Basic "+auth)
-
-	client := &http.Client{}
-	resp, err := client.Do(reqHttp)
+	orderID := orderref.New(orderref.CodeTopUp)
+	if isGroupPayment {
+		orderID = orderref.New(orderref.CodeGroupPayment)
+	}
+
+	paymentMethod := ""
+	if isGroupPayment {
+		paymentMethod = req.PaymentMethod
+	}
+	link, err := h.createPaymentLink(orderID, amount, paymentMethod, description, userName, userEmail)
 	if err != nil {
+		if detail := paymentLinkErrorDetail(link); detail != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":  "Payment gateway error",
+				"detail": detail,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment link: " + err.Error()})
 		return
 	}
-	defer resp.Body.Close()
 
-	var respData map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&respData)
+	// A seat is recorded with its price breakdown and promo redemption
+	if price != nil {
+		transactionID, err := h.groupPayments.StartLinkPayment(userID.(uuid.UUID).String(), *req.GroupID, price, services.PaymentLinkDetails{
+			OrderID:       orderID,
+			PaymentLinkID: link.PaymentLinkID,
+			PaymentMethod: req.PaymentMethod,
+			Description:   description,
+		})
+		if err != nil {
+			if services.IsPromoError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "promo_code": req.PromoCode})
+				return
+			}
+			if errors.Is(err, services.ErrNotAwaitingPayment) || errors.Is(err, services.ErrPaymentInProgress) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			fmt.Printf("Error recording group payment link: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return
+		}
 
-	if resp.StatusCode != 201 && resp.StatusCode != 200 {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Midtrans error",
-			"detail": respData,
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"payment_url":    link.PaymentURL,
+			"order_id":       orderID,
+			"transaction_id": transactionID,
+			"price":          price,
 		})
 		return
 	}
 
-	// Extract payment link ID from payment URL
-	paymentURL, _ := respData["payment_url"].(string)
-	paymentLinkID := ""
-	if paymentURL != "" {
-		parts := strings.Split(paymentURL, "/")
-		if len(parts) > 0 {
-			paymentLinkID = parts[len(parts)-1]
-		}
-	}
-
 	// Create transaction record
 	transactionID := uuid.New()
 	var groupID *uuid.UUID
@@ -283,7 +290,7 @@ func (h *PaymentHandler) CreateGroupPaymentLink(c *gin.Context) {
 	_, err = h.db.Exec(`
 		INSERT INTO transactions (id, user_id, group_id, type, amount, balance_before, balance_after, description, payment_reference, payment_link_id, status, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
-	`, transactionID, userID.(uuid.UUID), groupID, transactionType, req.Amount, 0, 0, description, orderID, paymentLinkID, "pending")
+	`, transactionID, userID.(uuid.UUID), groupID, transactionType, amount, 0, 0, description, orderID, link.PaymentLinkID, "pending")
 
 	if err != nil {
 		fmt.Printf("Warning: Failed to create transaction record: %v\n", err)
@@ -291,105 +298,390 @@ func (h *PaymentHandler) CreateGroupPaymentLink(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":        true,
-		"payment_url":    respData["payment_url"],
+		"payment_url":    link.PaymentURL,
 		"order_id":       orderID,
 		"transaction_id": transactionID.String(),
 	})
 }
 
-func (h *PaymentHandler) HandlePaymentNotification(c *gin.Context) {
-	var notification map[string]interface{}
-	if err := c.ShouldBindJSON(&notification); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification format"})
+// PayGroupWithBalance pays for a group seat from the wallet balance. When the balance does not cover
+// the seat price and allow_mixed is set, the balance covers part of it and a payment link covers the rest.
+func (h *PaymentHandler) PayGroupWithBalance(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Verify signature (implement proper signature verification)
-	orderID, ok := notification["order_id"].(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+	var req struct {
+		GroupID       string        `json:"group_id" binding:"required"`
+		AllowMixed    bool          `json:"allow_mixed"`
+		BalanceAmount *money.Amount `json:"balance_amount"` // Optional, defaults to as much balance as possible
+		PromoCode     string        `json:"promo_code"`     // Optional
+		PaymentMethod string        `json:"payment_method"` // Optional, payment method of the link in a mixed payment
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := uuid.Parse(req.GroupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
 		return
 	}
 
-	transactionStatus, ok := notification["transaction_status"].(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction status"})
+	price, err := h.groupPayments.QuoteSeatPrice(userID.(uuid.UUID).String(), req.GroupID, req.PromoCode, pricing.MethodWallet)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if services.IsPromoError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "promo_code": req.PromoCode})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group price"})
+		return
+	}
+
+	var userName, userEmail string
+	var balance money.Amount
+	err = h.db.QueryRow(`
+		SELECT full_name, email, COALESCE(balance, 0) FROM users WHERE id = $1
+	`, userID.(uuid.UUID)).Scan(&userName, &userEmail, &balance)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user details"})
+		return
+	}
+
+	useBalance := money.Min(balance, price.Total)
+	if req.BalanceAmount != nil {
+		useBalance = money.Min(*req.BalanceAmount, price.Total)
+	}
+
+	// Balance covers the whole seat
+	if useBalance >= price.Total {
+		result, err := h.groupPayments.PayWithBalance(userID.(uuid.UUID).String(), req.GroupID, req.PromoCode)
+		if err != nil {
+			h.respondGroupPaymentError(c, err, balance, price)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":     true,
+			"message":     "Group payment completed with balance",
+			"price":       price,
+			"wallet":      result,
+			"payment_url": nil,
+		})
+		return
+	}
+
+	if !req.AllowMixed {
+		h.respondGroupPaymentError(c, ledger.ErrInsufficientFunds, balance, price)
+		return
+	}
+
+	// Payment links are charged in whole rupiah, the balance takes the fraction
+	linkAmount := (price.Total - useBalance).Ceil()
+	useBalance = price.Total - linkAmount
+	if useBalance <= 0 {
+		h.respondGroupPaymentError(c, services.ErrNoBalanceForPayment, balance, price)
 		return
 	}
 
-	// Update payment status
-	var status string
-	switch transactionStatus {
-	case "capture", "settlement":
-		status = "completed"
-	case "cancel", "deny", "expire":
-		status = "failed"
-	case "pending":
-		status = "pending"
+	// Mixed payment: create the link for the remainder first, then debit the wallet
+	orderID := orderref.New(orderref.CodeGroupPayment)
+	description := fmt.Sprintf("Pembayaran grup %s (sisa setelah saldo), order_id: %s", price.GroupName, orderID)
+
+	// The link pays the surcharge of its payment method on top of the remainder
+	surcharge := h.groupPayments.LinkSurcharge(req.PaymentMethod, linkAmount)
+	link, err := h.createPaymentLink(orderID, linkAmount+surcharge, req.PaymentMethod, description, userName, userEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Failed to create payment link",
+			"detail": paymentLinkErrorDetail(link),
+		})
+		return
+	}
+
+	result, linkTransactionID, err := h.groupPayments.StartMixedPayment(userID.(uuid.UUID).String(), req.GroupID, req.PromoCode, useBalance, services.PaymentLinkDetails{
+		OrderID:       orderID,
+		PaymentLinkID: link.PaymentLinkID,
+		PaymentMethod: req.PaymentMethod,
+		Description:   description,
+	})
+	if err != nil {
+		h.respondGroupPaymentError(c, err, balance, price)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "Balance applied, pay the remaining amount with the payment link",
+		"price":          price,
+		"wallet":         result,
+		"payment_url":    link.PaymentURL,
+		"order_id":       orderID,
+		"link_amount":    linkAmount + surcharge,
+		"surcharge":      surcharge,
+		"transaction_id": linkTransactionID,
+	})
+}
+
+// respondGroupPaymentError maps wallet payment errors to HTTP responses
+func (h *PaymentHandler) respondGroupPaymentError(c *gin.Context, err error, balance money.Amount, price *services.SeatPrice) {
+	switch {
+	case errors.Is(err, ledger.ErrInsufficientFunds), errors.Is(err, services.ErrNoBalanceForPayment):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Insufficient balance",
+			"balance": balance,
+			"price":   price,
+		})
+	case errors.Is(err, services.ErrInvalidBalanceAmount), services.IsPromoError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotGroupMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is not a member of this group"})
+	case errors.Is(err, services.ErrNotAwaitingPayment), errors.Is(err, services.ErrPaymentInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 	default:
-		status = "failed"
+		fmt.Printf("Error paying group with balance: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
 	}
+}
 
-	// Get transaction details first
-	var transactionType string
-	var userID string
-	var amount int
-	var groupID *string
+// GetRenewalInvoices returns the user's renewal invoices, optionally filtered with ?status=open|paid|cancelled
+func (h *PaymentHandler) GetRenewalInvoices(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-	err := h.db.QueryRow(`
-		SELECT transaction_type, user_id, amount, group_id
-		FROM transactions 
-		WHERE payment_reference = $1
-	`, orderID).Scan(&transactionType, &userID, &amount, &groupID)
+	invoices, err := h.renewals.ListUserInvoices(userID.(uuid.UUID).String(), c.Query("status"))
+	if err != nil {
+		fmt.Printf("Error listing renewal invoices: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch renewal invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invoices,
+	})
+}
 
+// GetRefunds lists the refunds of the current user
+func (h *PaymentHandler) GetRefunds(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	refunds, err := h.refunds.ListUserRefunds(userID.(uuid.UUID).String())
 	if err != nil {
-		fmt.Printf("Warning: Failed to get transaction details: %v\n", err)
-		c.JSON(http.StatusOK, gin.H{"message": "Transaction not found"})
+		fmt.Printf("Error listing refunds: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
 		return
 	}
 
-	// Update transaction status
-	_, err = h.db.Exec(`
-		UPDATE transactions 
-		SET status = $1, updated_at = $2 
-		WHERE payment_reference = $3
-	`, status, time.Now(), orderID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    refunds,
+	})
+}
+
+// PayRenewalInvoice pays an open renewal invoice from the wallet balance or with a new payment link
+func (h *PaymentHandler) PayRenewalInvoice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
+	invoiceID := c.Param("id")
+	if _, err := uuid.Parse(invoiceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var req models.RenewalPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoice, err := h.renewals.GetUserInvoice(userID.(uuid.UUID).String(), invoiceID)
 	if err != nil {
-		fmt.Printf("Warning: Failed to update transaction status: %v\n", err)
-	}
-
-	// Handle different transaction types
-	if status == "completed" {
-		if transactionType == "top_up" {
-			// Update user balance for top-up
-			_, err = h.db.Exec(`
-				UPDATE users 
-				SET balance = balance + $1, updated_at = $2
-				WHERE id = $3
-			`, amount, time.Now(), userID)
-
-			if err != nil {
-				fmt.Printf("Warning: Failed to update user balance: %v\n", err)
-			} else {
-				fmt.Printf("✅ User balance updated: +%d for user %s\n", amount, userID)
-			}
-		} else if transactionType == "group_payment" && groupID != nil {
-			// Update group_members user_status for group payments
-			_, err = h.db.Exec(`
-				UPDATE group_members 
-				SET user_status = 'paid', paid_at = $1, updated_at = $2
-				WHERE group_id = $3 AND user_id = $4
-			`, time.Now(), time.Now(), *groupID, userID)
-
-			if err != nil {
-				fmt.Printf("Warning: Failed to update group member status: %v\n", err)
-			} else {
-				fmt.Printf("✅ Group member status updated for user %s in group %s\n", userID, *groupID)
-			}
+		h.respondRenewalError(c, err)
+		return
+	}
+	if invoice.Status != models.RenewalInvoiceOpen {
+		h.respondRenewalError(c, services.ErrInvoiceNotOpen)
+		return
+	}
+
+	if req.Method == "balance" {
+		result, err := h.renewals.PayWithBalance(userID.(uuid.UUID).String(), invoiceID)
+		if err != nil {
+			h.respondRenewalError(c, err)
+			return
 		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Renewal paid with balance",
+			"invoice": invoice,
+			"wallet":  result,
+		})
+		return
 	}
 
+	var userName, userEmail string
+	err = h.db.QueryRow(`SELECT full_name, email FROM users WHERE id = $1`, userID.(uuid.UUID)).Scan(&userName, &userEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user details"})
+		return
+	}
+
+	orderID := orderref.New(orderref.CodeRenewal)
+	description := fmt.Sprintf("Perpanjangan grup %s, order_id: %s", invoice.GroupName, orderID)
+
+	// StartLinkPayment stores the invoice amount rounded up to the whole rupiah the link charges
+	link, err := h.createPaymentLink(orderID, invoice.Amount.Ceil(), "", description, userName, userEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Failed to create payment link",
+			"detail": paymentLinkErrorDetail(link),
+		})
+		return
+	}
+
+	transactionID, err := h.renewals.StartLinkPayment(userID.(uuid.UUID).String(), invoiceID, services.PaymentLinkDetails{
+		OrderID:       orderID,
+		PaymentLinkID: link.PaymentLinkID,
+		Description:   description,
+	})
+	if err != nil {
+		h.respondRenewalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "Pay the renewal with the payment link",
+		"invoice":        invoice,
+		"payment_url":    link.PaymentURL,
+		"order_id":       orderID,
+		"transaction_id": transactionID,
+	})
+}
+
+// respondRenewalError maps renewal payment errors to HTTP responses
+func (h *PaymentHandler) respondRenewalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Renewal invoice not found"})
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+	case errors.Is(err, services.ErrInvoiceNotOpen), errors.Is(err, services.ErrRenewalClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGroupFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "Your seat in the group has been taken"})
+	default:
+		fmt.Printf("Error paying renewal invoice: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process renewal payment"})
+	}
+}
+
+// createPaymentLink creates a 24 hour payment link with the configured payment gateway, limited to
+// paymentMethod when one is given
+func (h *PaymentHandler) createPaymentLink(orderID string, amount money.Amount, paymentMethod, description, userName, userEmail string) (*service.PaymentLink, error) {
+	req := service.PaymentLinkRequest{
+		OrderID:       orderID,
+		Amount:        money.Rupiah(amount),
+		Description:   description,
+		CustomerName:  userName,
+		CustomerEmail: userEmail,
+		ExpiryHours:   models.PaymentLinkExpiryHours,
+	}
+	if paymentMethod != "" && paymentMethod != pricing.DefaultMethod {
+		req.EnabledPayments = []string{paymentMethod}
+	}
+	return h.gateway.CreatePaymentLink(req)
+}
+
+// paymentLinkErrorDetail returns the gateway response of a failed payment link request, if any
+func paymentLinkErrorDetail(link *service.PaymentLink) interface{} {
+	if link == nil || link.Raw == nil {
+		return nil
+	}
+	return link.Raw
+}
+
+func (h *PaymentHandler) HandlePaymentNotification(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification format"})
+		return
+	}
+
+	status, response := h.processNotification(body, c.ClientIP())
+	c.JSON(status, response)
+}
+
+// processNotification verifies a payment notification from the gateway and applies it exactly once
+func (h *PaymentHandler) processNotification(body []byte, remoteIP string) (int, gin.H) {
+	notification, err := h.gateway.ParseWebhook(body)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	}
+	orderID := notification.OrderID
+
+	// Verify signature before touching any transaction
+	verified := services.WebhookNotification{
+		Provider:          h.gateway.Name(),
+		OrderID:           orderID,
+		TransactionID:     notification.TransactionID,
+		TransactionStatus: notification.TransactionStatus,
+		PaymentType:       notification.PaymentType,
+		StatusCode:        notification.StatusCode,
+		GrossAmount:       notification.GrossAmount,
+		SignatureKey:      notification.SignatureKey,
+		RemoteIP:          remoteIP,
+		Payload:           body,
+	}
+	if err := h.verifier.VerifySignature(verified); err != nil {
+		return http.StatusUnauthorized, gin.H{"error": "Invalid signature"}
+	}
+
+	// Payment link purchases report "<order ID>-<timestamp>", map it back to our reference
+	paymentReference := orderref.Resolve(orderID)
+
+	// Make sure the transaction exists before storing the event
+	var exists bool
+	err = h.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM transactions WHERE payment_reference = $1)
+	`, paymentReference).Scan(&exists)
+
+	if err != nil || !exists {
+		fmt.Printf("Warning: Failed to get transaction details: %v\n", err)
+		return http.StatusOK, gin.H{"message": "Transaction not found"}
+	}
+
+	// Cross-check gross_amount against the stored transaction amount
+	if err := h.verifier.VerifyAmount(verified, paymentReference); err != nil {
+		fmt.Printf("Warning: Amount verification failed for %s: %v\n", orderID, err)
+		return http.StatusBadRequest, gin.H{"error": "Gross amount does not match transaction"}
+	}
+
+	// Store the event in the inbox and apply it exactly once
+	result, err := h.processor.Receive(verified, paymentReference)
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process notification"}
+	}
+	status := result.Status
+
 	// Also update payments table if exists
 	_, err = h.db.Exec(`
 		UPDATE payments 
@@ -401,7 +693,57 @@ func (h *PaymentHandler) HandlePaymentNotification(c *gin.Context) {
 		fmt.Printf("Warning: Failed to update payment status: %v\n", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Payment status updated"})
+	return http.StatusOK, gin.H{"message": "Payment status updated", "duplicate": result.Duplicate}
+}
+
+// GetFakePayment is the payment page of the fake gateway: it shows the state of a fake payment link
+func (h *PaymentHandler) GetFakePayment(c *gin.Context) {
+	fake, ok := h.gateway.(*service.FakeGateway)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fake payment gateway is not enabled"})
+		return
+	}
+
+	link, err := fake.GetPaymentLink(c.Param("order_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment link not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": link})
+}
+
+// SimulateFakePayment settles, expires or denies a fake payment link and delivers the resulting
+// notification through the same path as a real webhook
+func (h *PaymentHandler) SimulateFakePayment(c *gin.Context) {
+	fake, ok := h.gateway.(*service.FakeGateway)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fake payment gateway is not enabled"})
+		return
+	}
+
+	notification, err := fake.Simulate(c.Param("order_id"), c.Param("outcome"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPaymentLinkNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment link not found"})
+		case errors.Is(err, service.ErrInvalidFakeOutcome):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode notification"})
+		return
+	}
+
+	status, response := h.processNotification(body, c.ClientIP())
+	response["notification"] = notification
+	c.JSON(status, response)
 }
 
 func (h *PaymentHandler) GetUserPayments(c *gin.Context) {
@@ -6,20 +6,26 @@ import (
 	"net/http"
 	"time"
 
+	"salome-be/internal/money"
 	"salome-be/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PaymentLinkHandler struct {
-	db              *sql.DB
-	midtransService *service.MidtransService
+	db      *sql.DB
+	gateway service.PaymentGateway
+}
+
+// paymentLinkInspector is implemented by gateways that expose the raw state of a Midtrans payment link
+type paymentLinkInspector interface {
+	GetPaymentLinkStatus(paymentLinkID string) (*service.MidtransPaymentLinkResponse, error)
 }
 
 func NewPaymentLinkHandler(db *sql.DB) *PaymentLinkHandler {
 	return &PaymentLinkHandler{
-		db:              db,
-		midtransService: service.NewMidtransService(),
+		db:      db,
+		gateway: service.NewPaymentGateway(),
 	}
 }
 
@@ -28,23 +34,23 @@ type CheckPaymentLinkRequest struct {
 }
 
 type CheckPaymentLinkResponse struct {
-	PaymentLinkID    string `json:"payment_link_id"`
-	OrderID          string `json:"order_id"`
-	Status           string `json:"status"`
-	Amount           int    `json:"amount"`
-	Currency         string `json:"currency"`
-	ExpiryTime       string `json:"expiry_time"`
-	PaymentURL       string `json:"payment_url"`
-	IsExpired        bool   `json:"is_expired"`
-	IsPaid           bool   `json:"is_paid"`
-	IsPending        bool   `json:"is_pending"`
-	TransactionCount int    `json:"transaction_count"`
+	PaymentLinkID    string       `json:"payment_link_id"`
+	OrderID          string       `json:"order_id"`
+	Status           string       `json:"status"`
+	Amount           money.Amount `json:"amount"`
+	Currency         string       `json:"currency"`
+	ExpiryTime       string       `json:"expiry_time"`
+	PaymentURL       string       `json:"payment_url"`
+	IsExpired        bool         `json:"is_expired"`
+	IsPaid           bool         `json:"is_paid"`
+	IsPending        bool         `json:"is_pending"`
+	TransactionCount int          `json:"transaction_count"`
 	LastTransaction  *struct {
-		TransactionID string `json:"transaction_id"`
-		Status        string `json:"status"`
-		Method        string `json:"method"`
-		Amount        int    `json:"amount"`
-		CreatedAt     string `json:"created_at"`
+		TransactionID string       `json:"transaction_id"`
+		Status        string       `json:"status"`
+		Method        string       `json:"method"`
+		Amount        money.Amount `json:"amount"`
+		CreatedAt     string       `json:"created_at"`
 	} `json:"last_transaction,omitempty"`
 }
 
@@ -61,8 +67,16 @@ func (h *PaymentLinkHandler) CheckPaymentLinkStatus(c *gin.Context) {
 
 	fmt.Printf("🔍 [PAYMENT LINK DEBUG] Checking payment link status for ID: %s\n", req.PaymentLinkID)
 
+	inspector, ok := h.gateway.(paymentLinkInspector)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error": fmt.Sprintf("Payment link inspection is not supported by the %s gateway", h.gateway.Name()),
+		})
+		return
+	}
+
 	// Cek status payment link di Midtrans
-	midtransStatus, err := h.midtransService.GetPaymentLinkStatus(req.PaymentLinkID)
+	midtransStatus, err := inspector.GetPaymentLinkStatus(req.PaymentLinkID)
 	if err != nil {
 		fmt.Printf("❌ [PAYMENT LINK DEBUG] Midtrans API failed: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -79,7 +93,7 @@ func (h *PaymentLinkHandler) CheckPaymentLinkStatus(c *gin.Context) {
 	var dbStatus string
 	var userID string
 	var groupID string
-	var amount int
+	var amount money.Amount
 
 	err = h.db.QueryRow(`
 		SELECT id, status, user_id, group_id, amount 
@@ -138,10 +152,10 @@ func (h *PaymentLinkHandler) CheckPaymentLinkStatus(c *gin.Context) {
 		PaymentLinkID:    req.PaymentLinkID,
 		OrderID:          midtransStatus.OrderID,
 		Status:           status,
-		Amount:           midtransStatus.GrossAmount,
+		Amount:           money.Whole(int64(midtransStatus.GrossAmount)),
 		Currency:         midtransStatus.Currency,
 		ExpiryTime:       midtransStatus.ExpiryTime,
-		PaymentURL:       fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", req.PaymentLinkID),
+		PaymentURL:       h.gateway.PaymentLinkURL(req.PaymentLinkID),
 		IsExpired:        isExpired,
 		IsPaid:           isPaid,
 		IsPending:        isPending,
@@ -152,16 +166,16 @@ func (h *PaymentLinkHandler) CheckPaymentLinkStatus(c *gin.Context) {
 	if len(midtransStatus.Purchases) > 0 {
 		lastPurchase := midtransStatus.Purchases[len(midtransStatus.Purchases)-1]
 		response.LastTransaction = &struct {
-			TransactionID string `json:"transaction_id"`
-			Status        string `json:"status"`
-			Method        string `json:"method"`
-			Amount        int    `json:"amount"`
-			CreatedAt     string `json:"created_at"`
+			TransactionID string       `json:"transaction_id"`
+			Status        string       `json:"status"`
+			Method        string       `json:"method"`
+			Amount        money.Amount `json:"amount"`
+			CreatedAt     string       `json:"created_at"`
 		}{
 			TransactionID: lastPurchase.TransactionID,
 			Status:        lastPurchase.PaymentStatus,
 			Method:        lastPurchase.PaymentMethod,
-			Amount:        lastPurchase.AmountValue,
+			Amount:        money.Whole(int64(lastPurchase.AmountValue)),
 			CreatedAt:     lastPurchase.CreatedAt,
 		}
 	}
@@ -194,7 +208,7 @@ func (h *PaymentLinkHandler) GetPaymentLinkInfo(c *gin.Context) {
 	var dbStatus string
 	var userID string
 	var groupID string
-	var amount int
+	var amount money.Amount
 	var createdAt time.Time
 
 	err := h.db.QueryRow(`
@@ -222,7 +236,7 @@ func (h *PaymentLinkHandler) GetPaymentLinkInfo(c *gin.Context) {
 		"user_id":         userID,
 		"group_id":        groupID,
 		"created_at":      createdAt.Format(time.RFC3339),
-		"payment_url":     fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", paymentLinkID),
+		"payment_url":     h.gateway.PaymentLinkURL(paymentLinkID),
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -261,7 +275,7 @@ func (h *PaymentLinkHandler) ListUserPaymentLinks(c *gin.Context) {
 	var paymentLinks []gin.H
 	for rows.Next() {
 		var transactionID, paymentReference, status, groupID string
-		var amount int
+		var amount money.Amount
 		var createdAt, updatedAt time.Time
 
 		err := rows.Scan(&transactionID, &paymentReference, &status, &amount, &groupID, &createdAt, &updatedAt)
@@ -278,7 +292,7 @@ func (h *PaymentLinkHandler) ListUserPaymentLinks(c *gin.Context) {
 			"group_id":        groupID,
 			"created_at":      createdAt.Format(time.RFC3339),
 			"updated_at":      updatedAt.Format(time.RFC3339),
-			"payment_url":     fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", paymentReference),
+			"payment_url":     h.gateway.PaymentLinkURL(paymentReference),
 		})
 	}
 
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"salome-be/internal/config"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PayoutHandler struct {
+	db      *sql.DB
+	payouts *services.PayoutService
+}
+
+func NewPayoutHandler(db *sql.DB) *PayoutHandler {
+	return &PayoutHandler{
+		db:      db,
+		payouts: services.NewPayoutService(db),
+	}
+}
+
+// GetPayoutAccounts lists the bank accounts of the current user
+func (h *PayoutHandler) GetPayoutAccounts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	accounts, err := h.payouts.ListAccounts(userID.(uuid.UUID).String())
+	if err != nil {
+		fmt.Printf("Error listing payout accounts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    accounts,
+	})
+}
+
+// CreatePayoutAccount adds a bank account for withdrawals
+func (h *PayoutHandler) CreatePayoutAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.PayoutAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.payouts.AddAccount(userID.(uuid.UUID).String(), req)
+	if err != nil {
+		fmt.Printf("Error creating payout account: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payout account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    account,
+	})
+}
+
+// DeletePayoutAccount removes a bank account
+func (h *PayoutHandler) DeletePayoutAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	accountID := c.Param("id")
+	if _, err := uuid.Parse(accountID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	err := h.payouts.DeleteAccount(userID.(uuid.UUID).String(), accountID)
+	if errors.Is(err, services.ErrPayoutAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout account not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error deleting payout account: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payout account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Payout account deleted"})
+}
+
+// GetPayoutBalance shows what the current user can withdraw: wallet balance and earnings of owned groups
+func (h *PayoutHandler) GetPayoutBalance(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	balance, err := h.payouts.Balance(userID.(uuid.UUID).String())
+	if err != nil {
+		fmt.Printf("Error getting payout balance: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    balance,
+	})
+}
+
+// GetPayouts lists the withdrawal requests of the current user
+func (h *PayoutHandler) GetPayouts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	payouts, err := h.payouts.ListUserPayouts(userID.(uuid.UUID).String())
+	if err != nil {
+		fmt.Printf("Error listing payouts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    payouts,
+	})
+}
+
+// CreatePayout requests a withdrawal of wallet balance or, for owners, of a group's earnings
+func (h *PayoutHandler) CreatePayout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.PayoutCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := h.payouts.RequestPayout(userID.(uuid.UUID).String(), req)
+	switch {
+	case errors.Is(err, services.ErrPayoutBelowMinimum):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":          "Amount is below the minimum payout",
+			"minimum_amount": config.GetConfig().Payout.MinimumAmount,
+		})
+		return
+	case errors.Is(err, services.ErrPayoutFeeTooHigh):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount does not cover the payout fee"})
+		return
+	case errors.Is(err, services.ErrPayoutAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout account not found"})
+		return
+	case errors.Is(err, services.ErrNotGroupOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can withdraw group earnings"})
+		return
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+		return
+	case errors.Is(err, services.ErrPayoutNotWithdrawable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only balance from gateway top-ups and refunds can be withdrawn"})
+		return
+	case err != nil:
+		fmt.Printf("Error requesting payout: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request payout"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    payout,
+	})
+}
+
+// AdminGetPayouts lists payouts for review, e.g. ?status=requested, oldest first
+func (h *PayoutHandler) AdminGetPayouts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	payouts, total, err := h.payouts.ListPayouts(c.Query("status"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		fmt.Printf("Error listing payouts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payouts":   payouts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// AdminApprovePayout approves a requested payout
+func (h *PayoutHandler) AdminApprovePayout(c *gin.Context) {
+	var req models.PayoutReviewRequest
+	// The note is optional, an empty body is fine
+	_ = c.ShouldBindJSON(&req)
+
+	adminID, _ := c.Get("user_id")
+	payout, err := h.payouts.Approve(c.Param("id"), adminID.(uuid.UUID).String(), req.Note)
+	h.respondPayout(c, payout, err)
+}
+
+// AdminRejectPayout rejects a payout and returns the held money
+func (h *PayoutHandler) AdminRejectPayout(c *gin.Context) {
+	var req models.PayoutReviewRequest
+	_ = c.ShouldBindJSON(&req)
+
+	adminID, _ := c.Get("user_id")
+	payout, err := h.payouts.Reject(c.Param("id"), adminID.(uuid.UUID).String(), req.Note)
+	h.respondPayout(c, payout, err)
+}
+
+// AdminMarkPayoutPaid records the bank transfer of an approved payout
+func (h *PayoutHandler) AdminMarkPayoutPaid(c *gin.Context) {
+	var req models.PayoutPaidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	payout, err := h.payouts.MarkPaid(c.Param("id"), adminID.(uuid.UUID).String(), req.Reference, req.Note)
+	h.respondPayout(c, payout, err)
+}
+
+func (h *PayoutHandler) respondPayout(c *gin.Context, payout *models.Payout, err error) {
+	switch {
+	case errors.Is(err, services.ErrPayoutNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+	case errors.Is(err, services.ErrPayoutInvalidStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payout cannot be changed in its current status"})
+	case err != nil:
+		fmt.Printf("Error updating payout %s: %v\n", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"payout": payout})
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PromoHandler struct {
+	db            *sql.DB
+	promos        *services.PromoService
+	groupPayments *services.GroupPaymentService
+}
+
+func NewPromoHandler(db *sql.DB) *PromoHandler {
+	return &PromoHandler{
+		db:            db,
+		promos:        services.NewPromoService(db),
+		groupPayments: services.NewGroupPaymentService(db),
+	}
+}
+
+// ValidatePromoCode shows the seat price of a group with a promo code applied, without redeeming it
+func (h *PromoHandler) ValidatePromoCode(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.PromoValidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	price, err := h.groupPayments.QuoteSeatPrice(userID.(uuid.UUID).String(), req.GroupID, req.Code, "")
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	case services.IsPromoError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "valid": false})
+		return
+	case err != nil:
+		fmt.Printf("Error validating promo code: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate promo code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"valid":   true,
+		"data":    price,
+	})
+}
+
+// AdminGetPromoCodes lists promo codes with their usage, ?active=true for active codes only
+func (h *PromoHandler) AdminGetPromoCodes(c *gin.Context) {
+	page, pageSize := promoPagination(c)
+
+	promos, total, err := h.promos.ListPromoCodes(c.Query("active") == "true", pageSize, (page-1)*pageSize)
+	if err != nil {
+		fmt.Printf("Error listing promo codes: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promo_codes": promos,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+	})
+}
+
+// AdminGetPromoCode returns one promo code with its usage
+func (h *PromoHandler) AdminGetPromoCode(c *gin.Context) {
+	promo, err := h.promos.GetPromoCode(c.Param("id"))
+	h.respondPromoCode(c, http.StatusOK, promo, err)
+}
+
+// AdminCreatePromoCode adds a promo code
+func (h *PromoHandler) AdminCreatePromoCode(c *gin.Context) {
+	var req models.PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	promo, err := h.promos.CreatePromoCode(req, adminID.(uuid.UUID).String())
+	h.respondPromoCode(c, http.StatusCreated, promo, err)
+}
+
+// AdminUpdatePromoCode replaces the settings of a promo code
+func (h *PromoHandler) AdminUpdatePromoCode(c *gin.Context) {
+	var req models.PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo, err := h.promos.UpdatePromoCode(c.Param("id"), req)
+	h.respondPromoCode(c, http.StatusOK, promo, err)
+}
+
+// AdminDeactivatePromoCode stops a promo code from being used
+func (h *PromoHandler) AdminDeactivatePromoCode(c *gin.Context) {
+	err := h.promos.DeactivatePromoCode(c.Param("id"))
+	if errors.Is(err, services.ErrPromoNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error deactivating promo code %s: %v\n", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate promo code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promo code deactivated"})
+}
+
+// AdminGetPromoRedemptions lists the uses of a promo code
+func (h *PromoHandler) AdminGetPromoRedemptions(c *gin.Context) {
+	page, pageSize := promoPagination(c)
+
+	redemptions, total, err := h.promos.ListRedemptions(c.Param("id"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		fmt.Printf("Error listing promo redemptions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo redemptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"redemptions": redemptions,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+	})
+}
+
+func (h *PromoHandler) respondPromoCode(c *gin.Context, status int, promo *models.PromoCode, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+	case errors.Is(err, services.ErrPromoCodeExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+	case errors.Is(err, services.ErrInvalidPromoCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		fmt.Printf("Error saving promo code: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save promo code"})
+	default:
+		c.JSON(status, gin.H{"promo_code": promo})
+	}
+}
+
+func promoPagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RateGroupUser rates the owner of the group, or a member when the current user owns it, for the last ended
+// billing period
+func (h *GroupHandler) RateGroupUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.RatingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rating, err := h.reputation.Rate(userID.(uuid.UUID).String(), groupID, req)
+	if err != nil {
+		h.respondRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Rating saved",
+		"data":    rating,
+	})
+}
+
+// GetGroupRatingTargets lists who the current user can rate in the group and the ratings already given
+func (h *GroupHandler) GetGroupRatingTargets(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	targets, err := h.reputation.Targets(userID.(uuid.UUID).String(), groupID)
+	if err != nil {
+		h.respondRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    targets,
+	})
+}
+
+// GetUserReputation shows the reputation of a user with the most recent ratings they received
+func (h *GroupHandler) GetUserReputation(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	reputation, err := h.reputation.Get(userID)
+	if err != nil {
+		h.respondRatingError(c, err)
+		return
+	}
+	ratings, err := h.reputation.Received(userID, limit)
+	if err != nil {
+		h.respondRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"reputation": reputation,
+			"ratings":    ratings,
+		},
+	})
+}
+
+func (h *GroupHandler) respondRatingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrRatingNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRatingSelf), errors.Is(err, services.ErrRatingNotEligible):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error handling rating: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process rating request"})
+	}
+}
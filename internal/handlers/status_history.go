@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"salome-be/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetGroupHistory lists the status transitions of a group and its members, newest first. Members, the
+// owner and admins can see it.
+func (h *GroupHandler) GetGroupHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var groupExists, canView bool
+	err := h.db.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM groups WHERE id = $1),
+			EXISTS(SELECT 1 FROM groups WHERE id = $1 AND owner_id = $2)
+			OR EXISTS(SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)
+			OR EXISTS(SELECT 1 FROM users WHERE id = $2 AND is_admin = true)
+	`, groupID, userID.(uuid.UUID)).Scan(&groupExists, &canView)
+	if err != nil {
+		fmt.Printf("Error checking group access: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group access"})
+		return
+	}
+	if !groupExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	page, pageSize := historyPage(c)
+	events, total, err := h.stateMachineSvc.GroupHistory(groupID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		fmt.Printf("Error getting group history: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      events,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetStatusEvents - Timeline of status transitions across groups, filtered by group, user, entity, actor,
+// correlation ID and time range (admin only)
+func (h *AdminHandler) GetStatusEvents(c *gin.Context) {
+	filter := models.StatusEventFilter{
+		GroupID:       c.Query("group_id"),
+		UserID:        c.Query("user_id"),
+		EntityType:    c.Query("entity"),
+		ActorType:     c.Query("actor_type"),
+		CorrelationID: c.Query("correlation_id"),
+	}
+	for _, id := range []string{filter.GroupID, filter.UserID} {
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id or user_id"})
+			return
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseTimelineTime(c.Query("since"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since, use RFC3339 or YYYY-MM-DD"})
+		return
+	}
+	if filter.Until, err = parseTimelineTime(c.Query("until"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until, use RFC3339 or YYYY-MM-DD"})
+		return
+	}
+
+	page, pageSize := historyPage(c)
+	events, total, err := h.stateMachine.StatusTimeline(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed to list status events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func historyPage(c *gin.Context) (page, pageSize int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	return page, pageSize
+}
+
+// parseTimelineTime accepts RFC3339 or a date. A date used as the end of a range includes that whole day.
+func parseTimelineTime(value string, endOfRange bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return nil, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -2,22 +2,30 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-	"time"
 
+	"salome-be/internal/invoice"
 	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type TransactionHandler struct {
-	db *sql.DB
+	db       *sql.DB
+	invoices *services.InvoiceService
 }
 
 func NewTransactionHandler(db *sql.DB) *TransactionHandler {
-	return &TransactionHandler{db: db}
+	return &TransactionHandler{
+		db:       db,
+		invoices: services.NewInvoiceService(db),
+	}
 }
 
 // GetUserTransactions retrieves transactions for a specific user
@@ -41,7 +49,7 @@ func (h *TransactionHandler) GetUserTransactions(c *gin.Context) {
 	offset := (page - 1) * pageSize
 
 	// Get user balance first
-	var userBalance float64
+	var userBalance money.Amount
 	balanceQuery := `SELECT balance FROM users WHERE id = $1`
 	err := h.db.QueryRow(balanceQuery, userID).Scan(&userBalance)
 	if err != nil {
@@ -52,11 +60,13 @@ func (h *TransactionHandler) GetUserTransactions(c *gin.Context) {
 	// Query transactions
 	query := `
 		SELECT 
-			id, user_id, group_id, type, amount, balance_before, balance_after,
-			description, payment_method, payment_reference, payment_link_id, status, created_at, updated_at
-		FROM transactions
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+			t.id, t.user_id, t.group_id, t.type, t.amount, t.balance_before, t.balance_after,
+			t.description, t.payment_method, t.payment_reference, t.payment_link_id, t.status, t.created_at, t.updated_at,
+			i.invoice_number
+		FROM transactions t
+		LEFT JOIN invoices i ON i.transaction_id = t.id
+		WHERE t.user_id = $1
+		ORDER BY t.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -74,7 +84,7 @@ func (h *TransactionHandler) GetUserTransactions(c *gin.Context) {
 			&txn.ID, &txn.UserID, &txn.GroupID, &txn.Type, &txn.Amount,
 			&txn.BalanceBefore, &txn.BalanceAfter, &txn.Description,
 			&txn.PaymentMethod, &txn.PaymentReference, &txn.PaymentLinkID, &txn.Status,
-			&txn.CreatedAt, &txn.UpdatedAt,
+			&txn.CreatedAt, &txn.UpdatedAt, &txn.InvoiceNumber,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan transaction"})
@@ -102,10 +112,9 @@ func (h *TransactionHandler) GetUserTransactions(c *gin.Context) {
 	})
 }
 
-// CreateTransaction creates a new transaction
+// CreateTransaction validates a manual transaction request and points the client to the flow that handles its type
 func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	if _, exists := c.Get("user_id"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
@@ -116,203 +125,67 @@ func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
 		return
 	}
 
-	// Get current user balance
-	var currentBalance float64
-	balanceQuery := `SELECT balance FROM users WHERE id = $1`
-	err := h.db.QueryRow(balanceQuery, userID).Scan(&currentBalance)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user balance"})
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than 0"})
 		return
 	}
 
-	// Calculate new balance based on transaction type
-	var newBalance float64
+	// Every balance movement has a dedicated flow that checks who may move the money and how much
 	switch req.Type {
-	case "top-up":
-		newBalance = currentBalance + req.Amount
-	case "group_payment", "withdrawal":
-		if currentBalance < req.Amount {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
-			return
-		}
-		newBalance = currentBalance - req.Amount
+	case "group_payment":
+		// Seat payments need a pending membership and are priced by the server
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group payments must be paid through /payments/group-wallet-payment"})
+	case "top-up", "top_up":
+		// The wallet is only credited once the payment gateway settles the top-up
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Top-ups must be paid through /payments/group-payment-link"})
 	case "refund":
-		newBalance = currentBalance + req.Amount
+		// Refunds are issued by the platform when a member leaves or is removed from a group
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refunds cannot be created manually"})
+	case "withdrawal":
+		// Withdrawals need a bank account and admin approval
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Withdrawals must be requested through /payouts"})
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction type"})
-		return
 	}
-
-	// Start transaction
-	tx, err := h.db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-		return
-	}
-	defer tx.Rollback()
-
-	// Insert transaction
-	insertQuery := `
-		INSERT INTO transactions (
-			user_id, group_id, type, amount, balance_before, balance_after,
-			description, payment_method, payment_reference, status, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id
-	`
-
-	var transactionID uuid.UUID
-	now := time.Now()
-	err = tx.QueryRow(
-		insertQuery, userID, req.GroupID, req.Type, req.Amount,
-		currentBalance, newBalance, req.Description, req.PaymentMethod,
-		req.PaymentReference, "completed", now, now,
-	).Scan(&transactionID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
-		return
-	}
-
-	// Update user balance
-	updateBalanceQuery := `UPDATE users SET balance = $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.Exec(updateBalanceQuery, newBalance, now, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user balance"})
-		return
-	}
-
-	// Update total_spent if it's a payment (only for successful transactions)
-	if req.Type == "group_payment" {
-		updateSpentQuery := `UPDATE users SET total_spent = total_spent + $1, updated_at = $2 WHERE id = $3`
-		_, err = tx.Exec(updateSpentQuery, req.Amount, now, userID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update total spent"})
-			return
-		}
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
-
-	response := models.TransactionResponse{
-		ID:               transactionID,
-		UserID:           userID.(uuid.UUID),
-		GroupID:          req.GroupID,
-		Type:             req.Type,
-		Amount:           req.Amount,
-		BalanceBefore:    currentBalance,
-		BalanceAfter:     newBalance,
-		Description:      req.Description,
-		PaymentMethod:    &req.PaymentMethod,
-		PaymentReference: &req.PaymentReference,
-		Status:           "completed",
-		CreatedAt:        now,
-		UpdatedAt:        now,
-	}
-
-	c.JSON(http.StatusCreated, response)
 }
 
-// TopUpBalance handles user balance top-up
-func (h *TransactionHandler) TopUpBalance(c *gin.Context) {
+// GetTransactionInvoice returns the invoice of a successful transaction as a PDF, or as JSON with ?format=json
+func (h *TransactionHandler) GetTransactionInvoice(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	var req struct {
-		Amount    float64 `json:"amount" binding:"required,min=1000"`
-		Method    string  `json:"method" binding:"required"`
-		Reference string  `json:"reference"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Create top-up transaction
-	transactionReq := models.TransactionCreateRequest{
-		Type:             "top-up",
-		Amount:           req.Amount,
-		Description:      "Balance top-up via " + req.Method,
-		PaymentMethod:    req.Method,
-		PaymentReference: req.Reference,
-	}
-
-	// Get current user balance
-	var currentBalance float64
-	balanceQuery := `SELECT balance FROM users WHERE id = $1`
-	err := h.db.QueryRow(balanceQuery, userID).Scan(&currentBalance)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user balance"})
+	transactionID := c.Param("id")
+	if _, err := uuid.Parse(transactionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
 		return
 	}
 
-	// Calculate new balance
-	newBalance := currentBalance + req.Amount
-
-	// Start transaction
-	tx, err := h.db.Begin()
+	inv, err := h.invoices.ForTransaction(userID.(uuid.UUID).String(), transactionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		switch {
+		case errors.Is(err, services.ErrTransactionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		case errors.Is(err, services.ErrInvoiceNotAvailable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invoice"})
+		}
 		return
 	}
-	defer tx.Rollback()
-
-	// Insert transaction
-	insertQuery := `
-		INSERT INTO transactions (
-			user_id, group_id, type, amount, balance_before, balance_after,
-			description, payment_method, payment_reference, status, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id
-	`
 
-	var transactionID uuid.UUID
-	now := time.Now()
-	err = tx.QueryRow(
-		insertQuery, userID, nil, transactionReq.Type, transactionReq.Amount,
-		currentBalance, newBalance, transactionReq.Description, transactionReq.PaymentMethod,
-		transactionReq.PaymentReference, "completed", now, now,
-	).Scan(&transactionID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": inv})
 		return
 	}
 
-	// Update user balance
-	updateBalanceQuery := `UPDATE users SET balance = $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.Exec(updateBalanceQuery, newBalance, now, userID)
+	pdf, err := h.invoices.Render(inv)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user balance"})
-		return
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
 		return
 	}
-
-	response := models.TransactionResponse{
-		ID:               transactionID,
-		UserID:           userID.(uuid.UUID),
-		GroupID:          nil,
-		Type:             transactionReq.Type,
-		Amount:           transactionReq.Amount,
-		BalanceBefore:    currentBalance,
-		BalanceAfter:     newBalance,
-		Description:      transactionReq.Description,
-		PaymentMethod:    &transactionReq.PaymentMethod,
-		PaymentReference: &transactionReq.PaymentReference,
-		Status:           "completed",
-		CreatedAt:        now,
-		UpdatedAt:        now,
-	}
-
-	c.JSON(http.StatusCreated, response)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, invoice.FileName(inv)))
+	c.Data(http.StatusOK, "application/pdf", pdf)
 }
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"salome-be/internal/models"
+	"salome-be/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -730,142 +732,25 @@ func (h *UserBroadcastHandler) SendUserBroadcast(c *gin.Context) {
 		return
 	}
 
-	// Check if broadcast exists and get details
-	var broadcast models.UserBroadcast
-	var targetType string
-	var status string
-	err = h.db.QueryRow(`
-		SELECT id, title, message, target_type, status, total_targets
-		FROM user_broadcast 
-		WHERE id = $1
-	`, broadcastID).Scan(
-		&broadcast.ID, &broadcast.Title, &broadcast.Message,
-		&targetType, &status, &broadcast.TotalTargets,
-	)
-
+	result, err := services.NewUserBroadcastService(h.db).Send(broadcastID.String())
 	if err != nil {
-		if err == sql.ErrNoRows {
+		switch {
+		case errors.Is(err, services.ErrBroadcastNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Broadcast not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch broadcast"})
-		return
-	}
-
-	// Check if broadcast can be sent
-	if status != "draft" && status != "scheduled" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Broadcast can only be sent if status is draft or scheduled"})
-		return
-	}
-
-	// Start transaction
-	tx, err := h.db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-		return
-	}
-	defer tx.Rollback()
-
-	// Update broadcast status to sent
-	_, err = tx.Exec(`
-		UPDATE user_broadcast 
-		SET status = 'sent', sent_at = $1, updated_at = $1
-		WHERE id = $2
-	`, time.Now(), broadcastID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update broadcast status"})
-		return
-	}
-
-	// Get target users
-	var userIDs []uuid.UUID
-	if targetType == "all" {
-		rows, err := tx.Query("SELECT id FROM users WHERE is_admin = false")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-			return
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var userID uuid.UUID
-			if err := rows.Scan(&userID); err != nil {
-				continue
-			}
-			userIDs = append(userIDs, userID)
-		}
-	} else {
-		// Get selected users from targets table
-		rows, err := tx.Query(`
-			SELECT user_id FROM user_broadcast_targets 
-			WHERE broadcast_id = $1
-		`, broadcastID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch target users"})
-			return
+		case errors.Is(err, services.ErrBroadcastNotSendable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Broadcast can only be sent if status is draft or scheduled"})
+		default:
+			fmt.Printf("Error sending broadcast: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send broadcast"})
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var userID uuid.UUID
-			if err := rows.Scan(&userID); err != nil {
-				continue
-			}
-			userIDs = append(userIDs, userID)
-		}
-	}
-
-	// Send broadcast to each user (simulate sending)
-	successCount := 0
-	errorCount := 0
-
-	for _, userID := range userIDs {
-		// Here you would implement actual sending logic (email, push notification, etc.)
-		// For now, we'll just simulate success
-
-		// Update target status if it's a selected broadcast
-		if targetType == "selected" {
-			_, err = tx.Exec(`
-				UPDATE user_broadcast_targets 
-				SET status = 'sent', sent_at = $1
-				WHERE broadcast_id = $2 AND user_id = $3
-			`, time.Now(), broadcastID, userID)
-			if err != nil {
-				errorCount++
-				continue
-			}
-		}
-
-		// Create notification or store in user's broadcast inbox
-		// This is where you would integrate with your notification system
-		// For now, we'll just count as success
-		successCount++
-	}
-
-	// Update broadcast with results
-	_, err = tx.Exec(`
-		UPDATE user_broadcast 
-		SET success_count = $1, error_count = $2, updated_at = $3
-		WHERE id = $4
-	`, successCount, errorCount, time.Now(), broadcastID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update broadcast results"})
-		return
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Broadcast sent successfully",
-		"success_count": successCount,
-		"error_count":   errorCount,
-		"total_targets": len(userIDs),
+		"success_count": result.SuccessCount,
+		"error_count":   result.ErrorCount,
+		"total_targets": result.TotalTargets,
 	})
 }
 
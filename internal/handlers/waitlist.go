@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"salome-be/internal/models"
+	"salome-be/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// JoinWaitlist queues the user for a seat in a full group. Groups that are not public need their invite code.
+func (h *GroupHandler) JoinWaitlist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groupID := c.Param("id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.WaitlistJoinRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	entry, err := h.waitlist.Join(userID.(uuid.UUID).String(), groupID, req.InviteCode)
+	if err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Joined the waitlist",
+		"data":    entry,
+	})
+}
+
+// GetMyWaitlistEntry shows the user's place on the waitlist of a group, or the seat offered to them
+func (h *GroupHandler) GetMyWaitlistEntry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entry, err := h.waitlist.Status(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
+
+// LeaveWaitlist takes the user off the waitlist of a group
+func (h *GroupHandler) LeaveWaitlist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.waitlist.Leave(userID.(uuid.UUID).String(), c.Param("id")); err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Left the waitlist",
+	})
+}
+
+// ClaimWaitlistSeat takes the seat offered to the user as a pending seat to pay for
+func (h *GroupHandler) ClaimWaitlistSeat(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reservation, err := h.waitlist.Claim(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Seat claimed, complete the payment before the deadline",
+		"data":    reservation,
+	})
+}
+
+// GetGroupWaitlist lists the waitlist of a group for its owner
+func (h *GroupHandler) GetGroupWaitlist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entries, err := h.waitlist.List(userID.(uuid.UUID).String(), c.Param("id"))
+	if err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// ReorderGroupWaitlist lets the owner change the order of the waiting users
+func (h *GroupHandler) ReorderGroupWaitlist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.WaitlistReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.waitlist.Reorder(userID.(uuid.UUID).String(), c.Param("id"), req.EntryIDs)
+	if err != nil {
+		h.respondWaitlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Waitlist reordered",
+		"data":    entries,
+	})
+}
+
+func (h *GroupHandler) respondWaitlistError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, services.ErrWaitlistNotFound), errors.Is(err, services.ErrWaitlistEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWaitlistOwnerOnly), errors.Is(err, services.ErrInviteCodeRequired),
+		errors.Is(err, services.ErrInviteExpired), errors.Is(err, services.ErrInviteRevoked),
+		errors.Is(err, services.ErrInviteExhausted), errors.Is(err, services.ErrInviteWrongEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyMember), errors.Is(err, services.ErrAlreadyWaitlisted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGroupNotJoinable), errors.Is(err, services.ErrGroupFull),
+		errors.Is(err, services.ErrWaitlistFull), errors.Is(err, services.ErrWaitlistSeatsAvailable),
+		errors.Is(err, services.ErrWaitlistNoOffer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error handling waitlist: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process waitlist request"})
+	}
+}
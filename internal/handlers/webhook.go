@@ -4,24 +4,37 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"salome-be/internal/models"
+	"salome-be/internal/orderref"
+	"salome-be/internal/services"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 )
 
 type WebhookHandler struct {
-	db *sql.DB
+	db        *sql.DB
+	verifier  *services.WebhookVerifier
+	processor *services.WebhookProcessor
 }
 
 func NewWebhookHandler(db *sql.DB) *WebhookHandler {
-	return &WebhookHandler{db: db}
+	return &WebhookHandler{
+		db:        db,
+		verifier:  services.NewWebhookVerifier(db),
+		processor: services.NewWebhookProcessor(db),
+	}
 }
 
 // WebhookRequest represents the webhook payload from Cloudfren Core
 type WebhookRequest struct {
 	OrderID           string `json:"order_id" binding:"required"`
 	TransactionStatus string `json:"transaction_status" binding:"required"`
+	StatusCode        string `json:"status_code"`
 	PaymentType       string `json:"payment_type"`
 	GrossAmount       string `json:"gross_amount"`
 	FraudStatus       string `json:"fraud_status"`
@@ -39,7 +52,7 @@ type WebhookRequest struct {
 // HandleWebhookFromCloudfren handles webhook from Cloudfren Core
 func (h *WebhookHandler) HandleWebhookFromCloudfren(c *gin.Context) {
 	var webhookReq WebhookRequest
-	if err := c.ShouldBindJSON(&webhookReq); err != nil {
+	if err := c.ShouldBindBodyWith(&webhookReq, binding.JSON); err != nil {
 		fmt.Printf("[SALOME BE] ERROR: Failed to parse webhook payload: %v\n", err)
 		errorResponse := gin.H{
 			"error":  "Invalid webhook payload",
@@ -85,43 +98,28 @@ func (h *WebhookHandler) HandleWebhookFromCloudfren(c *gin.Context) {
 		return
 	}
 
-	// Determine transaction status
-	var status string
-	switch webhookReq.TransactionStatus {
-	case "capture", "settlement":
-		status = "success"
-	case "cancel", "deny", "expire":
-		status = "failed"
-	case "pending":
-		status = "pending"
-	default:
-		status = "failed"
+	// Reject forged notifications before touching any transaction
+	notification := services.WebhookNotification{
+		Provider:          services.WebhookProviderCloudfren,
+		OrderID:           webhookReq.OrderID,
+		TransactionID:     webhookReq.TransactionID,
+		TransactionStatus: webhookReq.TransactionStatus,
+		PaymentType:       webhookReq.PaymentType,
+		StatusCode:        webhookReq.StatusCode,
+		GrossAmount:       webhookReq.GrossAmount,
+		SignatureKey:      webhookReq.SignatureKey,
+		RemoteIP:          c.ClientIP(),
+		Payload:           cachedBody(c),
 	}
-
-	fmt.Printf("Mapped transaction status: %s -> %s\n", webhookReq.TransactionStatus, status)
-
-	// Extract the base order ID (first 6 digits after prefix)
-	var baseOrderID string
-	if strings.HasPrefix(webhookReq.OrderID, "SALO-TOPUP-") {
-		// Extract: SALO-TOPUP-892929-1759555433162 -> SALO-TOPUP-892929
-		parts := strings.Split(webhookReq.OrderID, "-")
-		if len(parts) >= 3 {
-			baseOrderID = fmt.Sprintf("SALO-TOPUP-%s", parts[2])
-		} else {
-			baseOrderID = webhookReq.OrderID
-		}
-	} else if strings.HasPrefix(webhookReq.OrderID, "SALO-GRP-") {
-		// Extract: SALO-GRP-123456-1759555433162 -> SALO-GRP-123456
-		parts := strings.Split(webhookReq.OrderID, "-")
-		if len(parts) >= 3 {
-			baseOrderID = fmt.Sprintf("SALO-GRP-%s", parts[2])
-		} else {
-			baseOrderID = webhookReq.OrderID
-		}
-	} else {
-		baseOrderID = webhookReq.OrderID
+	if err := h.verifier.VerifySignature(notification); err != nil {
+		errorResponse := gin.H{"error": "Invalid signature"}
+		fmt.Printf("[SALOME BE] ERROR RESPONSE: %+v\n", errorResponse)
+		c.JSON(http.StatusUnauthorized, errorResponse)
+		return
 	}
 
+	baseOrderID := orderref.Resolve(webhookReq.OrderID)
+
 	fmt.Printf("[SALOME BE] Original Order ID: %s\n", webhookReq.OrderID)
 	fmt.Printf("[SALOME BE] Base Order ID: %s\n", baseOrderID)
 
@@ -147,47 +145,34 @@ func (h *WebhookHandler) HandleWebhookFromCloudfren(c *gin.Context) {
 		return
 	}
 
-	// Update transaction status and payment method
-	_, err = h.db.Exec(`
-		UPDATE transactions 
-		SET status = $1, payment_method = $2, updated_at = $3 
-		WHERE payment_reference = $4
-	`, status, webhookReq.PaymentType, time.Now(), baseOrderID)
+	// Cross-check gross_amount against the amount we asked the customer to pay
+	if err := h.verifier.VerifyAmount(notification, baseOrderID); err != nil {
+		fmt.Printf("[SALOME BE] ERROR: Amount verification failed: %v\n", err)
+		errorResponse := gin.H{"error": "Gross amount does not match transaction"}
+		fmt.Printf("[SALOME BE] ERROR RESPONSE: %+v\n", errorResponse)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
 
+	// Store the event in the inbox and apply it exactly once
+	result, err := h.processor.Receive(notification, baseOrderID)
 	if err != nil {
-		fmt.Printf("[SALOME BE] ERROR: Failed to update transaction status: %v\n", err)
-		errorResponse := gin.H{"error": "Failed to update transaction status"}
+		errorResponse := gin.H{"error": "Failed to process webhook"}
 		fmt.Printf("[SALOME BE] ERROR RESPONSE: %+v\n", errorResponse)
 		c.JSON(http.StatusInternalServerError, errorResponse)
 		return
 	}
 
-	fmt.Printf("Updated transaction status: %s -> %s, payment_method: %s (base: %s)\n", webhookReq.OrderID, status, webhookReq.PaymentType, baseOrderID)
-
-	// If transaction is success, update user balance for top-up transactions
-	if status == "success" {
-		err = h.updateUserBalanceForTopUp(baseOrderID)
-		if err != nil {
-			fmt.Printf("Error updating user balance: %v\n", err)
-			// Don't return error here, just log it
-		}
-
-		// Update group member status for group payments
-		err = h.updateGroupMemberStatus(baseOrderID)
-		if err != nil {
-			fmt.Printf("Error updating group member status: %v\n", err)
-			// Don't return error here, just log it
-		}
-	}
-
 	// Prepare response
 	response := gin.H{
 		"success":        true,
 		"message":        "Webhook processed successfully",
 		"order_id":       webhookReq.OrderID,
 		"base_order_id":  baseOrderID,
-		"status":         status,
+		"status":         result.Status,
 		"payment_method": webhookReq.PaymentType,
+		"event_id":       result.EventID,
+		"duplicate":      result.Duplicate,
 	}
 
 	// Log response yang akan dikirim ke Cloudfren Core
@@ -199,120 +184,77 @@ func (h *WebhookHandler) HandleWebhookFromCloudfren(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// updateUserBalanceForTopUp updates user balance for top-up transactions
-func (h *WebhookHandler) updateUserBalanceForTopUp(orderID string) error {
-	// Check if this is a top-up transaction based on order_id prefix
-	if !strings.HasPrefix(orderID, "SALO-TOPUP-") {
-		// Not a top-up transaction, skip
-		fmt.Printf("[SALOME BE] Skipping balance update - not a top-up transaction: %s\n", orderID)
-		return nil
+// ListWebhookEvents lists stored webhook events for admins (filter with ?status=failed)
+func (h *WebhookHandler) ListWebhookEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
 	}
-
-	// Get transaction details
-	var userID string
-	var amount int
-
-	err := h.db.QueryRow(`
-		SELECT user_id, amount
-		FROM transactions
-		WHERE payment_reference = $1
-	`, orderID).Scan(&userID, &amount)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Transaction not found
-			fmt.Printf("[SALOME BE] Transaction not found for order_id: %s\n", orderID)
-			return nil
-		}
-		return err
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
 	}
 
-	// Get current balance before update
-	var currentBalance int
-	err = h.db.QueryRow(`
-		SELECT balance FROM users WHERE id = $1
-	`, userID).Scan(&currentBalance)
-
+	events, total, err := h.processor.ListEvents(c.Query("status"), pageSize, (page-1)*pageSize)
 	if err != nil {
-		fmt.Printf("[SALOME BE] ERROR: Failed to get current balance for user %s: %v\n", userID, err)
-		return err
-	}
-
-	fmt.Printf("[SALOME BE] Current balance before update: UserID=%s, CurrentBalance=%d, AmountToAdd=%d\n", userID, currentBalance, amount)
-
-	// Update user balance by adding transaction amount
-	_, err = h.db.Exec(`
-		UPDATE users
-		SET balance = balance + $1, updated_at = $2
-		WHERE id = $3
-	`, amount, time.Now(), userID)
-
-	if err != nil {
-		fmt.Printf("[SALOME BE] ERROR: Failed to update user balance: UserID=%s, Amount=%d, Error=%v\n", userID, amount, err)
-		return err
+		fmt.Printf("[SALOME BE] ERROR: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook events"})
+		return
 	}
 
-	// Get new balance after update
-	var newBalance int
-	err = h.db.QueryRow(`
-		SELECT balance FROM users WHERE id = $1
-	`, userID).Scan(&newBalance)
-
-	if err != nil {
-		fmt.Printf("[SALOME BE] WARNING: Failed to get new balance for user %s: %v\n", userID, err)
-	} else {
-		fmt.Printf("[SALOME BE] Updated user balance: UserID=%s, OldBalance=%d, AmountAdded=%d, NewBalance=%d\n", userID, currentBalance, amount, newBalance)
-	}
-	return nil
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
 }
 
-// updateGroupMemberStatus updates group member status for group payments
-func (h *WebhookHandler) updateGroupMemberStatus(orderID string) error {
-	// Check if this is a group payment transaction based on order_id prefix
-	if !strings.HasPrefix(orderID, "SALO-GRP-") {
-		// Not a group payment transaction, skip
-		fmt.Printf("[SALOME BE] Skipping group member update - not a group payment transaction: %s\n", orderID)
-		return nil
+// RetryWebhookEvent re-drives a failed webhook event
+func (h *WebhookHandler) RetryWebhookEvent(c *gin.Context) {
+	eventID := c.Param("id")
+	if _, err := uuid.Parse(eventID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
 	}
 
-	fmt.Printf("[SALOME BE] Updating group member status for order_id: %s\n", orderID)
-
-	// Get transaction details
-	var userID string
-	var groupID *string
-
-	err := h.db.QueryRow(`
-		SELECT user_id, group_id
-		FROM transactions
-		WHERE payment_reference = $1
-	`, orderID).Scan(&userID, &groupID)
-
+	event, err := h.processor.GetEvent(eventID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// Transaction not found
-			fmt.Printf("[SALOME BE] Transaction not found for order_id: %s\n", orderID)
-			return nil
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event not found"})
+			return
 		}
-		return err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook event"})
+		return
 	}
 
-	if groupID == nil {
-		return nil
+	if event.Status == models.WebhookEventProcessed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Webhook event already processed"})
+		return
 	}
 
-	// Update group member status
-	_, err = h.db.Exec(`
-		UPDATE group_members
-		SET user_status = 'paid', paid_at = $1
-		WHERE group_id = $2 AND user_id = $3
-	`, time.Now(), *groupID, userID)
-
+	result, err := h.processor.Process(eventID)
 	if err != nil {
-		fmt.Printf("[SALOME BE] ERROR: Failed to update group member status: GroupID=%s, UserID=%s, Error=%v\n", *groupID, userID, err)
-		return err
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Failed to process webhook event",
+			"detail": err.Error(),
+		})
+		return
 	}
 
-	fmt.Printf("[SALOME BE] Updated group member status: GroupID=%s, UserID=%s\n", *groupID, userID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook event processed successfully",
+		"result":  result,
+	})
+}
+
+// cachedBody returns the raw request body cached by ShouldBindBodyWith
+func cachedBody(c *gin.Context) []byte {
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		if b, ok := body.([]byte); ok {
+			return b
+		}
+	}
 	return nil
 }
 
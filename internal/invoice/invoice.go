@@ -0,0 +1,160 @@
+// Package invoice renders payment receipts as PDF documents. The PDF is written directly with the standard
+// Helvetica fonts, so no external service or font files are needed.
+package invoice
+
+import (
+	"strings"
+
+	"salome-be/internal/config"
+	"salome-be/internal/models"
+)
+
+const (
+	marginLeft  = 50.0
+	marginRight = pageWidth - 50.0
+	dateLayout  = "02 Jan 2006"
+)
+
+// Renderer lays out invoices with the configured issuer details
+type Renderer struct {
+	config config.InvoiceConfig
+}
+
+func New(cfg config.InvoiceConfig) *Renderer {
+	return &Renderer{config: cfg}
+}
+
+// Default returns a renderer using the application configuration
+func Default() *Renderer {
+	return New(config.GetConfig().Invoice)
+}
+
+// FileName returns the download name of an invoice, e.g. INV-2026-000042.pdf
+func FileName(inv *models.Invoice) string {
+	return strings.NewReplacer("/", "-", " ", "-").Replace(inv.InvoiceNumber) + ".pdf"
+}
+
+// MethodLabel returns a readable name for a payment method
+func MethodLabel(method string) string {
+	switch method {
+	case "", "default":
+		return "-"
+	case "wallet":
+		return "Saldo SALOME"
+	case "credit_card":
+		return "Kartu Kredit"
+	case "bank_transfer", "echannel", "permata_va", "bca_va", "bni_va", "bri_va":
+		return "Transfer Bank"
+	case "gopay":
+		return "GoPay"
+	case "shopeepay":
+		return "ShopeePay"
+	case "qris", "other_qris":
+		return "QRIS"
+	}
+	words := strings.Fields(strings.ReplaceAll(method, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// Render returns the invoice as a one page A4 PDF
+func (r *Renderer) Render(inv *models.Invoice) ([]byte, error) {
+	p := &page{}
+
+	// Header
+	p.text(marginLeft, 785, fontBold, 22, r.config.CompanyName)
+	p.textRight(marginRight, 787, fontBold, 18, "INVOICE")
+	p.gray(0.4)
+	if r.config.CompanyAddress != "" {
+		p.text(marginLeft, 768, fontRegular, 9, r.config.CompanyAddress)
+	}
+	p.textRight(marginRight, 770, fontRegular, 10, "Bukti Pembayaran")
+	p.line(marginLeft, 755, marginRight, 755)
+	p.gray(0)
+
+	// Invoice details on the left, customer on the right
+	y := 730.0
+	details := [][2]string{
+		{"Nomor Invoice", inv.InvoiceNumber},
+		{"Tanggal", inv.IssuedAt.Format(dateLayout)},
+		{"Tanggal Bayar", inv.PaidAt.Format(dateLayout)},
+		{"Metode Pembayaran", MethodLabel(inv.PaymentMethod)},
+		{"Status", "LUNAS"},
+	}
+	if inv.PaymentReference != nil && *inv.PaymentReference != "" {
+		details = append(details, [2]string{"Referensi", *inv.PaymentReference})
+	}
+	for _, d := range details {
+		p.gray(0.4)
+		p.text(marginLeft, y, fontRegular, 9, d[0])
+		p.gray(0)
+		p.textFit(marginLeft+95, y, fontBold, 9, d[1], 180)
+		y -= 15
+	}
+
+	p.gray(0.4)
+	p.text(340, 730, fontRegular, 9, "Ditagihkan kepada")
+	p.gray(0)
+	p.textFit(340, 715, fontBold, 10, inv.CustomerName, marginRight-340)
+	p.textFit(340, 700, fontRegular, 9, inv.CustomerEmail, marginRight-340)
+
+	// Subscription the payment is for
+	if inv.GroupName != "" {
+		y -= 10
+		p.text(marginLeft, y, fontBold, 11, "Langganan")
+		y -= 17
+		subscription := [][2]string{{"Aplikasi", inv.AppName}, {"Grup", inv.GroupName}}
+		if inv.PeriodStart != nil && inv.PeriodEnd != nil {
+			subscription = append(subscription, [2]string{"Periode",
+				inv.PeriodStart.Format(dateLayout) + " - " + inv.PeriodEnd.Format(dateLayout)})
+		}
+		for _, s := range subscription {
+			p.gray(0.4)
+			p.text(marginLeft, y, fontRegular, 9, s[0])
+			p.gray(0)
+			p.textFit(marginLeft+95, y, fontRegular, 9, s[1], marginRight-marginLeft-95)
+			y -= 15
+		}
+	}
+
+	// Fee breakdown
+	y -= 15
+	p.gray(0.92)
+	p.rect(marginLeft, y-6, marginRight-marginLeft, 20)
+	p.gray(0)
+	p.text(marginLeft+8, y, fontBold, 9, "Keterangan")
+	p.textRight(marginRight-8, y, fontBold, 9, "Jumlah")
+	y -= 22
+	for _, line := range inv.Lines {
+		p.textFit(marginLeft+8, y, fontRegular, 9, line.Description, 360)
+		p.textRight(marginRight-8, y, fontRegular, 9, line.Amount.Format())
+		p.gray(0.85)
+		p.line(marginLeft, y-7, marginRight, y-7)
+		p.gray(0)
+		y -= 20
+	}
+	p.text(marginLeft+8, y, fontBold, 10, "Total")
+	p.textRight(marginRight-8, y, fontBold, 10, inv.Total.Format())
+	y -= 30
+
+	// How the total was paid
+	if len(inv.Payments) > 0 {
+		p.text(marginLeft, y, fontBold, 11, "Pembayaran")
+		y -= 17
+		for _, payment := range inv.Payments {
+			p.text(marginLeft, y, fontRegular, 9, MethodLabel(payment.Method))
+			p.textRight(marginRight-8, y, fontRegular, 9, payment.Amount.Format())
+			y -= 15
+		}
+	}
+
+	// Footer
+	p.gray(0.4)
+	p.line(marginLeft, 80, marginRight, 80)
+	p.text(marginLeft, 65, fontRegular, 8, "Dokumen ini dibuat secara otomatis dan merupakan bukti pembayaran yang sah.")
+	p.text(marginLeft, 53, fontRegular, 8, "ID Transaksi: "+inv.TransactionID)
+
+	return writePDF(p, "Invoice "+inv.InvoiceNumber, inv.IssuedAt)
+}
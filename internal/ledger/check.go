@@ -0,0 +1,106 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+)
+
+// Violation is a single broken ledger invariant
+type Violation struct {
+	Check   string `json:"check"`
+	Subject string `json:"subject"`
+	Detail  string `json:"detail"`
+}
+
+// CheckReport is the result of an invariant check
+type CheckReport struct {
+	CheckedAt    time.Time   `json:"checked_at"`
+	Entries      int         `json:"entries"`
+	Accounts     int         `json:"accounts"`
+	TotalBalance int64       `json:"total_balance"`
+	Balanced     bool        `json:"balanced"`
+	Violations   []Violation `json:"violations"`
+}
+
+// Check verifies the ledger invariants:
+//   - every entry's postings sum to zero (and so do all postings together)
+//   - every account's cached balance equals the sum of its postings
+//   - users.balance equals the user's wallet balance, and no wallet is negative
+func (l *Ledger) Check() (*CheckReport, error) {
+	report := &CheckReport{CheckedAt: time.Now(), Violations: []Violation{}}
+
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM ledger_entries`).Scan(&report.Entries); err != nil {
+		return nil, fmt.Errorf("failed to count ledger entries: %v", err)
+	}
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM ledger_accounts`).Scan(&report.Accounts); err != nil {
+		return nil, fmt.Errorf("failed to count ledger accounts: %v", err)
+	}
+	if err := l.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM ledger_postings`).Scan(&report.TotalBalance); err != nil {
+		return nil, fmt.Errorf("failed to sum ledger postings: %v", err)
+	}
+	if report.TotalBalance != 0 {
+		report.Violations = append(report.Violations, Violation{
+			Check:   "ledger_total",
+			Subject: "ledger",
+			Detail:  fmt.Sprintf("all postings sum to %d", report.TotalBalance),
+		})
+	}
+
+	checks := []struct {
+		name  string
+		query string
+	}{
+		{
+			name: "unbalanced_entry",
+			query: `
+				SELECT e.id::text, 'postings sum to ' || COALESCE(SUM(p.amount), 0)::text
+				FROM ledger_entries e
+				LEFT JOIN ledger_postings p ON p.entry_id = e.id
+				GROUP BY e.id
+				HAVING COALESCE(SUM(p.amount), 0) <> 0 OR COUNT(p.id) < 2`,
+		},
+		{
+			name: "account_balance_mismatch",
+			query: `
+				SELECT a.id::text, 'cached ' || a.balance::text || ', postings ' || COALESCE(SUM(p.amount), 0)::text
+				FROM ledger_accounts a
+				LEFT JOIN ledger_postings p ON p.account_id = a.id
+				GROUP BY a.id, a.balance
+				HAVING a.balance <> COALESCE(SUM(p.amount), 0)`,
+		},
+		{
+			name: "user_balance_mismatch",
+			query: `
+				SELECT u.id::text, 'users.balance ' || COALESCE(u.balance, 0)::text || ', wallet ' || COALESCE(a.balance, 0)::text
+				FROM users u
+				LEFT JOIN ledger_accounts a ON a.account_type = 'user_wallet' AND a.owner_id = u.id
+				WHERE ROUND(COALESCE(u.balance, 0) * 100) <> COALESCE(a.balance, 0)`,
+		},
+		{
+			name: "negative_wallet",
+			query: `
+				SELECT owner_id::text, 'wallet balance ' || balance::text
+				FROM ledger_accounts
+				WHERE account_type = 'user_wallet' AND balance < 0`,
+		},
+	}
+
+	for _, check := range checks {
+		rows, err := l.db.Query(check.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run ledger check %s: %v", check.name, err)
+		}
+		for rows.Next() {
+			v := Violation{Check: check.name}
+			if err := rows.Scan(&v.Subject, &v.Detail); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan ledger check %s: %v", check.name, err)
+			}
+			report.Violations = append(report.Violations, v)
+		}
+		rows.Close()
+	}
+
+	report.Balanced = len(report.Violations) == 0
+	return report, nil
+}
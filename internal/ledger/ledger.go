@@ -0,0 +1,241 @@
+// Package ledger implements the double-entry wallet ledger.
+//
+// Every movement of money is a journal entry made of postings that sum to zero.
+// An account's balance is the sum of its postings; users.balance and users.total_spent
+// are caches kept in sync inside the same database transaction.
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"salome-be/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// Account types
+const (
+	AccountUserWallet      = "user_wallet"
+	AccountGroupEscrow     = "group_escrow"
+	AccountPlatformRevenue = "platform_revenue"
+	AccountAdminFeeIncome  = "admin_fee_income"
+	AccountPaymentClearing = "payment_clearing" // money held by the payment gateway / bank
+	AccountPayoutPending   = "payout_pending"   // withdrawals requested but not yet transferred
+	AccountPromoExpense    = "promo_expense"    // promo code discounts paid by the platform
+	AccountDisputeHold     = "dispute_hold"     // group earnings held while a dispute is open, one per dispute
+)
+
+// Entry types
+const (
+	EntryTopUp          = "top_up"
+	EntryGroupPayment   = "group_payment"
+	EntryWithdrawal     = "withdrawal"
+	EntryRefund         = "refund"
+	EntryPayoutHold     = "payout_hold"
+	EntryPayoutRelease  = "payout_release"
+	EntryPromoSubsidy   = "promo_subsidy"
+	EntryPromoReversal  = "promo_subsidy_reversal"
+	EntryDisputeHold    = "dispute_hold"
+	EntryDisputeRelease = "dispute_release"
+	EntryOpeningBalance = "opening_balance"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrUnbalancedEntry   = errors.New("ledger entry does not balance")
+	ErrInvalidAmount     = errors.New("amount must be positive")
+)
+
+// Posting is a signed movement on one account, in minor units
+type Posting struct {
+	AccountID string
+	Amount    int64
+}
+
+// Entry is a balanced journal entry
+type Entry struct {
+	EntryType     string
+	TransactionID *string
+	UserID        *string
+	Description   string
+	Postings      []Posting
+}
+
+// Ledger posts journal entries
+type Ledger struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Ledger {
+	return &Ledger{db: db}
+}
+
+// Post validates and writes an entry, returning its id and the new balance of every touched account.
+// Accounts are locked in a stable order so concurrent entries cannot deadlock, and user
+// wallets may never go below zero.
+func (l *Ledger) Post(tx *sql.Tx, entry Entry) (string, map[string]int64, error) {
+	if len(entry.Postings) < 2 {
+		return "", nil, fmt.Errorf("%w: need at least two postings", ErrUnbalancedEntry)
+	}
+
+	deltas := map[string]int64{}
+	var sum int64
+	for _, p := range entry.Postings {
+		if p.Amount == 0 {
+			return "", nil, fmt.Errorf("%w: zero posting", ErrUnbalancedEntry)
+		}
+		deltas[p.AccountID] += p.Amount
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return "", nil, fmt.Errorf("%w: postings sum to %d", ErrUnbalancedEntry, sum)
+	}
+
+	accountIDs := make([]string, 0, len(deltas))
+	for id := range deltas {
+		accountIDs = append(accountIDs, id)
+	}
+	sort.Strings(accountIDs)
+
+	type lockedAccount struct {
+		accountType string
+		ownerID     string
+		balance     int64
+	}
+	accounts := map[string]lockedAccount{}
+	for _, id := range accountIDs {
+		var a lockedAccount
+		err := tx.QueryRow(`
+			SELECT account_type, owner_id, balance FROM ledger_accounts WHERE id = $1 FOR UPDATE
+		`, id).Scan(&a.accountType, &a.ownerID, &a.balance)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to lock ledger account %s: %v", id, err)
+		}
+		if a.accountType == AccountUserWallet && deltas[id] < 0 && a.balance+deltas[id] < 0 {
+			return "", nil, ErrInsufficientFunds
+		}
+		accounts[id] = a
+	}
+
+	var entryID string
+	err := tx.QueryRow(`
+		INSERT INTO ledger_entries (entry_type, transaction_id, user_id, description, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id
+	`, entry.EntryType, entry.TransactionID, entry.UserID, entry.Description).Scan(&entryID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create ledger entry: %v", err)
+	}
+
+	for _, p := range entry.Postings {
+		_, err = tx.Exec(`
+			INSERT INTO ledger_postings (entry_id, account_id, amount, created_at)
+			VALUES ($1, $2, $3, NOW())
+		`, entryID, p.AccountID, p.Amount)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create ledger posting: %v", err)
+		}
+	}
+
+	balances := map[string]int64{}
+	for _, id := range accountIDs {
+		a := accounts[id]
+		newBalance := a.balance + deltas[id]
+		_, err = tx.Exec(`
+			UPDATE ledger_accounts SET balance = $1, updated_at = NOW() WHERE id = $2
+		`, newBalance, id)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to update ledger account balance: %v", err)
+		}
+
+		// users.balance is a cache of the wallet balance
+		if a.accountType == AccountUserWallet {
+			_, err = tx.Exec(`
+				UPDATE users SET balance = $1, updated_at = NOW() WHERE id = $2
+			`, money.FromMinor(newBalance), a.ownerID)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to update cached user balance: %v", err)
+			}
+		}
+		balances[id] = newBalance
+	}
+
+	return entryID, balances, nil
+}
+
+// Account returns the id of the account of the given type and owner, creating it when missing
+func (l *Ledger) Account(tx *sql.Tx, accountType string, ownerID string) (string, error) {
+	if ownerID == "" {
+		ownerID = uuid.Nil.String()
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO ledger_accounts (account_type, owner_id, name, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (account_type, owner_id) DO NOTHING
+	`, accountType, ownerID, accountName(accountType))
+	if err != nil {
+		return "", fmt.Errorf("failed to create ledger account: %v", err)
+	}
+
+	var id string
+	err = tx.QueryRow(`
+		SELECT id FROM ledger_accounts WHERE account_type = $1 AND owner_id = $2
+	`, accountType, ownerID).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get ledger account: %v", err)
+	}
+	return id, nil
+}
+
+// LockBalance locks the account of the given type and owner until tx ends and returns its balance, so a
+// check against it still holds when the entry is posted
+func (l *Ledger) LockBalance(tx *sql.Tx, accountType string, ownerID string) (money.Amount, error) {
+	id, err := l.Account(tx, accountType, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	if err := tx.QueryRow(`SELECT balance FROM ledger_accounts WHERE id = $1 FOR UPDATE`, id).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("failed to lock ledger account %s: %v", id, err)
+	}
+	return money.FromMinor(balance), nil
+}
+
+// WalletBalance returns a user's wallet balance
+func (l *Ledger) WalletBalance(userID string) (money.Amount, error) {
+	var balance int64
+	err := l.db.QueryRow(`
+		SELECT balance FROM ledger_accounts WHERE account_type = $1 AND owner_id = $2
+	`, AccountUserWallet, userID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return money.FromMinor(balance), err
+}
+
+func accountName(accountType string) string {
+	switch accountType {
+	case AccountUserWallet:
+		return "User wallet"
+	case AccountGroupEscrow:
+		return "Group escrow"
+	case AccountPlatformRevenue:
+		return "Platform revenue"
+	case AccountAdminFeeIncome:
+		return "Admin fee income"
+	case AccountPaymentClearing:
+		return "Payment gateway clearing"
+	case AccountPayoutPending:
+		return "Pending payouts"
+	case AccountPromoExpense:
+		return "Promo discounts"
+	case AccountDisputeHold:
+		return "Disputed earnings on hold"
+	default:
+		return accountType
+	}
+}
@@ -0,0 +1,398 @@
+package ledger
+
+import (
+	"database/sql"
+	"fmt"
+
+	"salome-be/internal/money"
+)
+
+// WalletMovement is the effect of an operation on a user's wallet
+type WalletMovement struct {
+	EntryID       string
+	BalanceBefore money.Amount
+	BalanceAfter  money.Amount
+}
+
+// Operation describes a money movement tied to a transactions row
+type Operation struct {
+	UserID        string
+	GroupID       string       // optional
+	TransactionID string       // optional
+	Amount        money.Amount // always positive
+	AdminFee      money.Amount // part of Amount booked as admin fee income (group payments only)
+	Description   string
+}
+
+// TopUp credits the wallet with money received through the payment gateway
+func (l *Ledger) TopUp(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	clearing, err := l.Account(tx, AccountPaymentClearing, "")
+	if err != nil {
+		return nil, err
+	}
+	return l.walletEntry(tx, EntryTopUp, op, op.Amount.Minor(), []Posting{{AccountID: clearing, Amount: -op.Amount.Minor()}})
+}
+
+// PayFromWallet debits the wallet for a group payment
+func (l *Ledger) PayFromWallet(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	postings, err := l.paymentPostings(tx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	movement, err := l.walletEntry(tx, EntryGroupPayment, op, -op.Amount.Minor(), postings)
+	if err != nil {
+		return nil, err
+	}
+	if err := addTotalSpent(tx, op.UserID, op.Amount.Minor()); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// SettleGroupPayment books a group payment paid through the payment gateway; the wallet is not touched
+func (l *Ledger) SettleGroupPayment(tx *sql.Tx, op Operation) (string, error) {
+	if op.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+
+	clearing, err := l.Account(tx, AccountPaymentClearing, "")
+	if err != nil {
+		return "", err
+	}
+	postings, err := l.paymentPostings(tx, op)
+	if err != nil {
+		return "", err
+	}
+
+	entryID, _, err := l.Post(tx, Entry{
+		EntryType:     EntryGroupPayment,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings:      append(postings, Posting{AccountID: clearing, Amount: -op.Amount.Minor()}),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := addTotalSpent(tx, op.UserID, op.Amount.Minor()); err != nil {
+		return "", err
+	}
+	return entryID, nil
+}
+
+// RefundToWallet reverses a group payment into the wallet: the group escrow (or platform revenue when no
+// group is given) and, for op.AdminFee, admin fee income are debited
+func (l *Ledger) RefundToWallet(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	postings, err := l.paymentPostings(tx, op)
+	if err != nil {
+		return nil, err
+	}
+	for i := range postings {
+		postings[i].Amount = -postings[i].Amount
+	}
+
+	movement, err := l.walletEntry(tx, EntryRefund, op, op.Amount.Minor(), postings)
+	if err != nil {
+		return nil, err
+	}
+	if err := addTotalSpent(tx, op.UserID, -op.Amount.Minor()); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// RefundToGateway reverses a group payment back through the payment gateway; the wallet is not touched
+func (l *Ledger) RefundToGateway(tx *sql.Tx, op Operation) (string, error) {
+	if op.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+
+	clearing, err := l.Account(tx, AccountPaymentClearing, "")
+	if err != nil {
+		return "", err
+	}
+	postings, err := l.paymentPostings(tx, op)
+	if err != nil {
+		return "", err
+	}
+	for i := range postings {
+		postings[i].Amount = -postings[i].Amount
+	}
+
+	entryID, _, err := l.Post(tx, Entry{
+		EntryType:     EntryRefund,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings:      append(postings, Posting{AccountID: clearing, Amount: op.Amount.Minor()}),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := addTotalSpent(tx, op.UserID, -op.Amount.Minor()); err != nil {
+		return "", err
+	}
+	return entryID, nil
+}
+
+// RedirectRefundToWallet credits the wallet with a refund booked by RefundToGateway that the payment gateway
+// could not pay out
+func (l *Ledger) RedirectRefundToWallet(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	clearing, err := l.Account(tx, AccountPaymentClearing, "")
+	if err != nil {
+		return nil, err
+	}
+	return l.walletEntry(tx, EntryRefund, op, op.Amount.Minor(), []Posting{{AccountID: clearing, Amount: -op.Amount.Minor()}})
+}
+
+// HoldPayout moves the amount of a withdrawal request to pending payouts so it cannot be spent while the
+// request is reviewed. The money comes from the wallet, or from the group escrow when op.GroupID is set;
+// the wallet movement is nil in that case.
+func (l *Ledger) HoldPayout(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	return l.movePayout(tx, EntryPayoutHold, op, -op.Amount.Minor())
+}
+
+// ReleasePayout returns the amount of a rejected withdrawal request to where HoldPayout took it from
+func (l *Ledger) ReleasePayout(tx *sql.Tx, op Operation) (*WalletMovement, error) {
+	return l.movePayout(tx, EntryPayoutRelease, op, op.Amount.Minor())
+}
+
+// PayOut books a held withdrawal as transferred: op.Amount leaves pending payouts, op.AdminFee of it is
+// kept as fee income and the rest goes out through the bank
+func (l *Ledger) PayOut(tx *sql.Tx, op Operation) (string, error) {
+	if op.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if op.AdminFee < 0 || op.AdminFee >= op.Amount {
+		return "", fmt.Errorf("invalid payout fee %s for amount %s", op.AdminFee, op.Amount)
+	}
+
+	pending, err := l.Account(tx, AccountPayoutPending, "")
+	if err != nil {
+		return "", err
+	}
+	clearing, err := l.Account(tx, AccountPaymentClearing, "")
+	if err != nil {
+		return "", err
+	}
+
+	postings := []Posting{
+		{AccountID: pending, Amount: -op.Amount.Minor()},
+		{AccountID: clearing, Amount: op.Amount.Minor() - op.AdminFee.Minor()},
+	}
+	if op.AdminFee > 0 {
+		feeAccount, err := l.Account(tx, AccountAdminFeeIncome, "")
+		if err != nil {
+			return "", err
+		}
+		postings = append(postings, Posting{AccountID: feeAccount, Amount: op.AdminFee.Minor()})
+	}
+
+	entryID, _, err := l.Post(tx, Entry{
+		EntryType:     EntryWithdrawal,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings:      postings,
+	})
+	return entryID, err
+}
+
+// movePayout posts sourceDelta on the payout source against pending payouts. Group escrow may not go
+// below zero here, same as a wallet.
+func (l *Ledger) movePayout(tx *sql.Tx, entryType string, op Operation, sourceDelta int64) (*WalletMovement, error) {
+	pending, err := l.Account(tx, AccountPayoutPending, "")
+	if err != nil {
+		return nil, err
+	}
+	counter := []Posting{{AccountID: pending, Amount: -sourceDelta}}
+
+	if op.GroupID == "" {
+		return l.walletEntry(tx, entryType, op, sourceDelta, counter)
+	}
+
+	if op.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	escrow, err := l.Account(tx, AccountGroupEscrow, op.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	_, balances, err := l.Post(tx, Entry{
+		EntryType:     entryType,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings:      append([]Posting{{AccountID: escrow, Amount: sourceDelta}}, counter...),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if balances[escrow] < 0 {
+		return nil, ErrInsufficientFunds
+	}
+	return nil, nil
+}
+
+// HoldDispute moves op.Amount of the earnings of op.GroupID to the hold account of a dispute, so the owner
+// cannot withdraw it while the dispute is open. The group escrow may not go below zero.
+func (l *Ledger) HoldDispute(tx *sql.Tx, disputeID string, op Operation) (string, error) {
+	return l.moveDisputeHold(tx, EntryDisputeHold, disputeID, op, -op.Amount.Minor())
+}
+
+// ReleaseDispute returns the amount held for a dispute to the group escrow
+func (l *Ledger) ReleaseDispute(tx *sql.Tx, disputeID string, op Operation) (string, error) {
+	return l.moveDisputeHold(tx, EntryDisputeRelease, disputeID, op, op.Amount.Minor())
+}
+
+// moveDisputeHold posts escrowDelta on the group escrow against the hold account of the dispute
+func (l *Ledger) moveDisputeHold(tx *sql.Tx, entryType, disputeID string, op Operation, escrowDelta int64) (string, error) {
+	if op.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+
+	escrow, err := l.Account(tx, AccountGroupEscrow, op.GroupID)
+	if err != nil {
+		return "", err
+	}
+	hold, err := l.Account(tx, AccountDisputeHold, disputeID)
+	if err != nil {
+		return "", err
+	}
+
+	entryID, balances, err := l.Post(tx, Entry{
+		EntryType:     entryType,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings: []Posting{
+			{AccountID: escrow, Amount: escrowDelta},
+			{AccountID: hold, Amount: -escrowDelta},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if balances[escrow] < 0 || balances[hold] < 0 {
+		return "", ErrInsufficientFunds
+	}
+	return entryID, nil
+}
+
+// SubsidizePromo credits the group escrow (or platform revenue) with the part of a promo discount the
+// platform pays for, so the owner still receives the full seat price
+func (l *Ledger) SubsidizePromo(tx *sql.Tx, op Operation) (string, error) {
+	return l.promoEntry(tx, EntryPromoSubsidy, op, op.Amount.Minor())
+}
+
+// ReversePromoSubsidy takes a promo subsidy, or the refunded share of it, back from the group escrow
+func (l *Ledger) ReversePromoSubsidy(tx *sql.Tx, op Operation) (string, error) {
+	return l.promoEntry(tx, EntryPromoReversal, op, -op.Amount.Minor())
+}
+
+func (l *Ledger) promoEntry(tx *sql.Tx, entryType string, op Operation, targetDelta int64) (string, error) {
+	if op.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+
+	expense, err := l.Account(tx, AccountPromoExpense, "")
+	if err != nil {
+		return "", err
+	}
+	target, err := l.revenueAccount(tx, op.GroupID)
+	if err != nil {
+		return "", err
+	}
+
+	entryID, _, err := l.Post(tx, Entry{
+		EntryType:     entryType,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings: []Posting{
+			{AccountID: expense, Amount: -targetDelta},
+			{AccountID: target, Amount: targetDelta},
+		},
+	})
+	return entryID, err
+}
+
+// paymentPostings credits the group escrow (or platform revenue) and admin fee income for a payment
+func (l *Ledger) paymentPostings(tx *sql.Tx, op Operation) ([]Posting, error) {
+	if op.AdminFee < 0 || op.AdminFee > op.Amount {
+		return nil, fmt.Errorf("invalid admin fee %s for amount %s", op.AdminFee, op.Amount)
+	}
+
+	target, err := l.revenueAccount(tx, op.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var postings []Posting
+	if net := op.Amount.Minor() - op.AdminFee.Minor(); net > 0 {
+		postings = append(postings, Posting{AccountID: target, Amount: net})
+	}
+	if op.AdminFee > 0 {
+		feeAccount, err := l.Account(tx, AccountAdminFeeIncome, "")
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, Posting{AccountID: feeAccount, Amount: op.AdminFee.Minor()})
+	}
+	return postings, nil
+}
+
+func (l *Ledger) revenueAccount(tx *sql.Tx, groupID string) (string, error) {
+	if groupID != "" {
+		return l.Account(tx, AccountGroupEscrow, groupID)
+	}
+	return l.Account(tx, AccountPlatformRevenue, "")
+}
+
+// walletEntry posts walletDelta on the user's wallet against the given counter postings
+func (l *Ledger) walletEntry(tx *sql.Tx, entryType string, op Operation, walletDelta int64, counter []Posting) (*WalletMovement, error) {
+	if op.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	wallet, err := l.Account(tx, AccountUserWallet, op.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	entryID, balances, err := l.Post(tx, Entry{
+		EntryType:     entryType,
+		TransactionID: optional(op.TransactionID),
+		UserID:        optional(op.UserID),
+		Description:   op.Description,
+		Postings:      append([]Posting{{AccountID: wallet, Amount: walletDelta}}, counter...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletMovement{
+		EntryID:       entryID,
+		BalanceBefore: money.FromMinor(balances[wallet] - walletDelta),
+		BalanceAfter:  money.FromMinor(balances[wallet]),
+	}, nil
+}
+
+// addTotalSpent keeps the users.total_spent cache in sync with group payments and refunds
+func addTotalSpent(tx *sql.Tx, userID string, amount int64) error {
+	_, err := tx.Exec(`
+		UPDATE users SET total_spent = GREATEST(COALESCE(total_spent, 0) + $1, 0), updated_at = NOW() WHERE id = $2
+	`, money.FromMinor(amount), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update total spent: %v", err)
+	}
+	return nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
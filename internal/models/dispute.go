@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Dispute categories
+const (
+	DisputeCategoryCredentialsNotShared = "credentials_not_shared"
+	DisputeCategoryAccountRevoked       = "account_revoked"
+	DisputeCategoryWrongPlan            = "wrong_plan"
+	DisputeCategoryOther                = "other"
+)
+
+// Dispute statuses
+const (
+	DisputeOpen      = "open"
+	DisputeResponded = "responded" // the owner answered, waiting for an admin
+	DisputeResolved  = "resolved"
+	DisputeWithdrawn = "withdrawn" // closed by the member
+)
+
+// Dispute resolutions
+const (
+	DisputeResolutionRefund       = "refund"        // the member gets the payment for the period back
+	DisputeResolutionRemoveMember = "remove_member" // the member leaves the group
+	DisputeResolutionSuspendOwner = "suspend_owner" // the owner's account is suspended
+	DisputeResolutionDismissed    = "dismissed"     // the owner keeps the earnings
+)
+
+// GroupDispute is a member's report that the shared subscription of a group did not work for a billing
+// period. The owner's earnings for the period are held until the dispute is resolved or withdrawn.
+type GroupDispute struct {
+	ID             string       `json:"id" db:"id"`
+	GroupID        string       `json:"group_id" db:"group_id"`
+	MemberID       string       `json:"member_id" db:"member_id"`
+	OwnerID        string       `json:"owner_id" db:"owner_id"`
+	PeriodStart    time.Time    `json:"period_start" db:"period_start"`
+	PeriodEnd      time.Time    `json:"period_end" db:"period_end"`
+	TransactionID  *string      `json:"transaction_id,omitempty" db:"transaction_id"`
+	Category       string       `json:"category" db:"category"`
+	Evidence       string       `json:"evidence" db:"evidence"`
+	Status         string       `json:"status" db:"status"`
+	OwnerResponse  *string      `json:"owner_response,omitempty" db:"owner_response"`
+	RespondedAt    *time.Time   `json:"responded_at,omitempty" db:"responded_at"`
+	HeldAmount     money.Amount `json:"held_amount" db:"held_amount"`
+	Resolution     *string      `json:"resolution,omitempty" db:"resolution"`
+	ResolutionNote *string      `json:"resolution_note,omitempty" db:"resolution_note"`
+	RefundedAmount money.Amount `json:"refunded_amount" db:"refunded_amount"`
+	ResolvedBy     *string      `json:"resolved_by,omitempty" db:"resolved_by"`
+	ClosedAt       *time.Time   `json:"closed_at,omitempty" db:"closed_at"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName  string `json:"group_name,omitempty"`
+	MemberName string `json:"member_name,omitempty"`
+	OwnerName  string `json:"owner_name,omitempty"`
+}
+
+// DisputeRequest opens a dispute about the member's current billing period
+type DisputeRequest struct {
+	Category string `json:"category" binding:"required,oneof=credentials_not_shared account_revoked wrong_plan other"`
+	Evidence string `json:"evidence" binding:"required,min=10,max=5000"`
+}
+
+// DisputeResponseRequest is the owner's answer to a dispute
+type DisputeResponseRequest struct {
+	Response string `json:"response" binding:"required,max=5000"`
+}
+
+// DisputeResolveRequest closes a dispute. Refund also returns the payment for the period when the member is
+// removed or the owner suspended; the refund resolution always does.
+type DisputeResolveRequest struct {
+	Resolution string `json:"resolution" binding:"required,oneof=refund remove_member suspend_owner dismissed"`
+	Refund     bool   `json:"refund"`
+	Note       string `json:"note" binding:"max=2000"`
+}
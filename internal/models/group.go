@@ -3,37 +3,41 @@ package models
 import (
 	"time"
 
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+
 	"github.com/google/uuid"
 )
 
 type Group struct {
-	ID             uuid.UUID     `json:"id" db:"id"`
-	Name           string        `json:"name" db:"name"`
-	Description    *string       `json:"description" db:"description"`
-	AppID          string        `json:"app_id" db:"app_id"`
-	MaxMembers     int           `json:"max_members" db:"max_members"`
-	CurrentMembers int           `json:"current_members" db:"current_members"`
-	PricePerMember float64       `json:"price_per_member" db:"price_per_member"`
-	AdminFee       float64       `json:"admin_fee" db:"admin_fee"`
-	TotalPrice     float64       `json:"total_price" db:"total_price"`
-	GroupStatus    string        `json:"group_status" db:"group_status"`
-	InviteCode     string        `json:"invite_code" db:"invite_code"`
-	OwnerID        uuid.UUID     `json:"owner_id" db:"owner_id"`
-	IsPublic       bool          `json:"is_public" db:"is_public"`
-	ExpiresAt      *time.Time    `json:"expires_at" db:"expires_at"`
-	AllPaidAt      *time.Time    `json:"all_paid_at" db:"all_paid_at"`
-	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
-	Members        []GroupMember `json:"members,omitempty"`
-	App            *App          `json:"app,omitempty"`
+	ID             uuid.UUID          `json:"id" db:"id"`
+	Name           string             `json:"name" db:"name"`
+	Description    *string            `json:"description" db:"description"`
+	AppID          string             `json:"app_id" db:"app_id"`
+	MaxMembers     int                `json:"max_members" db:"max_members"`
+	CurrentMembers int                `json:"current_members" db:"current_members"`
+	PricePerMember money.Amount       `json:"price_per_member" db:"price_per_member"`
+	AdminFee       money.Amount       `json:"admin_fee" db:"admin_fee"`
+	TotalPrice     money.Amount       `json:"total_price" db:"total_price"`
+	PriceBreakdown *pricing.Breakdown `json:"price_breakdown,omitempty" db:"price_breakdown"`
+	GroupStatus    string             `json:"group_status" db:"group_status"`
+	InviteCode     string             `json:"invite_code" db:"invite_code"`
+	OwnerID        uuid.UUID          `json:"owner_id" db:"owner_id"`
+	IsPublic       bool               `json:"is_public" db:"is_public"`
+	ExpiresAt      *time.Time         `json:"expires_at" db:"expires_at"`
+	AllPaidAt      *time.Time         `json:"all_paid_at" db:"all_paid_at"`
+	CreatedAt      time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" db:"updated_at"`
+	Members        []GroupMember      `json:"members,omitempty"`
+	App            *App               `json:"app,omitempty"`
 
 	// Admin view fields
-	OwnerName    string  `json:"owner_name,omitempty"`
-	OwnerEmail   string  `json:"owner_email,omitempty"`
-	AppName      string  `json:"app_name,omitempty"`
-	AppIcon      string  `json:"app_icon,omitempty"`
-	MembersCount int     `json:"members_count,omitempty"`
-	TotalRevenue float64 `json:"total_revenue,omitempty"`
+	OwnerName    string       `json:"owner_name,omitempty"`
+	OwnerEmail   string       `json:"owner_email,omitempty"`
+	AppName      string       `json:"app_name,omitempty"`
+	AppIcon      string       `json:"app_icon,omitempty"`
+	MembersCount int          `json:"members_count,omitempty"`
+	TotalRevenue money.Amount `json:"total_revenue,omitempty"`
 }
 
 type GroupMember struct {
@@ -43,8 +47,8 @@ type GroupMember struct {
 	Role                    string       `json:"role" db:"role"`
 	JoinedAt                time.Time    `json:"joined_at" db:"joined_at"`
 	UserStatus              string       `json:"user_status" db:"user_status"`
-	PaymentAmount           int          `json:"payment_amount" db:"payment_amount"`
-	PricePerMember          float64      `json:"price_per_member" db:"price_per_member"`
+	PaymentAmount           money.Amount `json:"payment_amount" db:"payment_amount"`
+	PricePerMember          money.Amount `json:"price_per_member" db:"price_per_member"`
 	PaymentDeadline         *time.Time   `json:"payment_deadline" db:"payment_deadline"`
 	PaidAt                  *time.Time   `json:"paid_at" db:"paid_at"`
 	ActivatedAt             *time.Time   `json:"activated_at" db:"activated_at"`
@@ -68,28 +72,35 @@ type GroupJoinRequest struct {
 	InviteCode string `json:"invite_code" binding:"required"`
 }
 
+// GroupMatchRequest asks for a seat in any open public group of an app, optionally up to a seat price
+type GroupMatchRequest struct {
+	AppID    string        `json:"app_id" binding:"required"`
+	MaxPrice *money.Amount `json:"max_price"`
+}
+
 type GroupResponse struct {
-	ID             uuid.UUID     `json:"id"`
-	Name           string        `json:"name"`
-	Description    *string       `json:"description"`
-	AppID          string        `json:"app_id"`
-	MaxMembers     int           `json:"max_members"`
-	CurrentMembers int           `json:"current_members"`
-	MemberCount    int           `json:"member_count"`
-	PricePerMember float64       `json:"price_per_member"`
-	AdminFee       float64       `json:"admin_fee"`
-	TotalPrice     float64       `json:"total_price"`
-	GroupStatus    string        `json:"group_status"`
-	InviteCode     string        `json:"invite_code"`
-	OwnerID        uuid.UUID     `json:"owner_id"`
-	IsPublic       bool          `json:"is_public"`
-	ExpiresAt      *time.Time    `json:"expires_at"`
-	AllPaidAt      *time.Time    `json:"all_paid_at"`
-	CreatedAt      time.Time     `json:"created_at"`
-	UpdatedAt      time.Time     `json:"updated_at"`
-	Members        []GroupMember `json:"members,omitempty"`
-	App            *App          `json:"app,omitempty"`
-	Owner          *UserResponse `json:"owner,omitempty"`
+	ID             uuid.UUID          `json:"id"`
+	Name           string             `json:"name"`
+	Description    *string            `json:"description"`
+	AppID          string             `json:"app_id"`
+	MaxMembers     int                `json:"max_members"`
+	CurrentMembers int                `json:"current_members"`
+	MemberCount    int                `json:"member_count"`
+	PricePerMember money.Amount       `json:"price_per_member"`
+	AdminFee       money.Amount       `json:"admin_fee"`
+	TotalPrice     money.Amount       `json:"total_price"`
+	PriceBreakdown *pricing.Breakdown `json:"price_breakdown,omitempty"`
+	GroupStatus    string             `json:"group_status"`
+	InviteCode     string             `json:"invite_code"`
+	OwnerID        uuid.UUID          `json:"owner_id"`
+	IsPublic       bool               `json:"is_public"`
+	ExpiresAt      *time.Time         `json:"expires_at"`
+	AllPaidAt      *time.Time         `json:"all_paid_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	Members        []GroupMember      `json:"members,omitempty"`
+	App            *App               `json:"app,omitempty"`
+	Owner          *UserResponse      `json:"owner,omitempty"`
 }
 
 // State Machine Constants
@@ -106,12 +117,32 @@ const (
 	GroupStatusPrivate   = "private"
 	GroupStatusFull      = "full"
 	GroupStatusPaidGroup = "paid_group"
+	GroupStatusPaused    = "paused"  // members keep their seat and are not billed
+	GroupStatusClosing   = "closing" // closes at the end of the current period, no renewals
 	GroupStatusClosed    = "closed"
 
 	// Payment timeout (24 hours)
 	PaymentTimeoutHours = 24
+
+	// Lifetime of a payment link created for a seat
+	PaymentLinkExpiryHours = 24
 )
 
+// GroupLifecycleRequest is the reason an owner gives for pausing or closing a group
+type GroupLifecycleRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GroupLifecycle is the pause and scheduled closure state of a group
+type GroupLifecycle struct {
+	GroupID       string     `json:"group_id"`
+	GroupStatus   string     `json:"group_status"`
+	PausedAt      *time.Time `json:"paused_at,omitempty"`
+	PauseReason   *string    `json:"pause_reason,omitempty"`
+	ClosesAt      *time.Time `json:"closes_at,omitempty"`
+	ClosureReason *string    `json:"closure_reason,omitempty"`
+}
+
 // Admin Request/Response
 type AdminUserStatusRequest struct {
 	UserID        string `json:"user_id" binding:"required"`
@@ -123,4 +154,5 @@ type AdminUserStatusRequest struct {
 type AdminGroupStatusRequest struct {
 	GroupID   string `json:"group_id" binding:"required"`
 	NewStatus string `json:"new_status" binding:"required"`
+	Reason    string `json:"reason,omitempty"`
 }
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Group invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationExpired  = "expired"
+)
+
+// How a group invitation reached the invited person
+const (
+	InvitationDeliveryNotification = "notification"
+	InvitationDeliveryEmail        = "email"
+)
+
+// GroupInvitation is an owner's invitation to an email address to join a group. Existing users are notified
+// in the app; other addresses receive an email with a sign-up-and-join link.
+type GroupInvitation struct {
+	ID          string     `json:"id" db:"id"`
+	GroupID     string     `json:"group_id" db:"group_id"`
+	InvitedBy   *string    `json:"invited_by,omitempty" db:"invited_by"`
+	Email       string     `json:"email" db:"email"`
+	UserID      *string    `json:"user_id,omitempty" db:"user_id"`
+	Token       string     `json:"token,omitempty" db:"token"`
+	Status      string     `json:"status" db:"status"`
+	Delivery    string     `json:"delivery" db:"delivery"`
+	EmailSentAt *time.Time `json:"email_sent_at,omitempty" db:"email_sent_at"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName   string `json:"group_name,omitempty"`
+	AppName     string `json:"app_name,omitempty"`
+	InviterName string `json:"inviter_name,omitempty"`
+}
+
+// GroupInvitationRequest invites a list of email addresses to a group
+type GroupInvitationRequest struct {
+	Emails []string `json:"emails" binding:"required,min=1,max=50,dive,required,email"`
+}
+
+// Outcomes of inviting one email address
+const (
+	InvitationResultSent           = "sent"
+	InvitationResultAlreadyMember  = "already_member"
+	InvitationResultAlreadyInvited = "already_invited"
+	InvitationResultSelf           = "self"
+	InvitationResultEmailFailed    = "email_failed" // the invitation exists but the email could not be delivered
+)
+
+// GroupInvitationResult is the outcome of inviting one email address
+type GroupInvitationResult struct {
+	Email      string           `json:"email"`
+	Result     string           `json:"result"`
+	Invitation *GroupInvitation `json:"invitation,omitempty"`
+}
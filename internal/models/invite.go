@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// Invite link statuses, derived from the link's expiry, usage and revocation
+const (
+	InviteActive    = "active"
+	InviteExpired   = "expired"
+	InviteExhausted = "exhausted"
+	InviteRevoked   = "revoked"
+)
+
+// GroupInvite is an invite link of a group
+type GroupInvite struct {
+	ID          string     `json:"id" db:"id"`
+	GroupID     string     `json:"group_id" db:"group_id"`
+	Code        string     `json:"code" db:"code"`
+	CreatedBy   *string    `json:"created_by,omitempty" db:"created_by"`
+	TargetEmail *string    `json:"target_email,omitempty" db:"target_email"`
+	MaxUses     *int       `json:"max_uses,omitempty" db:"max_uses"`
+	UseCount    int        `json:"use_count" db:"use_count"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ReplacedBy  *string    `json:"replaced_by,omitempty" db:"replaced_by"`
+	Status      string     `json:"status"`
+	IsPrimary   bool       `json:"is_primary"` // the group's invite_code
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// GroupInviteRequest creates an invite link. Zero values mean no expiry, no usage cap and anyone can use it.
+type GroupInviteRequest struct {
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0"`
+	MaxUses        int    `json:"max_uses" binding:"min=0"`
+	TargetEmail    string `json:"target_email" binding:"omitempty,email"`
+}
+
+// GroupInviteUse is a user who took a seat through an invite link
+type GroupInviteUse struct {
+	ID         string    `json:"id" db:"id"`
+	InviteID   string    `json:"invite_id" db:"invite_id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	UserName   string    `json:"user_name"`
+	UserEmail  string    `json:"user_email"`
+	UserStatus *string   `json:"user_status,omitempty"` // NULL when the user has left the group since
+	UsedAt     time.Time `json:"used_at" db:"used_at"`
+}
+
+// InvitePreview is what the public invite page shows about a link
+type InvitePreview struct {
+	Code       string     `json:"code"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	UsesLeft   *int       `json:"uses_left,omitempty"`
+	Restricted bool       `json:"restricted"` // only the invited email can join
+}
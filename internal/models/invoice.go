@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Invoice is the numbered receipt of a successful payment or top-up
+type Invoice struct {
+	ID            string     `json:"id" db:"id"`
+	InvoiceNumber string     `json:"invoice_number" db:"invoice_number"`
+	TransactionID string     `json:"transaction_id" db:"transaction_id"`
+	UserID        string     `json:"user_id" db:"user_id"`
+	IssuedAt      time.Time  `json:"issued_at" db:"issued_at"`
+	EmailedAt     *time.Time `json:"emailed_at,omitempty" db:"emailed_at"`
+
+	// Filled in from the transaction, user and group when the invoice is loaded
+	CustomerName     string           `json:"customer_name"`
+	CustomerEmail    string           `json:"customer_email"`
+	TransactionType  string           `json:"transaction_type"`
+	Description      string           `json:"description"`
+	PaymentMethod    string           `json:"payment_method"`
+	PaymentReference *string          `json:"payment_reference,omitempty"`
+	PaidAt           time.Time        `json:"paid_at"`
+	GroupID          *string          `json:"group_id,omitempty"`
+	GroupName        string           `json:"group_name,omitempty"`
+	AppName          string           `json:"app_name,omitempty"`
+	PeriodStart      *time.Time       `json:"period_start,omitempty"`
+	PeriodEnd        *time.Time       `json:"period_end,omitempty"`
+	Lines            []InvoiceLine    `json:"lines"`
+	Total            money.Amount     `json:"total"`
+	Payments         []InvoicePayment `json:"payments"`
+}
+
+// InvoiceLine is one row of the fee breakdown; discounts are negative
+type InvoiceLine struct {
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+}
+
+// InvoicePayment is the part of the total paid with one payment method
+type InvoicePayment struct {
+	Method string       `json:"method"`
+	Amount money.Amount `json:"amount"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job run statuses
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun is one execution of a background job
+type JobRun struct {
+	ID          string          `json:"id" db:"id"`
+	JobName     string          `json:"job_name" db:"job_name"`
+	Status      string          `json:"status" db:"status"`
+	TriggeredBy string          `json:"triggered_by" db:"triggered_by"`
+	Instance    *string         `json:"instance,omitempty" db:"instance"`
+	Result      json.RawMessage `json:"result,omitempty" db:"result"`
+	Error       *string         `json:"error,omitempty" db:"error"`
+	StartedAt   time.Time       `json:"started_at" db:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+	DurationMs  *int64          `json:"duration_ms,omitempty" db:"duration_ms"`
+}
+
+// JobInfo describes a registered background job and its latest run
+type JobInfo struct {
+	Name     string  `json:"name"`
+	Interval string  `json:"interval"`
+	LastRun  *JobRun `json:"last_run,omitempty"`
+}
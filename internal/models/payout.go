@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Payout statuses
+const (
+	PayoutRequested = "requested"
+	PayoutApproved  = "approved"
+	PayoutPaid      = "paid"
+	PayoutRejected  = "rejected"
+)
+
+// PayoutAccount is a bank account a user withdraws money to
+type PayoutAccount struct {
+	ID            string    `json:"id" db:"id"`
+	UserID        string    `json:"user_id" db:"user_id"`
+	BankCode      string    `json:"bank_code" db:"bank_code"`
+	BankName      string    `json:"bank_name" db:"bank_name"`
+	AccountNumber string    `json:"account_number" db:"account_number"`
+	AccountHolder string    `json:"account_holder" db:"account_holder"`
+	IsDefault     bool      `json:"is_default" db:"is_default"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PayoutAccountRequest adds a bank account
+type PayoutAccountRequest struct {
+	BankCode      string `json:"bank_code" binding:"required,max=20"`
+	BankName      string `json:"bank_name" binding:"required,max=100"`
+	AccountNumber string `json:"account_number" binding:"required,numeric,min=5,max=30"`
+	AccountHolder string `json:"account_holder" binding:"required,max=255"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+// Payout is a request to withdraw wallet balance, or an owner's group earnings, to a bank account
+type Payout struct {
+	ID              string       `json:"id" db:"id"`
+	UserID          string       `json:"user_id" db:"user_id"`
+	GroupID         *string      `json:"group_id,omitempty" db:"group_id"`
+	PayoutAccountID string       `json:"payout_account_id" db:"payout_account_id"`
+	TransactionID   *string      `json:"transaction_id,omitempty" db:"transaction_id"`
+	Amount          money.Amount `json:"amount" db:"amount"`
+	Fee             money.Amount `json:"fee" db:"fee"`
+	NetAmount       money.Amount `json:"net_amount" db:"net_amount"`
+	BankName        string       `json:"bank_name" db:"bank_name"`
+	AccountNumber   string       `json:"account_number" db:"account_number"`
+	AccountHolder   string       `json:"account_holder" db:"account_holder"`
+	Status          string       `json:"status" db:"status"`
+	ReviewedBy      *string      `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt      *time.Time   `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	PaidAt          *time.Time   `json:"paid_at,omitempty" db:"paid_at"`
+	PaidReference   *string      `json:"paid_reference,omitempty" db:"paid_reference"`
+	Note            *string      `json:"note,omitempty" db:"note"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+	UserName  string `json:"user_name,omitempty"`
+}
+
+// PayoutCreateRequest asks for a withdrawal. Owners set GroupID to withdraw the earnings of a group they own.
+type PayoutCreateRequest struct {
+	Amount          money.Amount `json:"amount" binding:"required,gt=0"`
+	PayoutAccountID string       `json:"payout_account_id" binding:"required,uuid"`
+	GroupID         *string      `json:"group_id" binding:"omitempty,uuid"`
+}
+
+// PayoutReviewRequest carries an admin's note when approving or rejecting a payout
+type PayoutReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// PayoutPaidRequest confirms the bank transfer of an approved payout
+type PayoutPaidRequest struct {
+	Reference string `json:"reference" binding:"required"`
+	Note      string `json:"note"`
+}
+
+// GroupEarnings is the money members paid into a group that its owner can withdraw
+type GroupEarnings struct {
+	GroupID   string       `json:"group_id"`
+	GroupName string       `json:"group_name"`
+	Balance   money.Amount `json:"balance"`
+}
+
+// PayoutBalance lists what a user can withdraw and the payout rules
+type PayoutBalance struct {
+	Wallet        money.Amount    `json:"wallet"`
+	Withdrawable  money.Amount    `json:"withdrawable"` // part of the wallet backed by gateway payments
+	Groups        []GroupEarnings `json:"groups"`
+	MinimumAmount money.Amount    `json:"minimum_amount"`
+	Fee           money.Amount    `json:"fee"`
+	FeePercentage float64         `json:"fee_percentage"`
+}
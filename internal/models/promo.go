@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Promo code discount types
+const (
+	PromoDiscountPercentage = "percentage"
+	PromoDiscountFixed      = "fixed"
+)
+
+// Promo redemption statuses
+const (
+	PromoRedemptionPending  = "pending"
+	PromoRedemptionRedeemed = "redeemed"
+	PromoRedemptionReleased = "released"
+)
+
+// PromoCode is a voucher that discounts a group seat at checkout
+type PromoCode struct {
+	ID            string        `json:"id" db:"id"`
+	Code          string        `json:"code" db:"code"`
+	Description   *string       `json:"description,omitempty" db:"description"`
+	DiscountType  string        `json:"discount_type" db:"discount_type"`
+	DiscountValue float64       `json:"discount_value" db:"discount_value"`
+	MaxDiscount   *money.Amount `json:"max_discount,omitempty" db:"max_discount"`
+	MinAmount     money.Amount  `json:"min_amount" db:"min_amount"`
+	UsageLimit    *int          `json:"usage_limit,omitempty" db:"usage_limit"`
+	PerUserLimit  *int          `json:"per_user_limit,omitempty" db:"per_user_limit"`
+	AppIDs        []string      `json:"app_ids" db:"app_ids"`
+	FirstTimeOnly bool          `json:"first_time_only" db:"first_time_only"`
+	StartsAt      *time.Time    `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt        *time.Time    `json:"ends_at,omitempty" db:"ends_at"`
+	IsActive      bool          `json:"is_active" db:"is_active"`
+	CreatedBy     *string       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+
+	// Pending and redeemed uses
+	UsedCount int `json:"used_count"`
+}
+
+// PromoCodeRequest creates or updates a promo code. Empty AppIDs means any app.
+type PromoCodeRequest struct {
+	Code          string        `json:"code" binding:"required,max=50"`
+	Description   string        `json:"description"`
+	DiscountType  string        `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	DiscountValue float64       `json:"discount_value" binding:"required,gt=0"`
+	MaxDiscount   *money.Amount `json:"max_discount" binding:"omitempty,gt=0"`
+	MinAmount     money.Amount  `json:"min_amount" binding:"min=0"`
+	UsageLimit    *int          `json:"usage_limit" binding:"omitempty,gt=0"`
+	PerUserLimit  *int          `json:"per_user_limit" binding:"omitempty,gt=0"`
+	AppIDs        []string      `json:"app_ids"`
+	FirstTimeOnly bool          `json:"first_time_only"`
+	StartsAt      *time.Time    `json:"starts_at"`
+	EndsAt        *time.Time    `json:"ends_at"`
+	IsActive      *bool         `json:"is_active"`
+}
+
+// PromoRedemption is one use of a promo code on a group payment
+type PromoRedemption struct {
+	ID             string       `json:"id" db:"id"`
+	PromoCodeID    string       `json:"promo_code_id" db:"promo_code_id"`
+	UserID         string       `json:"user_id" db:"user_id"`
+	GroupID        *string      `json:"group_id,omitempty" db:"group_id"`
+	TransactionID  string       `json:"transaction_id" db:"transaction_id"`
+	OriginalAmount money.Amount `json:"original_amount" db:"original_amount"`
+	DiscountAmount money.Amount `json:"discount_amount" db:"discount_amount"`
+	FeeDiscount    money.Amount `json:"fee_discount" db:"fee_discount"`
+	SubsidyAmount  money.Amount `json:"subsidy_amount" db:"subsidy_amount"`
+	Status         string       `json:"status" db:"status"`
+	RedeemedAt     *time.Time   `json:"redeemed_at,omitempty" db:"redeemed_at"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+
+	// Joined fields
+	UserName  string `json:"user_name,omitempty"`
+	GroupName string `json:"group_name,omitempty"`
+}
+
+// PromoValidateRequest checks a promo code against a group seat before paying
+type PromoValidateRequest struct {
+	Code    string `json:"code" binding:"required"`
+	GroupID string `json:"group_id" binding:"required,uuid"`
+}
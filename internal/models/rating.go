@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Who a rating is about
+const (
+	RatingRoleOwner  = "owner"  // a member rated the group owner
+	RatingRoleMember = "member" // the owner rated a member
+)
+
+// UserRating is a rating given in a group after a billing period
+type UserRating struct {
+	ID        string    `json:"id" db:"id"`
+	GroupID   string    `json:"group_id" db:"group_id"`
+	RaterID   string    `json:"rater_id" db:"rater_id"`
+	RateeID   string    `json:"ratee_id" db:"ratee_id"`
+	RateeRole string    `json:"ratee_role" db:"ratee_role"`
+	PeriodEnd time.Time `json:"period_end" db:"period_end"`
+	Score     int       `json:"score" db:"score"`
+	Comment   *string   `json:"comment,omitempty" db:"comment"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+	RaterName string `json:"rater_name,omitempty"`
+}
+
+// RatingRequest rates the owner of a group, or a member when the owner rates
+type RatingRequest struct {
+	RateeID string `json:"ratee_id" binding:"required,uuid"`
+	Score   int    `json:"score" binding:"required,min=1,max=5"`
+	Comment string `json:"comment" binding:"max=1000"`
+}
+
+// RatingTarget is someone the user can rate in a group, with the rating already given for the last
+// ended billing period
+type RatingTarget struct {
+	UserID    string      `json:"user_id"`
+	FullName  string      `json:"full_name"`
+	Role      string      `json:"role"`
+	PeriodEnd *time.Time  `json:"period_end,omitempty"` // NULL until a billing period has ended
+	CanRate   bool        `json:"can_rate"`
+	Rating    *UserRating `json:"rating,omitempty"`
+}
+
+// UserReputation aggregates the ratings a user received and their payment record into a 0-100 score
+type UserReputation struct {
+	Score             float64    `json:"score"`
+	OwnerRating       *float64   `json:"owner_rating,omitempty"`
+	OwnerRatingCount  int        `json:"owner_rating_count"`
+	MemberRating      *float64   `json:"member_rating,omitempty"`
+	MemberRatingCount int        `json:"member_rating_count"`
+	LatePayments      int        `json:"late_payments"`
+	Removals          int        `json:"removals"`
+	Disputes          int        `json:"disputes"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
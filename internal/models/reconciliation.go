@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Reconciliation report statuses
+const (
+	ReconciliationRunning   = "running"
+	ReconciliationCompleted = "completed"
+	ReconciliationFailed    = "failed"
+)
+
+// Reconciliation discrepancy kinds
+const (
+	DiscrepancyStatusMismatch = "status_mismatch"
+	DiscrepancyAmountMismatch = "amount_mismatch"
+	DiscrepancyMethodMismatch = "method_mismatch"
+	DiscrepancyNotFound       = "not_found"
+	DiscrepancyProviderError  = "provider_error"
+)
+
+// ReconciliationReport is one run comparing gateway transactions with the payment provider
+type ReconciliationReport struct {
+	ID            string               `json:"id" db:"id"`
+	RangeFrom     time.Time            `json:"range_from" db:"range_from"`
+	RangeTo       time.Time            `json:"range_to" db:"range_to"`
+	AutoFix       bool                 `json:"auto_fix" db:"auto_fix"`
+	TriggeredBy   *string              `json:"triggered_by,omitempty" db:"triggered_by"`
+	Status        string               `json:"status" db:"status"`
+	Checked       int                  `json:"checked" db:"checked"`
+	Matched       int                  `json:"matched" db:"matched"`
+	Discrepancies int                  `json:"discrepancies" db:"discrepancies"`
+	Fixed         int                  `json:"fixed" db:"fixed"`
+	Errors        int                  `json:"errors" db:"errors"`
+	Error         *string              `json:"error,omitempty" db:"error"`
+	StartedAt     time.Time            `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time           `json:"finished_at,omitempty" db:"finished_at"`
+	Items         []ReconciliationItem `json:"items,omitempty"`
+}
+
+// ReconciliationItem is a discrepancy between a transaction and the payment provider
+type ReconciliationItem struct {
+	ID               string        `json:"id" db:"id"`
+	ReportID         string        `json:"report_id" db:"report_id"`
+	TransactionID    *string       `json:"transaction_id,omitempty" db:"transaction_id"`
+	PaymentReference string        `json:"payment_reference" db:"payment_reference"`
+	TransactionType  string        `json:"transaction_type" db:"transaction_type"`
+	Kind             string        `json:"kind" db:"kind"`
+	LocalStatus      string        `json:"local_status" db:"local_status"`
+	ProviderStatus   string        `json:"provider_status" db:"provider_status"`
+	LocalAmount      money.Amount  `json:"local_amount" db:"local_amount"`
+	ProviderAmount   *money.Amount `json:"provider_amount,omitempty" db:"provider_amount"`
+	LocalMethod      string        `json:"local_method" db:"local_method"`
+	ProviderMethod   string        `json:"provider_method" db:"provider_method"`
+	Fixed            bool          `json:"fixed" db:"fixed"`
+	Action           string        `json:"action" db:"action"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ReconciliationRunRequest starts a reconciliation over transactions created between From and To
+// (YYYY-MM-DD, To inclusive). Both default to the configured lookback window.
+type ReconciliationRunRequest struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	AutoFix *bool  `json:"auto_fix"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Refund statuses
+const (
+	RefundPendingApproval = "pending_approval"
+	RefundPendingGateway  = "pending_gateway" // booked, waiting for the payment gateway to pay it out
+	RefundCompleted       = "completed"
+	RefundRejected        = "rejected"
+)
+
+// Refund reasons
+const (
+	RefundReasonLeave          = "leave"
+	RefundReasonAdminRemove    = "admin_remove"
+	RefundReasonPaymentTimeout = "payment_timeout"
+	RefundReasonLatePayment    = "late_payment"
+	RefundReasonGroupClosed    = "group_closed"
+	RefundReasonDispute        = "dispute"
+)
+
+// Refund methods
+const (
+	RefundMethodWallet  = "wallet"
+	RefundMethodGateway = "gateway"
+)
+
+// Refund returns (part of) a group payment to the member who made it
+type Refund struct {
+	ID                    string       `json:"id" db:"id"`
+	UserID                string       `json:"user_id" db:"user_id"`
+	GroupID               *string      `json:"group_id,omitempty" db:"group_id"`
+	OriginalTransactionID string       `json:"original_transaction_id" db:"original_transaction_id"`
+	RefundTransactionID   *string      `json:"refund_transaction_id,omitempty" db:"refund_transaction_id"`
+	Reason                string       `json:"reason" db:"reason"`
+	Method                string       `json:"method" db:"method"`
+	Amount                money.Amount `json:"amount" db:"amount"`
+	AdminFee              money.Amount `json:"admin_fee" db:"admin_fee"`
+	PaidAmount            money.Amount `json:"paid_amount" db:"paid_amount"`
+	ProrateRatio          float64      `json:"prorate_ratio" db:"prorate_ratio"`
+	PeriodStart           *time.Time   `json:"period_start,omitempty" db:"period_start"`
+	PeriodEnd             *time.Time   `json:"period_end,omitempty" db:"period_end"`
+	Status                string       `json:"status" db:"status"`
+	RequestedBy           *string      `json:"requested_by,omitempty" db:"requested_by"`
+	ReviewedBy            *string      `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt            *time.Time   `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	Note                  *string      `json:"note,omitempty" db:"note"`
+	CreatedAt             time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+	UserName  string `json:"user_name,omitempty"`
+}
+
+// RefundReviewRequest carries an admin's note when approving or rejecting a refund
+type RefundReviewRequest struct {
+	Note string `json:"note"`
+}
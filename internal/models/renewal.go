@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
+
+// Renewal invoice statuses
+const (
+	RenewalInvoiceOpen      = "open"
+	RenewalInvoicePaid      = "paid"
+	RenewalInvoiceCancelled = "cancelled"
+)
+
+// RenewalInvoice bills a member for the next subscription period of a paid group
+type RenewalInvoice struct {
+	ID            string       `json:"id" db:"id"`
+	GroupID       string       `json:"group_id" db:"group_id"`
+	UserID        string       `json:"user_id" db:"user_id"`
+	PeriodStart   time.Time    `json:"period_start" db:"period_start"`
+	PeriodEnd     time.Time    `json:"period_end" db:"period_end"`
+	Amount        money.Amount `json:"amount" db:"amount"`
+	AdminFee      money.Amount `json:"admin_fee" db:"admin_fee"`
+	Status        string       `json:"status" db:"status"`
+	DueAt         time.Time    `json:"due_at" db:"due_at"`
+	TransactionID *string      `json:"transaction_id,omitempty" db:"transaction_id"`
+	PaidAt        *time.Time   `json:"paid_at,omitempty" db:"paid_at"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+}
+
+// RenewalPayRequest selects how a renewal invoice is paid
+type RenewalPayRequest struct {
+	Method string `json:"method" binding:"required,oneof=balance link"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Status event entities
+const (
+	StatusEntityMember = "member"
+	StatusEntityGroup  = "group"
+)
+
+// Status event actors
+const (
+	ActorUser    = "user"
+	ActorAdmin   = "admin"
+	ActorSystem  = "system"
+	ActorWebhook = "webhook"
+)
+
+// StatusEvent is one recorded transition of a group or of a member of a group
+type StatusEvent struct {
+	ID            string    `json:"id" db:"id"`
+	EntityType    string    `json:"entity_type" db:"entity_type"`
+	GroupID       string    `json:"group_id" db:"group_id"`
+	UserID        *string   `json:"user_id,omitempty" db:"user_id"`
+	FromStatus    *string   `json:"from_status" db:"from_status"`
+	ToStatus      string    `json:"to_status" db:"to_status"`
+	ActorType     string    `json:"actor_type" db:"actor_type"`
+	ActorID       *string   `json:"actor_id,omitempty" db:"actor_id"`
+	Reason        *string   `json:"reason,omitempty" db:"reason"`
+	CorrelationID *string   `json:"correlation_id,omitempty" db:"correlation_id"`
+	IsOverride    bool      `json:"is_override" db:"is_override"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+	UserName  string `json:"user_name,omitempty"`
+	ActorName string `json:"actor_name,omitempty"`
+}
+
+// StatusEventFilter narrows the admin timeline; empty fields match everything
+type StatusEventFilter struct {
+	GroupID       string
+	UserID        string
+	EntityType    string
+	ActorType     string
+	CorrelationID string
+	Since         *time.Time
+	Until         *time.Time
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"salome-be/internal/money"
+)
 
 type PaidGroupWithCredentials struct {
 	ID                 string                      `json:"id"`
@@ -9,8 +13,8 @@ type PaidGroupWithCredentials struct {
 	AppID              string                      `json:"app_id"`
 	MaxMembers         int                         `json:"max_members"`
 	MemberCount        int                         `json:"member_count"`
-	PricePerMember     int                         `json:"price_per_member"`
-	TotalPrice         int                         `json:"total_price"`
+	PricePerMember     money.Amount                `json:"price_per_member"`
+	TotalPrice         money.Amount                `json:"total_price"`
 	GroupStatus        string                      `json:"group_status"`
 	AllPaidAt          time.Time                   `json:"all_paid_at"`
 	CreatedAt          time.Time                   `json:"created_at"`
@@ -3,22 +3,24 @@ package models
 import (
 	"time"
 
+	"salome-be/internal/money"
+
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID             uuid.UUID `json:"id" db:"id"`
-	Email          string    `json:"email" db:"email"`
-	PasswordHash   string    `json:"-" db:"password_hash"`
-	FullName       string    `json:"full_name" db:"full_name"`
-	WhatsappNumber *string   `json:"whatsapp_number" db:"whatsapp_number"`
-	AvatarURL      *string   `json:"avatar_url" db:"avatar_url"`
-	Status         string    `json:"status" db:"status"`
-	Balance        float64   `json:"balance" db:"balance"`
-	TotalSpent     float64   `json:"total_spent" db:"total_spent"`
-	IsAdmin        bool      `json:"is_admin" db:"is_admin"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID    `json:"id" db:"id"`
+	Email          string       `json:"email" db:"email"`
+	PasswordHash   string       `json:"-" db:"password_hash"`
+	FullName       string       `json:"full_name" db:"full_name"`
+	WhatsappNumber *string      `json:"whatsapp_number" db:"whatsapp_number"`
+	AvatarURL      *string      `json:"avatar_url" db:"avatar_url"`
+	Status         string       `json:"status" db:"status"`
+	Balance        money.Amount `json:"balance" db:"balance"`
+	TotalSpent     money.Amount `json:"total_spent" db:"total_spent"`
+	IsAdmin        bool         `json:"is_admin" db:"is_admin"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
 }
 
 type UserCreateRequest struct {
@@ -34,14 +36,15 @@ type UserLoginRequest struct {
 }
 
 type UserResponse struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	FullName       string    `json:"full_name"`
-	WhatsappNumber *string   `json:"whatsapp_number"`
-	AvatarURL      *string   `json:"avatar_url"`
-	Status         string    `json:"status"`
-	Balance        float64   `json:"balance"`
-	TotalSpent     float64   `json:"total_spent"`
-	IsAdmin        bool      `json:"is_admin"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             uuid.UUID       `json:"id"`
+	Email          string          `json:"email"`
+	FullName       string          `json:"full_name"`
+	WhatsappNumber *string         `json:"whatsapp_number"`
+	AvatarURL      *string         `json:"avatar_url"`
+	Status         string          `json:"status"`
+	Balance        money.Amount    `json:"balance"`
+	TotalSpent     money.Amount    `json:"total_spent"`
+	IsAdmin        bool            `json:"is_admin"`
+	Reputation     *UserReputation `json:"reputation,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
 }
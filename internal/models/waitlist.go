@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistClaimed   = "claimed"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry is a user queueing for a seat in a full group. When a seat frees up it is offered to the
+// first waiting user, who can claim it until OfferExpiresAt.
+type WaitlistEntry struct {
+	ID             string     `json:"id" db:"id"`
+	GroupID        string     `json:"group_id" db:"group_id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	Position       int        `json:"position" db:"position"`
+	Status         string     `json:"status" db:"status"`
+	Place          int        `json:"place,omitempty"` // place in line of a waiting entry, 1 is next
+	OfferedAt      *time.Time `json:"offered_at,omitempty" db:"offered_at"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty" db:"offer_expires_at"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty" db:"claimed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Joined fields
+	GroupName string `json:"group_name,omitempty"`
+	UserName  string `json:"user_name,omitempty"`
+	UserEmail string `json:"user_email,omitempty"`
+}
+
+// WaitlistJoinRequest queues the user for a seat. Groups that are not public also need their invite code.
+type WaitlistJoinRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// WaitlistReorderRequest is the owner's new order of the waiting entries, first in line first
+type WaitlistReorderRequest struct {
+	EntryIDs []string `json:"entry_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event statuses
+const (
+	WebhookEventReceived  = "received"
+	WebhookEventProcessed = "processed"
+	WebhookEventFailed    = "failed"
+)
+
+// WebhookEvent is a payment notification stored in the webhook inbox
+type WebhookEvent struct {
+	ID                string          `json:"id" db:"id"`
+	Provider          string          `json:"provider" db:"provider"`
+	TransactionID     string          `json:"transaction_id" db:"transaction_id"`
+	TransactionStatus string          `json:"transaction_status" db:"transaction_status"`
+	OrderID           string          `json:"order_id" db:"order_id"`
+	PaymentReference  *string         `json:"payment_reference,omitempty" db:"payment_reference"`
+	Payload           json.RawMessage `json:"payload" db:"payload"`
+	Status            string          `json:"status" db:"status"`
+	Attempts          int             `json:"attempts" db:"attempts"`
+	LastError         *string         `json:"last_error,omitempty" db:"last_error"`
+	ReceivedAt        time.Time       `json:"received_at" db:"received_at"`
+	ProcessedAt       *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+	UpdatedAt         time.Time       `json:"updated_at" db:"updated_at"`
+}
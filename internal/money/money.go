@@ -0,0 +1,268 @@
+// Package money represents amounts of money as integer minor units, so prices, fees and balances add up
+// without floating point drift.
+//
+// Amounts are stored in the database as NUMERIC(15,2) major units and in the ledger as BIGINT minor units.
+// In JSON they are major-unit numbers, so API responses keep their existing shape.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// IDR is the currency of every amount on the platform
+const IDR Currency = "IDR"
+
+// DefaultCurrency is used when an amount does not say otherwise
+const DefaultCurrency = IDR
+
+// minorPerMajor is the number of minor units in one major unit. Rupiah have no sen in practice, but fees
+// and prorated refunds are computed to the sen before they are rounded.
+const minorPerMajor = 100
+
+// ErrInvalidAmount is returned when a value cannot be read as an amount of money
+var ErrInvalidAmount = errors.New("money: invalid amount")
+
+// Amount is an amount of money in minor units
+type Amount int64
+
+// FromMinor returns an amount of minor units
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromMajor converts a major-unit value, rounding to the nearest minor unit
+func FromMajor(major float64) Amount {
+	return Amount(math.Round(major * minorPerMajor))
+}
+
+// Whole returns an amount of whole major units
+func Whole(major int64) Amount {
+	return Amount(major * minorPerMajor)
+}
+
+// Minor returns the amount in minor units
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Major returns the amount in major units
+func (a Amount) Major() float64 {
+	return float64(a) / minorPerMajor
+}
+
+// Ceil rounds the amount up to whole major units
+func (a Amount) Ceil() Amount {
+	whole := a / minorPerMajor * minorPerMajor
+	if whole < a {
+		whole += minorPerMajor
+	}
+	return whole
+}
+
+// Units returns the amount in whole major units, rounded up. Payment gateways charge whole rupiah.
+func (a Amount) Units() int64 {
+	return int64(a.Ceil() / minorPerMajor)
+}
+
+// Min returns the smaller of a and b
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of a and b
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// String formats the amount as a decimal number of major units, e.g. "150000.00"
+func (a Amount) String() string {
+	sign := ""
+	minor := int64(a)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+// Format formats the amount for people, e.g. "Rp 150.000"
+func (a Amount) Format() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+	digits := strconv.FormatInt(a.Units(), 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp " + b.String()
+}
+
+// Parse reads a decimal number of major units such as "150000", "150000.5" or "-2500.00" without going
+// through floating point
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/minorPerMajor-1 {
+		return 0, ErrInvalidAmount
+	}
+
+	// Anything below a minor unit is rounded half up
+	frac = strings.TrimRight(frac, "0")
+	var minor int64
+	if frac != "" {
+		padded := (frac + "00")[:2]
+		minor = int64(padded[0]-'0')*10 + int64(padded[1]-'0')
+		if len(frac) > 2 && frac[2] >= '5' {
+			minor++
+		}
+	}
+
+	amount := Amount(units*minorPerMajor + minor)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// isDigits reports whether s holds nothing but the digits 0-9
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Value stores the amount as a decimal number of major units
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a NUMERIC, integer or floating point column holding major units
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Whole(v)
+	case float64:
+		*a = FromMajor(v)
+	case []byte:
+		return a.parse(string(v))
+	case string:
+		return a.parse(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+	return nil
+}
+
+func (a *Amount) parse(s string) error {
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// MarshalJSON writes the amount as a number of major units, without decimals when it is whole
+func (a Amount) MarshalJSON() ([]byte, error) {
+	s := a.String()
+	s = strings.TrimSuffix(s, ".00")
+	if strings.Contains(s, ".") {
+		s = strings.TrimSuffix(s, "0")
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a number or a numeric string of major units
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	// Exponent notation is valid JSON but not a plain decimal
+	if strings.ContainsAny(s, "eE") {
+		var f float64
+		if err := json.Unmarshal([]byte(s), &f); err != nil {
+			return ErrInvalidAmount
+		}
+		*a = FromMajor(f)
+		return nil
+	}
+	return a.parse(s)
+}
+
+// UnmarshalYAML reads a configured amount of major units, e.g. `min_fee: 2500` or `min_fee: "2500.50"`
+func (a *Amount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return a.parse(s)
+}
+
+// Money is an amount in a currency
+type Money struct {
+	Amount   Amount   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// New returns amount in currency, or in the default currency when currency is empty
+func New(amount Amount, currency Currency) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Rupiah returns amount in IDR
+func Rupiah(amount Amount) Money {
+	return Money{Amount: amount, Currency: IDR}
+}
+
+// String formats the money as "150000.00 IDR"
+func (m Money) String() string {
+	return m.Amount.String() + " " + string(m.Currency)
+}
@@ -0,0 +1,87 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: "150000", want: Whole(150000)},
+		{in: "150000.5", want: FromMinor(15000050)},
+		{in: "150000.50", want: FromMinor(15000050)},
+		{in: "-2500.00", want: Whole(-2500)},
+		{in: "+12.34", want: FromMinor(1234)},
+		{in: " 7 ", want: Whole(7)},
+		{in: ".5", want: FromMinor(50)},
+		{in: "-.05", want: FromMinor(-5)},
+		{in: "1.", want: Whole(1)},
+		{in: "0", want: 0},
+		{in: "1.004", want: FromMinor(100)},
+		{in: "1.005", want: FromMinor(101)},
+		{in: "1.0050000", want: FromMinor(101)},
+		{in: "0.999", want: Whole(1)},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.-5", wantErr: true},
+		{in: "1.+5", wantErr: true},
+		{in: "1.005x", wantErr: true},
+		{in: "1.5x", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "-+1", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1,000", wantErr: true},
+		{in: "92233720368547758", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAmount) {
+					t.Fatalf("Parse(%q) = %v, %v, want ErrInvalidAmount", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{Whole(150000), "150000.00"},
+		{FromMinor(15000050), "150000.50"},
+		{FromMinor(5), "0.05"},
+		{FromMinor(-5), "-0.05"},
+		{Whole(-2500), "-2500.00"},
+		{0, "0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.in.String(); got != tt.want {
+				t.Errorf("Amount(%d).String() = %q, want %q", tt.in, got, tt.want)
+			}
+			back, err := Parse(tt.want)
+			if err != nil || back != tt.in {
+				t.Errorf("Parse(%q) = %d, %v, want %d", tt.want, back, err, tt.in)
+			}
+		})
+	}
+}
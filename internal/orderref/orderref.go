@@ -0,0 +1,119 @@
+// Package orderref generates the order IDs we send to the payment gateway and maps them back to
+// transactions.payment_reference.
+//
+// An order ID is "SALO-<type code>-<ULID>", always 33 characters: well within Midtrans' 36 character
+// limit, unique without coordination, sortable by creation time, and carrying the transaction type.
+// Payment link purchases come back as "<order ID>-<timestamp>"; because the length is fixed the
+// reference is simply the first 33 characters.
+package orderref
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// Type codes encoded in an order ID
+const (
+	CodeTopUp        = 'T'
+	CodeGroupPayment = 'G'
+	CodeRenewal      = 'R'
+)
+
+const (
+	prefix = "SALO-"
+	// Length is the length of every generated order ID
+	Length = len(prefix) + 2 + ulidLength
+
+	ulidLength = 26
+	crockford  = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// Order IDs generated before this package, e.g. SALO-GRP-123456
+var legacyPrefixes = []string{"SALO-TOPUP-", "SALO-GRP-", "SALO-RNW-"}
+
+// New returns a fresh order ID for the given type code
+func New(code byte) string {
+	return prefix + string(code) + "-" + newULID(time.Now())
+}
+
+// Resolve maps an order ID as reported by the gateway to the payment_reference stored on the transaction.
+// Unknown formats are returned unchanged.
+func Resolve(orderID string) string {
+	if ref, _, ok := Parse(orderID); ok {
+		return ref
+	}
+
+	// Legacy IDs: the six digit suffix is the third dash separated part,
+	// e.g. SALO-TOPUP-892929-1759555433162 -> SALO-TOPUP-892929
+	for _, legacy := range legacyPrefixes {
+		if strings.HasPrefix(orderID, legacy) {
+			parts := strings.Split(orderID, "-")
+			if len(parts) >= 3 {
+				return legacy + parts[2]
+			}
+		}
+	}
+	return orderID
+}
+
+// Parse checks that orderID starts with a generated order ID and returns that ID and its type code
+func Parse(orderID string) (string, byte, bool) {
+	if len(orderID) < Length || !strings.HasPrefix(orderID, prefix) {
+		return "", 0, false
+	}
+	// Anything after the ID must be a provider suffix
+	if len(orderID) > Length && orderID[Length] != '-' {
+		return "", 0, false
+	}
+
+	ref := orderID[:Length]
+	code := ref[len(prefix)]
+	if code < 'A' || code > 'Z' || ref[len(prefix)+1] != '-' {
+		return "", 0, false
+	}
+	for _, c := range ref[len(prefix)+2:] {
+		if !strings.ContainsRune(crockford, c) {
+			return "", 0, false
+		}
+	}
+	return ref, code, true
+}
+
+// TransactionType returns the transactions.type for a type code
+func TransactionType(code byte) string {
+	switch code {
+	case CodeTopUp:
+		return "top_up"
+	case CodeGroupPayment, CodeRenewal:
+		return "group_payment"
+	default:
+		return ""
+	}
+}
+
+// newULID encodes a 48 bit millisecond timestamp and 80 random bits in Crockford base32
+func newULID(t time.Time) string {
+	var data [16]byte
+	ms := uint64(t.UnixMilli())
+	data[0] = byte(ms >> 40)
+	data[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(data[2:6], uint32(ms))
+	if _, err := rand.Read(data[6:]); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+
+	hi := binary.BigEndian.Uint64(data[:8])
+	lo := binary.BigEndian.Uint64(data[8:])
+
+	// 128 bits in 26 characters of 5 bits each, the first character carries the top 3 bits
+	var out [ulidLength]byte
+	for i := ulidLength - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,116 @@
+package orderref
+
+import (
+	"testing"
+	"time"
+)
+
+const sampleID = "SALO-G-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8"
+
+func TestNew(t *testing.T) {
+	for _, code := range []byte{CodeTopUp, CodeGroupPayment, CodeRenewal} {
+		id := New(code)
+		if len(id) != Length {
+			t.Errorf("New(%q) = %q, length %d, want %d", code, id, len(id), Length)
+		}
+		ref, gotCode, ok := Parse(id)
+		if !ok || ref != id || gotCode != code {
+			t.Errorf("Parse(New(%q)) = %q, %q, %v, want %q, %q, true", code, ref, gotCode, ok, id, code)
+		}
+		if got := Resolve(id + "-1759555433162"); got != id {
+			t.Errorf("Resolve(%q with link suffix) = %q", id, got)
+		}
+	}
+
+	if a, b := New(CodeTopUp), New(CodeTopUp); a == b {
+		t.Errorf("New returned %q twice", a)
+	}
+}
+
+func TestNewULIDSortsByTime(t *testing.T) {
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := newULID(earlier)
+	for _, later := range []time.Time{earlier.Add(time.Millisecond), earlier.Add(time.Hour), earlier.AddDate(5, 0, 0)} {
+		next := newULID(later)
+		if next <= prev {
+			t.Errorf("newULID(%s) = %q, not after %q", later, next, prev)
+		}
+		prev = next
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderID string
+		ref     string
+		code    byte
+		ok      bool
+	}{
+		{"order ID", sampleID, sampleID, CodeGroupPayment, true},
+		{"payment link suffix", sampleID + "-1759555433162", sampleID, CodeGroupPayment, true},
+		{"top-up", "SALO-T-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", "SALO-T-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", CodeTopUp, true},
+		{"renewal", "SALO-R-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", "SALO-R-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", CodeRenewal, true},
+		{"too short", sampleID[:Length-1], "", 0, false},
+		{"suffix without dash", sampleID + "X", "", 0, false},
+		{"other prefix", "ABCD-G-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", "", 0, false},
+		{"lower case type code", "SALO-g-01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", "", 0, false},
+		{"missing dash after type code", "SALO-GX01J9Z3K8Q6V5W4X3Y2Z1A0B9C8", "", 0, false},
+		{"letter outside Crockford base32", "SALO-G-01J9Z3K8Q6V5W4X3Y2Z1A0B9CU", "", 0, false},
+		{"legacy group payment", "SALO-GRP-123456-1759555433162", "", 0, false},
+		{"empty", "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, code, ok := Parse(tt.orderID)
+			if ref != tt.ref || code != tt.code || ok != tt.ok {
+				t.Errorf("Parse(%q) = %q, %q, %v, want %q, %q, %v", tt.orderID, ref, code, ok, tt.ref, tt.code, tt.ok)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderID string
+		want    string
+	}{
+		{"order ID", sampleID, sampleID},
+		{"payment link suffix", sampleID + "-1759555433162", sampleID},
+		{"legacy top-up", "SALO-TOPUP-892929-1759555433162", "SALO-TOPUP-892929"},
+		{"legacy group payment", "SALO-GRP-123456-1759555433162", "SALO-GRP-123456"},
+		{"legacy renewal", "SALO-RNW-654321-1759555433162", "SALO-RNW-654321"},
+		{"legacy without suffix", "SALO-GRP-123456", "SALO-GRP-123456"},
+		{"legacy prefix only", "SALO-GRP-", "SALO-GRP-"},
+		{"unknown format", "ORDER-42", "ORDER-42"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Resolve(tt.orderID); got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.orderID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionType(t *testing.T) {
+	tests := []struct {
+		code byte
+		want string
+	}{
+		{CodeTopUp, "top_up"},
+		{CodeGroupPayment, "group_payment"},
+		{CodeRenewal, "group_payment"},
+		{'X', ""},
+	}
+
+	for _, tt := range tests {
+		if got := TransactionType(tt.code); got != tt.want {
+			t.Errorf("TransactionType(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
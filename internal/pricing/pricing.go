@@ -0,0 +1,164 @@
+// Package pricing computes seat prices: the member's share of an app subscription plus the admin fee,
+// and the surcharge of the payment method used to pay it.
+//
+// The admin fee is the app's percentage of the seat price plus a fixed fee, rounded to a multiple of RoundTo
+// rupiah and then kept between a minimum and a maximum. App settings override the configured defaults.
+// Seat prices and surcharges are whole rupiah, rounded up so the group owner never receives less than the
+// subscription costs.
+package pricing
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"salome-be/internal/config"
+	"salome-be/internal/money"
+)
+
+// Rounding modes for the admin fee
+const (
+	RoundUp      = "up"
+	RoundDown    = "down"
+	RoundNearest = "nearest"
+)
+
+// MethodWallet pays from the wallet balance and never has a surcharge
+const MethodWallet = "wallet"
+
+// DefaultMethod is the surcharge key used when the payment method is not known in advance
+const DefaultMethod = "default"
+
+// AppFees are the fee settings of an app; nil fields fall back to the configured defaults
+type AppFees struct {
+	Percentage *float64
+	Fixed      *money.Amount
+	Min        *money.Amount
+	Max        *money.Amount
+}
+
+// Breakdown explains how the price of a seat was computed. It is stored on groups and on the
+// transactions paying for a seat.
+type Breakdown struct {
+	AppPrice       money.Amount `json:"app_price,omitempty"`
+	MaxMembers     int          `json:"max_members,omitempty"`
+	PricePerMember money.Amount `json:"price_per_member"`
+	FeePercentage  float64      `json:"fee_percentage"`
+	PercentageFee  money.Amount `json:"percentage_fee"`
+	FixedFee       money.Amount `json:"fixed_fee"`
+	FeeAdjustment  money.Amount `json:"fee_adjustment"` // minimum/maximum caps and rounding
+	AdminFee       money.Amount `json:"admin_fee"`
+	Discount       money.Amount `json:"discount,omitempty"`
+	PromoCode      string       `json:"promo_code,omitempty"`
+	PaymentMethod  string       `json:"payment_method,omitempty"`
+	Surcharge      money.Amount `json:"surcharge,omitempty"`
+	Total          money.Amount `json:"total"`
+}
+
+// Value stores a breakdown as JSON
+func (b Breakdown) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan reads a breakdown stored as JSON
+func (b *Breakdown) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	case nil:
+		*b = Breakdown{}
+		return nil
+	default:
+		return errors.New("pricing: unsupported breakdown type")
+	}
+}
+
+// Engine computes prices with the configured rules
+type Engine struct {
+	config config.PricingConfig
+}
+
+func New(cfg config.PricingConfig) *Engine {
+	return &Engine{config: cfg}
+}
+
+// Default returns an engine using the application configuration
+func Default() *Engine {
+	return New(config.GetConfig().Pricing)
+}
+
+// Seat returns the price of one seat in a group of maxMembers sharing an app that costs appPrice
+func (e *Engine) Seat(appPrice money.Amount, maxMembers int, fees AppFees) Breakdown {
+	if maxMembers < 1 {
+		maxMembers = 1
+	}
+	members := money.Amount(maxMembers)
+
+	b := Breakdown{
+		AppPrice:       appPrice,
+		MaxMembers:     maxMembers,
+		PricePerMember: ((appPrice + members - 1) / members).Ceil(),
+		FeePercentage:  e.config.DefaultFeePercentage,
+		FixedFee:       amountOr(fees.Fixed, e.config.FixedFee),
+	}
+	if fees.Percentage != nil {
+		b.FeePercentage = *fees.Percentage
+	}
+	b.PercentageFee = money.FromMajor(b.PricePerMember.Major() * b.FeePercentage / 100)
+
+	// Rounded first so the caps hold exactly, even when they are not a multiple of RoundTo
+	fee := e.round(b.PercentageFee + b.FixedFee)
+	if min := amountOr(fees.Min, e.config.MinFee); min > 0 && fee < min {
+		fee = min
+	}
+	if max := amountOr(fees.Max, e.config.MaxFee); max > 0 && fee > max {
+		fee = max
+	}
+
+	b.AdminFee = fee
+	b.FeeAdjustment = fee - b.PercentageFee - b.FixedFee
+	b.Total = b.PricePerMember + b.AdminFee
+	return b
+}
+
+// Surcharge returns the surcharge for paying amount with method, in whole rupiah. Methods without their
+// own setting use the "default" surcharge; wallet payments have none.
+func (e *Engine) Surcharge(method string, amount money.Amount) money.Amount {
+	if method == MethodWallet || amount <= 0 {
+		return 0
+	}
+	if method == "" {
+		method = DefaultMethod
+	}
+	rule, ok := e.config.Surcharges[method]
+	if !ok {
+		rule = e.config.Surcharges[DefaultMethod]
+	}
+	return (money.FromMajor(amount.Major()*rule.Percentage/100) + rule.Fixed).Ceil()
+}
+
+// round rounds an admin fee to a multiple of RoundTo with the configured mode
+func (e *Engine) round(fee money.Amount) money.Amount {
+	step := e.config.RoundTo
+	if step <= 0 {
+		step = money.Whole(1)
+	}
+	switch e.config.Rounding {
+	case RoundDown:
+		return fee / step * step
+	case RoundNearest:
+		return (fee + step/2) / step * step
+	default:
+		return (fee + step - 1) / step * step
+	}
+}
+
+// amountOr returns the app setting when there is one, otherwise the configured default
+func amountOr(v *money.Amount, def money.Amount) money.Amount {
+	if v != nil {
+		return *v
+	}
+	return def
+}
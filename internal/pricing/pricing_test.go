@@ -0,0 +1,113 @@
+package pricing
+
+import (
+	"testing"
+
+	"salome-be/internal/config"
+	"salome-be/internal/money"
+)
+
+func amount(major int64) *money.Amount {
+	a := money.Whole(major)
+	return &a
+}
+
+func TestSeat(t *testing.T) {
+	base := config.PricingConfig{
+		DefaultFeePercentage: 10,
+		RoundTo:              money.Whole(100),
+		Rounding:             RoundUp,
+	}
+	with := func(change func(*config.PricingConfig)) config.PricingConfig {
+		cfg := base
+		change(&cfg)
+		return cfg
+	}
+	percentage := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name           string
+		config         config.PricingConfig
+		appPrice       money.Amount
+		maxMembers     int
+		fees           AppFees
+		pricePerMember money.Amount
+		adminFee       money.Amount
+	}{
+		{"percentage fee rounded up", base, money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(3800)},
+		{"rounded down", with(func(c *config.PricingConfig) { c.Rounding = RoundDown }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(3700)},
+		{"rounded to nearest, half up", with(func(c *config.PricingConfig) { c.Rounding = RoundNearest }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(3800)},
+		{"rounded to nearest, down", with(func(c *config.PricingConfig) { c.Rounding = RoundNearest }),
+			money.Whole(149600), 4, AppFees{}, money.Whole(37400), money.Whole(3700)},
+		{"seat price rounded up to whole rupiah", base, money.Whole(100000), 3, AppFees{}, money.Whole(33334), money.Whole(3400)},
+		{"no rounding step rounds to whole rupiah", with(func(c *config.PricingConfig) { c.RoundTo = 0 }),
+			money.Whole(100000), 3, AppFees{}, money.Whole(33334), money.Whole(3334)},
+		{"fixed fee is added", with(func(c *config.PricingConfig) { c.FixedFee = money.Whole(1000) }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(4800)},
+		{"minimum fee", with(func(c *config.PricingConfig) { c.MinFee = money.Whole(5000) }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(5000)},
+		{"maximum fee is not exceeded by rounding", with(func(c *config.PricingConfig) { c.MaxFee = money.Whole(3750) }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(3750)},
+		{"minimum fee is not undercut by rounding down",
+			with(func(c *config.PricingConfig) { c.MinFee = money.Whole(3750); c.Rounding = RoundDown }),
+			money.Whole(150000), 4, AppFees{}, money.Whole(37500), money.Whole(3750)},
+		{"app settings override the defaults", with(func(c *config.PricingConfig) { c.MaxFee = money.Whole(2000) }),
+			money.Whole(150000), 4, AppFees{Percentage: percentage(5), Fixed: amount(500), Max: amount(3000)},
+			money.Whole(37500), money.Whole(2400)},
+		{"app maximum", base, money.Whole(150000), 4, AppFees{Max: amount(2000)}, money.Whole(37500), money.Whole(2000)},
+		{"app minimum", base, money.Whole(150000), 4, AppFees{Min: amount(10000)}, money.Whole(37500), money.Whole(10000)},
+		{"no members counts as one", base, money.Whole(50000), 0, AppFees{}, money.Whole(50000), money.Whole(5000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.config).Seat(tt.appPrice, tt.maxMembers, tt.fees)
+			if b.PricePerMember != tt.pricePerMember {
+				t.Errorf("PricePerMember = %s, want %s", b.PricePerMember, tt.pricePerMember)
+			}
+			if b.AdminFee != tt.adminFee {
+				t.Errorf("AdminFee = %s, want %s", b.AdminFee, tt.adminFee)
+			}
+			if b.Total != b.PricePerMember+b.AdminFee {
+				t.Errorf("Total = %s, want %s", b.Total, b.PricePerMember+b.AdminFee)
+			}
+			if b.PercentageFee+b.FixedFee+b.FeeAdjustment != b.AdminFee {
+				t.Errorf("fee parts %s + %s + %s do not add up to %s", b.PercentageFee, b.FixedFee, b.FeeAdjustment, b.AdminFee)
+			}
+		})
+	}
+}
+
+func TestSurcharge(t *testing.T) {
+	engine := New(config.PricingConfig{
+		Surcharges: map[string]config.SurchargeConfig{
+			"qris":        {Percentage: 0.7},
+			"credit_card": {Percentage: 2.9, Fixed: money.Whole(2000)},
+			DefaultMethod: {Fixed: money.Whole(4000)},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		amount money.Amount
+		want   money.Amount
+	}{
+		{"wallet has no surcharge", MethodWallet, money.Whole(41300), 0},
+		{"nothing to pay", "qris", 0, 0},
+		{"percentage rounded up to whole rupiah", "qris", money.Whole(41300), money.Whole(290)},
+		{"percentage plus fixed", "credit_card", money.Whole(100000), money.Whole(4900)},
+		{"unknown method uses the default", "bank_transfer", money.Whole(41300), money.Whole(4000)},
+		{"no method uses the default", "", money.Whole(41300), money.Whole(4000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engine.Surcharge(tt.method, tt.amount); got != tt.want {
+				t.Errorf("Surcharge(%q, %s) = %s, want %s", tt.method, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
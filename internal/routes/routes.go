@@ -8,7 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler *handlers.GroupHandler, subscriptionHandler *handlers.SubscriptionHandler, paymentHandler *handlers.PaymentHandler, appHandler *handlers.AppHandler, messageHandler *handlers.MessageHandler, transactionHandler *handlers.TransactionHandler, otpHandler *handlers.OTPHandler, accountCredentialsHandler *handlers.AccountCredentialsHandler, emailSubmissionHandler *handlers.EmailSubmissionHandler, adminHandler *handlers.AdminHandler, midtransHandler *handlers.MidtransHandler, paymentLinkHandler *handlers.PaymentLinkHandler, webhookHandler *handlers.WebhookHandler, notificationHandler *handlers.NotificationHandler, broadcastHandler *handlers.BroadcastHandler, chatHandler *handlers.ChatHandler, userBroadcastHandler *handlers.UserBroadcastHandler, db *sql.DB) {
+func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler *handlers.GroupHandler, subscriptionHandler *handlers.SubscriptionHandler, paymentHandler *handlers.PaymentHandler, appHandler *handlers.AppHandler, messageHandler *handlers.MessageHandler, transactionHandler *handlers.TransactionHandler, otpHandler *handlers.OTPHandler, accountCredentialsHandler *handlers.AccountCredentialsHandler, emailSubmissionHandler *handlers.EmailSubmissionHandler, adminHandler *handlers.AdminHandler, midtransHandler *handlers.MidtransHandler, paymentLinkHandler *handlers.PaymentLinkHandler, webhookHandler *handlers.WebhookHandler, notificationHandler *handlers.NotificationHandler, broadcastHandler *handlers.BroadcastHandler, chatHandler *handlers.ChatHandler, userBroadcastHandler *handlers.UserBroadcastHandler, payoutHandler *handlers.PayoutHandler, promoHandler *handlers.PromoHandler, db *sql.DB) {
 	// API v1
 	v1 := r.Group("/api/v1")
 
@@ -45,6 +45,7 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 	{
 		groups.POST("", groupHandler.CreateGroup)
 		groups.POST("/join", groupHandler.JoinGroup)
+		groups.POST("/match", groupHandler.MatchGroup) // Join any open public group of an app
 		groups.DELETE("/:id/leave", groupHandler.LeaveGroup)
 		groups.GET("", groupHandler.GetUserGroups)
 		groups.GET("/:id", groupHandler.GetGroupDetails)
@@ -59,6 +60,43 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 		// State machine endpoints - using different path structure
 		groups.GET("/:id/status", groupHandler.GetGroupStatus)
 		groups.GET("/:id/users/:user_id/status", groupHandler.GetUserStatus)
+		groups.GET("/:id/history", groupHandler.GetGroupHistory)
+
+		// Pause, resume and scheduled closure of paid groups (owner only)
+		groups.POST("/:id/pause", groupHandler.PauseGroup)
+		groups.POST("/:id/resume", groupHandler.ResumeGroup)
+		groups.POST("/:id/closure", groupHandler.ScheduleGroupClosure)
+		groups.DELETE("/:id/closure", groupHandler.CancelGroupClosure)
+
+		// Invite links (owner only)
+		groups.GET("/:id/invites", groupHandler.ListGroupInvites)
+		groups.POST("/:id/invites", groupHandler.CreateGroupInvite)
+		groups.DELETE("/:id/invites/:invite_id", groupHandler.RevokeGroupInvite)
+		groups.POST("/:id/invites/:invite_id/regenerate", groupHandler.RegenerateGroupInvite)
+		groups.GET("/:id/invites/:invite_id/uses", groupHandler.GetGroupInviteUses)
+
+		// Email invitations
+		groups.POST("/:id/invitations", groupHandler.InviteToGroup)      // Owner only
+		groups.GET("/:id/invitations", groupHandler.GetGroupInvitations) // Owner only
+
+		// Ratings between members and the owner after a billing period
+		groups.POST("/:id/ratings", groupHandler.RateGroupUser)
+		groups.GET("/:id/ratings", groupHandler.GetGroupRatingTargets)
+
+		// Disputes about a billing period, resolved by an admin
+		groups.POST("/:id/disputes", groupHandler.OpenGroupDispute)
+		groups.GET("/:id/disputes", groupHandler.GetGroupDisputes)
+		groups.GET("/:id/disputes/:dispute_id", groupHandler.GetGroupDispute)
+		groups.POST("/:id/disputes/:dispute_id/response", groupHandler.RespondToDispute) // Owner only
+		groups.POST("/:id/disputes/:dispute_id/withdraw", groupHandler.WithdrawDispute)
+
+		// Waitlist of full groups
+		groups.POST("/:id/waitlist", groupHandler.JoinWaitlist)
+		groups.GET("/:id/waitlist", groupHandler.GetGroupWaitlist) // Owner only
+		groups.GET("/:id/waitlist/me", groupHandler.GetMyWaitlistEntry)
+		groups.DELETE("/:id/waitlist", groupHandler.LeaveWaitlist)
+		groups.POST("/:id/waitlist/claim", groupHandler.ClaimWaitlistSeat)
+		groups.PUT("/:id/waitlist/order", groupHandler.ReorderGroupWaitlist) // Owner only
 	}
 
 	// Public group routes (no auth required for browsing)
@@ -66,6 +104,23 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 	{
 		publicGroups.GET("", groupHandler.GetPublicGroups)
 		publicGroups.GET("/invite/:code", groupHandler.GetGroupByInviteCode)
+		publicGroups.GET("/invitations/:token", groupHandler.GetInvitation)
+	}
+
+	// Invitations sent to the current user (require active status)
+	invitations := v1.Group("/invitations")
+	invitations.Use(middleware.OptimizedAuthRequiredWithStatus(db))
+	{
+		invitations.GET("", groupHandler.GetMyInvitations)
+		invitations.POST("/:token/accept", groupHandler.AcceptInvitation)
+		invitations.POST("/:token/decline", groupHandler.DeclineInvitation)
+	}
+
+	// User reputation (require active status)
+	users := v1.Group("/users")
+	users.Use(middleware.OptimizedAuthRequiredWithStatus(db))
+	{
+		users.GET("/:id/reputation", groupHandler.GetUserReputation)
 	}
 
 	// Subscription routes (require active status) - removed unused routes
@@ -76,9 +131,26 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 	{
 		payments.POST("", paymentHandler.CreatePayment)
 		payments.POST("/group-payment-link", paymentHandler.CreateGroupPaymentLink)
+		payments.POST("/group-wallet-payment", paymentHandler.PayGroupWithBalance)
+		payments.GET("/renewals", paymentHandler.GetRenewalInvoices)
+		payments.POST("/renewals/:id/pay", paymentHandler.PayRenewalInvoice)
+		payments.GET("/refunds", paymentHandler.GetRefunds)
+		payments.POST("/promo/validate", promoHandler.ValidatePromoCode)
 		payments.GET("", paymentHandler.GetUserPayments)
 	}
 
+	// Payout routes (require active status)
+	payouts := v1.Group("/payouts")
+	payouts.Use(middleware.OptimizedAuthRequiredWithStatus(db))
+	{
+		payouts.GET("", payoutHandler.GetPayouts)
+		payouts.POST("", payoutHandler.CreatePayout)
+		payouts.GET("/balance", payoutHandler.GetPayoutBalance)
+		payouts.GET("/accounts", payoutHandler.GetPayoutAccounts)
+		payouts.POST("/accounts", payoutHandler.CreatePayoutAccount)
+		payouts.DELETE("/accounts/:id", payoutHandler.DeletePayoutAccount)
+	}
+
 	// Webhook routes (no auth required)
 	webhook := r.Group("/webhook")
 	{
@@ -110,6 +182,7 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 	{
 		transactions.GET("", transactionHandler.GetUserTransactions)
 		transactions.POST("", transactionHandler.CreateTransaction)
+		transactions.GET("/:id/invoice", transactionHandler.GetTransactionInvoice)
 	}
 
 	// Account credentials routes (require active status)
@@ -159,6 +232,48 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 
 		// Subscription management routes
 		admin.GET("/subscriptions", subscriptionHandler.GetPaidGroupsWithCredentials)
+
+		// Ledger routes
+		admin.GET("/ledger/check", adminHandler.CheckLedger)
+		admin.GET("/jobs", adminHandler.GetJobs)
+		admin.GET("/jobs/runs", adminHandler.GetJobRuns)
+		admin.POST("/jobs/:name/run", adminHandler.RunJob)
+
+		// Refund routes
+		admin.GET("/refunds", adminHandler.GetRefunds)
+		admin.POST("/refunds/:id/approve", adminHandler.ApproveRefund)
+		admin.POST("/refunds/:id/reject", adminHandler.RejectRefund)
+
+		// Dispute routes
+		admin.GET("/disputes", adminHandler.GetDisputes)
+		admin.GET("/disputes/:id", adminHandler.GetDispute)
+		admin.POST("/disputes/:id/resolve", adminHandler.ResolveDispute)
+
+		// Reconciliation routes
+		admin.POST("/reconciliation/run", adminHandler.RunReconciliation)
+		admin.GET("/reconciliation/reports", adminHandler.GetReconciliationReports)
+		admin.GET("/reconciliation/reports/:id", adminHandler.GetReconciliationReport)
+
+		// Payout routes
+		admin.GET("/payouts", payoutHandler.AdminGetPayouts)
+		admin.POST("/payouts/:id/approve", payoutHandler.AdminApprovePayout)
+		admin.POST("/payouts/:id/reject", payoutHandler.AdminRejectPayout)
+		admin.POST("/payouts/:id/mark-paid", payoutHandler.AdminMarkPayoutPaid)
+
+		// Promo code routes
+		admin.GET("/promo-codes", promoHandler.AdminGetPromoCodes)
+		admin.POST("/promo-codes", promoHandler.AdminCreatePromoCode)
+		admin.GET("/promo-codes/:id", promoHandler.AdminGetPromoCode)
+		admin.PUT("/promo-codes/:id", promoHandler.AdminUpdatePromoCode)
+		admin.DELETE("/promo-codes/:id", promoHandler.AdminDeactivatePromoCode)
+		admin.GET("/promo-codes/:id/redemptions", promoHandler.AdminGetPromoRedemptions)
+
+		// Webhook inbox routes
+		admin.GET("/webhook-events", webhookHandler.ListWebhookEvents)
+		admin.POST("/webhook-events/:id/retry", webhookHandler.RetryWebhookEvent)
+
+		// Status event history
+		admin.GET("/status-events", adminHandler.GetStatusEvents)
 	}
 
 	// Midtrans routes
@@ -166,7 +281,8 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 	{
 		midtrans.POST("/check-status", midtransHandler.CheckTransactionStatus)
 		midtrans.GET("/payment-link/:order_id", midtransHandler.GetTransactionPaymentLink)
-		midtrans.GET("/update-all-pending", midtransHandler.UpdateAllPendingTransactions)
+		// Pending payments are reconciled by the scheduler; the manual trigger is admin only
+		midtrans.GET("/update-all-pending", middleware.AuthRequiredWithStatus(db), middleware.AdminRequired(db), midtransHandler.UpdateAllPendingTransactions)
 	}
 
 	// Payment Link routes
@@ -260,6 +376,14 @@ func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, groupHandler
 		webhooks.POST("/midtrans", paymentHandler.HandlePaymentNotification)
 	}
 
+	// Fake payment gateway routes (only active when payment.gateway is "fake").
+	// Stand in for the hosted payment page, so anyone holding the link can pay.
+	fakeGateway := v1.Group("/fake-gateway")
+	{
+		fakeGateway.GET("/payments/:order_id", paymentHandler.GetFakePayment)
+		fakeGateway.POST("/payments/:order_id/:outcome", paymentHandler.SimulateFakePayment)
+	}
+
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
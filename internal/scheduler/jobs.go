@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/services"
+)
+
+// Job names
+const (
+	JobPaymentDeadlines    = "payment-deadlines"
+	JobPendingPayments     = "pending-payments"
+	JobScheduledBroadcasts = "scheduled-broadcasts"
+	JobRenewals            = "renewals"
+	JobReconciliation      = "payment-reconciliation"
+	JobInvoices            = "invoices"
+	JobPaymentReminders    = "payment-reminders"
+	JobWaitlist            = "waitlist"
+	JobInvitations         = "invitations"
+	JobReputation          = "reputation"
+	JobGatewayRefunds      = "gateway-refunds"
+)
+
+// NewDefault returns a scheduler with the housekeeping jobs registered at the configured intervals
+func NewDefault(db *sql.DB) *Scheduler {
+	cfg := config.GetConfig().Scheduler
+	s := New(db)
+
+	stateMachine := services.NewStateMachineService(db)
+	s.Register(Job{
+		Name:     JobPaymentDeadlines,
+		Interval: interval(cfg.PaymentDeadlineInterval, 5*time.Minute),
+		Run: func() (interface{}, error) {
+			assigned, err := stateMachine.AssignMissingPaymentDeadlines()
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign payment deadlines: %v", err)
+			}
+			removed, err := stateMachine.CheckExpiredPayments()
+			if err != nil {
+				return nil, fmt.Errorf("failed to check expired payments: %v", err)
+			}
+			return map[string]int{"deadlines_assigned": assigned, "members_removed": removed}, nil
+		},
+	})
+
+	poller := services.NewPendingPaymentPoller(db)
+	s.Register(Job{
+		Name:     JobPendingPayments,
+		Interval: interval(cfg.PendingPaymentsInterval, 10*time.Minute),
+		Run: func() (interface{}, error) {
+			return poller.PollAll()
+		},
+	})
+
+	broadcasts := services.NewUserBroadcastService(db)
+	s.Register(Job{
+		Name:     JobScheduledBroadcasts,
+		Interval: interval(cfg.BroadcastsInterval, time.Minute),
+		Run: func() (interface{}, error) {
+			return broadcasts.SendScheduled(time.Now())
+		},
+	})
+
+	renewals := services.NewRenewalService(db)
+	s.Register(Job{
+		Name:     JobRenewals,
+		Interval: interval(cfg.RenewalsInterval, time.Hour),
+		Run: func() (interface{}, error) {
+			return renewals.Run(time.Now())
+		},
+	})
+
+	reconciliation := services.NewReconciliationService(db)
+	s.Register(Job{
+		Name:     JobReconciliation,
+		Interval: interval(cfg.ReconcileInterval, 6*time.Hour),
+		Run: func() (interface{}, error) {
+			return reconciliation.RunScheduled(time.Now())
+		},
+	})
+
+	invoices := services.NewInvoiceService(db)
+	s.Register(Job{
+		Name:     JobInvoices,
+		Interval: interval(cfg.InvoicesInterval, 5*time.Minute),
+		Run: func() (interface{}, error) {
+			return invoices.Run(time.Now())
+		},
+	})
+
+	dunning := services.NewDunningService(db)
+	s.Register(Job{
+		Name:     JobPaymentReminders,
+		Interval: interval(cfg.DunningInterval, 5*time.Minute),
+		Run: func() (interface{}, error) {
+			return dunning.Run(time.Now())
+		},
+	})
+
+	waitlist := services.NewWaitlistService(db)
+	s.Register(Job{
+		Name:     JobWaitlist,
+		Interval: interval(cfg.WaitlistInterval, time.Minute),
+		Run: func() (interface{}, error) {
+			return waitlist.Run(time.Now())
+		},
+	})
+
+	invitations := services.NewInvitationService(db)
+	s.Register(Job{
+		Name:     JobInvitations,
+		Interval: interval(cfg.InvitationsInterval, time.Hour),
+		Run: func() (interface{}, error) {
+			expired, err := invitations.ExpireStale(time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return map[string]int{"invitations_expired": expired}, nil
+		},
+	})
+
+	reputation := services.NewReputationService(db)
+	s.Register(Job{
+		Name:     JobReputation,
+		Interval: interval(cfg.ReputationInterval, time.Hour),
+		Run: func() (interface{}, error) {
+			updated, err := reputation.RecalculateAll()
+			if err != nil {
+				return nil, err
+			}
+			return map[string]int{"users_updated": updated}, nil
+		},
+	})
+
+	refunds := services.NewRefundService(db)
+	s.Register(Job{
+		Name:     JobGatewayRefunds,
+		Interval: interval(cfg.GatewayRefundsInterval, 5*time.Minute),
+		Run: func() (interface{}, error) {
+			return refunds.SendGatewayRefunds(time.Now())
+		},
+	})
+
+	return s
+}
+
+// interval parses a configured interval, falling back to def when it is missing or invalid
+func interval(value string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		if value != "" {
+			fmt.Printf("[SALOME BE] Warning: invalid scheduler interval %q, using %s\n", value, def)
+		}
+		return def
+	}
+	return d
+}
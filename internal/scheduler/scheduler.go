@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"salome-be/internal/models"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// JobFunc runs a job once and returns a summary that is stored with the run
+type JobFunc func() (interface{}, error)
+
+// Job is a periodic background job
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      JobFunc
+}
+
+// Scheduler runs registered jobs periodically inside the API process. A Postgres advisory lock makes sure
+// only one replica runs a given job at a time, and a job that another replica ran within the current
+// interval is skipped. Every run is recorded in job_runs.
+type Scheduler struct {
+	db       *sql.DB
+	jobs     []Job
+	instance string
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New(db *sql.DB) *Scheduler {
+	instance, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		instance: fmt.Sprintf("%s:%d", instance, os.Getpid()),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Register adds a job; it must be called before Start
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job once and then on its interval until Stop is called
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+	fmt.Printf("[SALOME BE] Scheduler started with %d jobs on %s\n", len(s.jobs), s.instance)
+}
+
+// Stop stops scheduling and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.execute(job, "schedule"); err != nil && !errors.Is(err, ErrJobRunning) {
+			fmt.Printf("[SALOME BE] ERROR: Job %s: %v\n", job.Name, err)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunNow runs a job immediately, regardless of when it last ran
+func (s *Scheduler) RunNow(name string) (*models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return s.execute(job, "manual")
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// execute runs a job while holding its advisory lock. Scheduled runs return (nil, nil) when the job
+// already ran within its interval.
+func (s *Scheduler) execute(job Job, trigger string) (*models.JobRun, error) {
+	ctx := context.Background()
+
+	// The lock is transaction scoped so it is released even if this process dies mid-run
+	lockTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin lock transaction: %v", err)
+	}
+	defer lockTx.Rollback()
+
+	var locked bool
+	if err := lockTx.QueryRow(`SELECT pg_try_advisory_xact_lock(hashtext($1))`, "salome-job:"+job.Name).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to acquire job lock: %v", err)
+	}
+	if !locked {
+		return nil, ErrJobRunning
+	}
+
+	// Nobody else holds the lock, so runs still marked running were interrupted
+	if _, err := s.db.Exec(`
+		UPDATE job_runs SET status = $1, error = 'interrupted', finished_at = NOW()
+		WHERE job_name = $2 AND status = $3
+	`, models.JobRunFailed, job.Name, models.JobRunRunning); err != nil {
+		return nil, fmt.Errorf("failed to close interrupted job runs: %v", err)
+	}
+
+	if trigger == "schedule" {
+		var ranRecently bool
+		err := s.db.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 FROM job_runs
+				WHERE job_name = $1 AND triggered_by = 'schedule' AND started_at > NOW() - make_interval(secs => $2)
+			)
+		`, job.Name, job.Interval.Seconds()*0.9).Scan(&ranRecently)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check last job run: %v", err)
+		}
+		if ranRecently {
+			return nil, nil
+		}
+	}
+
+	run := &models.JobRun{JobName: job.Name, Status: models.JobRunRunning, TriggeredBy: trigger, Instance: &s.instance}
+	err = s.db.QueryRow(`
+		INSERT INTO job_runs (job_name, status, triggered_by, instance, started_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, started_at
+	`, job.Name, run.Status, trigger, s.instance).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record job run: %v", err)
+	}
+
+	started := time.Now()
+	result, runErr := runSafely(job.Run)
+	duration := time.Since(started).Milliseconds()
+
+	run.Status = models.JobRunSucceeded
+	if runErr != nil {
+		run.Status = models.JobRunFailed
+		message := runErr.Error()
+		run.Error = &message
+	}
+	if result != nil {
+		if data, err := json.Marshal(result); err == nil {
+			run.Result = data
+		}
+	}
+	run.DurationMs = &duration
+
+	err = s.db.QueryRow(`
+		UPDATE job_runs SET status = $1, result = $2, error = $3, finished_at = NOW(), duration_ms = $4
+		WHERE id = $5
+		RETURNING finished_at
+	`, run.Status, nullableJSON(run.Result), run.Error, duration, run.ID).Scan(&run.FinishedAt)
+	if err != nil {
+		return run, fmt.Errorf("failed to record job result: %v", err)
+	}
+
+	if err := lockTx.Commit(); err != nil {
+		return run, fmt.Errorf("failed to release job lock: %v", err)
+	}
+
+	fmt.Printf("[SALOME BE] Job %s %s in %dms (trigger=%s)\n", job.Name, run.Status, duration, trigger)
+	return run, runErr
+}
+
+// runSafely keeps a panicking job from taking the scheduler down
+func runSafely(fn JobFunc) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// Jobs returns the registered jobs with their latest run
+func (s *Scheduler) Jobs() ([]models.JobInfo, error) {
+	jobs := []models.JobInfo{}
+	for _, job := range s.jobs {
+		info := models.JobInfo{Name: job.Name, Interval: job.Interval.String()}
+		runs, _, err := s.ListRuns(job.Name, "", 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			info.LastRun = &runs[0]
+		}
+		jobs = append(jobs, info)
+	}
+	return jobs, nil
+}
+
+// ListRuns returns job runs filtered by job name and status (empty for all), newest first
+func (s *Scheduler) ListRuns(jobName, status string, limit, offset int) ([]models.JobRun, int, error) {
+	var total int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM job_runs WHERE ($1 = '' OR job_name = $1) AND ($2 = '' OR status = $2)
+	`, jobName, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count job runs: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, job_name, status, triggered_by, instance, result, error, started_at, finished_at, duration_ms
+		FROM job_runs
+		WHERE ($1 = '' OR job_name = $1) AND ($2 = '' OR status = $2)
+		ORDER BY started_at DESC
+		LIMIT $3 OFFSET $4
+	`, jobName, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list job runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []models.JobRun{}
+	for rows.Next() {
+		var run models.JobRun
+		var result []byte
+		if err := rows.Scan(&run.ID, &run.JobName, &run.Status, &run.TriggeredBy, &run.Instance, &result, &run.Error,
+			&run.StartedAt, &run.FinishedAt, &run.DurationMs); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan job run: %v", err)
+		}
+		if len(result) > 0 {
+			run.Result = json.RawMessage(result)
+		}
+		runs = append(runs, run)
+	}
+	return runs, total, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import "fmt"
+
+// emailLayout wraps the body of a transactional email in the SALOME email template
+func emailLayout(title, body string) string {
+	return fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>%s</title>
+		<style>
+			body {
+				font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+				line-height: 1.6;
+				color: #333;
+				max-width: 600px;
+				margin: 0 auto;
+				padding: 20px;
+				background-color: #f8f9fa;
+			}
+			.container {
+				background-color: white;
+				border-radius: 10px;
+				padding: 30px;
+				box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+			}
+			.header {
+				text-align: center;
+				margin-bottom: 30px;
+			}
+			.logo {
+				font-size: 28px;
+				font-weight: bold;
+				color: #3b82f6;
+				margin-bottom: 10px;
+			}
+			.title {
+				font-size: 24px;
+				color: #1f2937;
+				margin-bottom: 20px;
+			}
+			.summary {
+				background-color: #f8fafc;
+				padding: 15px;
+				margin: 20px 0;
+				border-radius: 8px;
+				border-left: 4px solid #3b82f6;
+			}
+			.button {
+				display: inline-block;
+				background-color: #3b82f6;
+				color: white;
+				padding: 12px 24px;
+				border-radius: 8px;
+				text-decoration: none;
+				font-weight: bold;
+			}
+			.footer {
+				text-align: center;
+				margin-top: 30px;
+				padding-top: 20px;
+				border-top: 1px solid #e5e7eb;
+				color: #6b7280;
+				font-size: 14px;
+			}
+		</style>
+	</head>
+	<body>
+		<div class="container">
+			<div class="header">
+				<div class="logo">SALOME</div>
+				<h1 class="title">%s</h1>
+			</div>
+%s
+			<div class="footer">
+				<p>Email ini dikirim secara otomatis, mohon tidak membalas email ini.</p>
+				<p>&copy; 2024 SALOME. All rights reserved.</p>
+			</div>
+		</div>
+	</body>
+	</html>
+	`, title, title, body)
+}
@@ -4,12 +4,24 @@ import (
 	"context"
 	"fmt"
 	"log"
+
+	"salome-be/internal/config"
 )
 
 // EmailProvider interface for different email services
 type EmailProvider interface {
 	SendOTPEmail(ctx context.Context, data OTPEmailData) error
 	SendWelcomeEmail(ctx context.Context, email, name string) error
+	SendInvoiceEmail(ctx context.Context, data InvoiceEmailData) error
+	SendPaymentReminderEmail(ctx context.Context, data PaymentReminderEmailData) error
+	SendGroupInvitationEmail(ctx context.Context, data GroupInvitationEmailData) error
+}
+
+// emailAttachment is a file sent along with an email
+type emailAttachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
 }
 
 // MultiProviderEmailService handles multiple email providers with fallback
@@ -30,6 +42,19 @@ func NewMultiProviderEmailService(providers []EmailProvider) *MultiProviderEmail
 	}
 }
 
+// NewConfiguredEmailService creates a multi-provider email service with the providers enabled in cfg,
+// MailerSend first
+func NewConfiguredEmailService(cfg config.EmailConfig) *MultiProviderEmailService {
+	var providers []EmailProvider
+	if cfg.MailerSend.Enabled {
+		providers = append(providers, NewEmailService(cfg.MailerSend.APIKey, cfg.MailerSend.FromEmail, cfg.MailerSend.FromName))
+	}
+	if cfg.Resend.Enabled {
+		providers = append(providers, NewResendService(cfg.Resend.APIKey, cfg.Resend.FromEmail))
+	}
+	return NewMultiProviderEmailService(providers)
+}
+
 // SendOTPEmail tries to send OTP email using available providers
 func (m *MultiProviderEmailService) SendOTPEmail(ctx context.Context, data OTPEmailData) error {
 	log.Printf("MultiProviderEmailService: Starting OTP email send to %s", data.Email)
@@ -83,6 +108,78 @@ func (m *MultiProviderEmailService) SendWelcomeEmail(ctx context.Context, email,
 	return fmt.Errorf("all email providers failed. Last error: %w", lastErr)
 }
 
+// SendInvoiceEmail tries to send a payment confirmation with the invoice attached using available providers
+func (m *MultiProviderEmailService) SendInvoiceEmail(ctx context.Context, data InvoiceEmailData) error {
+	if len(m.providers) == 0 {
+		return fmt.Errorf("no email providers configured")
+	}
+
+	var lastErr error
+	for i, provider := range m.providers {
+		log.Printf("Attempting to send invoice email via provider %d", i+1)
+
+		err := provider.SendInvoiceEmail(ctx, data)
+		if err == nil {
+			log.Printf("Invoice email sent successfully via provider %d", i+1)
+			return nil
+		}
+
+		log.Printf("Provider %d failed: %v", i+1, err)
+		lastErr = err
+	}
+
+	// All providers failed
+	return fmt.Errorf("all email providers failed. Last error: %w", lastErr)
+}
+
+// SendPaymentReminderEmail tries to send a payment reminder using available providers
+func (m *MultiProviderEmailService) SendPaymentReminderEmail(ctx context.Context, data PaymentReminderEmailData) error {
+	if len(m.providers) == 0 {
+		return fmt.Errorf("no email providers configured")
+	}
+
+	var lastErr error
+	for i, provider := range m.providers {
+		log.Printf("Attempting to send payment reminder email via provider %d", i+1)
+
+		err := provider.SendPaymentReminderEmail(ctx, data)
+		if err == nil {
+			log.Printf("Payment reminder email sent successfully via provider %d", i+1)
+			return nil
+		}
+
+		log.Printf("Provider %d failed: %v", i+1, err)
+		lastErr = err
+	}
+
+	// All providers failed
+	return fmt.Errorf("all email providers failed. Last error: %w", lastErr)
+}
+
+// SendGroupInvitationEmail tries to send a group invitation using available providers
+func (m *MultiProviderEmailService) SendGroupInvitationEmail(ctx context.Context, data GroupInvitationEmailData) error {
+	if len(m.providers) == 0 {
+		return fmt.Errorf("no email providers configured")
+	}
+
+	var lastErr error
+	for i, provider := range m.providers {
+		log.Printf("Attempting to send group invitation email via provider %d", i+1)
+
+		err := provider.SendGroupInvitationEmail(ctx, data)
+		if err == nil {
+			log.Printf("Group invitation email sent successfully via provider %d", i+1)
+			return nil
+		}
+
+		log.Printf("Provider %d failed: %v", i+1, err)
+		lastErr = err
+	}
+
+	// All providers failed
+	return fmt.Errorf("all email providers failed. Last error: %w", lastErr)
+}
+
 // GetProviderCount returns the number of configured providers
 func (m *MultiProviderEmailService) GetProviderCount() int {
 	return len(m.providers)
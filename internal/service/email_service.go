@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"time"
@@ -334,3 +335,58 @@ SALOME Team
 	log.Printf("Welcome email sent successfully to %s. Message ID: %s", email, res.Header.Get("X-Message-Id"))
 	return nil
 }
+
+func (es *EmailService) SendInvoiceEmail(ctx context.Context, data InvoiceEmailData) error {
+	subject, html, text := invoiceEmailContent(data)
+	return es.send(ctx, data.Email, data.Name, subject, html, text, &emailAttachment{
+		FileName:    data.FileName,
+		ContentType: "application/pdf",
+		Content:     data.PDF,
+	})
+}
+
+func (es *EmailService) SendPaymentReminderEmail(ctx context.Context, data PaymentReminderEmailData) error {
+	subject, html, text := paymentReminderEmailContent(data)
+	return es.send(ctx, data.Email, data.Name, subject, html, text, nil)
+}
+
+func (es *EmailService) SendGroupInvitationEmail(ctx context.Context, data GroupInvitationEmailData) error {
+	subject, html, text := groupInvitationEmailContent(data)
+	return es.send(ctx, data.Email, "", subject, html, text, nil)
+}
+
+// send delivers a transactional email with an optional attachment
+func (es *EmailService) send(ctx context.Context, email, name, subject, html, text string, attachment *emailAttachment) error {
+	recipients := []mailersend.Recipient{
+		{
+			Name:  name,
+			Email: email,
+		},
+	}
+
+	message := es.client.Email.NewMessage()
+	message.SetFrom(es.from)
+	message.SetRecipients(recipients)
+	message.SetSubject(subject)
+	message.SetHTML(html)
+	message.SetText(text)
+	if attachment != nil {
+		message.AddAttachment(mailersend.Attachment{
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			Filename:    attachment.FileName,
+			Disposition: mailersend.DispositionAttachment,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := es.client.Email.Send(ctx, message)
+	if err != nil {
+		log.Printf("Error sending email %q to %s: %v", subject, email, err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	log.Printf("Email %q sent successfully to %s. Message ID: %s", subject, email, res.Header.Get("X-Message-Id"))
+	return nil
+}
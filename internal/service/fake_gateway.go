@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/money"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FakeOutcomeSettle = "settle"
+	FakeOutcomeExpire = "expire"
+	FakeOutcomeDeny   = "deny"
+)
+
+var ErrInvalidFakeOutcome = errors.New("outcome must be settle, expire or deny")
+
+// FakeGateway is a local PaymentGateway that never leaves the process. Payment links stay pending until
+// they are settled, expired or denied with Simulate, which returns a signed notification in the Midtrans
+// format so it can go through the regular webhook path. Links are kept in memory and lost on restart.
+type FakeGateway struct {
+	mu         sync.Mutex
+	links      map[string]*FakePaymentLink
+	signingKey string
+	baseURL    string
+}
+
+// FakePaymentLink is the state of one fake payment link
+type FakePaymentLink struct {
+	OrderID           string    `json:"order_id"`
+	PaymentLinkID     string    `json:"payment_link_id"`
+	TransactionID     string    `json:"transaction_id"`
+	Amount            int64     `json:"amount"`
+	Refunded          int64     `json:"refunded"`
+	Description       string    `json:"description"`
+	CustomerEmail     string    `json:"customer_email"`
+	TransactionStatus string    `json:"transaction_status"`
+	PaymentType       string    `json:"payment_type"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+var (
+	fakeGateway     *FakeGateway
+	fakeGatewayOnce sync.Once
+)
+
+// NewFakeGateway returns the process-wide fake gateway, so every handler sees the same links
+func NewFakeGateway() *FakeGateway {
+	fakeGatewayOnce.Do(func() {
+		cfg := config.GetConfig()
+
+		// Sign like Cloudfren Core does, so the webhook verifier accepts the notifications
+		signingKey := cfg.Midtrans.Webhook.SecretKey
+		if signingKey == "" {
+			signingKey = cfg.Midtrans.ServerKey
+		}
+
+		fakeGateway = &FakeGateway{
+			links:      make(map[string]*FakePaymentLink),
+			signingKey: signingKey,
+			baseURL:    fmt.Sprintf("http://%s:%d/api/v1/fake-gateway/payments", cfg.Server.Host, cfg.Server.Port),
+		}
+		fmt.Printf("[SALOME BE] WARNING: Using the fake payment gateway, no real payments are collected\n")
+	})
+	return fakeGateway
+}
+
+func (f *FakeGateway) Name() string {
+	return PaymentGatewayFake
+}
+
+func (f *FakeGateway) CreatePaymentLink(req PaymentLinkRequest) (*PaymentLink, error) {
+	if req.OrderID == "" || req.Amount.Amount <= 0 {
+		return nil, fmt.Errorf("order ID and a positive amount are required")
+	}
+	if req.Amount.Currency != money.IDR {
+		return nil, ErrUnsupportedCurrency
+	}
+	expiryHours := req.ExpiryHours
+	if expiryHours <= 0 {
+		expiryHours = 24
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.links[req.OrderID]; exists {
+		return nil, fmt.Errorf("order ID %s has already been taken", req.OrderID)
+	}
+
+	now := time.Now()
+	link := &FakePaymentLink{
+		OrderID:           req.OrderID,
+		PaymentLinkID:     "fake-" + uuid.New().String()[:8],
+		Amount:            req.Amount.Amount.Units(),
+		Description:       req.Description,
+		CustomerEmail:     req.CustomerEmail,
+		TransactionStatus: "pending",
+		ExpiresAt:         now.Add(time.Duration(expiryHours) * time.Hour),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	f.links[req.OrderID] = link
+
+	paymentURL := f.PaymentLinkURL(link.OrderID)
+	return &PaymentLink{
+		OrderID:       link.OrderID,
+		PaymentLinkID: link.PaymentLinkID,
+		PaymentURL:    paymentURL,
+		Raw: map[string]interface{}{
+			"order_id":    link.OrderID,
+			"payment_url": paymentURL,
+		},
+	}, nil
+}
+
+func (f *FakeGateway) GetTransactionStatus(paymentReference string) (*PaymentStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	link, err := f.find(paymentReference)
+	if err != nil {
+		return nil, err
+	}
+	return link.status(), nil
+}
+
+// Refund refunds a settled fake payment, fully or partially
+func (f *FakeGateway) Refund(req RefundRequest) (*RefundResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	link, err := f.find(req.PaymentReference)
+	if err != nil {
+		return nil, err
+	}
+	if link.TransactionStatus != "settlement" && link.TransactionStatus != "partial_refund" {
+		return nil, ErrRefundNotAllowed
+	}
+
+	amount := req.Amount.Units()
+	if amount <= 0 || link.Refunded+amount > link.Amount {
+		return nil, fmt.Errorf("refund amount exceeds the refundable amount of %d", link.Amount-link.Refunded)
+	}
+
+	link.Refunded += amount
+	link.TransactionStatus = "partial_refund"
+	if link.Refunded == link.Amount {
+		link.TransactionStatus = "refund"
+	}
+	link.UpdatedAt = time.Now()
+
+	return &RefundResult{
+		RefundKey:         req.RefundKey,
+		Amount:            req.Amount,
+		TransactionStatus: link.TransactionStatus,
+	}, nil
+}
+
+func (f *FakeGateway) ParseWebhook(body []byte) (*PaymentNotification, error) {
+	return parseMidtransNotification(body)
+}
+
+func (f *FakeGateway) PaymentLinkURL(paymentLinkID string) string {
+	if paymentLinkID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", f.baseURL, paymentLinkID)
+}
+
+// GetPaymentLink returns the state of a fake payment link
+func (f *FakeGateway) GetPaymentLink(paymentReference string) (*FakePaymentLink, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	link, err := f.find(paymentReference)
+	if err != nil {
+		return nil, err
+	}
+	copied := *link
+	return &copied, nil
+}
+
+// Simulate settles, expires or denies a pending fake payment and returns the notification Midtrans
+// would have sent for it
+func (f *FakeGateway) Simulate(paymentReference, outcome string) (*PaymentNotification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	link, err := f.find(paymentReference)
+	if err != nil {
+		return nil, err
+	}
+	if link.TransactionStatus != "pending" {
+		return nil, fmt.Errorf("payment is already %s", link.TransactionStatus)
+	}
+
+	statusCode := "202"
+	switch outcome {
+	case FakeOutcomeSettle:
+		link.TransactionStatus = "settlement"
+		link.PaymentType = "bank_transfer"
+		statusCode = "200"
+	case FakeOutcomeExpire:
+		link.TransactionStatus = "expire"
+	case FakeOutcomeDeny:
+		link.TransactionStatus = "deny"
+		link.PaymentType = "credit_card"
+	default:
+		return nil, ErrInvalidFakeOutcome
+	}
+	link.TransactionID = uuid.New().String()
+	link.UpdatedAt = time.Now()
+
+	grossAmount := fmt.Sprintf("%d.00", link.Amount)
+	return &PaymentNotification{
+		OrderID:           link.OrderID,
+		TransactionID:     link.TransactionID,
+		TransactionStatus: link.TransactionStatus,
+		PaymentType:       link.PaymentType,
+		StatusCode:        statusCode,
+		GrossAmount:       grossAmount,
+		SignatureKey:      SignatureKey(link.OrderID, statusCode, grossAmount, f.signingKey),
+	}, nil
+}
+
+// find looks a link up by order ID or payment link ID and expires it when its time has passed.
+// The caller must hold f.mu.
+func (f *FakeGateway) find(paymentReference string) (*FakePaymentLink, error) {
+	link, ok := f.links[paymentReference]
+	if !ok {
+		for _, l := range f.links {
+			if l.PaymentLinkID == paymentReference {
+				link, ok = l, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, ErrPaymentLinkNotFound
+	}
+
+	if link.TransactionStatus == "pending" && time.Now().After(link.ExpiresAt) {
+		link.TransactionStatus = "expire"
+		link.UpdatedAt = time.Now()
+	}
+	return link, nil
+}
+
+func (l *FakePaymentLink) status() *PaymentStatus {
+	return &PaymentStatus{
+		TransactionID:     l.TransactionID,
+		OrderID:           l.OrderID,
+		PaymentType:       l.PaymentType,
+		TransactionTime:   l.UpdatedAt.Format("2006-01-02 15:04:05"),
+		TransactionStatus: l.TransactionStatus,
+		GrossAmount:       fmt.Sprintf("%d", l.Amount),
+		Currency:          "IDR",
+	}
+}
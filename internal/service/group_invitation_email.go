@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"html"
+)
+
+// GroupInvitationEmailData invites someone without a SALOME account to sign up and join a group
+type GroupInvitationEmailData struct {
+	Email       string
+	InviterName string
+	GroupName   string
+	AppName     string
+	ExpiresAt   string // formatted expiry, e.g. "23 Oct 2026 20:00 WIB"
+	JoinURL     string // sign-up page that joins the group afterwards
+}
+
+// groupInvitationEmailContent returns the subject, HTML and plain text body of a group invitation email
+func groupInvitationEmailContent(data GroupInvitationEmailData) (subject, htmlBody, text string) {
+	subject = fmt.Sprintf("%s mengundang Anda ke grup %s", data.InviterName, data.GroupName)
+
+	htmlBody = emailLayout("Undangan Grup", fmt.Sprintf(`
+			<p>Halo,</p>
+
+			<p><strong>%s</strong> mengundang Anda untuk bergabung ke grup <strong>%s</strong> di SALOME dan
+			berbagi langganan <strong>%s</strong>.</p>
+
+			<p>Daftar akun SALOME dengan alamat email ini untuk menerima undangan. Undangan berlaku sampai
+			<strong>%s</strong>.</p>
+
+			<p style="text-align: center;"><a class="button" href="%s">Daftar dan Bergabung</a></p>
+
+			<p>Abaikan email ini jika Anda tidak mengenal pengirimnya.</p>
+	`, html.EscapeString(data.InviterName), html.EscapeString(data.GroupName), html.EscapeString(data.AppName),
+		html.EscapeString(data.ExpiresAt), html.EscapeString(data.JoinURL)))
+
+	text = fmt.Sprintf(`
+SALOME - Undangan Grup
+
+Halo,
+
+%s mengundang Anda untuk bergabung ke grup %s di SALOME dan berbagi langganan %s.
+
+Daftar akun SALOME dengan alamat email ini untuk menerima undangan. Undangan berlaku sampai %s.
+
+Daftar dan bergabung: %s
+
+Abaikan email ini jika Anda tidak mengenal pengirimnya.
+
+--
+SALOME Team
+	`, data.InviterName, data.GroupName, data.AppName, data.ExpiresAt, data.JoinURL)
+
+	return subject, htmlBody, text
+}
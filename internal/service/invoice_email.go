@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"html"
+)
+
+// InvoiceEmailData is a payment confirmation with the invoice PDF attached
+type InvoiceEmailData struct {
+	Email         string
+	Name          string
+	InvoiceNumber string
+	Description   string
+	Total         string // formatted amount, e.g. "Rp 150.000"
+	FileName      string
+	PDF           []byte
+}
+
+// invoiceEmailContent returns the subject, HTML and plain text body of a payment confirmation email.
+// Every provider sends the same content.
+func invoiceEmailContent(data InvoiceEmailData) (subject, htmlBody, text string) {
+	subject = fmt.Sprintf("Pembayaran Berhasil - %s", data.InvoiceNumber)
+
+	htmlBody = emailLayout("Pembayaran Berhasil", fmt.Sprintf(`
+			<p>Halo <strong>%s</strong>,</p>
+
+			<p>Terima kasih, pembayaran Anda telah kami terima.</p>
+
+			<div class="summary">
+				<strong>Nomor Invoice:</strong> %s<br>
+				<strong>Keterangan:</strong> %s<br>
+				<strong>Total:</strong> %s
+			</div>
+
+			<p>Invoice lengkap terlampir dalam format PDF.</p>
+	`, html.EscapeString(data.Name), html.EscapeString(data.InvoiceNumber), html.EscapeString(data.Description),
+		html.EscapeString(data.Total)))
+
+	text = fmt.Sprintf(`
+SALOME - Pembayaran Berhasil
+
+Halo %s,
+
+Terima kasih, pembayaran Anda telah kami terima.
+
+Nomor Invoice: %s
+Keterangan: %s
+Total: %s
+
+Invoice lengkap terlampir dalam format PDF.
+
+--
+SALOME Team
+	`, data.Name, data.InvoiceNumber, data.Description, data.Total)
+
+	return subject, htmlBody, text
+}
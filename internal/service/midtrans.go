@@ -1,20 +1,29 @@
 package service
 
 import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"salome-be/internal/config"
+	"salome-be/internal/money"
 	"strings"
 	"time"
 )
 
+// MidtransService is the Midtrans implementation of PaymentGateway
 type MidtransService struct {
-	serverKey string
-	client    *http.Client
+	serverKey      string
+	baseURL        string
+	paymentLinkAPI string
+	paymentLinkWeb string
+	client         *http.Client
 }
 
 type MidtransTransactionStatus struct {
@@ -88,387 +97,341 @@ type MidtransResponse struct {
 }
 
 func NewMidtransService() *MidtransService {
-	// Coba ambil dari environment variable dulu
+	appConfig := config.GetConfig()
+
+	// Coba ambil dari environment variable dulu, jika tidak ada ambil dari config
 	serverKey := os.Getenv("MIDTRANS_SERVER_KEY")
 	if serverKey == "" {
-		// Jika tidak ada, ambil dari config
-		appConfig := config.GetConfig()
-		if appConfig.Midtrans.ServerKey != "" {
-			serverKey = appConfig.Midtrans.ServerKey
-		} else {
-			// Fallback ke hardcoded value
-			serverKey = "SB-Mid-server-i1dcC7yEZ88t9ojPby0wej3n"
-		}
+		serverKey = appConfig.Midtrans.ServerKey
+	}
+	if serverKey == "" {
+		fmt.Printf("[SALOME BE] WARNING: Midtrans server key is not configured, Midtrans requests will fail\n")
 	}
-
-	fmt.Printf("🔑 [MIDTRANS DEBUG] Using server key: %s\n", serverKey[:20]+"...")
 
 	return &MidtransService{
-		serverKey: serverKey,
+		serverKey:      serverKey,
+		baseURL:        strings.TrimRight(appConfig.Midtrans.BaseURL, "/"),
+		paymentLinkAPI: strings.TrimRight(appConfig.Midtrans.PaymentLink.CheckURL, "/"),
+		paymentLinkWeb: strings.TrimRight(appConfig.Midtrans.PaymentLink.WebURL, "/"),
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
-// GetTransactionStatus mengambil status transaksi dari Midtrans menggunakan Payment Link API
-func (m *MidtransService) GetTransactionStatus(paymentLinkID string) (*MidtransTransactionStatus, error) {
-	return m.checkPaymentLinkStatus(paymentLinkID)
+func (m *MidtransService) Name() string {
+	return PaymentGatewayMidtrans
 }
 
-func (m *MidtransService) checkPaymentLinkStatus(paymentLinkID string) (*MidtransTransactionStatus, error) {
-	url := fmt.Sprintf("https://api.sandbox.midtrans.com/v1/payment-links/%s", paymentLinkID)
-
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Checking payment link status for ID: %s\n", paymentLinkID)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request URL: %s\n", url)
-
-	req, err := http.NewRequest("GET", url, nil)
+// newRequest builds an authenticated Midtrans API request
+func (m *MidtransService) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	if m.serverKey == "" {
+		return nil, fmt.Errorf("midtrans server key is not configured")
+	}
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error creating request: %v\n", err)
 		return nil, err
 	}
-
-	// Set headers
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(m.serverKey+":")))
+	return req, nil
+}
 
-	// Set authorization header
-	auth := base64.StdEncoding.EncodeToString([]byte(m.serverKey + ":"))
-	req.Header.Set("Authorization", "Basic "+auth)
-
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request headers: Authorization=Basic %s\n", auth[:10]+"...")
-
-	resp, err := m.client.Do(req)
-	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error making request: %v\n", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
+// GetTransactionStatus mengambil status transaksi dari Midtrans menggunakan Payment Link API
+func (m *MidtransService) GetTransactionStatus(paymentLinkID string) (*PaymentStatus, error) {
+	return m.checkPaymentLinkStatus(paymentLinkID)
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (m *MidtransService) checkPaymentLinkStatus(paymentLinkID string) (*PaymentStatus, error) {
+	paymentLinkResp, err := m.GetPaymentLinkStatus(paymentLinkID)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error reading response body: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response status: %d\n", resp.StatusCode)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response body: %s\n", string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("❌ [MIDTRANS DEBUG] API error - Status: %d, Body: %s\n", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("midtrans API error: %s", string(body))
-	}
-
-	var paymentLinkResp MidtransPaymentLinkResponse
-	err = json.Unmarshal(body, &paymentLinkResp)
-	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error unmarshaling response: %v\n", err)
-		return nil, err
-	}
+	// Ambil purchase pertama (biasanya hanya ada 1) dengan status apapun (SETTLEMENT, EXPIRE, dll)
+	if len(paymentLinkResp.Purchases) > 0 {
+		purchase := paymentLinkResp.Purchases[0]
+		fmt.Printf("✅ [MIDTRANS DEBUG] Found purchase: OrderID=%s, Status=%s, Method=%s\n",
+			purchase.OrderID, purchase.PaymentStatus, purchase.PaymentMethod)
 
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment link response parsed successfully\n")
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment Link ID: %s\n", paymentLinkResp.PaymentLinkID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Order ID: %s\n", paymentLinkResp.OrderID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Status: %s\n", paymentLinkResp.Status)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Number of purchases: %d\n", len(paymentLinkResp.Purchases))
-
-	// Cari purchase dengan status apapun (SETTLEMENT, EXPIRE, dll)
-	var foundPurchase *MidtransPaymentLinkResponse
-	var purchaseStatus string
-	var purchaseMethod string
-	var purchaseOrderID string
-	var purchaseTransactionID string
-	var purchaseAmount int
-	var purchaseCurrency string
-	var purchaseTime string
-
-	for i, purchase := range paymentLinkResp.Purchases {
-		fmt.Printf("✅ [MIDTRANS DEBUG] Purchase %d: OrderID=%s, Status=%s, Method=%s\n",
-			i+1, purchase.OrderID, purchase.PaymentStatus, purchase.PaymentMethod)
-
-		// Ambil purchase terbaru (biasanya hanya ada 1)
-		foundPurchase = &paymentLinkResp
-		purchaseStatus = purchase.PaymentStatus
-		purchaseMethod = purchase.PaymentMethod
-		purchaseOrderID = purchase.OrderID
-		purchaseTransactionID = purchase.TransactionID
-		purchaseAmount = purchase.AmountValue
-		purchaseCurrency = purchase.AmountCurrency
-		purchaseTime = purchase.UpdatedAt
-		fmt.Printf("✅ [MIDTRANS DEBUG] Found purchase with status: %s\n", purchaseStatus)
-		break
-	}
-
-	// Jika ada purchase, return status berdasarkan purchase status
-	if foundPurchase != nil {
 		// Map status dari Midtrans ke format yang diharapkan
 		var mappedStatus string
-		switch purchaseStatus {
+		switch purchase.PaymentStatus {
 		case "SETTLEMENT":
-			mappedStatus = "settlement" // Akan di-mapping ke "completed" di handler
+			mappedStatus = "settlement" // Akan di-mapping ke "success" di handler
 		case "EXPIRE":
 			mappedStatus = "expire"
 		case "DENY":
 			mappedStatus = "deny"
 		case "CANCEL":
 			mappedStatus = "cancel"
+		case "REFUND", "PARTIAL_REFUND":
+			mappedStatus = strings.ToLower(purchase.PaymentStatus)
 		case "CREATED":
 			mappedStatus = "failed" // CREATED dengan method INIT = failed
+			fmt.Printf("⚠️ [MIDTRANS DEBUG] CREATED status detected with method %s - marking as failed\n", purchase.PaymentMethod)
 		default:
 			mappedStatus = "pending"
 		}
 
-		fmt.Printf("✅ [MIDTRANS DEBUG] Status mapping: %s -> %s\n", purchaseStatus, mappedStatus)
-		fmt.Printf("✅ [MIDTRANS DEBUG] Payment method: %s\n", purchaseMethod)
+		fmt.Printf("✅ [MIDTRANS DEBUG] Status mapping: %s -> %s\n", purchase.PaymentStatus, mappedStatus)
 
-		// Log khusus untuk status CREATED
-		if purchaseStatus == "CREATED" {
-			fmt.Printf("⚠️ [MIDTRANS DEBUG] CREATED status detected with method %s - marking as failed\n", purchaseMethod)
-		}
-
-		return &MidtransTransactionStatus{
-			TransactionID:     purchaseTransactionID,
-			OrderID:           purchaseOrderID,
-			PaymentType:       purchaseMethod,
-			TransactionTime:   purchaseTime,
+		return &PaymentStatus{
+			TransactionID:     purchase.TransactionID,
+			OrderID:           purchase.OrderID,
+			PaymentType:       purchase.PaymentMethod,
+			TransactionTime:   purchase.UpdatedAt,
 			TransactionStatus: mappedStatus,
-			GrossAmount:       fmt.Sprintf("%d", purchaseAmount),
-			Currency:          purchaseCurrency,
+			GrossAmount:       fmt.Sprintf("%d", purchase.AmountValue),
+			Currency:          purchase.AmountCurrency,
 		}, nil
 	}
 
 	// Jika tidak ada purchases, berarti transaksi belum pernah dibayar atau gagal
 	fmt.Printf("⚠️ [MIDTRANS DEBUG] No purchases found, marking as failed\n")
-	return &MidtransTransactionStatus{
+	return &PaymentStatus{
 		OrderID:           paymentLinkResp.OrderID,
 		TransactionStatus: "failed",
 		GrossAmount:       fmt.Sprintf("%d", paymentLinkResp.GrossAmount),
 		Currency:          paymentLinkResp.Currency,
 	}, nil
-
 }
 
+// checkTransactionStatus mengambil status transaksi dari Midtrans Core API berdasarkan order ID transaksi
 func (m *MidtransService) checkTransactionStatus(orderID string) (*MidtransTransactionStatus, error) {
-	url := fmt.Sprintf("https://api.sandbox.midtrans.com/v2/%s/status", orderID)
+	url := fmt.Sprintf("%s/v2/%s/status", m.baseURL, orderID)
 
 	fmt.Printf("🔍 [MIDTRANS DEBUG] Checking transaction status for order ID: %s\n", orderID)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request URL: %s\n", url)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := m.newRequest("GET", url, nil)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Failed to create request: %v\n", err)
 		return nil, err
 	}
 
-	// Set authorization header
-	auth := base64.StdEncoding.EncodeToString([]byte(m.serverKey + ":"))
-	req.Header.Set("Authorization", "Basic "+auth)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
+	body, err := m.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request headers: Authorization=Basic %s\n", auth[:10]+"...")
+	var result MidtransTransactionStatus
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Printf("❌ [MIDTRANS DEBUG] Failed to unmarshal response: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Printf("✅ [MIDTRANS DEBUG] Order %s: transaction_status=%s, payment_type=%s, gross_amount=%s\n",
+		result.OrderID, result.TransactionStatus, result.PaymentType, result.GrossAmount)
+	return &result, nil
+}
+
+// do sends a request and returns the response body, or an error if the status is not one of okStatuses
+func (m *MidtransService) do(req *http.Request, okStatuses ...int) ([]byte, error) {
+	fmt.Printf("🔍 [MIDTRANS DEBUG] Request: %s %s\n", req.Method, req.URL.String())
 
 	resp, err := m.client.Do(req)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Request failed: %v\n", err)
+		fmt.Printf("❌ [MIDTRANS DEBUG] Error making request: %v\n", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Failed to read response body: %v\n", err)
+		fmt.Printf("❌ [MIDTRANS DEBUG] Error reading response body: %v\n", err)
 		return nil, err
 	}
 
 	fmt.Printf("🔍 [MIDTRANS DEBUG] Response status: %d\n", resp.StatusCode)
 	fmt.Printf("🔍 [MIDTRANS DEBUG] Response body: %s\n", string(body))
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("❌ [MIDTRANS DEBUG] API error - Status: %d, Body: %s\n", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("midtrans API error: %s", string(body))
-	}
-
-	var result MidtransTransactionStatus
-	if err := json.Unmarshal(body, &result); err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Failed to unmarshal response: %v\n", err)
-		return nil, err
+	for _, status := range okStatuses {
+		if resp.StatusCode == status {
+			return body, nil
+		}
 	}
-
-	fmt.Printf("✅ [MIDTRANS DEBUG] Successfully parsed response:\n")
-	fmt.Printf("   - Order ID: %s\n", result.OrderID)
-	fmt.Printf("   - Transaction Status: %s\n", result.TransactionStatus)
-	fmt.Printf("   - Payment Type: %s\n", result.PaymentType)
-	fmt.Printf("   - Gross Amount: %s\n", result.GrossAmount)
-	fmt.Printf("   - Transaction Time: %s\n", result.TransactionTime)
-
-	return &result, nil
-}
-
-// GetPaymentLinkURL mengambil URL payment link dari Midtrans
-func (m *MidtransService) GetPaymentLinkURL(orderID string) (string, error) {
-	// Cek apakah ada payment link yang tersimpan di database
-	// Atau buat payment link baru jika belum ada
-
-	// Untuk sementara, return URL sandbox Midtrans
-	// Di production, ini harus disimpan di database saat membuat transaksi
-	return fmt.Sprintf("https://app.sandbox.midtrans.com/payment-links/%s", orderID), nil
-}
-
-// IsTransactionSettled mengecek apakah transaksi sudah settlement
-func (m *MidtransService) IsTransactionSettled(status string) bool {
-	return status == "settlement" || status == "capture"
-}
-
-// IsTransactionPending mengecek apakah transaksi masih pending
-func (m *MidtransService) IsTransactionPending(status string) bool {
-	return status == "pending" || status == "challenge"
+	return body, fmt.Errorf("midtrans API error (status %d): %s", resp.StatusCode, string(body))
 }
 
-// IsTransactionFailed mengecek apakah transaksi gagal
-func (m *MidtransService) IsTransactionFailed(status string) bool {
-	return status == "deny" || status == "cancel" || status == "expire" || status == "EXPIRE" || status == "failed"
+// PaymentLinkURL returns the Midtrans page where a payment link is paid
+func (m *MidtransService) PaymentLinkURL(paymentLinkID string) string {
+	if paymentLinkID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", m.paymentLinkWeb, paymentLinkID)
 }
 
 // GetPaymentLinkStatus mengambil status payment link dari Midtrans
 func (m *MidtransService) GetPaymentLinkStatus(paymentLinkID string) (*MidtransPaymentLinkResponse, error) {
-	url := fmt.Sprintf("https://api.sandbox.midtrans.com/v1/payment-links/%s", paymentLinkID)
+	url := fmt.Sprintf("%s/%s", m.paymentLinkAPI, paymentLinkID)
 
 	fmt.Printf("🔍 [MIDTRANS DEBUG] Getting payment link status for ID: %s\n", paymentLinkID)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request URL: %s\n", url)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := m.newRequest("GET", url, nil)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error creating request: %v\n", err)
 		return nil, err
 	}
 
-	// Set headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-
-	// Set authorization header
-	auth := base64.StdEncoding.EncodeToString([]byte(m.serverKey + ":"))
-	req.Header.Set("Authorization", "Basic "+auth)
-
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request headers: Authorization=Basic %s\n", auth[:10]+"...")
-
-	resp, err := m.client.Do(req)
+	body, err := m.do(req, http.StatusOK)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error making request: %v\n", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error reading response body: %v\n", err)
-		return nil, err
-	}
-
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response status: %d\n", resp.StatusCode)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response body: %s\n", string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("❌ [MIDTRANS DEBUG] API error - Status: %d, Body: %s\n", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("midtrans API error: %s", string(body))
-	}
 
 	var paymentLinkResp MidtransPaymentLinkResponse
-	err = json.Unmarshal(body, &paymentLinkResp)
-	if err != nil {
+	if err := json.Unmarshal(body, &paymentLinkResp); err != nil {
 		fmt.Printf("❌ [MIDTRANS DEBUG] Error unmarshaling response: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment link response parsed successfully\n")
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment Link ID: %s\n", paymentLinkResp.PaymentLinkID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Order ID: %s\n", paymentLinkResp.OrderID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Status: %s\n", paymentLinkResp.Status)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Gross Amount: %d\n", paymentLinkResp.GrossAmount)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Currency: %s\n", paymentLinkResp.Currency)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Expiry Time: %s\n", paymentLinkResp.ExpiryTime)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Number of purchases: %d\n", len(paymentLinkResp.Purchases))
+	fmt.Printf("✅ [MIDTRANS DEBUG] Payment link %s: order_id=%s, status=%s, purchases=%d\n",
+		paymentLinkResp.PaymentLinkID, paymentLinkResp.OrderID, paymentLinkResp.Status, len(paymentLinkResp.Purchases))
 
 	return &paymentLinkResp, nil
 }
 
 // CreatePaymentLink membuat payment link baru di Midtrans
-func (m *MidtransService) CreatePaymentLink(orderID string, amount int, currency string, expiryMinutes int) (*MidtransPaymentLinkResponse, error) {
-	url := "https://api.sandbox.midtrans.com/v1/payment-links"
-
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Creating payment link for order: %s\n", orderID)
+func (m *MidtransService) CreatePaymentLink(linkReq PaymentLinkRequest) (*PaymentLink, error) {
+	if linkReq.Amount.Currency != money.IDR {
+		return nil, ErrUnsupportedCurrency
+	}
+	expiryHours := linkReq.ExpiryHours
+	if expiryHours <= 0 {
+		expiryHours = 24
+	}
 
-	// Prepare request body
 	requestBody := map[string]interface{}{
-		"order_id":     orderID,
-		"gross_amount": amount,
-		"currency":     currency,
-		"expiry_time":  fmt.Sprintf("%d minutes", expiryMinutes),
-		"payment_settings": map[string]interface{}{
-			"payment_methods": []string{"credit_card", "bca_va", "bni_va", "bri_va", "echannel", "permata_va", "other_va", "gopay", "kredivo", "shopeepay"},
+		"transaction_details": map[string]interface{}{
+			"order_id":     linkReq.OrderID,
+			"gross_amount": linkReq.Amount.Amount.Units(),
 		},
+		"item_details": []map[string]interface{}{
+			{
+				"id":       fmt.Sprintf("item-%s", linkReq.OrderID),
+				"name":     linkReq.Description,
+				"price":    linkReq.Amount.Amount.Units(),
+				"quantity": 1,
+			},
+		},
+		"customer_details": map[string]interface{}{
+			"first_name": linkReq.CustomerName,
+			"last_name":  "User",
+			"email":      linkReq.CustomerEmail,
+			"phone":      "081234567890",
+		},
+		"expiry": map[string]interface{}{
+			"start_time": time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+			"duration":   expiryHours,
+			"unit":       "hours",
+		},
+	}
+	if len(linkReq.EnabledPayments) > 0 {
+		requestBody["enabled_payments"] = linkReq.EnabledPayments
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error marshaling request body: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request body: %s\n", string(jsonBody))
+	fmt.Printf("🔍 [MIDTRANS DEBUG] Creating payment link for order: %s\n", linkReq.OrderID)
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonBody)))
+	req, err := m.newRequest("POST", m.paymentLinkAPI, bytes.NewReader(jsonBody))
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error creating request: %v\n", err)
 		return nil, err
 	}
 
-	// Set headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
+	body, err := m.do(req, http.StatusOK, http.StatusCreated)
+	var respData map[string]interface{}
+	json.Unmarshal(body, &respData)
+	if err != nil {
+		return &PaymentLink{OrderID: linkReq.OrderID, Raw: respData}, err
+	}
 
-	// Set authorization header
-	auth := base64.StdEncoding.EncodeToString([]byte(m.serverKey + ":"))
-	req.Header.Set("Authorization", "Basic "+auth)
+	// Payment link ID adalah segmen terakhir dari payment_url
+	paymentURL, _ := respData["payment_url"].(string)
+	paymentLinkID := ""
+	if paymentURL != "" {
+		parts := strings.Split(paymentURL, "/")
+		paymentLinkID = parts[len(parts)-1]
+	}
 
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Request headers: Authorization=Basic %s\n", auth[:10]+"...")
+	return &PaymentLink{
+		OrderID:       linkReq.OrderID,
+		PaymentLinkID: paymentLinkID,
+		PaymentURL:    paymentURL,
+		Raw:           respData,
+	}, nil
+}
 
-	resp, err := m.client.Do(req)
+// Refund mengembalikan dana transaksi yang sudah settlement melalui Midtrans Refund API.
+// Payment link membuat transaksi dengan order ID sendiri, jadi order ID purchase dicari dulu.
+func (m *MidtransService) Refund(refundReq RefundRequest) (*RefundResult, error) {
+	status, err := m.checkPaymentLinkStatus(refundReq.PaymentReference)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error making request: %v\n", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if !IsTransactionSettled(status.TransactionStatus) && status.TransactionStatus != "partial_refund" {
+		return nil, ErrRefundNotAllowed
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	jsonBody, err := json.Marshal(map[string]interface{}{
+		"refund_key": refundReq.RefundKey,
+		"amount":     refundReq.Amount.Units(),
+		"reason":     refundReq.Reason,
+	})
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error reading response body: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response status: %d\n", resp.StatusCode)
-	fmt.Printf("🔍 [MIDTRANS DEBUG] Response body: %s\n", string(body))
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		fmt.Printf("❌ [MIDTRANS DEBUG] API error - Status: %d, Body: %s\n", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("midtrans API error: %s", string(body))
+	req, err := m.newRequest("POST", fmt.Sprintf("%s/v2/%s/refund", m.baseURL, status.OrderID), bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
 	}
 
-	var paymentLinkResp MidtransPaymentLinkResponse
-	err = json.Unmarshal(body, &paymentLinkResp)
+	body, err := m.do(req, http.StatusOK)
 	if err != nil {
-		fmt.Printf("❌ [MIDTRANS DEBUG] Error unmarshaling response: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment link created successfully\n")
-	fmt.Printf("✅ [MIDTRANS DEBUG] Payment Link ID: %s\n", paymentLinkResp.PaymentLinkID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Order ID: %s\n", paymentLinkResp.OrderID)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Status: %s\n", paymentLinkResp.Status)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Gross Amount: %d\n", paymentLinkResp.GrossAmount)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Currency: %s\n", paymentLinkResp.Currency)
-	fmt.Printf("✅ [MIDTRANS DEBUG] Expiry Time: %s\n", paymentLinkResp.ExpiryTime)
+	// Core API menjawab HTTP 200 dengan status_code di body
+	var refundResp struct {
+		StatusCode        string `json:"status_code"`
+		StatusMessage     string `json:"status_message"`
+		TransactionStatus string `json:"transaction_status"`
+		RefundKey         string `json:"refund_key"`
+		RefundAmount      string `json:"refund_amount"`
+	}
+	if err := json.Unmarshal(body, &refundResp); err != nil {
+		return nil, err
+	}
+	if refundResp.StatusCode != "200" {
+		return nil, fmt.Errorf("midtrans refund failed: %s %s", refundResp.StatusCode, refundResp.StatusMessage)
+	}
 
-	return &paymentLinkResp, nil
+	return &RefundResult{
+		RefundKey:         refundResp.RefundKey,
+		Amount:            refundReq.Amount,
+		TransactionStatus: refundResp.TransactionStatus,
+	}, nil
+}
+
+// ParseWebhook membaca body HTTP notification dari Midtrans
+func (m *MidtransService) ParseWebhook(body []byte) (*PaymentNotification, error) {
+	return parseMidtransNotification(body)
+}
+
+// SignatureKey menghitung signature_key notifikasi Midtrans:
+// SHA512(order_id + status_code + gross_amount + key) dalam bentuk hex
+func SignatureKey(orderID, statusCode, grossAmount, key string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifySignatureKey mengecek signature_key notifikasi dengan constant-time compare
+func VerifySignatureKey(orderID, statusCode, grossAmount, signature, key string) bool {
+	if signature == "" || key == "" {
+		return false
+	}
+	expected := SignatureKey(orderID, statusCode, grossAmount, key)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(signature))) == 1
 }
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"salome-be/internal/config"
+	"salome-be/internal/money"
+)
+
+const (
+	PaymentGatewayMidtrans = "midtrans"
+	PaymentGatewayFake     = "fake"
+)
+
+var (
+	ErrPaymentLinkNotFound = errors.New("payment link not found")
+	ErrRefundNotAllowed    = errors.New("payment cannot be refunded")
+	ErrUnsupportedCurrency = errors.New("payment gateway only accepts IDR")
+)
+
+// PaymentGateway is a payment provider that collects payments through hosted payment links.
+// Transaction statuses use the Midtrans vocabulary (settlement, pending, expire, deny, cancel, failed)
+// so every provider can be mapped to internal statuses the same way.
+type PaymentGateway interface {
+	Name() string
+	CreatePaymentLink(req PaymentLinkRequest) (*PaymentLink, error)
+	GetTransactionStatus(paymentReference string) (*PaymentStatus, error)
+	Refund(req RefundRequest) (*RefundResult, error)
+	ParseWebhook(body []byte) (*PaymentNotification, error)
+	PaymentLinkURL(paymentLinkID string) string
+}
+
+// PaymentLinkRequest describes a payment link to create
+type PaymentLinkRequest struct {
+	OrderID         string
+	Amount          money.Money
+	Description     string
+	CustomerName    string
+	CustomerEmail   string
+	ExpiryHours     int
+	EnabledPayments []string // Optional, restricts the link to these payment methods
+}
+
+// PaymentLink is a created payment link. Raw holds the provider response for error details.
+type PaymentLink struct {
+	OrderID       string                 `json:"order_id"`
+	PaymentLinkID string                 `json:"payment_link_id"`
+	PaymentURL    string                 `json:"payment_url"`
+	Raw           map[string]interface{} `json:"-"`
+}
+
+// PaymentStatus is the current state of the payment made through a payment link
+type PaymentStatus struct {
+	TransactionID     string `json:"transaction_id"`
+	OrderID           string `json:"order_id"`
+	PaymentType       string `json:"payment_type"`
+	TransactionTime   string `json:"transaction_time"`
+	TransactionStatus string `json:"transaction_status"`
+	GrossAmount       string `json:"gross_amount"`
+	Currency          string `json:"currency"`
+}
+
+// RefundRequest refunds a settled payment, fully or partially. RefundKey makes the refund idempotent.
+type RefundRequest struct {
+	PaymentReference string
+	Amount           money.Amount
+	Reason           string
+	RefundKey        string
+}
+
+// RefundResult is the provider's answer to a refund request
+type RefundResult struct {
+	RefundKey         string       `json:"refund_key"`
+	Amount            money.Amount `json:"amount"`
+	TransactionStatus string       `json:"transaction_status"`
+}
+
+// PaymentNotification is a payment status notification as sent to our webhook
+type PaymentNotification struct {
+	OrderID           string `json:"order_id"`
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	PaymentType       string `json:"payment_type"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+	FraudStatus       string `json:"fraud_status,omitempty"`
+}
+
+// NewPaymentGateway returns the gateway selected by payment.gateway in the config
+func NewPaymentGateway() PaymentGateway {
+	switch config.GetConfig().Payment.Gateway {
+	case PaymentGatewayFake:
+		return NewFakeGateway()
+	default:
+		return NewMidtransService()
+	}
+}
+
+// parseMidtransNotification decodes a notification in the Midtrans HTTP notification format
+func parseMidtransNotification(body []byte) (*PaymentNotification, error) {
+	var n PaymentNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("invalid notification format: %v", err)
+	}
+	if n.OrderID == "" {
+		return nil, errors.New("invalid order ID")
+	}
+	if n.TransactionStatus == "" {
+		return nil, errors.New("invalid transaction status")
+	}
+	return &n, nil
+}
+
+// IsTransactionSettled mengecek apakah transaksi sudah settlement
+func IsTransactionSettled(status string) bool {
+	return status == "settlement" || status == "capture"
+}
+
+// IsTransactionPending mengecek apakah transaksi masih pending
+func IsTransactionPending(status string) bool {
+	return status == "pending" || status == "challenge"
+}
+
+// IsTransactionFailed mengecek apakah transaksi gagal
+func IsTransactionFailed(status string) bool {
+	return status == "deny" || status == "cancel" || status == "expire" || status == "EXPIRE" || status == "failed"
+}
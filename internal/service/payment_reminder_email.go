@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"html"
+)
+
+// PaymentReminderEmailData reminds a member to pay for a seat before the payment deadline
+type PaymentReminderEmailData struct {
+	Email      string
+	Name       string
+	GroupName  string
+	Amount     string // formatted amount, e.g. "Rp 150.000"
+	Deadline   string // formatted deadline, e.g. "16 Oct 2026 20:00 WIB"
+	PaymentURL string // Optional, the member is sent to the app when there is no payment link
+}
+
+// paymentReminderEmailContent returns the subject, HTML and plain text body of a payment reminder email
+func paymentReminderEmailContent(data PaymentReminderEmailData) (subject, htmlBody, text string) {
+	subject = fmt.Sprintf("Pengingat Pembayaran - %s", data.GroupName)
+
+	action := `<p>Buka aplikasi SALOME untuk menyelesaikan pembayaran.</p>`
+	actionText := "Buka aplikasi SALOME untuk menyelesaikan pembayaran."
+	if data.PaymentURL != "" {
+		action = fmt.Sprintf(`<p style="text-align: center;"><a class="button" href="%s">Bayar Sekarang</a></p>`,
+			html.EscapeString(data.PaymentURL))
+		actionText = "Bayar sekarang: " + data.PaymentURL
+	}
+
+	htmlBody = emailLayout("Pengingat Pembayaran", fmt.Sprintf(`
+			<p>Halo <strong>%s</strong>,</p>
+
+			<p>Pembayaran seat Anda di grup <strong>%s</strong> belum kami terima.</p>
+
+			<div class="summary">
+				<strong>Jumlah:</strong> %s<br>
+				<strong>Batas waktu:</strong> %s
+			</div>
+
+			<p>Jika pembayaran belum diterima sampai batas waktu, Anda akan dikeluarkan dari grup.</p>
+
+			%s
+	`, html.EscapeString(data.Name), html.EscapeString(data.GroupName), html.EscapeString(data.Amount),
+		html.EscapeString(data.Deadline), action))
+
+	text = fmt.Sprintf(`
+SALOME - Pengingat Pembayaran
+
+Halo %s,
+
+Pembayaran seat Anda di grup %s belum kami terima.
+
+Jumlah: %s
+Batas waktu: %s
+
+Jika pembayaran belum diterima sampai batas waktu, Anda akan dikeluarkan dari grup.
+
+%s
+
+--
+SALOME Team
+	`, data.Name, data.GroupName, data.Amount, data.Deadline, actionText)
+
+	return subject, htmlBody, text
+}
@@ -314,3 +314,51 @@ SALOME Team
 	log.Printf("ResendService: Welcome email sent successfully to %s. Message ID: %s", email, res.Id)
 	return nil
 }
+
+func (rs *ResendService) SendInvoiceEmail(ctx context.Context, data InvoiceEmailData) error {
+	subject, html, text := invoiceEmailContent(data)
+	return rs.send(ctx, data.Email, subject, html, text, &emailAttachment{
+		FileName:    data.FileName,
+		ContentType: "application/pdf",
+		Content:     data.PDF,
+	})
+}
+
+func (rs *ResendService) SendPaymentReminderEmail(ctx context.Context, data PaymentReminderEmailData) error {
+	subject, html, text := paymentReminderEmailContent(data)
+	return rs.send(ctx, data.Email, subject, html, text, nil)
+}
+
+func (rs *ResendService) SendGroupInvitationEmail(ctx context.Context, data GroupInvitationEmailData) error {
+	subject, html, text := groupInvitationEmailContent(data)
+	return rs.send(ctx, data.Email, subject, html, text, nil)
+}
+
+// send delivers a transactional email with an optional attachment
+func (rs *ResendService) send(ctx context.Context, email, subject, html, text string, attachment *emailAttachment) error {
+	params := &resend.SendEmailRequest{
+		From:    fmt.Sprintf("%s <%s>", "SALOME Platform", rs.from),
+		To:      []string{email},
+		Subject: subject,
+		Html:    html,
+		Text:    text,
+	}
+	if attachment != nil {
+		params.Attachments = []*resend.Attachment{
+			{
+				Content:     attachment.Content,
+				Filename:    attachment.FileName,
+				ContentType: attachment.ContentType,
+			},
+		}
+	}
+
+	res, err := rs.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		log.Printf("ResendService: Error sending email %q to %s: %v", subject, email, err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	log.Printf("ResendService: Email %q sent successfully to %s. Message ID: %s", subject, email, res.Id)
+	return nil
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+)
+
+var (
+	ErrDisputeNotFound     = errors.New("dispute not found")
+	ErrDisputeNotMember    = errors.New("only members of the group can open a dispute")
+	ErrDisputeOwnerOnly    = errors.New("only the group owner can respond to a dispute")
+	ErrDisputeMemberOnly   = errors.New("only the member who opened the dispute can withdraw it")
+	ErrDisputeNoPeriod     = errors.New("there is no billing period to dispute yet")
+	ErrDisputeWindowClosed = errors.New("this billing period can no longer be disputed")
+	ErrDisputeExists       = errors.New("this billing period has already been disputed")
+	ErrDisputeClosed       = errors.New("dispute is already closed")
+)
+
+// DisputeService handles the disputes members open when the shared subscription of a group does not work for
+// their billing period. Opening a dispute moves the owner's earnings from the payment for the period to a
+// hold account, so they cannot be withdrawn; an admin resolution or the member withdrawing the dispute
+// releases them again, before any refund is booked.
+type DisputeService struct {
+	db      *sql.DB
+	ledger  *ledger.Ledger
+	refunds *RefundService
+	config  config.DisputeConfig
+}
+
+func NewDisputeService(db *sql.DB) *DisputeService {
+	return &DisputeService{
+		db:      db,
+		ledger:  ledger.New(db),
+		refunds: NewRefundService(db),
+		config:  config.GetConfig().Disputes,
+	}
+}
+
+const disputeColumns = `d.id, d.group_id, d.member_id, d.owner_id, d.period_start, d.period_end, d.transaction_id,
+	d.category, d.evidence, d.status, d.owner_response, d.responded_at, d.held_amount, d.resolution,
+	d.resolution_note, d.refunded_amount, d.resolved_by, d.closed_at, d.created_at, d.updated_at, g.name,
+	COALESCE(m.full_name, ''), COALESCE(o.full_name, '')`
+
+const disputeJoins = `
+	FROM group_disputes d
+	JOIN groups g ON g.id = d.group_id
+	JOIN users m ON m.id = d.member_id
+	JOIN users o ON o.id = d.owner_id`
+
+func scanDispute(row rowScanner) (*models.GroupDispute, error) {
+	var d models.GroupDispute
+	err := row.Scan(&d.ID, &d.GroupID, &d.MemberID, &d.OwnerID, &d.PeriodStart, &d.PeriodEnd, &d.TransactionID,
+		&d.Category, &d.Evidence, &d.Status, &d.OwnerResponse, &d.RespondedAt, &d.HeldAmount, &d.Resolution,
+		&d.ResolutionNote, &d.RefundedAmount, &d.ResolvedBy, &d.ClosedAt, &d.CreatedAt, &d.UpdatedAt, &d.GroupName,
+		&d.MemberName, &d.OwnerName)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Open disputes the member's current billing period in the group. It can be disputed until
+// config.WindowDays after the period ends, once per period unless the member withdraws the dispute.
+func (s *DisputeService) Open(memberID, groupID string, req models.DisputeRequest) (*models.GroupDispute, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var ownerID, groupName string
+	err = tx.QueryRow(`
+		SELECT owner_id, name FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+	`, groupID).Scan(&ownerID, &groupName)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+	if memberID == ownerID {
+		return nil, ErrDisputeNotMember
+	}
+
+	var userStatus string
+	var periodStart, periodEnd *time.Time
+	err = tx.QueryRow(`
+		SELECT user_status, subscription_period_start, subscription_period_end
+		FROM group_members
+		WHERE group_id = $1 AND user_id = $2
+		FOR UPDATE
+	`, groupID, memberID).Scan(&userStatus, &periodStart, &periodEnd)
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeNotMember
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member status: %v", err)
+	}
+	if userStatus == models.UserStatusPending || userStatus == models.UserStatusRemoved {
+		return nil, ErrDisputeNotMember
+	}
+	if periodStart == nil || periodEnd == nil {
+		return nil, ErrDisputeNoPeriod
+	}
+	now := time.Now()
+	if now.After(periodEnd.AddDate(0, 0, s.config.WindowDays)) {
+		return nil, ErrDisputeWindowClosed
+	}
+
+	transactionID, held, err := s.disputedPayment(tx, memberID, groupID, *periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var disputeID string
+	err = tx.QueryRow(`
+		INSERT INTO group_disputes (group_id, member_id, owner_id, period_start, period_end, transaction_id, category,
+		                            evidence, status, held_amount, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (group_id, member_id, period_end) WHERE status <> 'withdrawn' DO NOTHING
+		RETURNING id
+	`, groupID, memberID, ownerID, *periodStart, *periodEnd, transactionID, req.Category,
+		strings.TrimSpace(req.Evidence), models.DisputeOpen, held, now).Scan(&disputeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dispute: %v", err)
+	}
+
+	if held > 0 {
+		_, err = s.ledger.HoldDispute(tx, disputeID, ledger.Operation{
+			UserID:      ownerID,
+			GroupID:     groupID,
+			Amount:      held,
+			Description: fmt.Sprintf("Pendapatan grup %s ditahan karena sengketa", groupName),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	message := fmt.Sprintf("Anggota grup %s melaporkan masalah: %s.", groupName, disputeCategoryLabel(req.Category))
+	if held > 0 {
+		message += fmt.Sprintf(" Pendapatan sebesar %s ditahan sampai sengketa selesai.", held.Format())
+	}
+	err = notify(tx, ownerID, "dispute", "Sengketa Baru", message+" Silakan beri tanggapan.",
+		disputeURL(groupID, disputeID), "Tanggapi")
+	if err != nil {
+		return nil, err
+	}
+
+	dispute, err := scanDispute(tx.QueryRow(`SELECT `+disputeColumns+disputeJoins+` WHERE d.id = $1`, disputeID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Dispute opened: ID=%s, GroupID=%s, MemberID=%s, Category=%s, Held=%s\n",
+		disputeID, groupID, memberID, req.Category, held)
+	return dispute, nil
+}
+
+// disputedPayment finds the member's latest settled payment made by the end of the period and the part of it
+// credited to the group escrow, capped by what the escrow still holds
+func (s *DisputeService) disputedPayment(tx *sql.Tx, memberID, groupID string, periodEnd time.Time) (*string, money.Amount, error) {
+	var transactionID string
+	err := tx.QueryRow(`
+		SELECT id FROM transactions
+		WHERE user_id = $1 AND group_id = $2 AND type = 'group_payment'
+		  AND status IN ('success', 'completed') AND related_transaction_id IS NULL AND created_at <= $3
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, memberID, groupID, periodEnd).Scan(&transactionID)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get disputed payment: %v", err)
+	}
+
+	var earnings money.Amount
+	var escrow int64
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(t.amount - COALESCE(t.admin_fee, 0)), 0),
+		       COALESCE((SELECT balance FROM ledger_accounts WHERE account_type = $2 AND owner_id = $3), 0)
+		FROM transactions t
+		WHERE (t.id = $1 OR (t.related_transaction_id = $1 AND t.type = 'group_payment'))
+		  AND t.status IN ('success', 'completed')
+	`, transactionID, ledger.AccountGroupEscrow, groupID).Scan(&earnings, &escrow)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get disputed earnings: %v", err)
+	}
+	return &transactionID, money.Max(0, money.Min(earnings, money.FromMinor(escrow))), nil
+}
+
+// ListForGroup returns the disputes of a group for its owner, or the member's own disputes
+func (s *DisputeService) ListForGroup(userID, groupID string) ([]models.GroupDispute, error) {
+	var ownerID string
+	err := s.db.QueryRow(`
+		SELECT owner_id FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+	`, groupID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+
+	if userID == ownerID {
+		return s.listDisputes(`WHERE d.group_id = $1 ORDER BY d.created_at DESC`, groupID)
+	}
+	return s.listDisputes(`WHERE d.group_id = $1 AND d.member_id = $2 ORDER BY d.created_at DESC`, groupID, userID)
+}
+
+// ListDisputes returns disputes for the admin queue, optionally filtered by status, oldest first
+func (s *DisputeService) ListDisputes(status string, limit, offset int) ([]models.GroupDispute, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM group_disputes WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count disputes: %v", err)
+	}
+
+	disputes, err := s.listDisputes(`WHERE ($1 = '' OR d.status = $1) ORDER BY d.created_at ASC LIMIT $2 OFFSET $3`,
+		status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return disputes, total, nil
+}
+
+func (s *DisputeService) listDisputes(where string, args ...interface{}) ([]models.GroupDispute, error) {
+	rows, err := s.db.Query(`SELECT `+disputeColumns+disputeJoins+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disputes: %v", err)
+	}
+	defer rows.Close()
+
+	disputes := []models.GroupDispute{}
+	for rows.Next() {
+		d, err := scanDispute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dispute: %v", err)
+		}
+		disputes = append(disputes, *d)
+	}
+	return disputes, rows.Err()
+}
+
+// Get returns a dispute to the member who opened it or the group owner; an empty userID skips the check
+func (s *DisputeService) Get(userID, disputeID string) (*models.GroupDispute, error) {
+	d, err := scanDispute(s.db.QueryRow(`SELECT `+disputeColumns+disputeJoins+` WHERE d.id = $1`, disputeID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute: %v", err)
+	}
+	if userID != "" && userID != d.MemberID && userID != d.OwnerID {
+		return nil, ErrDisputeNotFound
+	}
+	return d, nil
+}
+
+// Respond records the owner's answer to an open dispute; answering again replaces it
+func (s *DisputeService) Respond(ownerID, disputeID, response string) (*models.GroupDispute, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	d, err := s.lockDispute(tx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != d.OwnerID {
+		if ownerID != d.MemberID {
+			return nil, ErrDisputeNotFound
+		}
+		return nil, ErrDisputeOwnerOnly
+	}
+	if d.ClosedAt != nil {
+		return nil, ErrDisputeClosed
+	}
+
+	_, err = tx.Exec(`
+		UPDATE group_disputes SET owner_response = $1, responded_at = NOW(), status = $2, updated_at = NOW()
+		WHERE id = $3
+	`, strings.TrimSpace(response), models.DisputeResponded, disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save dispute response: %v", err)
+	}
+
+	err = notify(tx, d.MemberID, "dispute", "Tanggapan Sengketa",
+		fmt.Sprintf("Pemilik grup %s menanggapi laporan Anda. Admin akan meninjau sengketa ini.", d.GroupName),
+		disputeURL(d.GroupID, d.ID), "Lihat Sengketa")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Owner %s responded to dispute %s\n", ownerID, disputeID)
+	return s.Get("", disputeID)
+}
+
+// Withdraw closes a dispute at the member's request and releases the held earnings to the owner
+func (s *DisputeService) Withdraw(memberID, disputeID string) (*models.GroupDispute, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	d, err := s.lockDispute(tx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if memberID != d.MemberID {
+		if memberID != d.OwnerID {
+			return nil, ErrDisputeNotFound
+		}
+		return nil, ErrDisputeMemberOnly
+	}
+	if d.ClosedAt != nil {
+		return nil, ErrDisputeClosed
+	}
+
+	if err := s.releaseHold(tx, d); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE group_disputes SET status = $1, closed_at = NOW(), updated_at = NOW() WHERE id = $2
+	`, models.DisputeWithdrawn, disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to withdraw dispute: %v", err)
+	}
+
+	err = notify(tx, d.OwnerID, "dispute", "Sengketa Dibatalkan",
+		fmt.Sprintf("Anggota grup %s membatalkan laporannya. Pendapatan yang ditahan telah dikembalikan.", d.GroupName),
+		disputeURL(d.GroupID, d.ID), "Lihat Sengketa")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Member %s withdrew dispute %s\n", memberID, disputeID)
+	return s.Get("", disputeID)
+}
+
+// Resolve closes a dispute with an admin's decision. The held earnings go back to the group escrow first;
+// a refund of the disputed payment is then booked against it like any other refund.
+func (s *DisputeService) Resolve(actor Actor, disputeID string, req models.DisputeResolveRequest) (*models.GroupDispute, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	d, err := s.lockDispute(tx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if d.ClosedAt != nil {
+		return nil, ErrDisputeClosed
+	}
+
+	if err := s.releaseHold(tx, d); err != nil {
+		return nil, err
+	}
+
+	var refunded money.Amount
+	refund := req.Resolution == models.DisputeResolutionRefund ||
+		(req.Refund && req.Resolution != models.DisputeResolutionDismissed)
+	if refund && d.TransactionID != nil {
+		summary, err := s.refunds.refundDisputedPayment(tx, d.MemberID, d.GroupID, *d.TransactionID, actor.ID,
+			&d.PeriodStart, &d.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		// Refunds the group earnings no longer cover wait for an admin and are not counted yet
+		for _, r := range summary.Refunds {
+			if r.Status == models.RefundCompleted {
+				refunded += r.Amount
+			}
+		}
+	}
+
+	switch req.Resolution {
+	case models.DisputeResolutionRemoveMember:
+		_, err := s.refunds.removeMemberTx(tx, actor, d.MemberID, d.GroupID, models.RefundReasonDispute, false)
+		if err != nil && !errors.Is(err, ErrNotGroupMember) {
+			return nil, err
+		}
+	case models.DisputeResolutionSuspendOwner:
+		_, err := tx.Exec(`UPDATE users SET status = 'suspended', updated_at = NOW() WHERE id = $1`, d.OwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suspend owner: %v", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE group_disputes
+		SET status = $1, resolution = $2, resolution_note = $3, refunded_amount = $4, resolved_by = $5,
+		    closed_at = NOW(), updated_at = NOW()
+		WHERE id = $6
+	`, models.DisputeResolved, req.Resolution, nullIfEmpty(strings.TrimSpace(req.Note)), refunded,
+		nullIfEmpty(actor.ID), disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve dispute: %v", err)
+	}
+	if err := recalculateReputation(tx, d.OwnerID); err != nil {
+		return nil, err
+	}
+
+	outcome := disputeResolutionLabel(req.Resolution)
+	if refunded > 0 {
+		outcome += fmt.Sprintf(" Dana sebesar %s dikembalikan kepada anggota.", refunded.Format())
+	}
+	for _, userID := range []string{d.MemberID, d.OwnerID} {
+		err = notify(tx, userID, "dispute", "Sengketa Selesai",
+			fmt.Sprintf("Admin telah meninjau sengketa di grup %s. %s", d.GroupName, outcome),
+			disputeURL(d.GroupID, d.ID), "Lihat Sengketa")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Dispute resolved: ID=%s, Resolution=%s, Refunded=%s, By=%s\n",
+		disputeID, req.Resolution, refunded, actor.ID)
+	return s.Get("", disputeID)
+}
+
+// lockDispute loads a dispute and locks its row until tx ends
+func (s *DisputeService) lockDispute(tx *sql.Tx, disputeID string) (*models.GroupDispute, error) {
+	d, err := scanDispute(tx.QueryRow(`SELECT `+disputeColumns+disputeJoins+` WHERE d.id = $1 FOR UPDATE OF d`, disputeID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute: %v", err)
+	}
+	return d, nil
+}
+
+// releaseHold returns the earnings held for a dispute to the group escrow
+func (s *DisputeService) releaseHold(tx *sql.Tx, d *models.GroupDispute) error {
+	if d.HeldAmount <= 0 {
+		return nil
+	}
+	_, err := s.ledger.ReleaseDispute(tx, d.ID, ledger.Operation{
+		UserID:      d.OwnerID,
+		GroupID:     d.GroupID,
+		Amount:      d.HeldAmount,
+		Description: fmt.Sprintf("Pendapatan grup %s yang ditahan untuk sengketa dilepas", d.GroupName),
+	})
+	return err
+}
+
+func disputeURL(groupID, disputeID string) string {
+	return fmt.Sprintf("/groups/%s/disputes/%s", groupID, disputeID)
+}
+
+func disputeCategoryLabel(category string) string {
+	switch category {
+	case models.DisputeCategoryCredentialsNotShared:
+		return "akun belum dibagikan"
+	case models.DisputeCategoryAccountRevoked:
+		return "akses akun dicabut"
+	case models.DisputeCategoryWrongPlan:
+		return "paket tidak sesuai"
+	default:
+		return "masalah lain"
+	}
+}
+
+func disputeResolutionLabel(resolution string) string {
+	switch resolution {
+	case models.DisputeResolutionRefund:
+		return "Laporan anggota disetujui."
+	case models.DisputeResolutionRemoveMember:
+		return "Anggota dikeluarkan dari grup."
+	case models.DisputeResolutionSuspendOwner:
+		return "Akun pemilik grup ditangguhkan."
+	default:
+		return "Laporan ditolak dan pendapatan diteruskan kepada pemilik grup."
+	}
+}
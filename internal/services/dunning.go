@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/orderref"
+	"salome-be/internal/service"
+)
+
+// Payment reminder statuses
+const (
+	ReminderSending = "sending"
+	ReminderSent    = "sent"
+	ReminderSkipped = "skipped"
+	ReminderFailed  = "failed"
+)
+
+// paymentLinkReuseMargin is how long a payment link must still be valid to be put in a reminder
+const paymentLinkReuseMargin = 15 * time.Minute
+
+// DunningStats summarizes one pass over members waiting for payment
+type DunningStats struct {
+	Checked      int `json:"checked"`
+	Sent         int `json:"sent"`
+	Skipped      int `json:"skipped"`
+	LinksCreated int `json:"links_created"`
+	Failed       int `json:"failed"`
+}
+
+// DunningService reminds pending members to pay before their payment deadline, following the configured
+// reminder schedule. Every reminder is recorded in payment_reminders before it is delivered, so it is sent
+// at most once per deadline.
+type DunningService struct {
+	db       *sql.DB
+	config   config.DunningConfig
+	gateway  service.PaymentGateway
+	payments *GroupPaymentService
+	email    *service.MultiProviderEmailService
+}
+
+func NewDunningService(db *sql.DB) *DunningService {
+	cfg := config.GetConfig()
+	return &DunningService{
+		db:       db,
+		config:   cfg.Dunning,
+		gateway:  service.NewPaymentGateway(),
+		payments: NewGroupPaymentService(db),
+		email:    service.NewConfiguredEmailService(cfg.Email),
+	}
+}
+
+// dunningReminder is one step of the reminder schedule
+type dunningReminder struct {
+	label  string
+	before time.Duration
+}
+
+// dunningMember is a pending member whose deadline is within the reminder schedule
+type dunningMember struct {
+	groupID, userID     string
+	groupName           string
+	userName, userEmail string
+	deadline            time.Time
+}
+
+// reminders returns the configured schedule, earliest reminder first
+func (s *DunningService) reminders() []dunningReminder {
+	var reminders []dunningReminder
+	for _, label := range s.config.Reminders {
+		d, err := time.ParseDuration(label)
+		if err != nil || d <= 0 {
+			fmt.Printf("[SALOME BE] Warning: invalid dunning reminder %q, ignoring it\n", label)
+			continue
+		}
+		reminders = append(reminders, dunningReminder{label: label, before: d})
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].before > reminders[j].before })
+	return reminders
+}
+
+// Run sends the reminders that are due at now
+func (s *DunningService) Run(now time.Time) (*DunningStats, error) {
+	stats := &DunningStats{}
+	reminders := s.reminders()
+	if !s.config.Enabled || len(reminders) == 0 {
+		return stats, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT gm.group_id, gm.user_id, gm.payment_deadline, g.name, COALESCE(u.full_name, ''), u.email
+		FROM group_members gm
+		JOIN groups g ON g.id = gm.group_id
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.user_status = $1 AND gm.payment_deadline > $2 AND gm.payment_deadline <= $3
+		ORDER BY gm.payment_deadline ASC
+	`, models.UserStatusPending, now, now.Add(reminders[0].before))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending members: %v", err)
+	}
+	var members []dunningMember
+	for rows.Next() {
+		var m dunningMember
+		if err := rows.Scan(&m.groupID, &m.userID, &m.deadline, &m.groupName, &m.userName, &m.userEmail); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan pending member: %v", err)
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+
+	for _, m := range members {
+		stats.Checked++
+		if err := s.remind(m, reminders, now, stats); err != nil {
+			fmt.Printf("❌ [ERROR] Failed to remind member %s of group %s: %v\n", m.userID, m.groupID, err)
+			stats.Failed++
+		}
+	}
+	return stats, nil
+}
+
+// remind sends the most urgent due reminder of a member, unless it was already sent. Earlier reminders that
+// were never sent, e.g. because the member joined late, are recorded as skipped.
+func (s *DunningService) remind(m dunningMember, reminders []dunningReminder, now time.Time, stats *DunningStats) error {
+	var due []dunningReminder
+	for _, r := range reminders {
+		if !m.deadline.Add(-r.before).After(now) {
+			due = append(due, r)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	urgent := due[len(due)-1]
+
+	reminderID, err := s.claim(m, urgent, due[:len(due)-1], stats)
+	if err != nil || reminderID == "" {
+		return err
+	}
+
+	link, err := s.paymentLink(m, now)
+	if err != nil {
+		s.finish(reminderID, ReminderFailed, nil, false, err)
+		return err
+	}
+	if link.created {
+		stats.LinksCreated++
+	}
+
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	deadline := m.deadline.In(loc).Format("02 Jan 2006 15:04") + " WIB"
+	actionURL := "/groups/" + m.groupID
+	if link.url != "" {
+		actionURL = link.url
+	}
+	err = notify(s.db, m.userID, "payment", "Segera selesaikan pembayaran",
+		fmt.Sprintf("Pembayaran seat grup %s sebesar %s belum diterima. Selesaikan sebelum %s agar Anda tidak dikeluarkan dari grup.",
+			m.groupName, link.amount.Format(), deadline),
+		actionURL, "Bayar Sekarang")
+	if err != nil {
+		s.finish(reminderID, ReminderFailed, &link, false, err)
+		return err
+	}
+
+	emailed := false
+	var emailErr error
+	if s.config.Email {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		emailErr = s.email.SendPaymentReminderEmail(ctx, service.PaymentReminderEmailData{
+			Email:      m.userEmail,
+			Name:       m.userName,
+			GroupName:  m.groupName,
+			Amount:     link.amount.Format(),
+			Deadline:   deadline,
+			PaymentURL: link.url,
+		})
+		cancel()
+		if emailErr != nil {
+			fmt.Printf("❌ [ERROR] Failed to email payment reminder to %s: %v\n", m.userEmail, emailErr)
+		}
+		emailed = emailErr == nil
+	}
+
+	// The in-app notification went out, so the reminder counts as sent even when the email failed
+	s.finish(reminderID, ReminderSent, &link, emailed, emailErr)
+	stats.Sent++
+	fmt.Printf("[SALOME BE] Sent %s payment reminder to member %s of group %s\n", urgent.label, m.userID, m.groupID)
+	return nil
+}
+
+// claim records the reminder about to be sent together with the skipped ones. It returns an empty ID when
+// the reminder was already recorded by an earlier run.
+func (s *DunningService) claim(m dunningMember, urgent dunningReminder, skipped []dunningReminder, stats *DunningStats) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range skipped {
+		result, err := tx.Exec(`
+			INSERT INTO payment_reminders (group_id, user_id, payment_deadline, reminder, status, created_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+			ON CONFLICT (group_id, user_id, payment_deadline, reminder) DO NOTHING
+		`, m.groupID, m.userID, m.deadline, r.label, ReminderSkipped)
+		if err != nil {
+			return "", fmt.Errorf("failed to record skipped reminder: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			stats.Skipped++
+		}
+	}
+
+	var reminderID string
+	err = tx.QueryRow(`
+		INSERT INTO payment_reminders (group_id, user_id, payment_deadline, reminder, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (group_id, user_id, payment_deadline, reminder) DO NOTHING
+		RETURNING id
+	`, m.groupID, m.userID, m.deadline, urgent.label, ReminderSending).Scan(&reminderID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to record reminder: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return reminderID, nil
+}
+
+// finish stores the outcome of a reminder
+func (s *DunningService) finish(reminderID, status string, link *reminderLink, emailed bool, cause error) {
+	var transactionID, paymentURL, errorText *string
+	linkCreated := false
+	if link != nil {
+		transactionID = link.transactionID
+		paymentURL = nullIfEmpty(link.url)
+		linkCreated = link.created
+	}
+	if cause != nil {
+		errorText = nullIfEmpty(cause.Error())
+	}
+	_, err := s.db.Exec(`
+		UPDATE payment_reminders
+		SET status = $1, transaction_id = $2, payment_url = $3, link_created = $4, emailed = $5, error = $6,
+		    sent_at = CASE WHEN $1 = 'sent' THEN NOW() END
+		WHERE id = $7
+	`, status, transactionID, paymentURL, linkCreated, emailed, errorText, reminderID)
+	if err != nil {
+		fmt.Printf("❌ [ERROR] Failed to record payment reminder %s: %v\n", reminderID, err)
+	}
+}
+
+// reminderLink is the payment link put in a reminder
+type reminderLink struct {
+	url           string
+	transactionID *string
+	amount        money.Amount
+	created       bool
+}
+
+// paymentLink returns the member's open payment link, or a fresh one when the last link expired. Members
+// who never opened a payment link, or whose mixed payment still holds wallet balance, get no link and are
+// sent to the group page instead.
+func (s *DunningService) paymentLink(m dunningMember, now time.Time) (reminderLink, error) {
+	var link reminderLink
+	var transactionID, paymentLinkID, status string
+	var createdAt time.Time
+	err := s.db.QueryRow(`
+		SELECT id, payment_link_id, amount, status, created_at
+		FROM transactions
+		WHERE user_id = $1 AND group_id = $2 AND type = 'group_payment'
+		  AND related_transaction_id IS NULL AND payment_link_id IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, m.userID, m.groupID).Scan(&transactionID, &paymentLinkID, &link.amount, &status, &createdAt)
+	if err != nil && err != sql.ErrNoRows {
+		return link, fmt.Errorf("failed to get payment link: %v", err)
+	}
+
+	expiresAt := createdAt.Add(time.Duration(models.PaymentLinkExpiryHours) * time.Hour)
+	if err == nil && status == "pending" && expiresAt.After(now.Add(paymentLinkReuseMargin)) {
+		link.url = s.gateway.PaymentLinkURL(paymentLinkID)
+		link.transactionID = &transactionID
+		return link, nil
+	}
+
+	price, priceErr := s.payments.QuoteSeatPrice(m.userID, m.groupID, "", "")
+	if priceErr != nil {
+		return link, priceErr
+	}
+	link.amount = price.Total
+	if err == sql.ErrNoRows {
+		return link, nil
+	}
+
+	// The old link expired; a new one is only created when no other payment is holding the seat
+	if inProgress, err := s.paymentInProgress(m); err != nil || inProgress {
+		return link, err
+	}
+
+	hours := int(math.Ceil(m.deadline.Sub(now).Hours()))
+	if hours < 1 {
+		hours = 1
+	}
+	if hours > models.PaymentLinkExpiryHours {
+		hours = models.PaymentLinkExpiryHours
+	}
+
+	orderID := orderref.New(orderref.CodeGroupPayment)
+	description := fmt.Sprintf("Pembayaran grup %s", m.groupName)
+	created, err := s.gateway.CreatePaymentLink(service.PaymentLinkRequest{
+		OrderID:       orderID,
+		Amount:        money.Rupiah(price.Total),
+		Description:   description,
+		CustomerName:  m.userName,
+		CustomerEmail: m.userEmail,
+		ExpiryHours:   hours,
+	})
+	if err != nil {
+		return link, fmt.Errorf("failed to create payment link: %v", err)
+	}
+
+	newTransactionID, err := s.payments.StartLinkPayment(m.userID, m.groupID, price, PaymentLinkDetails{
+		OrderID:       orderID,
+		PaymentLinkID: created.PaymentLinkID,
+		Description:   description,
+	})
+	if err != nil {
+		return link, err
+	}
+
+	link.url = created.PaymentURL
+	link.transactionID = &newTransactionID
+	link.created = true
+	return link, nil
+}
+
+// paymentInProgress reports whether the member is no longer pending or has another payment for the seat open
+// or settled, in which case a new full-price link must not be created
+func (s *DunningService) paymentInProgress(m dunningMember) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	switch err := lockPendingMember(tx, m.userID, m.groupID); err {
+	case nil:
+		return false, nil
+	case ErrPaymentInProgress, ErrNotAwaitingPayment, ErrNotGroupMember:
+		return true, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,470 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+)
+
+var (
+	ErrLifecycleOwnerOnly = errors.New("only the group owner can pause, resume or close the group")
+	ErrGroupNotActive     = errors.New("only a paid group can be paused or scheduled for closure")
+	ErrGroupNotPaused     = errors.New("group is not paused")
+	ErrGroupNotClosing    = errors.New("group has no scheduled closure")
+)
+
+// GroupLifecycleService pauses and resumes paid groups and closes them at the end of their period. While a
+// group is paused its members keep their seat, no renewal invoices are issued and nobody expires; resuming
+// starts a new period that keeps the paid time that was left when the group was paused. A group scheduled
+// for closure is not renewed and is closed by the renewal job once the last paid period ends.
+type GroupLifecycleService struct {
+	db           *sql.DB
+	stateMachine *StateMachineService
+	refunds      *RefundService
+}
+
+func NewGroupLifecycleService(db *sql.DB) *GroupLifecycleService {
+	return &GroupLifecycleService{
+		db:           db,
+		stateMachine: NewStateMachineService(db),
+		refunds:      NewRefundService(db),
+	}
+}
+
+// lifecycleGroup is a group row locked for a lifecycle change
+type lifecycleGroup struct {
+	id, name, ownerID, status string
+	pausedAt                  *time.Time
+}
+
+// Pause stops billing of a paid group. Open renewal invoices are cancelled and members are notified.
+func (s *GroupLifecycleService) Pause(ownerID, groupID, reason string) (*models.GroupLifecycle, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := s.lockOwnedGroup(tx, ownerID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.status != models.GroupStatusPaidGroup {
+		return nil, ErrGroupNotActive
+	}
+
+	actor := UserActor(ownerID).WithCorrelation(NewCorrelationID())
+	if err := s.stateMachine.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusPaused, reason); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE groups SET paused_at = NOW(), pause_reason = $1 WHERE id = $2
+	`, nullIfEmpty(reason), groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause group: %v", err)
+	}
+	if err := cancelGroupInvoices(tx, groupID); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Grup %s dijeda oleh pemilik. Kursi Anda tetap aman dan tidak ada tagihan selama grup dijeda.", group.name)
+	if reason != "" {
+		message += " Alasan: " + reason
+	}
+	if err := s.notifyMembers(tx, group, "Grup dijeda", message); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Group %s paused by owner %s\n", groupID, ownerID)
+	return s.Get(groupID)
+}
+
+// Resume restarts billing of a paused group. Every active member starts a new period now that keeps the
+// paid time they had left when the group was paused; members without paid time left are invoiced by the
+// next renewal run.
+func (s *GroupLifecycleService) Resume(ownerID, groupID string) (*models.GroupLifecycle, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := s.lockOwnedGroup(tx, ownerID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.status != models.GroupStatusPaused {
+		return nil, ErrGroupNotPaused
+	}
+
+	now := time.Now()
+	pausedAt := now
+	if group.pausedAt != nil {
+		pausedAt = *group.pausedAt
+	}
+	if err := s.restartPeriods(tx, groupID, pausedAt, now); err != nil {
+		return nil, err
+	}
+
+	actor := UserActor(ownerID).WithCorrelation(NewCorrelationID())
+	if err := s.stateMachine.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusPaidGroup, "Group resumed"); err != nil {
+		return nil, err
+	}
+	// Members who paid while the group was paused start their period now
+	if err := s.stateMachine.checkAndActivateGroup(tx, actor, groupID); err != nil {
+		return nil, fmt.Errorf("failed to activate paid members: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE groups SET paused_at = NULL, pause_reason = NULL WHERE id = $1`, groupID); err != nil {
+		return nil, fmt.Errorf("failed to resume group: %v", err)
+	}
+
+	if err := s.notifyMembers(tx, group, "Grup dilanjutkan",
+		fmt.Sprintf("Grup %s aktif kembali. Periode langganan Anda dimulai lagi hari ini dengan sisa waktu yang sudah dibayar.", group.name)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Group %s resumed by owner %s\n", groupID, ownerID)
+	return s.Get(groupID)
+}
+
+// restartPeriods starts a new period at now for the active members of a group paused at pausedAt
+func (s *GroupLifecycleService) restartPeriods(tx *sql.Tx, groupID string, pausedAt, now time.Time) error {
+	rows, err := tx.Query(`
+		SELECT user_id, subscription_period_end FROM group_members
+		WHERE group_id = $1 AND user_status = $2 AND subscription_period_end IS NOT NULL
+		FOR UPDATE
+	`, groupID, models.UserStatusActive)
+	if err != nil {
+		return fmt.Errorf("failed to get active members: %v", err)
+	}
+	type period struct {
+		userID string
+		end    time.Time
+	}
+	var periods []period
+	for rows.Next() {
+		var p period
+		if err := rows.Scan(&p.userID, &p.end); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan active member: %v", err)
+		}
+		periods = append(periods, p)
+	}
+	rows.Close()
+
+	for _, p := range periods {
+		left := p.end.Sub(pausedAt)
+		if left < 0 {
+			left = 0
+		}
+		_, err := tx.Exec(`
+			UPDATE group_members SET subscription_period_start = $1, subscription_period_end = $2
+			WHERE group_id = $3 AND user_id = $4
+		`, now, now.Add(left), groupID, p.userID)
+		if err != nil {
+			return fmt.Errorf("failed to restart subscription period: %v", err)
+		}
+	}
+	return nil
+}
+
+// ScheduleClosure stops renewals of a paid group and closes it when the last paid period of its members
+// ends. Open renewal invoices are cancelled and members are notified of the closing date.
+func (s *GroupLifecycleService) ScheduleClosure(ownerID, groupID, reason string) (*models.GroupLifecycle, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := s.lockOwnedGroup(tx, ownerID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.status != models.GroupStatusPaidGroup {
+		return nil, ErrGroupNotActive
+	}
+
+	var closesAt time.Time
+	err = tx.QueryRow(`
+		SELECT COALESCE(MAX(subscription_period_end), NOW()) FROM group_members
+		WHERE group_id = $1 AND user_status = $2
+	`, groupID, models.UserStatusActive).Scan(&closesAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get period end: %v", err)
+	}
+
+	actor := UserActor(ownerID).WithCorrelation(NewCorrelationID())
+	if err := s.stateMachine.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusClosing, reason); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE groups SET closes_at = $1, closure_reason = $2 WHERE id = $3
+	`, closesAt, nullIfEmpty(reason), groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule group closure: %v", err)
+	}
+	if err := cancelGroupInvoices(tx, groupID); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Grup %s akan ditutup pada %s, di akhir periode langganan. Langganan tidak akan diperpanjang.",
+		group.name, closesAt.Format("02 Jan 2006"))
+	if reason != "" {
+		message += " Alasan: " + reason
+	}
+	if err := s.notifyMembers(tx, group, "Grup akan ditutup", message); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Group %s scheduled to close at %s by owner %s\n", groupID, closesAt.Format(time.RFC3339), ownerID)
+	return s.Get(groupID)
+}
+
+// CancelClosure keeps a group scheduled for closure running. Renewal invoices cancelled by the closure are
+// reopened for members who are still active.
+func (s *GroupLifecycleService) CancelClosure(ownerID, groupID string) (*models.GroupLifecycle, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := s.lockOwnedGroup(tx, ownerID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.status != models.GroupStatusClosing {
+		return nil, ErrGroupNotClosing
+	}
+
+	actor := UserActor(ownerID).WithCorrelation(NewCorrelationID())
+	if err := s.stateMachine.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusPaidGroup, "Closure cancelled"); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE groups SET closes_at = NULL, closure_reason = NULL WHERE id = $1`, groupID); err != nil {
+		return nil, fmt.Errorf("failed to cancel group closure: %v", err)
+	}
+
+	// Invoices are unique per period, so the cancelled invoice of the coming period is reopened
+	_, err = tx.Exec(`
+		UPDATE renewal_invoices ri SET status = $1, updated_at = NOW()
+		FROM group_members gm
+		WHERE ri.group_id = $2 AND ri.status = $3
+		  AND gm.group_id = ri.group_id AND gm.user_id = ri.user_id AND gm.user_status = $4
+		  AND ri.period_start = gm.subscription_period_end
+	`, models.RenewalInvoiceOpen, groupID, models.RenewalInvoiceCancelled, models.UserStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen renewal invoices: %v", err)
+	}
+
+	if err := s.notifyMembers(tx, group, "Penutupan grup dibatalkan",
+		fmt.Sprintf("Grup %s tidak jadi ditutup. Langganan Anda akan diperpanjang seperti biasa.", group.name)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Closure of group %s cancelled by owner %s\n", groupID, ownerID)
+	return s.Get(groupID)
+}
+
+// CloseDue closes the groups whose scheduled closure is due and returns how many were closed
+func (s *GroupLifecycleService) CloseDue(now time.Time) (int, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM groups WHERE group_status = $1 AND closes_at <= $2
+	`, models.GroupStatusClosing, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find groups to close: %v", err)
+	}
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan group to close: %v", err)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+
+	closed := 0
+	for _, groupID := range groupIDs {
+		ok, err := s.close(groupID, now)
+		if err != nil {
+			fmt.Printf("[SALOME BE] ERROR: Failed to close group %s: %v\n", groupID, err)
+			continue
+		}
+		if ok {
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+// close closes one group unless its closure was cancelled since it was selected. Active members expire and
+// pending seats are released with their held balance returned.
+func (s *GroupLifecycleService) close(groupID string, now time.Time) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := lockLifecycleGroup(tx, groupID)
+	if err != nil {
+		return false, err
+	}
+	var closesAt *time.Time
+	if err := tx.QueryRow(`SELECT closes_at FROM groups WHERE id = $1`, groupID).Scan(&closesAt); err != nil {
+		return false, fmt.Errorf("failed to get closing date: %v", err)
+	}
+	if group.status != models.GroupStatusClosing || closesAt == nil || closesAt.After(now) {
+		return false, nil
+	}
+
+	if err := s.notifyMembers(tx, group, "Grup ditutup",
+		fmt.Sprintf("Grup %s telah ditutup sesuai jadwal. Terima kasih telah berlangganan bersama SALOME.", group.name)); err != nil {
+		return false, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT user_id, user_status FROM group_members WHERE group_id = $1 AND user_status IN ($2, $3, $4)
+	`, groupID, models.UserStatusPending, models.UserStatusPaid, models.UserStatusActive)
+	if err != nil {
+		return false, fmt.Errorf("failed to get group members: %v", err)
+	}
+	type member struct{ userID, status string }
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.userID, &m.status); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan group member: %v", err)
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+
+	actor := SystemActor(NewCorrelationID())
+	for _, m := range members {
+		if m.status == models.UserStatusActive {
+			err = s.stateMachine.UpdateUserStatusTx(tx, actor, m.userID, groupID, models.UserStatusExpired, "Group closed")
+		} else {
+			_, err = s.refunds.refundMemberTx(tx, m.userID, groupID, models.RefundReasonGroupClosed, "", false, now)
+			if err == nil {
+				err = s.stateMachine.UpdateUserStatusTx(tx, actor, m.userID, groupID, models.UserStatusRemoved, "Group closed")
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := s.stateMachine.UpdateGroupStatusTx(tx, actor, groupID, models.GroupStatusClosed, "Scheduled closure"); err != nil {
+		return false, err
+	}
+	if err := notify(tx, group.ownerID, "group", "Grup ditutup",
+		fmt.Sprintf("Grup %s telah ditutup sesuai jadwal.", group.name), "/groups/"+groupID, "Lihat Grup"); err != nil {
+		return false, err
+	}
+
+	fmt.Printf("[SALOME BE] Group %s closed as scheduled\n", groupID)
+	return true, tx.Commit()
+}
+
+// Get returns the pause and closure state of a group
+func (s *GroupLifecycleService) Get(groupID string) (*models.GroupLifecycle, error) {
+	lifecycle := &models.GroupLifecycle{GroupID: groupID}
+	err := s.db.QueryRow(`
+		SELECT group_status, paused_at, pause_reason, closes_at, closure_reason FROM groups WHERE id = $1
+	`, groupID).Scan(&lifecycle.GroupStatus, &lifecycle.PausedAt, &lifecycle.PauseReason, &lifecycle.ClosesAt,
+		&lifecycle.ClosureReason)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+	return lifecycle, nil
+}
+
+// lockOwnedGroup locks a group and returns ErrLifecycleOwnerOnly unless ownerID owns it
+func (s *GroupLifecycleService) lockOwnedGroup(tx *sql.Tx, ownerID, groupID string) (*lifecycleGroup, error) {
+	group, err := lockLifecycleGroup(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.ownerID != ownerID {
+		return nil, ErrLifecycleOwnerOnly
+	}
+	return group, nil
+}
+
+func lockLifecycleGroup(tx *sql.Tx, groupID string) (*lifecycleGroup, error) {
+	group := &lifecycleGroup{id: groupID}
+	err := tx.QueryRow(`
+		SELECT name, owner_id, group_status, paused_at FROM groups
+		WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+		FOR UPDATE
+	`, groupID).Scan(&group.name, &group.ownerID, &group.status, &group.pausedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock group: %v", err)
+	}
+	return group, nil
+}
+
+// notifyMembers notifies every member of a group except its owner
+func (s *GroupLifecycleService) notifyMembers(q database.Queryer, group *lifecycleGroup, title, message string) error {
+	rows, err := q.Query(`
+		SELECT user_id FROM group_members WHERE group_id = $1 AND user_id <> $2 AND user_status <> $3
+	`, group.id, group.ownerID, models.UserStatusRemoved)
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %v", err)
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan group member: %v", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+
+	for _, userID := range userIDs {
+		if err := notify(q, userID, "group", title, message, "/groups/"+group.id, "Lihat Grup"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelGroupInvoices cancels the open renewal invoices of a group that stops renewing
+func cancelGroupInvoices(q database.Queryer, groupID string) error {
+	_, err := q.Exec(`
+		UPDATE renewal_invoices SET status = $1, updated_at = NOW() WHERE group_id = $2 AND status = $3
+	`, models.RenewalInvoiceCancelled, groupID, models.RenewalInvoiceOpen)
+	if err != nil {
+		return fmt.Errorf("failed to cancel renewal invoices: %v", err)
+	}
+	return nil
+}
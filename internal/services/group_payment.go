@@ -0,0 +1,457 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+)
+
+var (
+	ErrNotGroupMember       = errors.New("user is not a member of this group")
+	ErrNotAwaitingPayment   = errors.New("member is not awaiting payment")
+	ErrNoBalanceForPayment  = errors.New("no wallet balance available")
+	ErrInvalidBalanceAmount = errors.New("balance amount must be between 0 and the seat price")
+	ErrPaymentInProgress    = errors.New("a payment for this seat is already in progress")
+)
+
+// SeatPrice is what a member pays for one seat in a group
+type SeatPrice struct {
+	GroupName      string       `json:"group_name"`
+	PricePerMember money.Amount `json:"price_per_member"`
+	AdminFee       money.Amount `json:"admin_fee"`
+	Discount       money.Amount `json:"discount,omitempty"`
+	PromoCode      string       `json:"promo_code,omitempty"`
+	PaymentMethod  string       `json:"payment_method,omitempty"`
+	Surcharge      money.Amount `json:"surcharge,omitempty"`
+	Total          money.Amount `json:"total"`
+
+	Breakdown pricing.Breakdown `json:"breakdown"`
+
+	promo *PromoQuote
+}
+
+// WalletPaymentResult is the wallet leg of a group payment
+type WalletPaymentResult struct {
+	TransactionID string       `json:"transaction_id"`
+	Amount        money.Amount `json:"amount"`
+	BalanceBefore money.Amount `json:"balance_before"`
+	BalanceAfter  money.Amount `json:"balance_after"`
+	Status        string       `json:"status"`
+}
+
+// PaymentLinkDetails identifies the payment link covering the rest of a mixed payment
+type PaymentLinkDetails struct {
+	OrderID       string
+	PaymentLinkID string
+	PaymentMethod string // Optional, the surcharge of the default method applies when empty
+	Description   string
+}
+
+// GroupPaymentService pays for group seats from the wallet, fully or mixed with a payment link
+type GroupPaymentService struct {
+	db           *sql.DB
+	ledger       *ledger.Ledger
+	stateMachine *StateMachineService
+	promos       *PromoService
+	pricing      *pricing.Engine
+	invoices     *InvoiceService
+}
+
+func NewGroupPaymentService(db *sql.DB) *GroupPaymentService {
+	return &GroupPaymentService{
+		db:           db,
+		ledger:       ledger.New(db),
+		stateMachine: NewStateMachineService(db),
+		promos:       NewPromoService(db),
+		pricing:      pricing.Default(),
+		invoices:     NewInvoiceService(db),
+	}
+}
+
+// GetSeatPrice returns the seat price of a group
+func (s *GroupPaymentService) GetSeatPrice(groupID string) (*SeatPrice, error) {
+	var price SeatPrice
+	var breakdown *pricing.Breakdown
+	err := s.db.QueryRow(`
+		SELECT name, COALESCE(price_per_member, 0), COALESCE(admin_fee, 0), price_breakdown
+		FROM groups WHERE id = $1
+	`, groupID).Scan(&price.GroupName, &price.PricePerMember, &price.AdminFee, &breakdown)
+	if err != nil {
+		return nil, err
+	}
+
+	price.Total = price.PricePerMember + price.AdminFee
+	if breakdown != nil {
+		price.Breakdown = *breakdown
+	} else {
+		// Groups created before the pricing engine only have the stored price and fee
+		price.Breakdown = pricing.Breakdown{PricePerMember: price.PricePerMember, FixedFee: price.AdminFee, AdminFee: price.AdminFee}
+	}
+	price.Breakdown.Total = price.Total
+	return &price, nil
+}
+
+// QuoteSeatPrice returns the seat price of a group for userID with promoCode applied, plus the surcharge of
+// paying it with paymentMethod. Without a promo code it is the plain seat price; wallet payments have no
+// surcharge. Prices paid by link are whole rupiah.
+func (s *GroupPaymentService) QuoteSeatPrice(userID, groupID, promoCode, paymentMethod string) (*SeatPrice, error) {
+	price, err := s.GetSeatPrice(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if promoCode != "" {
+		quote, err := s.promos.Quote(promoCode, userID, groupID, price)
+		if err != nil {
+			return nil, err
+		}
+		price.AdminFee = quote.AdminFee
+		price.Discount = quote.Discount
+		price.PromoCode = quote.Code
+		price.Total = quote.Total
+		price.promo = quote
+	}
+
+	// The surcharge is collected as part of the admin fee
+	price.PaymentMethod = paymentMethod
+	price.Surcharge = s.pricing.Surcharge(paymentMethod, price.Total)
+	price.AdminFee += price.Surcharge
+	price.Total += price.Surcharge
+
+	// Payment gateways charge whole rupiah, so a fractional price paid by link is rounded up into the admin fee
+	if paymentMethod != pricing.MethodWallet {
+		rounding := price.Total.Ceil() - price.Total
+		price.AdminFee += rounding
+		price.Total += rounding
+		price.Breakdown.FeeAdjustment += rounding
+	}
+
+	price.Breakdown.Discount = price.Discount
+	price.Breakdown.PromoCode = price.PromoCode
+	price.Breakdown.PaymentMethod = price.PaymentMethod
+	price.Breakdown.Surcharge = price.Surcharge
+	price.Breakdown.AdminFee = price.AdminFee
+	price.Breakdown.Total = price.Total
+	return price, nil
+}
+
+// LinkSurcharge returns the surcharge of paying amount with a payment link of paymentMethod
+func (s *GroupPaymentService) LinkSurcharge(paymentMethod string, amount money.Amount) money.Amount {
+	if paymentMethod == pricing.MethodWallet {
+		paymentMethod = pricing.DefaultMethod
+	}
+	return s.pricing.Surcharge(paymentMethod, amount)
+}
+
+// PayWithBalance pays the full seat price, less the optional promo code discount, from the wallet and marks
+// the member paid, all in one DB transaction
+func (s *GroupPaymentService) PayWithBalance(userID, groupID, promoCode string) (*WalletPaymentResult, error) {
+	price, err := s.QuoteSeatPrice(userID, groupID, promoCode, pricing.MethodWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPendingMember(tx, userID, groupID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.debitWallet(tx, userID, groupID, price.Total, price.AdminFee, "completed", nil, &price.Breakdown,
+		fmt.Sprintf("Pembayaran grup %s dengan saldo", price.GroupName))
+	if err != nil {
+		return nil, err
+	}
+
+	if price.promo != nil {
+		if err := s.promos.Reserve(tx, price.promo, userID, groupID, result.TransactionID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	actor := UserActor(userID).WithCorrelation(result.TransactionID)
+	if err := s.stateMachine.UpdateUserStatusTx(tx, actor, userID, groupID, models.UserStatusPaid, "Paid with balance"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// StartMixedPayment debits balanceAmount from the wallet and records a pending payment link transaction for
+// the rest of the seat price plus the surcharge of the link's payment method. The wallet leg stays pending until
+// the link settles and is refunded if it fails. A promo code is redeemed on the link transaction.
+func (s *GroupPaymentService) StartMixedPayment(userID, groupID, promoCode string, balanceAmount money.Amount, link PaymentLinkDetails) (*WalletPaymentResult, string, error) {
+	price, err := s.QuoteSeatPrice(userID, groupID, promoCode, pricing.MethodWallet)
+	if err != nil {
+		return nil, "", err
+	}
+	if balanceAmount <= 0 || balanceAmount >= price.Total {
+		return nil, "", ErrInvalidBalanceAmount
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPendingMember(tx, userID, groupID); err != nil {
+		return nil, "", err
+	}
+
+	// The wallet leg carries the admin fee first, the link carries whatever is left of it
+	walletFee := money.Min(price.AdminFee, balanceAmount)
+	linkAmount := price.Total - balanceAmount
+	surcharge := s.LinkSurcharge(link.PaymentMethod, linkAmount)
+
+	breakdown := price.Breakdown
+	breakdown.PaymentMethod = link.PaymentMethod
+	breakdown.Surcharge = surcharge
+	breakdown.AdminFee += surcharge
+	breakdown.Total += surcharge
+
+	var linkTransactionID string
+	err = tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_reference, payment_link_id, price_breakdown, status, created_at, updated_at)
+		VALUES ($1, $2, 'group_payment', $3, $4, 0, 0, $5, $6, $7, $8, 'pending', NOW(), NOW())
+		RETURNING id
+	`, userID, groupID, linkAmount+surcharge, price.AdminFee-walletFee+surcharge, link.Description, link.OrderID,
+		link.PaymentLinkID, breakdown).Scan(&linkTransactionID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	if price.promo != nil {
+		if err := s.promos.Reserve(tx, price.promo, userID, groupID, linkTransactionID, false); err != nil {
+			return nil, "", err
+		}
+	}
+
+	result, err := s.debitWallet(tx, userID, groupID, balanceAmount, walletFee, "pending", &linkTransactionID, nil,
+		fmt.Sprintf("Pembayaran grup %s dengan saldo (sebagian), order_id: %s", price.GroupName, link.OrderID))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, linkTransactionID, nil
+}
+
+// StartLinkPayment records a pending payment link transaction for a seat priced with QuoteSeatPrice and
+// reserves its promo code, if any, in one DB transaction. The member must still be awaiting payment with no
+// other payment for the seat open or settled.
+func (s *GroupPaymentService) StartLinkPayment(userID, groupID string, price *SeatPrice, link PaymentLinkDetails) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPendingMember(tx, userID, groupID); err != nil {
+		return "", err
+	}
+
+	var transactionID string
+	err = tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_reference, payment_link_id, price_breakdown, status, created_at, updated_at)
+		VALUES ($1, $2, 'group_payment', $3, $4, 0, 0, $5, $6, $7, $8, 'pending', NOW(), NOW())
+		RETURNING id
+	`, userID, groupID, price.Total, price.AdminFee, link.Description, link.OrderID, link.PaymentLinkID,
+		price.Breakdown).Scan(&transactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	if price.promo != nil {
+		if err := s.promos.Reserve(tx, price.promo, userID, groupID, transactionID, false); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return transactionID, nil
+}
+
+// debitWallet records a wallet group_payment transaction and posts it to the ledger. The wallet leg of a
+// mixed payment has no breakdown of its own; the link transaction carries it.
+func (s *GroupPaymentService) debitWallet(tx *sql.Tx, userID, groupID string, amount, adminFee money.Amount, status string, relatedTransactionID *string, breakdown *pricing.Breakdown, description string) (*WalletPaymentResult, error) {
+	var transactionID string
+	err := tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_method, related_transaction_id, price_breakdown, status, created_at, updated_at)
+		VALUES ($1, $2, 'group_payment', $3, $4, 0, 0, $5, 'wallet', $6, $7, $8, NOW(), NOW())
+		RETURNING id
+	`, userID, groupID, amount, adminFee, description, relatedTransactionID, breakdown, status).Scan(&transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	movement, err := s.ledger.PayFromWallet(tx, ledger.Operation{
+		UserID:        userID,
+		GroupID:       groupID,
+		TransactionID: transactionID,
+		Amount:        amount,
+		AdminFee:      adminFee,
+		Description:   description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WalletPaymentResult{
+		TransactionID: transactionID,
+		Amount:        amount,
+		BalanceBefore: movement.BalanceBefore,
+		BalanceAfter:  movement.BalanceAfter,
+		Status:        status,
+	}
+
+	_, err = tx.Exec(`
+		UPDATE transactions SET balance_before = $1, balance_after = $2, updated_at = $3 WHERE id = $4
+	`, result.BalanceBefore, result.BalanceAfter, time.Now(), transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update transaction balance: %v", err)
+	}
+
+	// A payment made entirely from the wallet is settled right away
+	if relatedTransactionID == nil && isSettledStatus(status) {
+		if _, err := s.invoices.Issue(tx, transactionID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// lockPendingMember locks the membership row and checks that it is waiting for payment and that no other
+// payment for the seat is open or already settled
+func lockPendingMember(tx *sql.Tx, userID, groupID string) error {
+	var userStatus string
+	var joinedAt time.Time
+	err := tx.QueryRow(`
+		SELECT user_status, joined_at FROM group_members
+		WHERE user_id = $1 AND group_id = $2
+		FOR UPDATE
+	`, userID, groupID).Scan(&userStatus, &joinedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotGroupMember
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get member status: %v", err)
+	}
+	if userStatus != models.UserStatusPending {
+		return ErrNotAwaitingPayment
+	}
+
+	// A payment link that has not expired yet or a mixed payment holding wallet balance may still settle, and
+	// a payment settled since the member joined has already paid for the seat
+	var inProgress bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM transactions
+			WHERE user_id = $1 AND group_id = $2 AND type = 'group_payment'
+			  AND (
+			    (status = 'pending' AND (payment_method = 'wallet' OR created_at > NOW() - make_interval(hours => $3)))
+			    OR (status IN ('success', 'completed') AND created_at >= $4)
+			  )
+		)
+	`, userID, groupID, models.PaymentLinkExpiryHours, joinedAt).Scan(&inProgress)
+	if err != nil {
+		return fmt.Errorf("failed to check pending payments: %v", err)
+	}
+	if inProgress {
+		return ErrPaymentInProgress
+	}
+	return nil
+}
+
+// CheckLinkPayment reports whether a payment link may be opened for the member's seat, so that no gateway link
+// is created for a seat that StartLinkPayment would refuse
+func (s *GroupPaymentService) CheckLinkPayment(userID, groupID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return lockPendingMember(tx, userID, groupID)
+}
+
+// settleWalletLegs completes or refunds the pending wallet legs of a mixed payment once its link is final
+func (s *PaymentSettlement) settleWalletLegs(tx *sql.Tx, linkTransactionID, status string) error {
+	rows, err := tx.Query(`
+		SELECT id, user_id, group_id, amount, admin_fee
+		FROM transactions
+		WHERE related_transaction_id = $1 AND payment_method = 'wallet' AND status = 'pending'
+		FOR UPDATE
+	`, linkTransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to load wallet legs: %v", err)
+	}
+
+	type walletLeg struct {
+		id, userID string
+		groupID    *string
+		amount     money.Amount
+		adminFee   money.Amount
+	}
+	var legs []walletLeg
+	for rows.Next() {
+		var leg walletLeg
+		if err := rows.Scan(&leg.id, &leg.userID, &leg.groupID, &leg.amount, &leg.adminFee); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan wallet leg: %v", err)
+		}
+		legs = append(legs, leg)
+	}
+	rows.Close()
+
+	for _, leg := range legs {
+		if status == "success" {
+			_, err = tx.Exec(`UPDATE transactions SET status = 'completed', updated_at = NOW() WHERE id = $1`, leg.id)
+			if err != nil {
+				return fmt.Errorf("failed to complete wallet leg: %v", err)
+			}
+			continue
+		}
+
+		// The link failed: give the balance back
+		op := ledger.Operation{
+			UserID:        leg.userID,
+			TransactionID: leg.id,
+			Amount:        leg.amount,
+			AdminFee:      leg.adminFee,
+			Description:   "Pengembalian saldo, pembayaran link gagal",
+		}
+		if leg.groupID != nil {
+			op.GroupID = *leg.groupID
+		}
+		if _, err := s.ledger.RefundToWallet(tx, op); err != nil {
+			return fmt.Errorf("failed to refund wallet leg: %v", err)
+		}
+		_, err = tx.Exec(`UPDATE transactions SET status = 'refunded', updated_at = NOW() WHERE id = $1`, leg.id)
+		if err != nil {
+			return fmt.Errorf("failed to mark wallet leg refunded: %v", err)
+		}
+		fmt.Printf("[SALOME BE] Refunded wallet leg %s (%s) for failed payment %s\n", leg.id, leg.amount, linkTransactionID)
+	}
+	return nil
+}
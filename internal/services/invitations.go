@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+	"salome-be/internal/service"
+)
+
+var (
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationOwnerOnly  = errors.New("only the group owner can invite people to the group")
+	ErrInvitationNotPending = errors.New("invitation has already been answered")
+	ErrInvitationExpired    = errors.New("invitation has expired")
+	ErrInvitationWrongUser  = errors.New("invitation was sent to a different email address")
+)
+
+// InvitationService sends owners' invitations to join a group by email address. Existing users are notified
+// in the app and other addresses get an email with a sign-up-and-join link. Accepting an invitation
+// reserves a seat like joining with the invite code does.
+type InvitationService struct {
+	db     *sql.DB
+	config config.InvitationConfig
+	seats  *SeatService
+	email  *service.MultiProviderEmailService
+}
+
+func NewInvitationService(db *sql.DB) *InvitationService {
+	cfg := config.GetConfig()
+	return &InvitationService{
+		db:     db,
+		config: cfg.Invitations,
+		seats:  NewSeatService(db),
+		email:  service.NewConfiguredEmailService(cfg.Email),
+	}
+}
+
+const invitationColumns = `i.id, i.group_id, i.invited_by, i.email, i.user_id, i.token, i.status, i.delivery,
+	i.email_sent_at, i.expires_at, i.responded_at, i.created_at, i.updated_at, g.name, a.name,
+	COALESCE(u.full_name, '')`
+
+const invitationJoins = `
+	FROM group_invitations i
+	JOIN groups g ON g.id = i.group_id
+	JOIN apps a ON a.id = g.app_id
+	LEFT JOIN users u ON u.id = i.invited_by`
+
+func scanInvitation(row rowScanner) (*models.GroupInvitation, error) {
+	var inv models.GroupInvitation
+	err := row.Scan(&inv.ID, &inv.GroupID, &inv.InvitedBy, &inv.Email, &inv.UserID, &inv.Token, &inv.Status,
+		&inv.Delivery, &inv.EmailSentAt, &inv.ExpiresAt, &inv.RespondedAt, &inv.CreatedAt, &inv.UpdatedAt,
+		&inv.GroupName, &inv.AppName, &inv.InviterName)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// invitationGroup is the group an owner invites people to
+type invitationGroup struct {
+	id, name, appName     string
+	ownerName, ownerEmail string
+}
+
+// invitationEmail is an invitation email to send once the invitations are committed
+type invitationEmail struct {
+	result int // index in the results of Send
+	data   service.GroupInvitationEmailData
+}
+
+// Send invites every email address to the group. Addresses that belong to a member, or that already have a
+// pending invitation, are skipped; the result says what happened to each address.
+func (s *InvitationService) Send(ownerID, groupID string, emails []string) ([]models.GroupInvitationResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	group, err := s.ownedGroup(tx, ownerID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.AddDate(0, 0, s.config.ExpiryDays)
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+
+	// Invitations that lapsed are not pending anymore, so their address can be invited again
+	if _, err := s.expireTx(tx, groupID, now); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var results []models.GroupInvitationResult
+	var pendingEmails []invitationEmail
+	for _, raw := range emails {
+		email := strings.ToLower(strings.TrimSpace(raw))
+		if email == "" || seen[email] {
+			continue
+		}
+		seen[email] = true
+		result := models.GroupInvitationResult{Email: email}
+
+		if email == strings.ToLower(group.ownerEmail) {
+			result.Result = models.InvitationResultSelf
+			results = append(results, result)
+			continue
+		}
+
+		var userID sql.NullString
+		var isMember, isInvited bool
+		err := tx.QueryRow(`
+			SELECT u.id,
+			       EXISTS(SELECT 1 FROM group_members gm WHERE gm.group_id = $2 AND gm.user_id = u.id AND gm.user_status NOT IN ($3, $5)),
+			       EXISTS(SELECT 1 FROM group_invitations i WHERE i.group_id = $2 AND i.email = $1 AND i.status = $4)
+			FROM (SELECT 1) one
+			LEFT JOIN users u ON LOWER(u.email) = $1
+		`, email, groupID, models.UserStatusRemoved, models.InvitationPending, models.UserStatusExpired).Scan(&userID, &isMember, &isInvited)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check invited address: %v", err)
+		}
+		if isMember {
+			result.Result = models.InvitationResultAlreadyMember
+			results = append(results, result)
+			continue
+		}
+		if isInvited {
+			result.Result = models.InvitationResultAlreadyInvited
+			results = append(results, result)
+			continue
+		}
+
+		token, err := newInvitationToken()
+		if err != nil {
+			return nil, err
+		}
+		delivery := models.InvitationDeliveryEmail
+		if userID.Valid {
+			delivery = models.InvitationDeliveryNotification
+		}
+		var invitationID string
+		err = tx.QueryRow(`
+			INSERT INTO group_invitations (group_id, invited_by, email, user_id, token, status, delivery, expires_at,
+			                               created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+			ON CONFLICT (group_id, email) WHERE status = 'pending' DO NOTHING
+			RETURNING id
+		`, groupID, ownerID, email, userID, token, models.InvitationPending, delivery, expiresAt, now).Scan(&invitationID)
+		if err == sql.ErrNoRows {
+			// Invited by a concurrent request
+			result.Result = models.InvitationResultAlreadyInvited
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create invitation: %v", err)
+		}
+
+		if userID.Valid {
+			err := notify(tx, userID.String, "group", "Undangan grup",
+				fmt.Sprintf("%s mengundang Anda bergabung ke grup %s untuk berbagi langganan %s.",
+					group.ownerName, group.name, group.appName),
+				"/invitations/"+token, "Lihat Undangan")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if result.Invitation, err = getInvitation(tx, invitationID); err != nil {
+			return nil, err
+		}
+		result.Result = models.InvitationResultSent
+		results = append(results, result)
+
+		if !userID.Valid {
+			pendingEmails = append(pendingEmails, invitationEmail{
+				result: len(results) - 1,
+				data: service.GroupInvitationEmailData{
+					Email:       email,
+					InviterName: group.ownerName,
+					GroupName:   group.name,
+					AppName:     group.appName,
+					ExpiresAt:   expiresAt.In(loc).Format("02 Jan 2006 15:04") + " WIB",
+					JoinURL:     s.joinURL(token),
+				},
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// Emails go out after the invitations are saved, so a slow provider does not hold the transaction open
+	for _, e := range pendingEmails {
+		s.sendEmail(&results[e.result], e.data)
+	}
+	// The links belong to the invited people, not to the owner
+	for _, result := range results {
+		if result.Invitation != nil {
+			result.Invitation.Token = ""
+		}
+	}
+
+	fmt.Printf("[SALOME BE] Owner %s invited %d address(es) to group %s\n", ownerID, len(results), groupID)
+	return results, nil
+}
+
+// sendEmail delivers one invitation email and records when it went out
+func (s *InvitationService) sendEmail(result *models.GroupInvitationResult, data service.GroupInvitationEmailData) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err := s.email.SendGroupInvitationEmail(ctx, data)
+	cancel()
+	if err != nil {
+		fmt.Printf("❌ [ERROR] Failed to email group invitation to %s: %v\n", data.Email, err)
+		result.Result = models.InvitationResultEmailFailed
+		return
+	}
+
+	now := time.Now()
+	_, err = s.db.Exec(`
+		UPDATE group_invitations SET email_sent_at = $1, updated_at = $1 WHERE id = $2
+	`, now, result.Invitation.ID)
+	if err != nil {
+		fmt.Printf("[SALOME BE] Warning: failed to record invitation email %s: %v\n", result.Invitation.ID, err)
+		return
+	}
+	result.Invitation.EmailSentAt = &now
+}
+
+// joinURL is the sign-up-and-join link of an invitation
+func (s *InvitationService) joinURL(token string) string {
+	sep := "?"
+	if strings.Contains(s.config.JoinURL, "?") {
+		sep = "&"
+	}
+	return s.config.JoinURL + sep + "token=" + url.QueryEscape(token)
+}
+
+// ListForGroup returns the invitations of a group, newest first (owner only)
+func (s *InvitationService) ListForGroup(ownerID, groupID string) ([]models.GroupInvitation, error) {
+	if _, err := s.ownedGroup(s.db, ownerID, groupID); err != nil {
+		return nil, err
+	}
+	if _, err := s.expireTx(s.db, groupID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	invitations, err := queryInvitations(s.db, `WHERE i.group_id = $1 ORDER BY i.created_at DESC`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	// The owner does not need the invited people's links
+	for i := range invitations {
+		invitations[i].Token = ""
+	}
+	return invitations, nil
+}
+
+// ListForUser returns the pending invitations sent to the user's email address, newest first
+func (s *InvitationService) ListForUser(userID string) ([]models.GroupInvitation, error) {
+	return queryInvitations(s.db, `
+		WHERE i.status = $1 AND i.expires_at > NOW()
+		  AND (i.user_id = $2 OR i.email = (SELECT LOWER(email) FROM users WHERE id = $2))
+		ORDER BY i.created_at DESC
+	`, models.InvitationPending, userID)
+}
+
+// Get returns an invitation by the token of its link. Anyone with the link can see it, so the sign-up page
+// can show the group before the invited person has an account.
+func (s *InvitationService) Get(token string) (*models.GroupInvitation, error) {
+	inv, err := scanInvitation(s.db.QueryRow(`SELECT `+invitationColumns+invitationJoins+` WHERE i.token = $1`, token))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %v", err)
+	}
+	if inv.Status == models.InvitationPending && !inv.ExpiresAt.After(time.Now()) {
+		inv.Status = models.InvitationExpired
+	}
+	return inv, nil
+}
+
+// Accept reserves a pending seat in the group for the invited user and marks the invitation accepted, in
+// one DB transaction. The invitation stays pending when the group has no free seat.
+func (s *InvitationService) Accept(userID, token string) (*models.GroupInvitation, *SeatReservation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := s.lockForResponse(tx, userID, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reservation, err := s.seats.reserveTx(tx, UserActor(userID).WithCorrelation(inv.ID), userID, inv.GroupID)
+	if err != nil {
+		return inv, nil, err
+	}
+	if err := s.respondTx(tx, inv, userID, models.InvitationAccepted); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+	}
+	fmt.Printf("[SALOME BE] User %s accepted invitation %s to group %s\n", userID, inv.ID, inv.GroupID)
+	return inv, reservation, nil
+}
+
+// Decline turns down an invitation
+func (s *InvitationService) Decline(userID, token string) (*models.GroupInvitation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := s.lockForResponse(tx, userID, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.respondTx(tx, inv, userID, models.InvitationDeclined); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] User %s declined invitation %s to group %s\n", userID, inv.ID, inv.GroupID)
+	return inv, nil
+}
+
+// ExpireStale marks pending invitations past their expiry as expired and returns how many there were
+func (s *InvitationService) ExpireStale(now time.Time) (int, error) {
+	return s.expireTx(s.db, "", now)
+}
+
+// expireTx expires the lapsed pending invitations of a group, or of every group when groupID is empty
+func (s *InvitationService) expireTx(q database.Queryer, groupID string, now time.Time) (int, error) {
+	result, err := q.Exec(`
+		UPDATE group_invitations SET status = $1, updated_at = $2
+		WHERE status = $3 AND expires_at <= $2 AND ($4 = '' OR group_id::text = $4)
+	`, models.InvitationExpired, now, models.InvitationPending, groupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire invitations: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// lockForResponse locks a pending invitation addressed to the user
+func (s *InvitationService) lockForResponse(tx *sql.Tx, userID, token string) (*models.GroupInvitation, error) {
+	var invitationID string
+	err := tx.QueryRow(`SELECT id FROM group_invitations WHERE token = $1 FOR UPDATE`, token).Scan(&invitationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock invitation: %v", err)
+	}
+	inv, err := getInvitation(tx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var email string
+	if err := tx.QueryRow(`SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+		return nil, fmt.Errorf("failed to get user email: %v", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(email), inv.Email) {
+		return nil, ErrInvitationWrongUser
+	}
+
+	if inv.Status != models.InvitationPending {
+		return nil, ErrInvitationNotPending
+	}
+	if !inv.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvitationExpired
+	}
+	return inv, nil
+}
+
+// respondTx records the user's answer to an invitation and lets the owner know
+func (s *InvitationService) respondTx(tx *sql.Tx, inv *models.GroupInvitation, userID, status string) error {
+	now := time.Now()
+	_, err := tx.Exec(`
+		UPDATE group_invitations SET status = $1, user_id = $2, responded_at = $3, updated_at = $3 WHERE id = $4
+	`, status, userID, now, inv.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update invitation: %v", err)
+	}
+	inv.Status = status
+	inv.UserID = &userID
+	inv.RespondedAt = &now
+
+	if inv.InvitedBy == nil {
+		return nil
+	}
+	title, verb := "Undangan diterima", "menerima"
+	if status == models.InvitationDeclined {
+		title, verb = "Undangan ditolak", "menolak"
+	}
+	return notify(tx, *inv.InvitedBy, "group", title,
+		fmt.Sprintf("%s %s undangan Anda ke grup %s.", inv.Email, verb, inv.GroupName),
+		"/groups/"+inv.GroupID, "Lihat Grup")
+}
+
+// ownedGroup returns a group of the owner that people can be invited to
+func (s *InvitationService) ownedGroup(q database.Queryer, ownerID, groupID string) (*invitationGroup, error) {
+	var group invitationGroup
+	var owner, status string
+	err := q.QueryRow(`
+		SELECT g.id, g.name, a.name, g.owner_id, g.group_status, COALESCE(u.full_name, ''), u.email
+		FROM groups g
+		JOIN apps a ON a.id = g.app_id
+		JOIN users u ON u.id = g.owner_id
+		WHERE g.id = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)
+	`, groupID).Scan(&group.id, &group.name, &group.appName, &owner, &status, &group.ownerName, &group.ownerEmail)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+	if owner != ownerID {
+		return nil, ErrInvitationOwnerOnly
+	}
+	switch status {
+	case models.GroupStatusClosed, models.GroupStatusPaused, models.GroupStatusClosing:
+		return nil, ErrGroupNotJoinable
+	}
+	return &group, nil
+}
+
+func getInvitation(q database.Queryer, invitationID string) (*models.GroupInvitation, error) {
+	inv, err := scanInvitation(q.QueryRow(`SELECT `+invitationColumns+invitationJoins+` WHERE i.id = $1`, invitationID))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %v", err)
+	}
+	return inv, nil
+}
+
+func queryInvitations(q database.Queryer, where string, args ...interface{}) ([]models.GroupInvitation, error) {
+	rows, err := q.Query(`SELECT `+invitationColumns+invitationJoins+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %v", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.GroupInvitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %v", err)
+		}
+		invitations = append(invitations, *inv)
+	}
+	return invitations, rows.Err()
+}
+
+// newInvitationToken returns a random token for the link of an invitation
+func newInvitationToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate invitation token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
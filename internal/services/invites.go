@@ -0,0 +1,458 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInviteNotFound   = errors.New("invite link not found")
+	ErrInviteExpired    = errors.New("invite link has expired")
+	ErrInviteRevoked    = errors.New("invite link has been revoked")
+	ErrInviteExhausted  = errors.New("invite link has reached its maximum number of uses")
+	ErrInviteWrongEmail = errors.New("invite link was sent to a different email address")
+	ErrInviteOwnerOnly  = errors.New("only the group owner can manage invite links")
+)
+
+// InviteService manages the invite links of groups. A link can expire, be limited to a number of uses or to
+// one email address, and be revoked or regenerated by the owner. Every seat taken through a link is recorded.
+type InviteService struct {
+	db    *sql.DB
+	seats *SeatService
+}
+
+func NewInviteService(db *sql.DB) *InviteService {
+	return &InviteService{
+		db:    db,
+		seats: NewSeatService(db),
+	}
+}
+
+const inviteColumns = `i.id, i.group_id, i.code, i.created_by, i.target_email, i.max_uses, i.use_count, i.expires_at,
+	i.revoked_at, i.replaced_by, i.created_at, i.updated_at, i.code = g.invite_code`
+
+func scanInvite(row rowScanner) (*models.GroupInvite, error) {
+	var inv models.GroupInvite
+	err := row.Scan(&inv.ID, &inv.GroupID, &inv.Code, &inv.CreatedBy, &inv.TargetEmail, &inv.MaxUses, &inv.UseCount,
+		&inv.ExpiresAt, &inv.RevokedAt, &inv.ReplacedBy, &inv.CreatedAt, &inv.UpdatedAt, &inv.IsPrimary)
+	if err != nil {
+		return nil, err
+	}
+	inv.Status = inviteStatus(&inv, time.Now())
+	return &inv, nil
+}
+
+func inviteStatus(inv *models.GroupInvite, now time.Time) string {
+	switch {
+	case inv.RevokedAt != nil:
+		return models.InviteRevoked
+	case inv.ExpiresAt != nil && !inv.ExpiresAt.After(now):
+		return models.InviteExpired
+	case inv.MaxUses != nil && inv.UseCount >= *inv.MaxUses:
+		return models.InviteExhausted
+	}
+	return models.InviteActive
+}
+
+// inviteError is the error for an invite link that cannot be used
+func inviteError(status string) error {
+	switch status {
+	case models.InviteRevoked:
+		return ErrInviteRevoked
+	case models.InviteExpired:
+		return ErrInviteExpired
+	case models.InviteExhausted:
+		return ErrInviteExhausted
+	}
+	return nil
+}
+
+// NewInviteCode returns a random 8 character code that no group or invite link uses yet
+func NewInviteCode(q database.Queryer) (string, error) {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	for attempt := 0; attempt < 10; attempt++ {
+		code := make([]byte, 8)
+		for i := range code {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate invite code: %v", err)
+			}
+			code[i] = charset[n.Int64()]
+		}
+
+		var taken bool
+		err := q.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM groups WHERE invite_code = $1)
+			    OR EXISTS(SELECT 1 FROM group_invites WHERE code = $1)
+		`, string(code)).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check invite code: %v", err)
+		}
+		if !taken {
+			return string(code), nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique invite code")
+}
+
+// CreatePrimaryInvite records the invite code a group is created with as its first invite link
+func CreatePrimaryInvite(q database.Queryer, groupID, code, createdBy string) error {
+	_, err := q.Exec(`
+		INSERT INTO group_invites (group_id, code, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`, groupID, code, nullIfEmpty(createdBy))
+	if err != nil {
+		return fmt.Errorf("failed to create invite link: %v", err)
+	}
+	return nil
+}
+
+// Create adds an invite link to the group
+func (s *InviteService) Create(ownerID, groupID string, req models.GroupInviteRequest) (*models.GroupInvite, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.checkOwner(tx, ownerID, groupID); err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+	var maxUses *int
+	if req.MaxUses > 0 {
+		maxUses = &req.MaxUses
+	}
+	inviteID, err := s.insertTx(tx, groupID, ownerID, strings.ToLower(strings.TrimSpace(req.TargetEmail)), maxUses, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Invite link %s created for group %s by owner %s\n", inviteID, groupID, ownerID)
+	return s.get(s.db, groupID, inviteID)
+}
+
+func (s *InviteService) insertTx(tx *sql.Tx, groupID, createdBy, targetEmail string, maxUses *int, expiresAt *time.Time) (string, error) {
+	code, err := NewInviteCode(tx)
+	if err != nil {
+		return "", err
+	}
+	var inviteID string
+	err = tx.QueryRow(`
+		INSERT INTO group_invites (group_id, code, created_by, target_email, max_uses, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING id
+	`, groupID, code, createdBy, nullIfEmpty(targetEmail), maxUses, expiresAt).Scan(&inviteID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create invite link: %v", err)
+	}
+	return inviteID, nil
+}
+
+// List returns the invite links of a group, newest first
+func (s *InviteService) List(ownerID, groupID string) ([]models.GroupInvite, error) {
+	if err := s.checkOwner(s.db, ownerID, groupID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+inviteColumns+`
+		FROM group_invites i
+		JOIN groups g ON g.id = i.group_id
+		WHERE i.group_id = $1
+		ORDER BY i.created_at DESC
+	`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite links: %v", err)
+	}
+	defer rows.Close()
+
+	invites := []models.GroupInvite{}
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite link: %v", err)
+		}
+		invites = append(invites, *inv)
+	}
+	return invites, rows.Err()
+}
+
+// Revoke stops an invite link from being used. Seats already taken through it are kept.
+func (s *InviteService) Revoke(ownerID, groupID, inviteID string) (*models.GroupInvite, error) {
+	if err := s.checkOwner(s.db, ownerID, groupID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE group_invites SET revoked_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL
+	`, inviteID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke invite link: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// Revoking twice is not an error, a link of another group is
+		if _, err := s.get(s.db, groupID, inviteID); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("[SALOME BE] Invite link %s of group %s revoked by owner %s\n", inviteID, groupID, ownerID)
+	return s.get(s.db, groupID, inviteID)
+}
+
+// Regenerate revokes an invite link and replaces it with a link with a new code and the same target, usage
+// cap and lifetime. Regenerating the group's primary link also changes the group's invite code.
+func (s *InviteService) Regenerate(ownerID, groupID, inviteID string) (*models.GroupInvite, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.checkOwner(tx, ownerID, groupID); err != nil {
+		return nil, err
+	}
+	old, err := s.get(tx, groupID, inviteID)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if old.ExpiresAt != nil {
+		t := time.Now().Add(old.ExpiresAt.Sub(old.CreatedAt))
+		expiresAt = &t
+	}
+	targetEmail := ""
+	if old.TargetEmail != nil {
+		targetEmail = *old.TargetEmail
+	}
+	newID, err := s.insertTx(tx, groupID, ownerID, targetEmail, old.MaxUses, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE group_invites SET revoked_at = COALESCE(revoked_at, NOW()), replaced_by = $1, updated_at = NOW()
+		WHERE id = $2
+	`, newID, inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke invite link: %v", err)
+	}
+	if old.IsPrimary {
+		_, err = tx.Exec(`
+			UPDATE groups SET invite_code = (SELECT code FROM group_invites WHERE id = $1), updated_at = NOW()
+			WHERE id = $2
+		`, newID, groupID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update group invite code: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Invite link %s of group %s regenerated as %s by owner %s\n", inviteID, groupID, newID, ownerID)
+	return s.get(s.db, groupID, newID)
+}
+
+// Uses returns the users who took a seat through an invite link, most recent first
+func (s *InviteService) Uses(ownerID, groupID, inviteID string) ([]models.GroupInviteUse, error) {
+	if err := s.checkOwner(s.db, ownerID, groupID); err != nil {
+		return nil, err
+	}
+	if _, err := s.get(s.db, groupID, inviteID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT u.id, u.invite_id, u.user_id, COALESCE(usr.full_name, ''), usr.email, u.used_at,
+		       (SELECT gm.user_status FROM group_members gm WHERE gm.group_id = u.group_id AND gm.user_id = u.user_id)
+		FROM group_invite_uses u
+		JOIN users usr ON usr.id = u.user_id
+		WHERE u.invite_id = $1
+		ORDER BY u.used_at DESC
+	`, inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite link uses: %v", err)
+	}
+	defer rows.Close()
+
+	uses := []models.GroupInviteUse{}
+	for rows.Next() {
+		var use models.GroupInviteUse
+		if err := rows.Scan(&use.ID, &use.InviteID, &use.UserID, &use.UserName, &use.UserEmail, &use.UsedAt,
+			&use.UserStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan invite link use: %v", err)
+		}
+		uses = append(uses, use)
+	}
+	return uses, rows.Err()
+}
+
+// Resolve returns the invite link with the code when it can still be used. The target email is checked when
+// the link is redeemed, since the invite page can be viewed without signing in.
+func (s *InviteService) Resolve(code string) (*models.GroupInvite, error) {
+	inv, err := lookupInvite(s.db, code, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := inviteError(inv.Status); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// Join reserves a pending seat for the user through an invite link and records the use, in one DB transaction
+func (s *InviteService) Join(userID, code string) (*models.GroupInvite, *SeatReservation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := validateInviteTx(tx, code, userID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reservation, err := s.seats.reserveTx(tx, UserActor(userID).WithCorrelation(inv.ID), userID, inv.GroupID)
+	if err != nil {
+		return inv, nil, err
+	}
+	if err := redeemInviteTx(tx, inv, userID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+	}
+	return inv, reservation, nil
+}
+
+// validateInviteTx returns the invite link with the code if userID can use it, optionally locking it
+func validateInviteTx(q database.Queryer, code, userID string, lock bool) (*models.GroupInvite, error) {
+	inv, err := lookupInvite(q, code, lock)
+	if err != nil {
+		return nil, err
+	}
+	if err := inviteError(inv.Status); err != nil {
+		return nil, err
+	}
+
+	if inv.TargetEmail != nil {
+		var email string
+		if err := q.QueryRow(`SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+			return nil, fmt.Errorf("failed to get user email: %v", err)
+		}
+		if !strings.EqualFold(strings.TrimSpace(email), *inv.TargetEmail) {
+			return nil, ErrInviteWrongEmail
+		}
+	}
+	return inv, nil
+}
+
+// redeemInviteTx counts a seat taken through an invite link
+func redeemInviteTx(tx *sql.Tx, inv *models.GroupInvite, userID string) error {
+	_, err := tx.Exec(`UPDATE group_invites SET use_count = use_count + 1, updated_at = NOW() WHERE id = $1`, inv.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update invite link: %v", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO group_invite_uses (invite_id, group_id, user_id, used_at) VALUES ($1, $2, $3, NOW())
+	`, inv.ID, inv.GroupID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record invite link use: %v", err)
+	}
+	return nil
+}
+
+// lookupInvite returns the invite link with the code, if its group still exists
+func lookupInvite(q database.Queryer, code string, lock bool) (*models.GroupInvite, error) {
+	query := `
+		SELECT ` + inviteColumns + `
+		FROM group_invites i
+		JOIN groups g ON g.id = i.group_id
+		WHERE i.code = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)`
+	if lock {
+		query += ` FOR UPDATE OF i`
+	}
+	inv, err := scanInvite(q.QueryRow(query, strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %v", err)
+	}
+	return inv, nil
+}
+
+// get returns an invite link of a group
+func (s *InviteService) get(q database.Queryer, groupID, inviteID string) (*models.GroupInvite, error) {
+	if _, err := uuid.Parse(inviteID); err != nil {
+		return nil, ErrInviteNotFound
+	}
+	inv, err := scanInvite(q.QueryRow(`
+		SELECT `+inviteColumns+`
+		FROM group_invites i
+		JOIN groups g ON g.id = i.group_id
+		WHERE i.id = $1 AND i.group_id = $2
+	`, inviteID, groupID))
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %v", err)
+	}
+	return inv, nil
+}
+
+// checkOwner returns ErrInviteOwnerOnly unless userID owns the group
+func (s *InviteService) checkOwner(q database.Queryer, userID, groupID string) error {
+	var ownerID string
+	err := q.QueryRow(`
+		SELECT owner_id FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+	`, groupID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get group: %v", err)
+	}
+	if ownerID != userID {
+		return ErrInviteOwnerOnly
+	}
+	return nil
+}
+
+// InvitePreview is what the public invite page shows about an invite link
+func InvitePreview(inv *models.GroupInvite) models.InvitePreview {
+	preview := models.InvitePreview{
+		Code:       inv.Code,
+		ExpiresAt:  inv.ExpiresAt,
+		Restricted: inv.TargetEmail != nil,
+	}
+	if inv.MaxUses != nil {
+		left := *inv.MaxUses - inv.UseCount
+		preview.UsesLeft = &left
+	}
+	return preview
+}
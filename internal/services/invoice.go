@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/invoice"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+	"salome-be/internal/service"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvoiceNotAvailable = errors.New("invoices are only issued for successful payments and top-ups")
+)
+
+// invoiceEligible selects the transactions t that get an invoice: settled payments and top-ups. The wallet
+// part of a mixed payment is listed on the invoice of its payment link transaction.
+const invoiceEligible = `
+	t.status IN ('success', 'completed')
+	AND t.type IN ('group_payment', 'top-up', 'top_up')
+	AND t.related_transaction_id IS NULL`
+
+const (
+	invoiceEmailMaxAttempts = 3
+	invoiceEmailWindow      = 48 * time.Hour // older invoices are not emailed, e.g. after a backfill
+	invoiceBackfillWindow   = 7 * 24 * time.Hour
+	invoiceBatchSize        = 50
+)
+
+// InvoiceRunStats summarizes one scheduled invoice run
+type InvoiceRunStats struct {
+	Issued      int `json:"issued"`
+	Emailed     int `json:"emailed"`
+	EmailFailed int `json:"email_failed"`
+}
+
+// InvoiceService issues sequentially numbered invoices for successful transactions, renders them as PDF and
+// sends them with the payment confirmation email
+type InvoiceService struct {
+	db       *sql.DB
+	config   config.InvoiceConfig
+	renderer *invoice.Renderer
+	email    *service.MultiProviderEmailService
+}
+
+func NewInvoiceService(db *sql.DB) *InvoiceService {
+	cfg := config.GetConfig()
+	return &InvoiceService{
+		db:       db,
+		config:   cfg.Invoice,
+		renderer: invoice.New(cfg.Invoice),
+		email:    service.NewConfiguredEmailService(cfg.Email),
+	}
+}
+
+// Issue assigns the next invoice number to a transaction inside tx and returns it. A transaction that already
+// has an invoice keeps its number. The transaction row is locked, so numbers are handed out once and without
+// gaps when the caller commits.
+func (s *InvoiceService) Issue(tx *sql.Tx, transactionID string) (string, error) {
+	var userID string
+	var eligible bool
+	err := tx.QueryRow(`
+		SELECT t.user_id, `+invoiceEligible+`
+		FROM transactions t
+		WHERE t.id = $1
+		FOR UPDATE
+	`, transactionID).Scan(&userID, &eligible)
+	if err == sql.ErrNoRows {
+		return "", ErrTransactionNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load transaction: %v", err)
+	}
+	if !eligible {
+		return "", ErrInvoiceNotAvailable
+	}
+
+	var number string
+	err = tx.QueryRow(`SELECT invoice_number FROM invoices WHERE transaction_id = $1`, transactionID).Scan(&number)
+	if err == nil {
+		return number, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get invoice: %v", err)
+	}
+
+	now := time.Now()
+	var sequence int
+	err = tx.QueryRow(`
+		INSERT INTO invoice_sequences (year, last_number) VALUES ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number
+	`, now.Year()).Scan(&sequence)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next invoice number: %v", err)
+	}
+	number = fmt.Sprintf("%s/%d/%06d", s.config.Prefix, now.Year(), sequence)
+
+	_, err = tx.Exec(`
+		INSERT INTO invoices (invoice_number, transaction_id, user_id, issued_at, created_at)
+		VALUES ($1, $2, $3, $4, $4)
+	`, number, transactionID, userID, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to create invoice: %v", err)
+	}
+
+	fmt.Printf("[SALOME BE] Issued invoice %s for transaction %s\n", number, transactionID)
+	return number, nil
+}
+
+// ForTransaction returns the invoice of a transaction of the user, issuing it first when the transaction was
+// settled before invoices existed
+func (s *InvoiceService) ForTransaction(userID, transactionID string) (*models.Invoice, error) {
+	var owner string
+	var issued bool
+	err := s.db.QueryRow(`
+		SELECT t.user_id, EXISTS (SELECT 1 FROM invoices i WHERE i.transaction_id = t.id)
+		FROM transactions t
+		WHERE t.id = $1
+	`, transactionID).Scan(&owner, &issued)
+	if err == sql.ErrNoRows || (err == nil && owner != userID) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction: %v", err)
+	}
+
+	if !issued {
+		if err := s.issue(transactionID); err != nil {
+			return nil, err
+		}
+	}
+	return s.load(transactionID)
+}
+
+// Render returns the invoice as a PDF document
+func (s *InvoiceService) Render(inv *models.Invoice) ([]byte, error) {
+	return s.renderer.Render(inv)
+}
+
+// Run issues invoices for recent transactions that were settled without one, then emails new invoices when
+// confirmation emails are enabled
+func (s *InvoiceService) Run(now time.Time) (*InvoiceRunStats, error) {
+	stats := &InvoiceRunStats{}
+
+	rows, err := s.db.Query(`
+		SELECT t.id
+		FROM transactions t
+		WHERE `+invoiceEligible+`
+		  AND t.updated_at >= $1
+		  AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.transaction_id = t.id)
+		ORDER BY t.updated_at ASC
+		LIMIT $2
+	`, now.Add(-invoiceBackfillWindow), invoiceBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions without invoice: %v", err)
+	}
+	var missing []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan transaction: %v", err)
+		}
+		missing = append(missing, id)
+	}
+	rows.Close()
+
+	for _, id := range missing {
+		if err := s.issue(id); err != nil {
+			fmt.Printf("❌ [ERROR] Failed to issue invoice for transaction %s: %v\n", id, err)
+			continue
+		}
+		stats.Issued++
+	}
+
+	if !s.config.EmailEnabled {
+		return stats, nil
+	}
+
+	rows, err = s.db.Query(`
+		SELECT transaction_id
+		FROM invoices
+		WHERE emailed_at IS NULL AND email_attempts < $1 AND issued_at >= $2
+		ORDER BY issued_at ASC
+		LIMIT $3
+	`, invoiceEmailMaxAttempts, now.Add(-invoiceEmailWindow), invoiceBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unsent invoices: %v", err)
+	}
+	var unsent []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan invoice: %v", err)
+		}
+		unsent = append(unsent, id)
+	}
+	rows.Close()
+
+	for _, id := range unsent {
+		if err := s.sendEmail(id); err != nil {
+			fmt.Printf("❌ [ERROR] Failed to email invoice for transaction %s: %v\n", id, err)
+			stats.EmailFailed++
+			continue
+		}
+		stats.Emailed++
+	}
+	return stats, nil
+}
+
+// issue assigns an invoice number in its own DB transaction
+func (s *InvoiceService) issue(transactionID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.Issue(tx, transactionID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// sendEmail sends the payment confirmation with the invoice attached and records the outcome
+func (s *InvoiceService) sendEmail(transactionID string) error {
+	inv, err := s.load(transactionID)
+	if err != nil {
+		return err
+	}
+	pdf, err := s.renderer.Render(inv)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = s.email.SendInvoiceEmail(ctx, service.InvoiceEmailData{
+		Email:         inv.CustomerEmail,
+		Name:          inv.CustomerName,
+		InvoiceNumber: inv.InvoiceNumber,
+		Description:   inv.Description,
+		Total:         inv.Total.Format(),
+		FileName:      invoice.FileName(inv),
+		PDF:           pdf,
+	})
+	if err != nil {
+		if _, dbErr := s.db.Exec(`
+			UPDATE invoices SET email_attempts = email_attempts + 1, email_error = $1 WHERE id = $2
+		`, err.Error(), inv.ID); dbErr != nil {
+			fmt.Printf("❌ [ERROR] Failed to record invoice email error: %v\n", dbErr)
+		}
+		return err
+	}
+
+	_, err = s.db.Exec(`UPDATE invoices SET emailed_at = $1, email_error = NULL WHERE id = $2`, time.Now(), inv.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark invoice emailed: %v", err)
+	}
+	return nil
+}
+
+// load reads an issued invoice together with the transaction, customer, group and subscription period
+func (s *InvoiceService) load(transactionID string) (*models.Invoice, error) {
+	var inv models.Invoice
+	var amount, adminFee money.Amount
+	var paymentMethod, groupName, appName sql.NullString
+	var breakdown pricing.Breakdown
+	err := s.db.QueryRow(`
+		SELECT i.id, i.invoice_number, i.transaction_id, i.user_id, i.issued_at, i.emailed_at,
+		       COALESCE(u.full_name, ''), u.email, t.type, t.description, t.payment_method, t.payment_reference, t.updated_at,
+		       t.group_id, g.name, a.name, t.amount, COALESCE(t.admin_fee, 0), t.price_breakdown,
+		       COALESCE(ri.period_start, gm.subscription_period_start),
+		       COALESCE(ri.period_end, gm.subscription_period_end)
+		FROM invoices i
+		JOIN transactions t ON t.id = i.transaction_id
+		JOIN users u ON u.id = i.user_id
+		LEFT JOIN groups g ON g.id = t.group_id
+		LEFT JOIN apps a ON a.id = g.app_id
+		LEFT JOIN renewal_invoices ri ON ri.id = t.renewal_invoice_id
+		LEFT JOIN group_members gm ON gm.group_id = t.group_id AND gm.user_id = t.user_id
+		WHERE i.transaction_id = $1
+	`, transactionID).Scan(&inv.ID, &inv.InvoiceNumber, &inv.TransactionID, &inv.UserID, &inv.IssuedAt, &inv.EmailedAt,
+		&inv.CustomerName, &inv.CustomerEmail, &inv.TransactionType, &inv.Description, &paymentMethod,
+		&inv.PaymentReference, &inv.PaidAt, &inv.GroupID, &groupName, &appName, &amount, &adminFee, &breakdown,
+		&inv.PeriodStart, &inv.PeriodEnd)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice: %v", err)
+	}
+	inv.PaymentMethod = paymentMethod.String
+	inv.GroupName = groupName.String
+	inv.AppName = appName.String
+
+	// The wallet part of a mixed payment was booked as its own transaction
+	inv.Payments = []models.InvoicePayment{{Method: inv.PaymentMethod, Amount: amount}}
+	var walletAmount money.Amount
+	err = s.db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM transactions
+		WHERE related_transaction_id = $1 AND type = 'group_payment' AND status IN ('success', 'completed')
+	`, transactionID).Scan(&walletAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet payment: %v", err)
+	}
+	if walletAmount > 0 {
+		inv.Payments = append([]models.InvoicePayment{{Method: pricing.MethodWallet, Amount: walletAmount}}, inv.Payments...)
+	}
+
+	inv.Lines, inv.Total = invoiceLines(&inv, amount+walletAmount, adminFee, breakdown)
+	return &inv, nil
+}
+
+// invoiceLines splits a paid amount into the lines printed on the invoice. Group payments use the stored
+// price breakdown; older payments without one are split into seat price and admin fee.
+func invoiceLines(inv *models.Invoice, paid, adminFee money.Amount, b pricing.Breakdown) ([]models.InvoiceLine, money.Amount) {
+	if inv.TransactionType != "group_payment" {
+		return []models.InvoiceLine{{Description: "Top up saldo", Amount: paid}}, paid
+	}
+
+	seat := "Harga seat"
+	if inv.AppName != "" {
+		seat += " " + inv.AppName
+	}
+	if b.Total == 0 {
+		lines := []models.InvoiceLine{{Description: seat, Amount: paid - adminFee}}
+		if adminFee > 0 {
+			lines = append(lines, models.InvoiceLine{Description: "Biaya admin", Amount: adminFee})
+		}
+		return lines, paid
+	}
+
+	// The admin fee is whatever the seat price, surcharge and discount do not explain, so the lines add up
+	lines := []models.InvoiceLine{
+		{Description: seat, Amount: b.PricePerMember},
+		{Description: "Biaya admin", Amount: b.Total - b.PricePerMember - b.Surcharge + b.Discount},
+	}
+	if b.Surcharge > 0 {
+		lines = append(lines, models.InvoiceLine{
+			Description: "Biaya metode pembayaran (" + invoice.MethodLabel(b.PaymentMethod) + ")",
+			Amount:      b.Surcharge,
+		})
+	}
+	if b.Discount > 0 {
+		description := "Diskon promo"
+		if b.PromoCode != "" {
+			description += " " + b.PromoCode
+		}
+		lines = append(lines, models.InvoiceLine{Description: description, Amount: -b.Discount})
+	}
+	return lines, b.Total
+}
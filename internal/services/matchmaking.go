@@ -0,0 +1,318 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAppNotFound       = errors.New("app not found or not available")
+	ErrAlreadyInAppGroup = errors.New("user is already in a group for this app")
+	ErrNoMatchingGroup   = errors.New("no open group matches the request")
+	ErrNoPlatformOwner   = errors.New("no platform account is configured to own new groups")
+)
+
+// matchCandidates is how many groups are tried before falling back to a new one
+const matchCandidates = 10
+
+// MatchResult is the seat matchmaking found for a user
+type MatchResult struct {
+	Reservation *SeatReservation `json:"reservation"`
+	AppID       string           `json:"app_id"`
+	AppName     string           `json:"app_name"`
+	InviteCode  string           `json:"invite_code"`
+	Created     bool             `json:"created"` // a new platform-owned group was created for the user
+}
+
+// MatchmakingService places users into open public groups of an app. It prefers the group closest to
+// filling up, then groups whose owner has completed the most groups, and only considers groups whose seat
+// price is close to the current price of the app. When no group fits, it creates a platform-owned group
+// from the app catalog.
+type MatchmakingService struct {
+	db      *sql.DB
+	config  config.MatchmakingConfig
+	seats   *SeatService
+	pricing *pricing.Engine
+}
+
+func NewMatchmakingService(db *sql.DB) *MatchmakingService {
+	return &MatchmakingService{
+		db:      db,
+		config:  config.GetConfig().Matchmaking,
+		seats:   NewSeatService(db),
+		pricing: pricing.Default(),
+	}
+}
+
+// matchApp is the catalog entry matched against
+type matchApp struct {
+	id, name   string
+	maxMembers int
+	seatPrice  *pricing.Breakdown
+}
+
+// Match reserves a pending seat for the user in the best open public group of the app. maxPrice, when
+// given, is the most the user wants to pay for a seat.
+func (s *MatchmakingService) Match(userID, appID string, maxPrice *money.Amount) (*MatchResult, error) {
+	app, err := s.loadApp(s.db, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	var inAppGroup bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM group_members gm
+			JOIN groups g ON g.id = gm.group_id
+			WHERE gm.user_id = $1 AND g.app_id = $2 AND gm.user_status NOT IN ($3, $5)
+			  AND (g.is_deleted IS NULL OR g.is_deleted = false) AND g.group_status <> $4
+		)
+	`, userID, appID, models.UserStatusRemoved, models.GroupStatusClosed, models.UserStatusExpired).Scan(&inAppGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing groups: %v", err)
+	}
+	if inAppGroup {
+		return nil, ErrAlreadyInAppGroup
+	}
+
+	limit := app.seatPrice.Total + money.FromMajor(app.seatPrice.Total.Major()*s.config.PriceTolerancePercentage/100)
+	if maxPrice != nil && *maxPrice < limit {
+		limit = *maxPrice
+	}
+
+	candidates, err := s.candidates(s.db, userID, app, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, groupID := range candidates {
+		result, err := s.reserve(userID, groupID, app)
+		if err == nil {
+			return result, nil
+		}
+		if !isSeatTaken(err) {
+			return nil, err
+		}
+	}
+
+	if !s.config.CreateGroups || app.seatPrice.Total > limit {
+		return nil, ErrNoMatchingGroup
+	}
+	return s.createAndReserve(userID, app, limit)
+}
+
+// loadApp returns an active app with the current price of a seat in a full-size group
+func (s *MatchmakingService) loadApp(q database.Queryer, appID string) (*matchApp, error) {
+	app := &matchApp{id: appID}
+	err := q.QueryRow(`
+		SELECT name, max_group_members FROM apps WHERE id = $1 AND is_active = true
+	`, appID).Scan(&app.name, &app.maxMembers)
+	if err == sql.ErrNoRows {
+		return nil, ErrAppNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app: %v", err)
+	}
+
+	app.seatPrice, err = AppSeatPrice(q, s.pricing, appID, app.maxMembers)
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// candidates returns the open public groups of the app the user can join, best match first. Groups with
+// users on their waitlist are left to the waitlist.
+func (s *MatchmakingService) candidates(q database.Queryer, userID string, app *matchApp, limit money.Amount) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT g.id
+		FROM groups g
+		JOIN users o ON o.id = g.owner_id
+		CROSS JOIN LATERAL (
+			SELECT g.max_members
+				- (SELECT COUNT(*) FROM group_members gm WHERE gm.group_id = g.id AND gm.user_status NOT IN ($1, $10))
+				- (SELECT COUNT(*) FROM group_waitlist w WHERE w.group_id = g.id AND w.status = $2 AND w.offer_expires_at > NOW())
+				AS free_seats
+		) seats
+		WHERE g.app_id = $3 AND g.is_public = true AND g.group_status = $4
+		  AND (g.is_deleted IS NULL OR g.is_deleted = false)
+		  AND g.max_members <= $5 AND g.owner_id <> $6 AND o.status = 'active'
+		  AND COALESCE(g.price_per_member, 0) + COALESCE(g.admin_fee, 0) <= $7
+		  AND seats.free_seats > 0
+		  AND NOT EXISTS (SELECT 1 FROM group_members gm WHERE gm.group_id = g.id AND gm.user_id = $6 AND gm.user_status <> $10)
+		  AND NOT EXISTS (SELECT 1 FROM group_waitlist w WHERE w.group_id = g.id AND w.status = $8)
+		ORDER BY seats.free_seats ASC,
+		         (SELECT COUNT(*) FROM groups og WHERE og.owner_id = g.owner_id AND og.all_paid_at IS NOT NULL) DESC,
+		         g.created_at ASC
+		LIMIT $9
+	`, models.UserStatusRemoved, models.WaitlistOffered, app.id, models.GroupStatusOpen, app.maxMembers, userID,
+		limit, models.WaitlistWaiting, matchCandidates, models.UserStatusExpired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find groups: %v", err)
+	}
+	defer rows.Close()
+
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %v", err)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	return groupIDs, rows.Err()
+}
+
+// reserve takes a seat in one of the candidate groups
+func (s *MatchmakingService) reserve(userID, groupID string, app *matchApp) (*MatchResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := s.reserveTx(tx, userID, groupID, app, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+	}
+	return result, nil
+}
+
+func (s *MatchmakingService) reserveTx(tx *sql.Tx, userID, groupID string, app *matchApp, created bool) (*MatchResult, error) {
+	reservation, err := s.seats.reserveTx(tx, UserActor(userID), userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MatchResult{Reservation: reservation, AppID: app.id, AppName: app.name, Created: created}
+	if err := tx.QueryRow(`SELECT invite_code FROM groups WHERE id = $1`, groupID).Scan(&result.InviteCode); err != nil {
+		return nil, fmt.Errorf("failed to get invite code: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Matched user %s into group %s of app %s (created: %v)\n", userID, groupID, app.id, created)
+	return result, nil
+}
+
+// createAndReserve creates a platform-owned group for the app and reserves the first seat in it. The app row
+// is locked, so concurrent requests that find no group share the new one instead of each creating a group.
+func (s *MatchmakingService) createAndReserve(userID string, app *matchApp, limit money.Amount) (*MatchResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT id FROM apps WHERE id = $1 FOR UPDATE`, app.id); err != nil {
+		return nil, fmt.Errorf("failed to lock app: %v", err)
+	}
+
+	// A group may have been created while waiting for the lock
+	candidates, err := s.candidates(tx, userID, app, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > 0 {
+		result, err := s.reserveTx(tx, userID, candidates[0], app, false)
+		if err == nil {
+			if err := tx.Commit(); err != nil {
+				return nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+			}
+			return result, nil
+		}
+		if !isSeatTaken(err) {
+			return nil, err
+		}
+	}
+
+	groupID, err := s.createGroup(tx, UserActor(userID), app)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.reserveTx(tx, userID, groupID, app, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit group creation: %v", err)
+	}
+	return result, nil
+}
+
+// createGroup creates an open public group of the app's full size, owned by the platform account. The
+// owner is not a member, so every seat is available.
+func (s *MatchmakingService) createGroup(tx *sql.Tx, actor Actor, app *matchApp) (string, error) {
+	ownerID, err := s.platformOwner(tx)
+	if err != nil {
+		return "", err
+	}
+
+	var count int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM groups WHERE app_id = $1 AND is_platform_owned = true
+	`, app.id).Scan(&count); err != nil {
+		return "", fmt.Errorf("failed to count platform groups: %v", err)
+	}
+
+	inviteCode, err := NewInviteCode(tx)
+	if err != nil {
+		return "", err
+	}
+
+	groupID := uuid.New().String()
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO groups (
+			id, name, description, app_id, owner_id, invite_code, max_members,
+			price_per_member, admin_fee, total_price, price_breakdown, group_status, is_public, is_platform_owned,
+			is_deleted, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, true, true, false, $13, $13)
+	`, groupID, fmt.Sprintf("%s SALOME #%d", app.name, count+1), "Grup publik yang dikelola oleh SALOME",
+		app.id, ownerID, inviteCode, app.maxMembers, app.seatPrice.PricePerMember, app.seatPrice.AdminFee,
+		app.seatPrice.AppPrice, *app.seatPrice, models.GroupStatusOpen, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to create group: %v", err)
+	}
+	if err := RecordGroupCreated(tx, actor, groupID, models.GroupStatusOpen, "", ""); err != nil {
+		return "", err
+	}
+	if err := CreatePrimaryInvite(tx, groupID, inviteCode, ownerID); err != nil {
+		return "", err
+	}
+	fmt.Printf("[SALOME BE] Created platform group %s for app %s\n", groupID, app.id)
+	return groupID, nil
+}
+
+// platformOwner returns the configured platform account, or the first admin when none is configured
+func (s *MatchmakingService) platformOwner(q database.Queryer) (string, error) {
+	if s.config.PlatformOwnerID != "" {
+		return s.config.PlatformOwnerID, nil
+	}
+	var ownerID string
+	err := q.QueryRow(`
+		SELECT id FROM users WHERE is_admin = true AND status = 'active' ORDER BY created_at ASC LIMIT 1
+	`).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return "", ErrNoPlatformOwner
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get platform account: %v", err)
+	}
+	return ownerID, nil
+}
+
+// isSeatTaken reports whether a reservation failed because the group stopped taking members
+func isSeatTaken(err error) bool {
+	return errors.Is(err, ErrGroupFull) || errors.Is(err, ErrGroupNotJoinable) || errors.Is(err, ErrGroupNotFound)
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"salome-be/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// notify inserts an in-app notification, inside the caller's transaction when q is a *sql.Tx
+func notify(q database.Queryer, userID, notificationType, title, message, actionURL, actionText string) error {
+	now := time.Now()
+	_, err := q.Exec(`
+		INSERT INTO notifications (id, user_id, type, title, message, action_url, action_text, is_read, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, uuid.New().String(), userID, notificationType, title, message,
+		nullIfEmpty(actionURL), nullIfEmpty(actionText), false, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %v", err)
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+)
+
+// SettlementResult describes a transaction after a gateway status was applied
+type SettlementResult struct {
+	TransactionID   string
+	TransactionType string
+	UserID          string
+	GroupID         *string
+	Status          string
+	Applied         bool
+}
+
+// PaymentSettlement applies payment gateway statuses to transactions and books settled money in the ledger.
+// It is shared by the webhook inbox and the Midtrans status polling endpoints.
+type PaymentSettlement struct {
+	db           *sql.DB
+	ledger       *ledger.Ledger
+	stateMachine *StateMachineService
+	refunds      *RefundService
+	promos       *PromoService
+	invoices     *InvoiceService
+	payments     *GroupPaymentService
+}
+
+func NewPaymentSettlement(db *sql.DB) *PaymentSettlement {
+	return &PaymentSettlement{
+		db:           db,
+		ledger:       ledger.New(db),
+		stateMachine: NewStateMachineService(db),
+		refunds:      NewRefundService(db),
+		promos:       NewPromoService(db),
+		invoices:     NewInvoiceService(db),
+		payments:     NewGroupPaymentService(db),
+	}
+}
+
+// isSettledStatus reports whether a transaction has already been paid and must not be applied again
+func isSettledStatus(status string) bool {
+	return status == "success" || status == "completed"
+}
+
+// Apply updates the transaction identified by paymentReference inside tx. The transaction row is locked so
+// a settlement is booked at most once, no matter how many times or through which path it arrives. actor is
+// the path it arrived through; status changes it causes are recorded with the payment reference as their
+// correlation ID.
+func (s *PaymentSettlement) Apply(tx *sql.Tx, actor Actor, paymentReference, status, paymentType string) (*SettlementResult, error) {
+	actor = actor.WithCorrelation(paymentReference)
+	result := &SettlementResult{Status: status}
+	var currentStatus string
+	var amount, adminFee money.Amount
+	var renewalInvoiceID *string
+	err := tx.QueryRow(`
+		SELECT id, user_id, group_id, type, amount, COALESCE(admin_fee, 0), status, renewal_invoice_id
+		FROM transactions
+		WHERE payment_reference = $1
+		FOR UPDATE
+	`, paymentReference).Scan(&result.TransactionID, &result.UserID, &result.GroupID, &result.TransactionType, &amount, &adminFee,
+		&currentStatus, &renewalInvoiceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transaction %s not found", paymentReference)
+		}
+		return nil, fmt.Errorf("failed to load transaction: %v", err)
+	}
+
+	if isSettledStatus(currentStatus) || currentStatus == status {
+		fmt.Printf("[SALOME BE] Transaction %s already %s, ignoring %s status\n", paymentReference, currentStatus, status)
+		result.Status = currentStatus
+		return result, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE transactions
+		SET status = $1, payment_method = COALESCE(NULLIF($2, ''), payment_method), updated_at = $3
+		WHERE id = $4
+	`, status, paymentType, time.Now(), result.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update transaction status: %v", err)
+	}
+	result.Applied = true
+
+	if status != "success" {
+		// Give back the wallet part of a mixed payment whose link failed
+		if status != "pending" && result.TransactionType == "group_payment" {
+			if err := s.settleWalletLegs(tx, result.TransactionID, status); err != nil {
+				return nil, err
+			}
+			if err := s.promos.Release(tx, result.TransactionID); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	op := ledger.Operation{
+		UserID:        result.UserID,
+		TransactionID: result.TransactionID,
+		Amount:        amount,
+		AdminFee:      adminFee,
+		Description:   fmt.Sprintf("Payment %s settled", paymentReference),
+	}
+
+	switch result.TransactionType {
+	case "top_up", "top-up":
+		movement, err := s.ledger.TopUp(tx, op)
+		if err != nil {
+			return nil, fmt.Errorf("failed to book top-up: %v", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE transactions SET balance_before = $1, balance_after = $2 WHERE id = $3
+		`, movement.BalanceBefore, movement.BalanceAfter, result.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update transaction balance: %v", err)
+		}
+		fmt.Printf("[SALOME BE] Updated user balance: UserID=%s, OldBalance=%s, AmountAdded=%s, NewBalance=%s\n",
+			result.UserID, movement.BalanceBefore, amount, movement.BalanceAfter)
+	case "group_payment":
+		if result.GroupID != nil {
+			op.GroupID = *result.GroupID
+		}
+		if _, err := s.ledger.SettleGroupPayment(tx, op); err != nil {
+			return nil, fmt.Errorf("failed to book group payment: %v", err)
+		}
+		if err := s.promos.Confirm(tx, result.TransactionID); err != nil {
+			return nil, err
+		}
+		if renewalInvoiceID != nil {
+			if err := s.applyRenewalPayment(tx, actor, *renewalInvoiceID, result.TransactionID); err != nil {
+				return nil, err
+			}
+			break
+		}
+		// The wallet legs of a link that failed before were refunded; the seat is only paid once they are taken again
+		if currentStatus != "pending" && result.GroupID != nil {
+			covered, err := s.rebookWalletLegs(tx, *result.GroupID, result.UserID, result.TransactionID)
+			if err != nil {
+				return nil, err
+			}
+			if !covered {
+				fmt.Printf("[SALOME BE] Failed payment %s settled without its wallet part, refunding\n", paymentReference)
+				if _, err := s.refunds.refundLatePayment(tx, result.UserID, *result.GroupID, result.TransactionID); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if err := s.settleWalletLegs(tx, result.TransactionID, status); err != nil {
+			return nil, err
+		}
+		if result.GroupID != nil {
+			if err := s.markMemberPaid(tx, actor, *result.GroupID, result.UserID, result.TransactionID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := s.invoices.Issue(tx, result.TransactionID); err != nil && err != ErrInvoiceNotAvailable {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// markMemberPaid marks the paying member of a group as paid. Pending members go through the state machine
+// so the group is activated once everyone has paid; paid and active members are left as they are. A payment that arrives after the member left or was
+// removed is refunded in full.
+func (s *PaymentSettlement) markMemberPaid(tx *sql.Tx, actor Actor, groupID, userID, transactionID string) error {
+	var userStatus string
+	err := tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE
+	`, groupID, userID).Scan(&userStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get group member status: %v", err)
+	}
+	if err == sql.ErrNoRows || userStatus == models.UserStatusRemoved {
+		fmt.Printf("[SALOME BE] Late payment for a seat no longer held, refunding: GroupID=%s, UserID=%s\n", groupID, userID)
+		_, err := s.refunds.refundLatePayment(tx, userID, groupID, transactionID)
+		return err
+	}
+
+	// Members that are already paid or active keep their status
+	if userStatus == models.UserStatusPending {
+		if err := s.stateMachine.UpdateUserStatusTx(tx, actor, userID, groupID, models.UserStatusPaid, "Payment settled"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("[SALOME BE] Updated group member status: GroupID=%s, UserID=%s\n", groupID, userID)
+	return nil
+}
+
+// rebookWalletLegs takes the wallet part of a mixed payment again when its link settles after it had failed
+// and the wallet legs were refunded. It reports false, taking nothing, when the member no longer waits for
+// the payment or the wallet cannot cover the legs; the caller then refunds the link payment.
+func (s *PaymentSettlement) rebookWalletLegs(tx *sql.Tx, groupID, userID, linkTransactionID string) (bool, error) {
+	rows, err := tx.Query(`
+		SELECT amount, admin_fee, COALESCE(description, '')
+		FROM transactions
+		WHERE related_transaction_id = $1 AND payment_method = 'wallet' AND status = 'refunded'
+		FOR UPDATE
+	`, linkTransactionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load refunded wallet legs: %v", err)
+	}
+
+	type walletLeg struct {
+		amount, adminFee money.Amount
+		description      string
+	}
+	var legs []walletLeg
+	var total money.Amount
+	for rows.Next() {
+		var leg walletLeg
+		if err := rows.Scan(&leg.amount, &leg.adminFee, &leg.description); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan wallet leg: %v", err)
+		}
+		legs = append(legs, leg)
+		total += leg.amount
+	}
+	rows.Close()
+	if len(legs) == 0 {
+		return true, nil
+	}
+
+	var userStatus string
+	err = tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE
+	`, groupID, userID).Scan(&userStatus)
+	if err == sql.ErrNoRows || (err == nil && userStatus != models.UserStatusPending) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get group member status: %v", err)
+	}
+
+	wallet, err := s.ledger.Account(tx, ledger.AccountUserWallet, userID)
+	if err != nil {
+		return false, err
+	}
+	var balance int64
+	err = tx.QueryRow(`SELECT balance FROM ledger_accounts WHERE id = $1 FOR UPDATE`, wallet).Scan(&balance)
+	if err != nil {
+		return false, fmt.Errorf("failed to get wallet balance: %v", err)
+	}
+	if balance < total.Minor() {
+		return false, nil
+	}
+
+	for _, leg := range legs {
+		_, err := s.payments.debitWallet(tx, userID, groupID, leg.amount, leg.adminFee, "completed", &linkTransactionID, nil, leg.description)
+		if err != nil {
+			return false, fmt.Errorf("failed to rebook wallet leg: %v", err)
+		}
+	}
+	fmt.Printf("[SALOME BE] Rebooked %d wallet legs (%s) for payment %s\n", len(legs), total, linkTransactionID)
+	return true, nil
+}
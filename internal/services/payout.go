@@ -0,0 +1,550 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/database"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+)
+
+var (
+	ErrPayoutAccountNotFound = errors.New("payout account not found")
+	ErrPayoutNotFound        = errors.New("payout not found")
+	ErrPayoutBelowMinimum    = errors.New("payout amount is below the minimum")
+	ErrPayoutFeeTooHigh      = errors.New("payout amount does not cover the fee")
+	ErrPayoutInvalidStatus   = errors.New("payout cannot be changed in its current status")
+	ErrNotGroupOwner         = errors.New("only the group owner can withdraw group earnings")
+	ErrPayoutNotWithdrawable = errors.New("payout amount exceeds the withdrawable wallet balance")
+)
+
+// PayoutService handles withdrawals to bank accounts. Requesting a payout holds the money in the ledger;
+// an admin approves it, transfers it and marks it paid, or rejects it and the money is returned.
+type PayoutService struct {
+	db     *sql.DB
+	ledger *ledger.Ledger
+	config config.PayoutConfig
+}
+
+func NewPayoutService(db *sql.DB) *PayoutService {
+	return &PayoutService{
+		db:     db,
+		ledger: ledger.New(db),
+		config: config.GetConfig().Payout,
+	}
+}
+
+// Fee returns the fee deducted from a payout of the given amount, in whole rupiah
+func (s *PayoutService) Fee(amount money.Amount) money.Amount {
+	return s.config.Fee + money.FromMajor(amount.Major()*s.config.FeePercentage/100).Ceil()
+}
+
+// ListAccounts returns the active bank accounts of a user, default first
+func (s *PayoutService) ListAccounts(userID string) ([]models.PayoutAccount, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, bank_code, bank_name, account_number, account_holder, is_default, created_at, updated_at
+		FROM payout_accounts
+		WHERE user_id = $1 AND is_active = TRUE
+		ORDER BY is_default DESC, created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payout accounts: %v", err)
+	}
+	defer rows.Close()
+
+	accounts := []models.PayoutAccount{}
+	for rows.Next() {
+		var a models.PayoutAccount
+		if err := rows.Scan(&a.ID, &a.UserID, &a.BankCode, &a.BankName, &a.AccountNumber, &a.AccountHolder,
+			&a.IsDefault, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan payout account: %v", err)
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// AddAccount saves a bank account. The first account of a user becomes the default.
+func (s *PayoutService) AddAccount(userID string, req models.PayoutAccountRequest) (*models.PayoutAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var hasDefault bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM payout_accounts WHERE user_id = $1 AND is_active = TRUE AND is_default = TRUE)
+	`, userID).Scan(&hasDefault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check default payout account: %v", err)
+	}
+
+	isDefault := req.IsDefault || !hasDefault
+	if isDefault && hasDefault {
+		if _, err := tx.Exec(`UPDATE payout_accounts SET is_default = FALSE, updated_at = NOW() WHERE user_id = $1`, userID); err != nil {
+			return nil, fmt.Errorf("failed to reset default payout account: %v", err)
+		}
+	}
+
+	a := models.PayoutAccount{
+		UserID:        userID,
+		BankCode:      strings.ToLower(strings.TrimSpace(req.BankCode)),
+		BankName:      strings.TrimSpace(req.BankName),
+		AccountNumber: strings.TrimSpace(req.AccountNumber),
+		AccountHolder: strings.TrimSpace(req.AccountHolder),
+		IsDefault:     isDefault,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO payout_accounts (user_id, bank_code, bank_name, account_number, account_holder, is_default, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, a.UserID, a.BankCode, a.BankName, a.AccountNumber, a.AccountHolder, a.IsDefault).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payout account: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return &a, nil
+}
+
+// DeleteAccount deactivates a bank account; payouts already requested to it are not affected
+func (s *PayoutService) DeleteAccount(userID, accountID string) error {
+	result, err := s.db.Exec(`
+		UPDATE payout_accounts SET is_active = FALSE, is_default = FALSE, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND is_active = TRUE
+	`, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete payout account: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPayoutAccountNotFound
+	}
+	return nil
+}
+
+// Balance returns the wallet balance and the earnings of every group the user owns
+func (s *PayoutService) Balance(userID string) (*models.PayoutBalance, error) {
+	wallet, err := s.ledger.WalletBalance(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet balance: %v", err)
+	}
+	withdrawable, err := withdrawableWallet(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT g.id, g.name, COALESCE(a.balance, 0)
+		FROM groups g
+		LEFT JOIN ledger_accounts a ON a.account_type = $1 AND a.owner_id = g.id
+		WHERE g.owner_id = $2 AND (g.is_deleted IS NULL OR g.is_deleted = false)
+		ORDER BY g.created_at DESC
+	`, ledger.AccountGroupEscrow, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group earnings: %v", err)
+	}
+	defer rows.Close()
+
+	balance := &models.PayoutBalance{
+		Wallet:        wallet,
+		Withdrawable:  withdrawable,
+		Groups:        []models.GroupEarnings{},
+		MinimumAmount: s.config.MinimumAmount,
+		Fee:           s.config.Fee,
+		FeePercentage: s.config.FeePercentage,
+	}
+	for rows.Next() {
+		var g models.GroupEarnings
+		var minor int64
+		if err := rows.Scan(&g.GroupID, &g.GroupName, &minor); err != nil {
+			return nil, fmt.Errorf("failed to scan group earnings: %v", err)
+		}
+		g.Balance = money.FromMinor(minor)
+		balance.Groups = append(balance.Groups, g)
+	}
+	return balance, rows.Err()
+}
+
+// withdrawableWallet returns the part of a wallet balance that came in through the payment gateway: top-ups
+// settled by the gateway and refunds of payments made by payment link, less what was already withdrawn. Opening balances and any other
+// credit can be spent on groups but not sent to a bank account. Spending is taken from the backed part
+// last, so the result is capped by the wallet balance.
+func withdrawableWallet(q database.Queryer, userID string) (money.Amount, error) {
+	var balance, backed int64
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(a.balance), 0),
+		       COALESCE(SUM(CASE
+		           WHEN e.entry_type = $3 AND t.payment_link_id IS NOT NULL THEN p.amount
+		           WHEN e.entry_type = $4 AND o.payment_link_id IS NOT NULL THEN p.amount
+		           WHEN e.entry_type IN ($5, $6) THEN p.amount
+		           ELSE 0
+		       END), 0)
+		FROM ledger_accounts a
+		LEFT JOIN ledger_postings p ON p.account_id = a.id
+		LEFT JOIN ledger_entries e ON e.id = p.entry_id
+		LEFT JOIN transactions t ON t.id = e.transaction_id
+		LEFT JOIN transactions o ON o.id = t.related_transaction_id
+		WHERE a.account_type = $1 AND a.owner_id = $2
+	`, ledger.AccountUserWallet, userID, ledger.EntryTopUp, ledger.EntryRefund, ledger.EntryPayoutHold,
+		ledger.EntryPayoutRelease).Scan(&balance, &backed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get withdrawable balance: %v", err)
+	}
+	return money.FromMinor(max(0, min(balance, backed))), nil
+}
+
+// RequestPayout creates a withdrawal request and holds its amount until an admin reviews it
+func (s *PayoutService) RequestPayout(userID string, req models.PayoutCreateRequest) (*models.Payout, error) {
+	amount := req.Amount / money.Whole(1) * money.Whole(1)
+	if amount < s.config.MinimumAmount {
+		return nil, ErrPayoutBelowMinimum
+	}
+	fee := s.Fee(amount)
+	if fee >= amount {
+		return nil, ErrPayoutFeeTooHigh
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	p := models.Payout{
+		UserID:          userID,
+		GroupID:         req.GroupID,
+		PayoutAccountID: req.PayoutAccountID,
+		Amount:          amount,
+		Fee:             fee,
+		NetAmount:       amount - fee,
+		Status:          models.PayoutRequested,
+	}
+	err = tx.QueryRow(`
+		SELECT bank_name, account_number, account_holder FROM payout_accounts
+		WHERE id = $1 AND user_id = $2 AND is_active = TRUE
+	`, req.PayoutAccountID, userID).Scan(&p.BankName, &p.AccountNumber, &p.AccountHolder)
+	if err == sql.ErrNoRows {
+		return nil, ErrPayoutAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payout account: %v", err)
+	}
+
+	if req.GroupID != nil {
+		var ownerID string
+		err = tx.QueryRow(`
+			SELECT owner_id, name FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+		`, *req.GroupID).Scan(&ownerID, &p.GroupName)
+		if err == sql.ErrNoRows || (err == nil && ownerID != userID) {
+			return nil, ErrNotGroupOwner
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group: %v", err)
+		}
+	} else {
+		// Lock the wallet so concurrent requests cannot withdraw the same backed balance twice
+		_, err = tx.Exec(`
+			SELECT id FROM ledger_accounts WHERE account_type = $1 AND owner_id = $2 FOR UPDATE
+		`, ledger.AccountUserWallet, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock wallet: %v", err)
+		}
+		withdrawable, err := withdrawableWallet(tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if amount > withdrawable {
+			return nil, ErrPayoutNotWithdrawable
+		}
+	}
+
+	description := fmt.Sprintf("Penarikan dana ke %s %s", p.BankName, maskAccountNumber(p.AccountNumber))
+	if p.GroupName != "" {
+		description = fmt.Sprintf("Penarikan pendapatan grup %s ke %s %s", p.GroupName, p.BankName, maskAccountNumber(p.AccountNumber))
+	}
+
+	var transactionID string
+	err = tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_method, status, created_at, updated_at)
+		VALUES ($1, $2, 'withdrawal', $3, $4, 0, 0, $5, 'bank_transfer', 'pending', NOW(), NOW())
+		RETURNING id
+	`, userID, req.GroupID, amount, fee, description).Scan(&transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create withdrawal transaction: %v", err)
+	}
+	p.TransactionID = &transactionID
+
+	op := ledger.Operation{
+		UserID:        userID,
+		TransactionID: transactionID,
+		Amount:        amount,
+		Description:   description,
+	}
+	if req.GroupID != nil {
+		op.GroupID = *req.GroupID
+	}
+	movement, err := s.ledger.HoldPayout(tx, op)
+	if err != nil {
+		return nil, err
+	}
+	if movement != nil {
+		_, err = tx.Exec(`
+			UPDATE transactions SET balance_before = $1, balance_after = $2 WHERE id = $3
+		`, movement.BalanceBefore, movement.BalanceAfter, transactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update transaction balance: %v", err)
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO payouts (user_id, group_id, payout_account_id, transaction_id, amount, fee, net_amount,
+		                     bank_name, account_number, account_holder, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, p.UserID, p.GroupID, p.PayoutAccountID, p.TransactionID, p.Amount, p.Fee, p.NetAmount,
+		p.BankName, p.AccountNumber, p.AccountHolder, p.Status).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payout: %v", err)
+	}
+
+	err = notify(tx, userID, "payout", "Penarikan Dana Diajukan",
+		fmt.Sprintf("Penarikan %s ke %s %s sedang ditinjau admin.", p.NetAmount.Format(), p.BankName, maskAccountNumber(p.AccountNumber)),
+		"/payouts", "Lihat Penarikan")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Payout requested: ID=%s, UserID=%s, Amount=%s, Fee=%s\n", p.ID, userID, p.Amount, p.Fee)
+	return &p, nil
+}
+
+// ListUserPayouts returns the payouts of a user, newest first
+func (s *PayoutService) ListUserPayouts(userID string) ([]models.Payout, error) {
+	return s.listPayouts(`WHERE p.user_id = $1 ORDER BY p.created_at DESC`, userID)
+}
+
+// ListPayouts returns payouts for the admin queue, optionally filtered by status, oldest first
+func (s *PayoutService) ListPayouts(status string, limit, offset int) ([]models.Payout, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM payouts WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count payouts: %v", err)
+	}
+
+	payouts, err := s.listPayouts(`WHERE ($1 = '' OR p.status = $1) ORDER BY p.created_at ASC LIMIT $2 OFFSET $3`,
+		status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return payouts, total, nil
+}
+
+func (s *PayoutService) listPayouts(where string, args ...interface{}) ([]models.Payout, error) {
+	rows, err := s.db.Query(`
+		SELECT p.id, p.user_id, p.group_id, p.payout_account_id, p.transaction_id, p.amount, p.fee, p.net_amount,
+		       p.bank_name, p.account_number, p.account_holder, p.status, p.reviewed_by, p.reviewed_at,
+		       p.paid_at, p.paid_reference, p.note, p.created_at, p.updated_at,
+		       COALESCE(g.name, ''), COALESCE(u.full_name, '')
+		FROM payouts p
+		LEFT JOIN groups g ON g.id = p.group_id
+		LEFT JOIN users u ON u.id = p.user_id
+		`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payouts: %v", err)
+	}
+	defer rows.Close()
+
+	payouts := []models.Payout{}
+	for rows.Next() {
+		var p models.Payout
+		err := rows.Scan(&p.ID, &p.UserID, &p.GroupID, &p.PayoutAccountID, &p.TransactionID, &p.Amount, &p.Fee, &p.NetAmount,
+			&p.BankName, &p.AccountNumber, &p.AccountHolder, &p.Status, &p.ReviewedBy, &p.ReviewedAt,
+			&p.PaidAt, &p.PaidReference, &p.Note, &p.CreatedAt, &p.UpdatedAt,
+			&p.GroupName, &p.UserName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payout: %v", err)
+		}
+		payouts = append(payouts, p)
+	}
+	return payouts, rows.Err()
+}
+
+// Approve accepts a requested payout; the money stays held until it is marked paid
+func (s *PayoutService) Approve(payoutID, adminID, note string) (*models.Payout, error) {
+	return s.transition(payoutID, func(tx *sql.Tx, p *models.Payout) error {
+		if p.Status != models.PayoutRequested {
+			return ErrPayoutInvalidStatus
+		}
+		p.Status = models.PayoutApproved
+		p.ReviewedBy = &adminID
+		p.ReviewedAt = timePtr(time.Now())
+		setNote(p, note)
+		return notify(tx, p.UserID, "payout", "Penarikan Dana Disetujui",
+			fmt.Sprintf("Penarikan %s disetujui dan akan segera ditransfer ke %s %s.", p.NetAmount.Format(), p.BankName, maskAccountNumber(p.AccountNumber)),
+			"/payouts", "Lihat Penarikan")
+	})
+}
+
+// Reject declines a payout that was not transferred yet and returns the held money
+func (s *PayoutService) Reject(payoutID, adminID, note string) (*models.Payout, error) {
+	return s.transition(payoutID, func(tx *sql.Tx, p *models.Payout) error {
+		if p.Status != models.PayoutRequested && p.Status != models.PayoutApproved {
+			return ErrPayoutInvalidStatus
+		}
+
+		op := s.operation(p, fmt.Sprintf("Penarikan dana %s ditolak", p.ID))
+		if _, err := s.ledger.ReleasePayout(tx, op); err != nil {
+			return fmt.Errorf("failed to release payout: %v", err)
+		}
+		if err := setTransactionStatus(tx, p.TransactionID, "failed"); err != nil {
+			return err
+		}
+
+		p.Status = models.PayoutRejected
+		p.ReviewedBy = &adminID
+		p.ReviewedAt = timePtr(time.Now())
+		setNote(p, note)
+
+		message := fmt.Sprintf("Penarikan %s ditolak dan dana telah dikembalikan.", p.Amount.Format())
+		if note != "" {
+			message += " Catatan: " + note
+		}
+		return notify(tx, p.UserID, "payout", "Penarikan Dana Ditolak", message, "/payouts", "Lihat Penarikan")
+	})
+}
+
+// MarkPaid records the bank transfer of an approved payout
+func (s *PayoutService) MarkPaid(payoutID, adminID, reference, note string) (*models.Payout, error) {
+	return s.transition(payoutID, func(tx *sql.Tx, p *models.Payout) error {
+		if p.Status != models.PayoutApproved {
+			return ErrPayoutInvalidStatus
+		}
+
+		op := s.operation(p, fmt.Sprintf("Penarikan dana %s ditransfer (ref %s)", p.ID, reference))
+		op.AdminFee = p.Fee
+		if _, err := s.ledger.PayOut(tx, op); err != nil {
+			return fmt.Errorf("failed to book payout: %v", err)
+		}
+		if err := setTransactionStatus(tx, p.TransactionID, "completed"); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p.Status = models.PayoutPaid
+		p.PaidAt = &now
+		p.PaidReference = &reference
+		if p.ReviewedBy == nil {
+			p.ReviewedBy = &adminID
+			p.ReviewedAt = &now
+		}
+		setNote(p, note)
+		return notify(tx, p.UserID, "payout", "Penarikan Dana Berhasil",
+			fmt.Sprintf("%s telah ditransfer ke %s %s.", p.NetAmount.Format(), p.BankName, maskAccountNumber(p.AccountNumber)),
+			"/payouts", "Lihat Penarikan")
+	})
+}
+
+// transition locks a payout, applies change and saves the result in one DB transaction
+func (s *PayoutService) transition(payoutID string, change func(tx *sql.Tx, p *models.Payout) error) (*models.Payout, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var p models.Payout
+	err = tx.QueryRow(`
+		SELECT id, user_id, group_id, payout_account_id, transaction_id, amount, fee, net_amount,
+		       bank_name, account_number, account_holder, status, reviewed_by, reviewed_at,
+		       paid_at, paid_reference, note, created_at, updated_at
+		FROM payouts
+		WHERE id = $1
+		FOR UPDATE
+	`, payoutID).Scan(&p.ID, &p.UserID, &p.GroupID, &p.PayoutAccountID, &p.TransactionID, &p.Amount, &p.Fee, &p.NetAmount,
+		&p.BankName, &p.AccountNumber, &p.AccountHolder, &p.Status, &p.ReviewedBy, &p.ReviewedAt,
+		&p.PaidAt, &p.PaidReference, &p.Note, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payout: %v", err)
+	}
+
+	previous := p.Status
+	if err := change(tx, &p); err != nil {
+		return nil, err
+	}
+
+	p.UpdatedAt = time.Now()
+	_, err = tx.Exec(`
+		UPDATE payouts
+		SET status = $1, reviewed_by = $2, reviewed_at = $3, paid_at = $4, paid_reference = $5, note = $6, updated_at = $7
+		WHERE id = $8
+	`, p.Status, p.ReviewedBy, p.ReviewedAt, p.PaidAt, p.PaidReference, p.Note, p.UpdatedAt, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payout: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Payout %s: %s -> %s\n", p.ID, previous, p.Status)
+	return &p, nil
+}
+
+func (s *PayoutService) operation(p *models.Payout, description string) ledger.Operation {
+	op := ledger.Operation{
+		UserID:      p.UserID,
+		Amount:      p.Amount,
+		Description: description,
+	}
+	if p.TransactionID != nil {
+		op.TransactionID = *p.TransactionID
+	}
+	if p.GroupID != nil {
+		op.GroupID = *p.GroupID
+	}
+	return op
+}
+
+func setTransactionStatus(tx *sql.Tx, transactionID *string, status string) error {
+	if transactionID == nil {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2`, status, *transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to update withdrawal transaction: %v", err)
+	}
+	return nil
+}
+
+func setNote(p *models.Payout, note string) {
+	if note != "" {
+		p.Note = &note
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// maskAccountNumber keeps the last four digits of a bank account number
+func maskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return "****" + number[len(number)-4:]
+}
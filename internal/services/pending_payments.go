@@ -0,0 +1,158 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"salome-be/internal/service"
+)
+
+// PendingPaymentStats summarizes one pass over pending payment link transactions
+type PendingPaymentStats struct {
+	Processed int `json:"processed"`
+	Updated   int `json:"updated"`
+	Failed    int `json:"failed"`
+}
+
+// PendingPaymentPoller asks the payment gateway for the status of pending payment link transactions and applies
+// the result, for payments whose webhook never arrived
+type PendingPaymentPoller struct {
+	db           *sql.DB
+	gateway      service.PaymentGateway
+	settlement   *PaymentSettlement
+	stateMachine *StateMachineService
+}
+
+func NewPendingPaymentPoller(db *sql.DB) *PendingPaymentPoller {
+	return &PendingPaymentPoller{
+		db:           db,
+		gateway:      service.NewPaymentGateway(),
+		settlement:   NewPaymentSettlement(db),
+		stateMachine: NewStateMachineService(db),
+	}
+}
+
+// PollAll checks every pending transaction that has a payment reference
+func (p *PendingPaymentPoller) PollAll() (*PendingPaymentStats, error) {
+	rows, err := p.db.Query(`
+		SELECT id, payment_reference, payment_link_id
+		FROM transactions
+		WHERE status = 'pending' AND payment_reference IS NOT NULL
+		ORDER BY created_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending transactions: %v", err)
+	}
+
+	type pending struct {
+		id, paymentReference string
+		paymentLinkID        *string
+	}
+	var transactions []pending
+	for rows.Next() {
+		var t pending
+		if err := rows.Scan(&t.id, &t.paymentReference, &t.paymentLinkID); err != nil {
+			fmt.Printf("❌ [ERROR] Failed to scan transaction: %v\n", err)
+			continue
+		}
+		transactions = append(transactions, t)
+	}
+	rows.Close()
+
+	fmt.Printf("📊 [STATS] Found %d pending transactions to process\n", len(transactions))
+
+	stats := &PendingPaymentStats{}
+	for _, t := range transactions {
+		stats.Processed++
+
+		gatewayStatus, err := p.gateway.GetTransactionStatus(StatusLookupID(t.paymentReference, t.paymentLinkID))
+		if err != nil {
+			fmt.Printf("❌ [ERROR] Failed to check %s status for payment_reference %s: %v\n", p.gateway.Name(), t.paymentReference, err)
+			stats.Failed++
+			continue
+		}
+
+		newStatus := LocalPaymentStatus(gatewayStatus.TransactionStatus)
+
+		result, err := p.apply(t.paymentReference, newStatus, gatewayStatus.PaymentType)
+		if err != nil {
+			fmt.Printf("❌ [ERROR] Failed to update transaction %s: %v\n", t.id, err)
+			stats.Failed++
+			continue
+		}
+
+		if result.Applied && newStatus == "success" && result.TransactionType == "group_payment" && result.GroupID != nil {
+			if err := p.MarkGroupPaidIfComplete(SystemActor(t.paymentReference), *result.GroupID); err != nil {
+				fmt.Printf("❌ [ERROR] Failed to update group status: %v\n", err)
+			}
+		}
+
+		stats.Updated++
+		fmt.Printf("✅ [SUCCESS] Transaction %s updated to %s\n", t.id, newStatus)
+	}
+
+	fmt.Printf("🎉 [COMPLETE] Processed %d transactions: %d updated, %d failed\n", stats.Processed, stats.Updated, stats.Failed)
+	return stats, nil
+}
+
+// StatusLookupID returns the ID the gateway status lookup expects: the payment link ID when the
+// transaction has one, otherwise its payment reference
+func StatusLookupID(paymentReference string, paymentLinkID *string) string {
+	if paymentLinkID != nil && *paymentLinkID != "" {
+		return *paymentLinkID
+	}
+	return paymentReference
+}
+
+// LocalPaymentStatus maps a gateway transaction status to the status stored on transactions
+func LocalPaymentStatus(gatewayStatus string) string {
+	switch gatewayStatus {
+	case "settlement", "capture":
+		return "success"
+	case "expire":
+		return "expired"
+	case "deny", "cancel", "failed":
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
+// apply runs the settlement of one transaction in its own DB transaction
+func (p *PendingPaymentPoller) apply(paymentReference, status, paymentType string) (*SettlementResult, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := p.settlement.Apply(tx, SystemActor(paymentReference), paymentReference, status, paymentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// MarkGroupPaidIfComplete activates the group and its paid members once every member has paid
+func (p *PendingPaymentPoller) MarkGroupPaidIfComplete(actor Actor, groupID string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT id FROM groups WHERE id = $1 FOR UPDATE`, groupID); err != nil {
+		return fmt.Errorf("failed to lock group: %v", err)
+	}
+	if err := p.stateMachine.checkAndActivateGroup(tx, actor, groupID); err != nil {
+		return fmt.Errorf("failed to activate group: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
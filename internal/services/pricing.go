@@ -0,0 +1,25 @@
+package services
+
+import (
+	"fmt"
+
+	"salome-be/internal/database"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+)
+
+// AppSeatPrice prices a seat in a group of maxMembers for an app, using the app's fee settings
+func AppSeatPrice(q database.Queryer, engine *pricing.Engine, appID string, maxMembers int) (*pricing.Breakdown, error) {
+	var appPrice money.Amount
+	var fees pricing.AppFees
+	err := q.QueryRow(`
+		SELECT COALESCE(total_price, 0), admin_fee_percentage, admin_fee_fixed, admin_fee_min, admin_fee_max
+		FROM apps WHERE id = $1
+	`, appID).Scan(&appPrice, &fees.Percentage, &fees.Fixed, &fees.Min, &fees.Max)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app pricing: %v", err)
+	}
+
+	breakdown := engine.Seat(appPrice, maxMembers, fees)
+	return &breakdown, nil
+}
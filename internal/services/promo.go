@@ -0,0 +1,519 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"salome-be/internal/database"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPromoNotFound      = errors.New("promo code not found")
+	ErrPromoInactive      = errors.New("promo code is not active")
+	ErrPromoNotStarted    = errors.New("promo code is not valid yet")
+	ErrPromoExpired       = errors.New("promo code has expired")
+	ErrPromoUsageLimit    = errors.New("promo code has been fully used")
+	ErrPromoUserLimit     = errors.New("promo code usage limit reached for this user")
+	ErrPromoNotApplicable = errors.New("promo code does not apply to this group")
+	ErrPromoFirstTimeOnly = errors.New("promo code is only for first-time users")
+	ErrPromoMinAmount     = errors.New("seat price is below the promo code minimum")
+	ErrPromoCodeExists    = errors.New("promo code already exists")
+	ErrInvalidPromoCode   = errors.New("invalid promo code")
+)
+
+// promoMinimumCharge is what a discounted seat costs at least: payment links and wallet payments cannot be zero
+var promoMinimumCharge = money.Whole(1000)
+
+// IsPromoError reports whether err means a promo code cannot be used, as opposed to a server error
+func IsPromoError(err error) bool {
+	for _, target := range []error{ErrPromoNotFound, ErrPromoInactive, ErrPromoNotStarted, ErrPromoExpired, ErrPromoUsageLimit,
+		ErrPromoUserLimit, ErrPromoNotApplicable, ErrPromoFirstTimeOnly, ErrPromoMinAmount} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// PromoQuote is a seat price with a promo code applied. The discount comes out of the admin fee first; the
+// rest is subsidized by the platform into the group escrow so the owner still receives the full seat price.
+type PromoQuote struct {
+	PromoCodeID string       `json:"promo_code_id"`
+	Code        string       `json:"code"`
+	Subtotal    money.Amount `json:"subtotal"`
+	Discount    money.Amount `json:"discount"`
+	FeeDiscount money.Amount `json:"-"`
+	Subsidy     money.Amount `json:"-"`
+	AdminFee    money.Amount `json:"admin_fee"`
+	Total       money.Amount `json:"total"`
+}
+
+// PromoService validates promo codes and records their redemptions
+type PromoService struct {
+	db     *sql.DB
+	ledger *ledger.Ledger
+}
+
+func NewPromoService(db *sql.DB) *PromoService {
+	return &PromoService{
+		db:     db,
+		ledger: ledger.New(db),
+	}
+}
+
+const promoCodeColumns = `id, code, description, discount_type, discount_value, max_discount, min_amount, usage_limit,
+	per_user_limit, app_ids, first_time_only, starts_at, ends_at, is_active, created_by, created_at, updated_at`
+
+func scanPromoCode(row rowScanner) (*models.PromoCode, error) {
+	var p models.PromoCode
+	err := row.Scan(&p.ID, &p.Code, &p.Description, &p.DiscountType, &p.DiscountValue, &p.MaxDiscount, &p.MinAmount,
+		&p.UsageLimit, &p.PerUserLimit, pq.Array(&p.AppIDs), &p.FirstTimeOnly, &p.StartsAt, &p.EndsAt, &p.IsActive,
+		&p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if p.AppIDs == nil {
+		p.AppIDs = []string{}
+	}
+	return &p, nil
+}
+
+// Quote checks that userID may use code on a seat of groupID and returns the discounted price
+func (s *PromoService) Quote(code, userID, groupID string, price *SeatPrice) (*PromoQuote, error) {
+	promo, err := scanPromoCode(s.db.QueryRow(`
+		SELECT `+promoCodeColumns+` FROM promo_codes WHERE UPPER(code) = UPPER($1)
+	`, strings.TrimSpace(code)))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %v", err)
+	}
+
+	if err := s.check(s.db, promo, userID, groupID, price.Total, time.Now()); err != nil {
+		return nil, err
+	}
+	return quotePromo(promo, price), nil
+}
+
+// Reserve records the redemption of quote on transactionID inside tx. The promo code row is locked and the
+// limits are checked again, so concurrent checkouts cannot use a code more often than allowed. A settled
+// redemption is booked right away; a pending one waits for Confirm or Release.
+func (s *PromoService) Reserve(tx *sql.Tx, quote *PromoQuote, userID, groupID, transactionID string, settled bool) error {
+	promo, err := scanPromoCode(tx.QueryRow(`
+		SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1 FOR UPDATE
+	`, quote.PromoCodeID))
+	if err == sql.ErrNoRows {
+		return ErrPromoNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock promo code: %v", err)
+	}
+	if err := s.check(tx, promo, userID, groupID, quote.Subtotal, time.Now()); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO promo_redemptions (promo_code_id, user_id, group_id, transaction_id, original_amount, discount_amount,
+		                               fee_discount, subsidy_amount, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+	`, quote.PromoCodeID, userID, groupID, transactionID, quote.Subtotal, quote.Discount, quote.FeeDiscount, quote.Subsidy,
+		models.PromoRedemptionPending)
+	if err != nil {
+		return fmt.Errorf("failed to record promo redemption: %v", err)
+	}
+
+	if settled {
+		return s.Confirm(tx, transactionID)
+	}
+	return nil
+}
+
+// Confirm marks the pending redemption of a settled payment redeemed and books the platform's subsidy
+func (s *PromoService) Confirm(tx *sql.Tx, transactionID string) error {
+	var userID string
+	var groupID *string
+	var subsidy money.Amount
+	err := tx.QueryRow(`
+		UPDATE promo_redemptions
+		SET status = $1, redeemed_at = NOW(), updated_at = NOW()
+		WHERE transaction_id = $2 AND status = $3
+		RETURNING user_id, group_id, subsidy_amount
+	`, models.PromoRedemptionRedeemed, transactionID, models.PromoRedemptionPending).Scan(&userID, &groupID, &subsidy)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to confirm promo redemption: %v", err)
+	}
+	if subsidy <= 0 {
+		return nil
+	}
+
+	op := ledger.Operation{
+		UserID:        userID,
+		TransactionID: transactionID,
+		Amount:        subsidy,
+		Description:   "Promo discount subsidy",
+	}
+	if groupID != nil {
+		op.GroupID = *groupID
+	}
+	if _, err := s.ledger.SubsidizePromo(tx, op); err != nil {
+		return fmt.Errorf("failed to book promo subsidy: %v", err)
+	}
+	return nil
+}
+
+// Release gives the use of a promo code back when its payment failed or expired
+func (s *PromoService) Release(tx *sql.Tx, transactionID string) error {
+	_, err := tx.Exec(`
+		UPDATE promo_redemptions SET status = $1, updated_at = NOW()
+		WHERE transaction_id = $2 AND status = $3
+	`, models.PromoRedemptionReleased, transactionID, models.PromoRedemptionPending)
+	if err != nil {
+		return fmt.Errorf("failed to release promo redemption: %v", err)
+	}
+	return nil
+}
+
+// reverseSubsidy takes the refunded share of a redemption's subsidy back from the group escrow. A full refund
+// also gives the use of the code back.
+func (s *PromoService) reverseSubsidy(tx *sql.Tx, transactionID string, ratio float64) error {
+	var id, userID string
+	var groupID *string
+	var subsidy money.Amount
+	err := tx.QueryRow(`
+		SELECT id, user_id, group_id, subsidy_amount FROM promo_redemptions
+		WHERE transaction_id = $1 AND status = $2
+		FOR UPDATE
+	`, transactionID, models.PromoRedemptionRedeemed).Scan(&id, &userID, &groupID, &subsidy)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get promo redemption: %v", err)
+	}
+
+	if amount := money.Whole(int64(math.Floor(subsidy.Major() * ratio))); amount > 0 {
+		op := ledger.Operation{
+			UserID:        userID,
+			TransactionID: transactionID,
+			Amount:        amount,
+			Description:   "Promo discount subsidy refunded",
+		}
+		if groupID != nil {
+			op.GroupID = *groupID
+		}
+		if _, err := s.ledger.ReversePromoSubsidy(tx, op); err != nil {
+			return fmt.Errorf("failed to reverse promo subsidy: %v", err)
+		}
+	}
+
+	if ratio >= 1 {
+		_, err = tx.Exec(`UPDATE promo_redemptions SET status = $1, updated_at = NOW() WHERE id = $2`, models.PromoRedemptionReleased, id)
+		if err != nil {
+			return fmt.Errorf("failed to release promo redemption: %v", err)
+		}
+	}
+	return nil
+}
+
+// check validates a promo code for a user and group. Pending and redeemed uses count against the limits.
+func (s *PromoService) check(q database.Queryer, promo *models.PromoCode, userID, groupID string, amount money.Amount, now time.Time) error {
+	if !promo.IsActive {
+		return ErrPromoInactive
+	}
+	if promo.StartsAt != nil && now.Before(*promo.StartsAt) {
+		return ErrPromoNotStarted
+	}
+	if promo.EndsAt != nil && !now.Before(*promo.EndsAt) {
+		return ErrPromoExpired
+	}
+	if amount < promo.MinAmount {
+		return ErrPromoMinAmount
+	}
+
+	if len(promo.AppIDs) > 0 {
+		var appID sql.NullString
+		if err := q.QueryRow(`SELECT app_id FROM groups WHERE id = $1`, groupID).Scan(&appID); err != nil {
+			return fmt.Errorf("failed to get group app: %v", err)
+		}
+		allowed := false
+		for _, id := range promo.AppIDs {
+			if appID.Valid && id == appID.String {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrPromoNotApplicable
+		}
+	}
+
+	if promo.FirstTimeOnly {
+		var hasPaid bool
+		err := q.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 FROM transactions
+				WHERE user_id = $1 AND type = 'group_payment' AND status IN ('success', 'completed')
+			)
+		`, userID).Scan(&hasPaid)
+		if err != nil {
+			return fmt.Errorf("failed to check payment history: %v", err)
+		}
+		if hasPaid {
+			return ErrPromoFirstTimeOnly
+		}
+	}
+
+	var used, usedByUser int
+	err := q.QueryRow(`
+		SELECT COUNT(*), COUNT(CASE WHEN user_id = $2 THEN 1 END)
+		FROM promo_redemptions
+		WHERE promo_code_id = $1 AND status <> $3
+	`, promo.ID, userID, models.PromoRedemptionReleased).Scan(&used, &usedByUser)
+	if err != nil {
+		return fmt.Errorf("failed to count promo redemptions: %v", err)
+	}
+	if promo.UsageLimit != nil && used >= *promo.UsageLimit {
+		return ErrPromoUsageLimit
+	}
+	if promo.PerUserLimit != nil && usedByUser >= *promo.PerUserLimit {
+		return ErrPromoUserLimit
+	}
+	return nil
+}
+
+// quotePromo applies a promo code to a seat price. The discounted total is whole rupiah and never below
+// promoMinimumCharge.
+func quotePromo(promo *models.PromoCode, price *SeatPrice) *PromoQuote {
+	discount := money.FromMajor(promo.DiscountValue)
+	if promo.DiscountType == models.PromoDiscountPercentage {
+		discount = money.Whole(int64(math.Floor(price.Total.Major() * promo.DiscountValue / 100)))
+		if promo.MaxDiscount != nil && discount > *promo.MaxDiscount {
+			discount = *promo.MaxDiscount
+		}
+	}
+
+	total := money.Max(price.Total-discount, money.Min(promoMinimumCharge, price.Total)).Ceil()
+	discount = money.Max(price.Total-total, 0)
+	feeDiscount := money.Min(discount, price.AdminFee)
+
+	return &PromoQuote{
+		PromoCodeID: promo.ID,
+		Code:        promo.Code,
+		Subtotal:    price.Total,
+		Discount:    discount,
+		FeeDiscount: feeDiscount,
+		Subsidy:     discount - feeDiscount,
+		AdminFee:    price.AdminFee - feeDiscount,
+		Total:       price.Total - discount,
+	}
+}
+
+// ListPromoCodes returns promo codes with their usage, newest first
+func (s *PromoService) ListPromoCodes(activeOnly bool, limit, offset int) ([]models.PromoCode, int, error) {
+	where := ""
+	if activeOnly {
+		where = "WHERE is_active = TRUE"
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM promo_codes ` + where).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count promo codes: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes `+where+`
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query promo codes: %v", err)
+	}
+	defer rows.Close()
+
+	promos := []models.PromoCode{}
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan promo code: %v", err)
+		}
+		promos = append(promos, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range promos {
+		if err := s.loadUsage(&promos[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return promos, total, nil
+}
+
+// GetPromoCode returns a promo code with its usage
+func (s *PromoService) GetPromoCode(id string) (*models.PromoCode, error) {
+	promo, err := scanPromoCode(s.db.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %v", err)
+	}
+	if err := s.loadUsage(promo); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+func (s *PromoService) loadUsage(promo *models.PromoCode) error {
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM promo_redemptions WHERE promo_code_id = $1 AND status <> $2
+	`, promo.ID, models.PromoRedemptionReleased).Scan(&promo.UsedCount)
+	if err != nil {
+		return fmt.Errorf("failed to count promo redemptions: %v", err)
+	}
+	return nil
+}
+
+// CreatePromoCode adds a promo code. Codes are stored upper case and are unique regardless of case.
+func (s *PromoService) CreatePromoCode(req models.PromoCodeRequest, adminID string) (*models.PromoCode, error) {
+	if err := validatePromoRequest(req); err != nil {
+		return nil, err
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO promo_codes (code, description, discount_type, discount_value, max_discount, min_amount, usage_limit,
+		                         per_user_limit, app_ids, first_time_only, starts_at, ends_at, is_active, created_by,
+		                         created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW())
+		RETURNING id
+	`, strings.ToUpper(strings.TrimSpace(req.Code)), nullIfEmpty(req.Description), req.DiscountType, req.DiscountValue,
+		req.MaxDiscount, req.MinAmount, req.UsageLimit, req.PerUserLimit, promoAppIDs(req.AppIDs), req.FirstTimeOnly,
+		req.StartsAt, req.EndsAt, isActive, nullIfEmpty(adminID)).Scan(&id)
+	if isUniqueViolation(err) {
+		return nil, ErrPromoCodeExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create promo code: %v", err)
+	}
+	return s.GetPromoCode(id)
+}
+
+// UpdatePromoCode replaces the settings of a promo code. Existing redemptions are kept.
+func (s *PromoService) UpdatePromoCode(id string, req models.PromoCodeRequest) (*models.PromoCode, error) {
+	if err := validatePromoRequest(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE promo_codes
+		SET code = $1, description = $2, discount_type = $3, discount_value = $4, max_discount = $5, min_amount = $6,
+		    usage_limit = $7, per_user_limit = $8, app_ids = $9, first_time_only = $10, starts_at = $11, ends_at = $12,
+		    is_active = COALESCE($13, is_active), updated_at = NOW()
+		WHERE id = $14
+	`, strings.ToUpper(strings.TrimSpace(req.Code)), nullIfEmpty(req.Description), req.DiscountType, req.DiscountValue,
+		req.MaxDiscount, req.MinAmount, req.UsageLimit, req.PerUserLimit, promoAppIDs(req.AppIDs), req.FirstTimeOnly,
+		req.StartsAt, req.EndsAt, req.IsActive, id)
+	if isUniqueViolation(err) {
+		return nil, ErrPromoCodeExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update promo code: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrPromoNotFound
+	}
+	return s.GetPromoCode(id)
+}
+
+// DeactivatePromoCode stops a promo code from being used; it stays referenced by its redemptions
+func (s *PromoService) DeactivatePromoCode(id string) error {
+	result, err := s.db.Exec(`UPDATE promo_codes SET is_active = FALSE, updated_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate promo code: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPromoNotFound
+	}
+	return nil
+}
+
+// ListRedemptions returns the uses of a promo code, newest first
+func (s *PromoService) ListRedemptions(promoCodeID string, limit, offset int) ([]models.PromoRedemption, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM promo_redemptions WHERE promo_code_id = $1`, promoCodeID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count promo redemptions: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT r.id, r.promo_code_id, r.user_id, r.group_id, r.transaction_id, r.original_amount, r.discount_amount,
+		       r.fee_discount, r.subsidy_amount, r.status, r.redeemed_at, r.created_at,
+		       COALESCE(u.full_name, ''), COALESCE(g.name, '')
+		FROM promo_redemptions r
+		LEFT JOIN users u ON u.id = r.user_id
+		LEFT JOIN groups g ON g.id = r.group_id
+		WHERE r.promo_code_id = $1
+		ORDER BY r.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, promoCodeID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query promo redemptions: %v", err)
+	}
+	defer rows.Close()
+
+	redemptions := []models.PromoRedemption{}
+	for rows.Next() {
+		var r models.PromoRedemption
+		if err := rows.Scan(&r.ID, &r.PromoCodeID, &r.UserID, &r.GroupID, &r.TransactionID, &r.OriginalAmount,
+			&r.DiscountAmount, &r.FeeDiscount, &r.SubsidyAmount, &r.Status, &r.RedeemedAt, &r.CreatedAt,
+			&r.UserName, &r.GroupName); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan promo redemption: %v", err)
+		}
+		redemptions = append(redemptions, r)
+	}
+	return redemptions, total, rows.Err()
+}
+
+func validatePromoRequest(req models.PromoCodeRequest) error {
+	if req.DiscountType == models.PromoDiscountPercentage && req.DiscountValue > 100 {
+		return fmt.Errorf("%w: percentage discount cannot exceed 100", ErrInvalidPromoCode)
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.StartsAt.Before(*req.EndsAt) {
+		return fmt.Errorf("%w: starts_at must be before ends_at", ErrInvalidPromoCode)
+	}
+	return nil
+}
+
+// promoAppIDs stores an empty app list as NULL, meaning any app
+func promoAppIDs(appIDs []string) interface{} {
+	if len(appIDs) == 0 {
+		return nil
+	}
+	return pq.Array(appIDs)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
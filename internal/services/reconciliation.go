@@ -0,0 +1,412 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/service"
+)
+
+var ErrReconciliationReportNotFound = errors.New("reconciliation report not found")
+
+// ReconcileOptions selects the transactions of a reconciliation run
+type ReconcileOptions struct {
+	From        time.Time // transactions created at or after From
+	To          time.Time // and before To
+	AutoFix     bool
+	TriggeredBy string // admin user ID, empty for scheduled runs
+}
+
+// ReconciliationService compares gateway-backed transactions with the payment provider: status, amount and
+// payment method. Safe discrepancies are fixed through PaymentSettlement, exactly as a late webhook would;
+// everything else is written to a report for an admin.
+//
+// Fixed automatically:
+//   - pending locally, final at the provider (paid, expired or failed)
+//   - expired or failed locally, paid at the provider with the same amount (a late settlement)
+//   - paid on both sides with a different payment method
+//
+// Left for an admin: amount differences, paid locally but not at the provider, and lookup failures.
+type ReconciliationService struct {
+	db         *sql.DB
+	gateway    service.PaymentGateway
+	settlement *PaymentSettlement
+	config     config.ReconciliationConfig
+}
+
+func NewReconciliationService(db *sql.DB) *ReconciliationService {
+	return &ReconciliationService{
+		db:         db,
+		gateway:    service.NewPaymentGateway(),
+		settlement: NewPaymentSettlement(db),
+		config:     config.GetConfig().Reconciliation,
+	}
+}
+
+// reconcileTransaction is a gateway-backed transaction under reconciliation
+type reconcileTransaction struct {
+	id               string
+	transactionType  string
+	amount           money.Amount
+	status           string
+	paymentMethod    *string
+	paymentReference string
+	paymentLinkID    *string
+	createdAt        time.Time
+}
+
+// RunScheduled reconciles the configured lookback window
+func (s *ReconciliationService) RunScheduled(now time.Time) (*models.ReconciliationReport, error) {
+	report, err := s.Run(ReconcileOptions{
+		From:    now.AddDate(0, 0, -s.config.LookbackDays),
+		To:      now,
+		AutoFix: s.config.AutoFix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The job run history only needs the totals
+	report.Items = nil
+	return report, nil
+}
+
+// Run reconciles every gateway transaction created in the range and stores the report
+func (s *ReconciliationService) Run(opts ReconcileOptions) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		RangeFrom:   opts.From,
+		RangeTo:     opts.To,
+		AutoFix:     opts.AutoFix,
+		TriggeredBy: nullIfEmpty(opts.TriggeredBy),
+		Status:      models.ReconciliationRunning,
+		Items:       []models.ReconciliationItem{},
+	}
+	err := s.db.QueryRow(`
+		INSERT INTO reconciliation_reports (range_from, range_to, auto_fix, triggered_by, status, started_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, started_at
+	`, report.RangeFrom, report.RangeTo, report.AutoFix, report.TriggeredBy, report.Status).Scan(&report.ID, &report.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reconciliation report: %v", err)
+	}
+
+	transactions, err := s.loadTransactions(opts.From, opts.To)
+	if err != nil {
+		return nil, s.finish(report, err)
+	}
+
+	fmt.Printf("[SALOME BE] Reconciling %d transactions from %s to %s\n", len(transactions),
+		opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339))
+	for _, t := range transactions {
+		report.Checked++
+		item := s.reconcile(t, opts.AutoFix)
+		if item == nil {
+			report.Matched++
+			continue
+		}
+
+		item.ReportID = report.ID
+		if err := s.saveItem(item); err != nil {
+			return nil, s.finish(report, err)
+		}
+		report.Items = append(report.Items, *item)
+		report.Discrepancies++
+		if item.Fixed {
+			report.Fixed++
+		}
+		if item.Kind == models.DiscrepancyProviderError {
+			report.Errors++
+		}
+	}
+
+	if err := s.finish(report, nil); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[SALOME BE] Reconciliation %s: checked=%d matched=%d discrepancies=%d fixed=%d errors=%d\n",
+		report.ID, report.Checked, report.Matched, report.Discrepancies, report.Fixed, report.Errors)
+	return report, nil
+}
+
+func (s *ReconciliationService) loadTransactions(from, to time.Time) ([]reconcileTransaction, error) {
+	rows, err := s.db.Query(`
+		SELECT id, type, amount, status, payment_method, payment_reference, payment_link_id, created_at
+		FROM transactions
+		WHERE payment_reference IS NOT NULL
+		  AND type IN ('top_up', 'top-up', 'group_payment')
+		  AND COALESCE(payment_method, '') <> 'wallet'
+		  AND created_at >= $1 AND created_at < $2
+		ORDER BY created_at ASC
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions to reconcile: %v", err)
+	}
+	defer rows.Close()
+
+	var transactions []reconcileTransaction
+	for rows.Next() {
+		var t reconcileTransaction
+		if err := rows.Scan(&t.id, &t.transactionType, &t.amount, &t.status, &t.paymentMethod, &t.paymentReference,
+			&t.paymentLinkID, &t.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction to reconcile: %v", err)
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// reconcile compares one transaction with the provider and returns the discrepancy, or nil when they match
+func (s *ReconciliationService) reconcile(t reconcileTransaction, autoFix bool) *models.ReconciliationItem {
+	item := &models.ReconciliationItem{
+		TransactionID:    &t.id,
+		PaymentReference: t.paymentReference,
+		TransactionType:  t.transactionType,
+		LocalStatus:      t.status,
+		LocalAmount:      t.amount,
+	}
+	if t.paymentMethod != nil {
+		item.LocalMethod = *t.paymentMethod
+	}
+
+	status, err := s.gateway.GetTransactionStatus(StatusLookupID(t.paymentReference, t.paymentLinkID))
+	if err != nil {
+		item.Kind = models.DiscrepancyProviderError
+		if errors.Is(err, service.ErrPaymentLinkNotFound) {
+			item.Kind = models.DiscrepancyNotFound
+		}
+		item.Action = err.Error()
+		return item
+	}
+	item.ProviderStatus = status.TransactionStatus
+	item.ProviderMethod = status.PaymentType
+
+	providerStatus := s.providerLocalStatus(t, status)
+	providerPaid := providerStatus == "success"
+	if amount, err := money.Parse(status.GrossAmount); err == nil && status.GrossAmount != "" {
+		item.ProviderAmount = &amount
+	}
+
+	// A paid amount that differs from what we billed always needs a human
+	if providerPaid && item.ProviderAmount != nil && money.Max(*item.ProviderAmount-t.amount, t.amount-*item.ProviderAmount) >= money.Whole(1) {
+		item.Kind = models.DiscrepancyAmountMismatch
+		item.Action = "Left for review: provider amount differs"
+		return item
+	}
+
+	localPaid := isSettledStatus(t.status)
+	switch {
+	case localPaid && providerPaid:
+		if status.PaymentType == "" || status.PaymentType == item.LocalMethod {
+			return nil
+		}
+		item.Kind = models.DiscrepancyMethodMismatch
+		if autoFix {
+			s.fix(item, func() error {
+				_, err := s.db.Exec(`UPDATE transactions SET payment_method = $1, updated_at = NOW() WHERE id = $2`,
+					status.PaymentType, t.id)
+				return err
+			}, "Payment method updated")
+		}
+		return item
+
+	case localPaid:
+		item.Kind = models.DiscrepancyStatusMismatch
+		item.Action = "Left for review: paid locally but not at the provider"
+		return item
+
+	case providerStatus == t.status, providerStatus != "success" && providerStatus != "pending" && t.status != "pending":
+		// Same status, or not paid on either side (expired and failed are equivalent here)
+		return nil
+
+	case providerStatus == "pending":
+		// Pending at the provider, expired or failed locally: the link can no longer be paid on our side
+		return nil
+	}
+
+	item.Kind = models.DiscrepancyStatusMismatch
+	if autoFix {
+		s.fix(item, func() error {
+			_, err := s.apply(t.paymentReference, providerStatus, status.PaymentType)
+			return err
+		}, fmt.Sprintf("Status set to %s", providerStatus))
+	}
+	return item
+}
+
+// providerLocalStatus maps the provider status to a transactions status. A payment link nobody paid is
+// reported as failed by the provider; it only counts as expired once it is older than StaleAfterHours.
+func (s *ReconciliationService) providerLocalStatus(t reconcileTransaction, status *service.PaymentStatus) string {
+	switch status.TransactionStatus {
+	case "refund", "partial_refund":
+		// Refunds are tracked in the refunds table, the payment itself was settled
+		return "success"
+	case "failed":
+		if status.TransactionID == "" {
+			if time.Since(t.createdAt) < time.Duration(s.config.StaleAfterHours)*time.Hour {
+				return "pending"
+			}
+			return "expired"
+		}
+	}
+	return LocalPaymentStatus(status.TransactionStatus)
+}
+
+func (s *ReconciliationService) fix(item *models.ReconciliationItem, apply func() error, action string) {
+	if err := apply(); err != nil {
+		item.Action = fmt.Sprintf("Auto-fix failed: %v", err)
+		return
+	}
+	item.Fixed = true
+	item.Action = action
+}
+
+// apply runs the settlement of one transaction in its own DB transaction
+func (s *ReconciliationService) apply(paymentReference, status, paymentType string) (*SettlementResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := s.settlement.Apply(tx, SystemActor(paymentReference), paymentReference, status, paymentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+func (s *ReconciliationService) saveItem(item *models.ReconciliationItem) error {
+	err := s.db.QueryRow(`
+		INSERT INTO reconciliation_items (report_id, transaction_id, payment_reference, transaction_type, kind,
+		                                  local_status, provider_status, local_amount, provider_amount,
+		                                  local_method, provider_method, fixed, action, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
+		RETURNING id, created_at
+	`, item.ReportID, item.TransactionID, item.PaymentReference, item.TransactionType, item.Kind,
+		item.LocalStatus, item.ProviderStatus, item.LocalAmount, item.ProviderAmount,
+		item.LocalMethod, item.ProviderMethod, item.Fixed, item.Action).Scan(&item.ID, &item.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save reconciliation item: %v", err)
+	}
+	return nil
+}
+
+// finish stores the totals of a report, marking it failed when runErr is set, and returns runErr
+func (s *ReconciliationService) finish(report *models.ReconciliationReport, runErr error) error {
+	now := time.Now()
+	report.FinishedAt = &now
+	report.Status = models.ReconciliationCompleted
+	if runErr != nil {
+		report.Status = models.ReconciliationFailed
+		message := runErr.Error()
+		report.Error = &message
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE reconciliation_reports
+		SET status = $1, checked = $2, matched = $3, discrepancies = $4, fixed = $5, errors = $6, error = $7, finished_at = $8
+		WHERE id = $9
+	`, report.Status, report.Checked, report.Matched, report.Discrepancies, report.Fixed, report.Errors, report.Error,
+		report.FinishedAt, report.ID)
+	if runErr != nil {
+		return runErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update reconciliation report: %v", err)
+	}
+	return nil
+}
+
+// ListReports returns reconciliation reports, newest first, without their items
+func (s *ReconciliationService) ListReports(limit, offset int) ([]models.ReconciliationReport, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM reconciliation_reports`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count reconciliation reports: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+reconciliationReportColumns+`
+		FROM reconciliation_reports
+		ORDER BY started_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query reconciliation reports: %v", err)
+	}
+	defer rows.Close()
+
+	reports := []models.ReconciliationReport{}
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, total, rows.Err()
+}
+
+// GetReport returns a report with its discrepancies
+func (s *ReconciliationService) GetReport(reportID string) (*models.ReconciliationReport, error) {
+	report, err := scanReconciliationReport(s.db.QueryRow(`
+		SELECT `+reconciliationReportColumns+` FROM reconciliation_reports WHERE id = $1
+	`, reportID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReconciliationReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, report_id, transaction_id, COALESCE(payment_reference, ''), COALESCE(transaction_type, ''), kind,
+		       COALESCE(local_status, ''), COALESCE(provider_status, ''), COALESCE(local_amount, 0), provider_amount,
+		       COALESCE(local_method, ''), COALESCE(provider_method, ''), fixed, COALESCE(action, ''), created_at
+		FROM reconciliation_items
+		WHERE report_id = $1
+		ORDER BY created_at ASC
+	`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation items: %v", err)
+	}
+	defer rows.Close()
+
+	report.Items = []models.ReconciliationItem{}
+	for rows.Next() {
+		var item models.ReconciliationItem
+		if err := rows.Scan(&item.ID, &item.ReportID, &item.TransactionID, &item.PaymentReference, &item.TransactionType,
+			&item.Kind, &item.LocalStatus, &item.ProviderStatus, &item.LocalAmount, &item.ProviderAmount,
+			&item.LocalMethod, &item.ProviderMethod, &item.Fixed, &item.Action, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation item: %v", err)
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report, rows.Err()
+}
+
+const reconciliationReportColumns = `id, range_from, range_to, auto_fix, triggered_by, status, checked, matched,
+	discrepancies, fixed, errors, error, started_at, finished_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReconciliationReport(row rowScanner) (*models.ReconciliationReport, error) {
+	var r models.ReconciliationReport
+	err := row.Scan(&r.ID, &r.RangeFrom, &r.RangeTo, &r.AutoFix, &r.TriggeredBy, &r.Status, &r.Checked, &r.Matched,
+		&r.Discrepancies, &r.Fixed, &r.Errors, &r.Error, &r.StartedAt, &r.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan reconciliation report: %v", err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,865 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/database"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/service"
+)
+
+var (
+	ErrRefundNotFound           = errors.New("refund not found")
+	ErrRefundNotPending         = errors.New("refund is not waiting for approval")
+	ErrRefundNoEarnings         = errors.New("group earnings do not cover the refund")
+	ErrRefundNoPaymentReference = errors.New("payment has no gateway reference")
+)
+
+// RefundSummary is the outcome of refunding a member's payments
+type RefundSummary struct {
+	Refunds         []models.Refund `json:"refunds"`
+	Total           money.Amount    `json:"total"`
+	PendingApproval bool            `json:"pending_approval"`
+}
+
+// GatewayRefundStats is the outcome of one SendGatewayRefunds run
+type GatewayRefundStats struct {
+	Refunded int `json:"refunded"` // paid out by the gateway
+	Credited int `json:"credited"` // credited to the wallet after the gateway kept failing
+	Retrying int `json:"retrying"`
+	Failed   int `json:"failed"`
+}
+
+// RefundService returns money to members who leave or are removed from a group. Settled payments are refunded
+// for the unused share of the subscription period; balance held by an unfinished mixed payment is always returned.
+// Refunds above the approval threshold wait for an admin.
+type RefundService struct {
+	db           *sql.DB
+	ledger       *ledger.Ledger
+	gateway      service.PaymentGateway
+	promos       *PromoService
+	stateMachine *StateMachineService
+	waitlist     *WaitlistService
+	config       config.RefundConfig
+}
+
+func NewRefundService(db *sql.DB) *RefundService {
+	return &RefundService{
+		db:           db,
+		ledger:       ledger.New(db),
+		gateway:      service.NewPaymentGateway(),
+		promos:       NewPromoService(db),
+		stateMachine: NewStateMachineService(db),
+		waitlist:     NewWaitlistService(db),
+		config:       config.GetConfig().Refund,
+	}
+}
+
+// refundablePayment is a group payment transaction that can be refunded
+type refundablePayment struct {
+	id               string
+	amount           money.Amount
+	adminFee         money.Amount
+	paymentMethod    *string
+	paymentReference *string
+	status           string
+}
+
+const refundablePaymentColumns = `id, amount, COALESCE(admin_fee, 0), payment_method, payment_reference, status`
+
+// RemoveMember removes a member from a group and refunds their payments in the same DB transaction.
+// With refundPaid false only balance held by an unfinished payment is returned.
+func (s *RefundService) RemoveMember(actor Actor, userID, groupID, reason string, refundPaid bool) (*RefundSummary, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	summary, err := s.removeMemberTx(tx, actor, userID, groupID, reason, refundPaid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return summary, nil
+}
+
+// removeMemberTx is RemoveMember inside the caller's transaction
+func (s *RefundService) removeMemberTx(tx *sql.Tx, actor Actor, userID, groupID, reason string, refundPaid bool) (*RefundSummary, error) {
+	// The group is locked before the member, in the order seats are reserved in
+	if _, err := tx.Exec(`SELECT id FROM groups WHERE id = $1 FOR UPDATE`, groupID); err != nil {
+		return nil, fmt.Errorf("failed to lock group: %v", err)
+	}
+
+	summary, err := s.refundMemberTx(tx, userID, groupID, reason, actor.ID, refundPaid, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var userStatus string
+	err = tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE user_id = $1 AND group_id = $2
+	`, userID, groupID).Scan(&userStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member status: %v", err)
+	}
+	if userStatus != models.UserStatusRemoved {
+		err = recordStatusEvent(tx, actor, statusChange{
+			groupID: groupID, userID: userID, from: userStatus, to: models.UserStatusRemoved, reason: reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove member: %v", err)
+	}
+
+	// The member's seat is free again and goes to the next user on the waitlist
+	if err := s.stateMachine.SyncGroupCapacity(tx, actor, groupID); err != nil {
+		return nil, err
+	}
+	if _, err := s.waitlist.promoteTx(tx, actor, groupID, time.Now()); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// refundMemberTx refunds what a member paid for their seat. The membership row stays locked until tx ends.
+func (s *RefundService) refundMemberTx(tx *sql.Tx, userID, groupID, reason, actorID string, refundPaid bool, now time.Time) (*RefundSummary, error) {
+	var userStatus string
+	var periodStart, periodEnd *time.Time
+	err := tx.QueryRow(`
+		SELECT user_status, subscription_period_start, subscription_period_end
+		FROM group_members
+		WHERE user_id = $1 AND group_id = $2
+		FOR UPDATE
+	`, userID, groupID).Scan(&userStatus, &periodStart, &periodEnd)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotGroupMember
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member status: %v", err)
+	}
+
+	summary := &RefundSummary{Refunds: []models.Refund{}}
+
+	// Balance held by a mixed payment whose link is still open always goes back in full
+	held, err := s.loadPayments(tx, `
+		SELECT `+refundablePaymentColumns+`
+		FROM transactions
+		WHERE user_id = $1 AND group_id = $2 AND type = 'group_payment'
+		  AND payment_method = 'wallet' AND status = 'pending'
+		FOR UPDATE
+	`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refundPayments(tx, summary, userID, groupID, held, 1, true, reason, actorID, true, periodStart, periodEnd); err != nil {
+		return nil, err
+	}
+
+	if !refundPaid {
+		return summary, nil
+	}
+
+	ratio, full := prorateRatio(userStatus, periodStart, periodEnd, now)
+	if ratio <= 0 {
+		return summary, nil
+	}
+
+	// The latest settled payment (a wallet payment, or a payment link with its wallet legs) covers the current period
+	paid, err := s.loadPayments(tx, `
+		WITH latest AS (
+			SELECT id FROM transactions
+			WHERE user_id = $1 AND group_id = $2 AND type = 'group_payment'
+			  AND status IN ('success', 'completed') AND related_transaction_id IS NULL
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+		SELECT `+refundablePaymentColumns+`
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.type = 'group_payment' AND t.status IN ('success', 'completed')
+		  AND (t.id IN (SELECT id FROM latest) OR t.related_transaction_id IN (SELECT id FROM latest))
+		  AND NOT EXISTS (SELECT 1 FROM refunds r WHERE r.original_transaction_id = t.id AND r.status <> 'rejected')
+		FOR UPDATE OF t
+	`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	automatic := reason == models.RefundReasonPaymentTimeout || reason == models.RefundReasonLatePayment
+	if err := s.refundPayments(tx, summary, userID, groupID, paid, ratio, full, reason, actorID, automatic, periodStart, periodEnd); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// refundLatePayment returns a payment link (and its wallet legs) that settled after the member lost the seat
+func (s *RefundService) refundLatePayment(tx *sql.Tx, userID, groupID, transactionID string) (*RefundSummary, error) {
+	payments, err := s.loadPayments(tx, `
+		SELECT `+refundablePaymentColumns+`
+		FROM transactions
+		WHERE (id = $1 OR (related_transaction_id = $1 AND type = 'group_payment'))
+		  AND status IN ('success', 'completed')
+		FOR UPDATE
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RefundSummary{Refunds: []models.Refund{}}
+	if err := s.refundPayments(tx, summary, userID, groupID, payments, 1, true, models.RefundReasonLatePayment, "", true, nil, nil); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// refundDisputedPayment returns a payment (and its wallet legs) in full after an admin upheld a dispute about
+// the period it paid for. Payments refunded before, for example when the member left, are skipped.
+func (s *RefundService) refundDisputedPayment(tx *sql.Tx, userID, groupID, transactionID, actorID string,
+	periodStart, periodEnd *time.Time) (*RefundSummary, error) {
+	payments, err := s.loadPayments(tx, `
+		SELECT `+refundablePaymentColumns+`
+		FROM transactions t
+		WHERE (t.id = $1 OR (t.related_transaction_id = $1 AND t.type = 'group_payment'))
+		  AND t.status IN ('success', 'completed')
+		  AND NOT EXISTS (SELECT 1 FROM refunds r WHERE r.original_transaction_id = t.id AND r.status <> 'rejected')
+		FOR UPDATE OF t
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RefundSummary{Refunds: []models.Refund{}}
+	if err := s.refundPayments(tx, summary, userID, groupID, payments, 1, true, models.RefundReasonDispute, actorID, true, periodStart, periodEnd); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// prorateRatio returns the share of a payment to refund and whether the admin fee is refunded as well.
+// Until the subscription period starts everything is returned; afterwards the unused share of the period
+// is refunded and the admin fee is kept.
+func prorateRatio(userStatus string, periodStart, periodEnd *time.Time, now time.Time) (float64, bool) {
+	if userStatus == models.UserStatusPending || userStatus == models.UserStatusPaid {
+		return 1, true
+	}
+	if periodStart == nil || periodEnd == nil || !periodEnd.After(*periodStart) {
+		return 0, false
+	}
+	if now.Before(*periodStart) {
+		return 1, true
+	}
+	if !now.Before(*periodEnd) {
+		return 0, false
+	}
+	return periodEnd.Sub(now).Seconds() / periodEnd.Sub(*periodStart).Seconds(), false
+}
+
+func (s *RefundService) loadPayments(tx *sql.Tx, query string, args ...interface{}) ([]refundablePayment, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payments to refund: %v", err)
+	}
+	defer rows.Close()
+
+	var payments []refundablePayment
+	for rows.Next() {
+		var p refundablePayment
+		if err := rows.Scan(&p.id, &p.amount, &p.adminFee, &p.paymentMethod, &p.paymentReference, &p.status); err != nil {
+			return nil, fmt.Errorf("failed to scan payment to refund: %v", err)
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// refundPayments records a refund for each payment and executes them, unless the total needs approval
+func (s *RefundService) refundPayments(tx *sql.Tx, summary *RefundSummary, userID, groupID string, payments []refundablePayment,
+	ratio float64, full bool, reason, actorID string, automatic bool, periodStart, periodEnd *time.Time) error {
+	var refunds []models.Refund
+	var total money.Amount
+	for _, p := range payments {
+		amount, adminFee := p.amount, p.adminFee
+		if !full {
+			// Whole rupiah, rounded in favour of the platform
+			amount, adminFee = money.Whole(int64(math.Floor((p.amount-p.adminFee).Major()*ratio))), 0
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		refund := models.Refund{
+			UserID:                userID,
+			GroupID:               &groupID,
+			OriginalTransactionID: p.id,
+			Reason:                reason,
+			Method:                s.refundMethod(p),
+			Amount:                amount,
+			AdminFee:              adminFee,
+			PaidAmount:            p.amount,
+			ProrateRatio:          ratio,
+			PeriodStart:           periodStart,
+			PeriodEnd:             periodEnd,
+			Status:                models.RefundPendingApproval,
+			RequestedBy:           nullIfEmpty(actorID),
+		}
+		if full {
+			refund.ProrateRatio = 1
+		}
+		refunds = append(refunds, refund)
+		total += amount
+	}
+	if len(refunds) == 0 {
+		return nil
+	}
+
+	needsApproval := !automatic && total > s.config.ApprovalThreshold
+	held := false
+
+	byID := make(map[string]refundablePayment, len(payments))
+	for _, p := range payments {
+		byID[p.id] = p
+	}
+
+	for i := range refunds {
+		refund := &refunds[i]
+		err := tx.QueryRow(`
+			INSERT INTO refunds (user_id, group_id, original_transaction_id, reason, method, amount, admin_fee, paid_amount,
+			                     prorate_ratio, period_start, period_end, status, requested_by, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())
+			RETURNING id, created_at, updated_at
+		`, refund.UserID, refund.GroupID, refund.OriginalTransactionID, refund.Reason, refund.Method, refund.Amount,
+			refund.AdminFee, refund.PaidAmount, refund.ProrateRatio, refund.PeriodStart, refund.PeriodEnd, refund.Status,
+			refund.RequestedBy).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create refund: %v", err)
+		}
+
+		if needsApproval {
+			continue
+		}
+		err = s.execute(tx, refund, byID[refund.OriginalTransactionID])
+		if errors.Is(err, ErrRefundNoEarnings) {
+			// The owner already withdrew the earnings; an admin decides how the member is paid back
+			note := "Group earnings were already paid out"
+			refund.Note = &note
+			if _, err := tx.Exec(`UPDATE refunds SET note = $1, updated_at = NOW() WHERE id = $2`, note, refund.ID); err != nil {
+				return fmt.Errorf("failed to update refund: %v", err)
+			}
+			held = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if needsApproval || held {
+		err := notify(tx, userID, "refund_pending", "Pengembalian Dana Diproses",
+			fmt.Sprintf("Pengembalian dana sebesar %s sedang menunggu persetujuan admin.", total.Format()),
+			"/payments/refunds", "Lihat Pengembalian")
+		if err != nil {
+			return err
+		}
+		summary.PendingApproval = true
+	}
+
+	summary.Refunds = append(summary.Refunds, refunds...)
+	summary.Total += total
+	return nil
+}
+
+// refundMethod sends money paid through the gateway back through the gateway when enabled; everything else
+// is credited to the wallet
+func (s *RefundService) refundMethod(p refundablePayment) string {
+	if s.config.GatewayRefunds && p.paymentReference != nil && (p.paymentMethod == nil || *p.paymentMethod != "wallet") {
+		return models.RefundMethodGateway
+	}
+	return models.RefundMethodWallet
+}
+
+// execute moves the money of a recorded refund and marks it completed. Gateway refunds are booked here and
+// left pending_gateway; SendGatewayRefunds pays them out once the caller's transaction has committed. The group
+// escrow may not go below zero: ErrRefundNoEarnings is returned, before anything is moved, when it cannot cover
+// the refund.
+func (s *RefundService) execute(tx *sql.Tx, refund *models.Refund, p refundablePayment) error {
+	description := fmt.Sprintf("Pengembalian dana %s", refundReasonLabel(refund.Reason))
+
+	if refund.GroupID != nil {
+		escrow, err := s.ledger.LockBalance(tx, ledger.AccountGroupEscrow, *refund.GroupID)
+		if err != nil {
+			return err
+		}
+		if escrow < refund.Amount-refund.AdminFee {
+			return ErrRefundNoEarnings
+		}
+	}
+
+	refund.Status = models.RefundCompleted
+	if refund.Method == models.RefundMethodGateway {
+		transactionID, err := s.bookGatewayRefund(tx, refund, p, description)
+		if err != nil {
+			return err
+		}
+		refund.RefundTransactionID = &transactionID
+		refund.Status = models.RefundPendingGateway
+	} else {
+		result, err := bookWalletRefund(tx, s.ledger, refund.UserID, refund.GroupID, p.id, refund.Amount, refund.AdminFee, description)
+		if err != nil {
+			return err
+		}
+		refund.RefundTransactionID = &result.TransactionID
+	}
+
+	// The platform's promo subsidy for the refunded share goes back as well
+	if err := s.promos.reverseSubsidy(tx, p.id, refund.ProrateRatio); err != nil {
+		return err
+	}
+
+	// A wallet leg whose link never settled is done for good
+	if p.status == "pending" {
+		_, err := tx.Exec(`UPDATE transactions SET status = 'refunded', updated_at = NOW() WHERE id = $1`, p.id)
+		if err != nil {
+			return fmt.Errorf("failed to mark payment refunded: %v", err)
+		}
+	}
+
+	refund.UpdatedAt = time.Now()
+	_, err := tx.Exec(`
+		UPDATE refunds
+		SET status = $1, method = $2, refund_transaction_id = $3, note = COALESCE($4, note), updated_at = $5
+		WHERE id = $6
+	`, refund.Status, refund.Method, refund.RefundTransactionID, refund.Note, refund.UpdatedAt, refund.ID)
+	if err != nil {
+		return fmt.Errorf("failed to complete refund: %v", err)
+	}
+
+	if refund.Status == models.RefundPendingGateway {
+		fmt.Printf("[SALOME BE] Refund %s booked for the payment gateway: UserID=%s, Amount=%s\n", refund.ID, refund.UserID, refund.Amount)
+		return nil
+	}
+	fmt.Printf("[SALOME BE] Refund %s completed: UserID=%s, Amount=%s, Method=%s\n", refund.ID, refund.UserID, refund.Amount, refund.Method)
+	return notifyRefundCompleted(tx, refund)
+}
+
+// notifyRefundCompleted tells the member where the money of a completed refund went
+func notifyRefundCompleted(q database.Queryer, refund *models.Refund) error {
+	description := fmt.Sprintf("Pengembalian dana %s", refundReasonLabel(refund.Reason))
+	destination := "saldo Anda"
+	if refund.Method == models.RefundMethodGateway {
+		destination = "metode pembayaran asal"
+	}
+	return notify(q, refund.UserID, "refund_completed", "Dana Dikembalikan",
+		fmt.Sprintf("%s sebesar %s telah dikembalikan ke %s.", description, refund.Amount.Format(), destination),
+		"/payments/refunds", "Lihat Pengembalian")
+}
+
+// bookGatewayRefund records a pending refund transaction for a payment link purchase and books it against
+// payment clearing. The provider is only asked to pay it out after the transaction commits.
+func (s *RefundService) bookGatewayRefund(tx *sql.Tx, refund *models.Refund, p refundablePayment, description string) (string, error) {
+	var transactionID string
+	err := tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_method, related_transaction_id, status, created_at, updated_at)
+		VALUES ($1, $2, 'refund', $3, $4, 0, 0, $5, $6, $7, 'pending', NOW(), NOW())
+		RETURNING id
+	`, refund.UserID, refund.GroupID, refund.Amount, refund.AdminFee, description, s.gateway.Name(), p.id).Scan(&transactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create refund transaction: %v", err)
+	}
+
+	op := ledger.Operation{
+		UserID:        refund.UserID,
+		TransactionID: transactionID,
+		Amount:        refund.Amount,
+		AdminFee:      refund.AdminFee,
+		Description:   description,
+	}
+	if refund.GroupID != nil {
+		op.GroupID = *refund.GroupID
+	}
+	if _, err := s.ledger.RefundToGateway(tx, op); err != nil {
+		return "", fmt.Errorf("failed to book refund: %v", err)
+	}
+	return transactionID, nil
+}
+
+// gatewayRefundRetryWindow is how long a gateway refund keeps being retried before it is credited to the
+// wallet instead
+const gatewayRefundRetryWindow = 24 * time.Hour
+
+// SendGatewayRefunds asks the payment gateway to pay out every refund booked for it. A refund the gateway keeps
+// refusing is credited to the wallet once gatewayRefundRetryWindow has passed, so the member is never left
+// without their money.
+func (s *RefundService) SendGatewayRefunds(now time.Time) (*GatewayRefundStats, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM refunds WHERE status = $1 ORDER BY created_at
+	`, models.RefundPendingGateway)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find gateway refunds: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan gateway refund: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	stats := &GatewayRefundStats{}
+	for _, id := range ids {
+		outcome, err := s.sendGatewayRefund(id, now)
+		if err != nil {
+			fmt.Printf("[SALOME BE] ERROR: Failed to send gateway refund %s: %v\n", id, err)
+			stats.Failed++
+			continue
+		}
+		switch outcome {
+		case models.RefundMethodGateway:
+			stats.Refunded++
+		case models.RefundMethodWallet:
+			stats.Credited++
+		default:
+			stats.Retrying++
+		}
+	}
+	return stats, nil
+}
+
+// sendGatewayRefund pays out one pending_gateway refund and returns the method it was completed with, or ""
+// when the gateway failed and the refund is retried later. The gateway is called outside any DB transaction with
+// a key derived from the refunded payment, so a retry after a crash cannot refund the payment twice.
+func (s *RefundService) sendGatewayRefund(refundID string, now time.Time) (string, error) {
+	var refund models.Refund
+	var paymentReference *string
+	var bookedAt time.Time
+	err := s.db.QueryRow(`
+		SELECT r.user_id, r.group_id, r.original_transaction_id, r.refund_transaction_id, r.reason, r.amount,
+		       r.admin_fee, r.status, o.payment_reference, rt.created_at
+		FROM refunds r
+		JOIN transactions o ON o.id = r.original_transaction_id
+		JOIN transactions rt ON rt.id = r.refund_transaction_id
+		WHERE r.id = $1
+	`, refundID).Scan(&refund.UserID, &refund.GroupID, &refund.OriginalTransactionID, &refund.RefundTransactionID,
+		&refund.Reason, &refund.Amount, &refund.AdminFee, &refund.Status, &paymentReference, &bookedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to get refund: %v", err)
+	}
+	if refund.Status != models.RefundPendingGateway {
+		return "", nil
+	}
+	refund.ID = refundID
+
+	gatewayErr := ErrRefundNoPaymentReference
+	if paymentReference != nil {
+		_, gatewayErr = s.gateway.Refund(service.RefundRequest{
+			PaymentReference: *paymentReference,
+			Amount:           refund.Amount,
+			Reason:           fmt.Sprintf("Pengembalian dana %s", refundReasonLabel(refund.Reason)),
+			RefundKey:        refund.OriginalTransactionID,
+		})
+	}
+	if gatewayErr != nil && now.Sub(bookedAt) < gatewayRefundRetryWindow {
+		fmt.Printf("[SALOME BE] WARNING: Gateway refund %s failed, retrying later: %v\n", refundID, gatewayErr)
+		return "", nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM refunds WHERE id = $1 FOR UPDATE`, refundID).Scan(&status)
+	if err != nil {
+		return "", fmt.Errorf("failed to lock refund: %v", err)
+	}
+	if status != models.RefundPendingGateway {
+		return "", nil
+	}
+
+	gatewayTransactionID := *refund.RefundTransactionID
+	refund.Method = models.RefundMethodGateway
+	transactionStatus := "completed"
+	if gatewayErr != nil {
+		fmt.Printf("[SALOME BE] WARNING: Gateway refund %s failed, crediting wallet instead: %v\n", refundID, gatewayErr)
+		transactionID, err := s.redirectToWallet(tx, &refund)
+		if err != nil {
+			return "", err
+		}
+		refund.Method = models.RefundMethodWallet
+		refund.RefundTransactionID = &transactionID
+		note := fmt.Sprintf("Gateway refund failed: %v", gatewayErr)
+		refund.Note = &note
+		transactionStatus = "failed"
+	}
+
+	_, err = tx.Exec(`UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2`, transactionStatus, gatewayTransactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to update refund transaction: %v", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE refunds
+		SET status = $1, method = $2, refund_transaction_id = $3, note = COALESCE($4, note), updated_at = NOW()
+		WHERE id = $5
+	`, models.RefundCompleted, refund.Method, refund.RefundTransactionID, refund.Note, refundID)
+	if err != nil {
+		return "", fmt.Errorf("failed to complete refund: %v", err)
+	}
+	if err := notifyRefundCompleted(tx, &refund); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Refund %s completed: UserID=%s, Amount=%s, Method=%s\n", refundID, refund.UserID, refund.Amount, refund.Method)
+	return refund.Method, nil
+}
+
+// redirectToWallet credits a booked gateway refund to the member's wallet as a new refund transaction
+func (s *RefundService) redirectToWallet(tx *sql.Tx, refund *models.Refund) (string, error) {
+	description := fmt.Sprintf("Pengembalian dana %s", refundReasonLabel(refund.Reason))
+	var transactionID string
+	err := tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_method, related_transaction_id, status, created_at, updated_at)
+		VALUES ($1, $2, 'refund', $3, $4, 0, 0, $5, 'wallet', $6, 'completed', NOW(), NOW())
+		RETURNING id
+	`, refund.UserID, refund.GroupID, refund.Amount, refund.AdminFee, description, refund.OriginalTransactionID).Scan(&transactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create refund transaction: %v", err)
+	}
+
+	movement, err := s.ledger.RedirectRefundToWallet(tx, ledger.Operation{
+		UserID:        refund.UserID,
+		TransactionID: transactionID,
+		Amount:        refund.Amount,
+		Description:   description,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to book refund: %v", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE transactions SET balance_before = $1, balance_after = $2 WHERE id = $3
+	`, movement.BalanceBefore, movement.BalanceAfter, transactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to update transaction balance: %v", err)
+	}
+	return transactionID, nil
+}
+
+// bookWalletRefund credits (part of) a group payment back to the payer's wallet as a new refund transaction
+func bookWalletRefund(tx *sql.Tx, l *ledger.Ledger, userID string, groupID *string, originalTransactionID string,
+	amount, adminFee money.Amount, description string) (*WalletPaymentResult, error) {
+	var refundID string
+	err := tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_method, related_transaction_id, status, created_at, updated_at)
+		VALUES ($1, $2, 'refund', $3, $4, 0, 0, $5, 'wallet', $6, 'completed', NOW(), NOW())
+		RETURNING id
+	`, userID, groupID, amount, adminFee, description, originalTransactionID).Scan(&refundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refund transaction: %v", err)
+	}
+
+	op := ledger.Operation{
+		UserID:        userID,
+		TransactionID: refundID,
+		Amount:        amount,
+		AdminFee:      adminFee,
+		Description:   description,
+	}
+	if groupID != nil {
+		op.GroupID = *groupID
+	}
+	movement, err := l.RefundToWallet(tx, op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to book refund: %v", err)
+	}
+
+	result := &WalletPaymentResult{
+		TransactionID: refundID,
+		Amount:        amount,
+		BalanceBefore: movement.BalanceBefore,
+		BalanceAfter:  movement.BalanceAfter,
+		Status:        "completed",
+	}
+	_, err = tx.Exec(`
+		UPDATE transactions SET balance_before = $1, balance_after = $2 WHERE id = $3
+	`, result.BalanceBefore, result.BalanceAfter, refundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update transaction balance: %v", err)
+	}
+	return result, nil
+}
+
+func refundReasonLabel(reason string) string {
+	switch reason {
+	case models.RefundReasonLeave:
+		return "karena keluar dari grup"
+	case models.RefundReasonAdminRemove:
+		return "karena dikeluarkan dari grup"
+	case models.RefundReasonPaymentTimeout:
+		return "karena batas waktu pembayaran terlewati"
+	case models.RefundReasonLatePayment:
+		return "atas pembayaran yang terlambat"
+	case models.RefundReasonDispute:
+		return "atas sengketa yang disetujui admin"
+	default:
+		return reason
+	}
+}
+
+// ListRefunds returns refunds for the admin review queue, optionally filtered by status
+func (s *RefundService) ListRefunds(status string, limit, offset int) ([]models.Refund, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM refunds WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count refunds: %v", err)
+	}
+
+	refunds, err := s.listRefunds(`WHERE ($1 = '' OR r.status = $1) ORDER BY r.created_at DESC LIMIT $2 OFFSET $3`,
+		status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return refunds, total, nil
+}
+
+// ListUserRefunds returns the refunds of a user, newest first
+func (s *RefundService) ListUserRefunds(userID string) ([]models.Refund, error) {
+	return s.listRefunds(`WHERE r.user_id = $1 ORDER BY r.created_at DESC`, userID)
+}
+
+func (s *RefundService) listRefunds(where string, args ...interface{}) ([]models.Refund, error) {
+	rows, err := s.db.Query(`
+		SELECT r.id, r.user_id, r.group_id, r.original_transaction_id, r.refund_transaction_id, r.reason, r.method,
+		       r.amount, r.admin_fee, r.paid_amount, r.prorate_ratio, r.period_start, r.period_end, r.status,
+		       r.requested_by, r.reviewed_by, r.reviewed_at, r.note, r.created_at, r.updated_at,
+		       COALESCE(g.name, ''), COALESCE(u.full_name, '')
+		FROM refunds r
+		LEFT JOIN groups g ON g.id = r.group_id
+		LEFT JOIN users u ON u.id = r.user_id
+		`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refunds: %v", err)
+	}
+	defer rows.Close()
+
+	refunds := []models.Refund{}
+	for rows.Next() {
+		var r models.Refund
+		err := rows.Scan(&r.ID, &r.UserID, &r.GroupID, &r.OriginalTransactionID, &r.RefundTransactionID, &r.Reason, &r.Method,
+			&r.Amount, &r.AdminFee, &r.PaidAmount, &r.ProrateRatio, &r.PeriodStart, &r.PeriodEnd, &r.Status,
+			&r.RequestedBy, &r.ReviewedBy, &r.ReviewedAt, &r.Note, &r.CreatedAt, &r.UpdatedAt,
+			&r.GroupName, &r.UserName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %v", err)
+		}
+		refunds = append(refunds, r)
+	}
+	return refunds, rows.Err()
+}
+
+// Approve executes a refund that was waiting for approval
+func (s *RefundService) Approve(refundID, adminID, note string) (*models.Refund, error) {
+	return s.review(refundID, adminID, note, true)
+}
+
+// Reject declines a refund that was waiting for approval; the payment can be refunded again later
+func (s *RefundService) Reject(refundID, adminID, note string) (*models.Refund, error) {
+	return s.review(refundID, adminID, note, false)
+}
+
+func (s *RefundService) review(refundID, adminID, note string, approve bool) (*models.Refund, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var refund models.Refund
+	err = tx.QueryRow(`
+		SELECT id, user_id, group_id, original_transaction_id, reason, method, amount, admin_fee, paid_amount,
+		       prorate_ratio, period_start, period_end, status, requested_by, note, created_at
+		FROM refunds
+		WHERE id = $1
+		FOR UPDATE
+	`, refundID).Scan(&refund.ID, &refund.UserID, &refund.GroupID, &refund.OriginalTransactionID, &refund.Reason, &refund.Method,
+		&refund.Amount, &refund.AdminFee, &refund.PaidAmount, &refund.ProrateRatio, &refund.PeriodStart, &refund.PeriodEnd,
+		&refund.Status, &refund.RequestedBy, &refund.Note, &refund.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund: %v", err)
+	}
+	if refund.Status != models.RefundPendingApproval {
+		return nil, ErrRefundNotPending
+	}
+
+	now := time.Now()
+	refund.ReviewedBy = &adminID
+	refund.ReviewedAt = &now
+	if note != "" {
+		refund.Note = &note
+	}
+	_, err = tx.Exec(`
+		UPDATE refunds SET reviewed_by = $1, reviewed_at = $2, note = $3, updated_at = $2 WHERE id = $4
+	`, adminID, now, refund.Note, refund.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update refund: %v", err)
+	}
+
+	if approve {
+		payments, err := s.loadPayments(tx, `
+			SELECT `+refundablePaymentColumns+` FROM transactions WHERE id = $1 FOR UPDATE
+		`, refund.OriginalTransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if len(payments) == 0 {
+			return nil, fmt.Errorf("payment %s of refund %s not found", refund.OriginalTransactionID, refund.ID)
+		}
+		if err := s.execute(tx, &refund, payments[0]); err != nil {
+			return nil, err
+		}
+	} else {
+		refund.Status = models.RefundRejected
+		refund.UpdatedAt = now
+		if _, err := tx.Exec(`UPDATE refunds SET status = $1 WHERE id = $2`, refund.Status, refund.ID); err != nil {
+			return nil, fmt.Errorf("failed to reject refund: %v", err)
+		}
+		message := fmt.Sprintf("Pengembalian dana sebesar %s tidak disetujui.", refund.Amount.Format())
+		if note != "" {
+			message += " Catatan: " + note
+		}
+		if err := notify(tx, refund.UserID, "refund_rejected", "Pengembalian Dana Ditolak", message,
+			"/payments/refunds", "Lihat Pengembalian"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return &refund, nil
+}
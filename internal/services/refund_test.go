@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"salome-be/internal/models"
+)
+
+func TestProrateRatio(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	at := func(days int) time.Time { return start.AddDate(0, 0, days) }
+
+	tests := []struct {
+		name         string
+		status       string
+		start, end   *time.Time
+		now          time.Time
+		wantRatio    float64
+		wantAdminFee bool
+	}{
+		{"pending member gets everything", models.UserStatusPending, nil, nil, at(10), 1, true},
+		{"paid member before activation gets everything", models.UserStatusPaid, &start, &end, at(10), 1, true},
+		{"active member without a period", models.UserStatusActive, nil, nil, at(10), 0, false},
+		{"period not started yet", models.UserStatusActive, &start, &end, at(-1), 1, true},
+		{"first day of the period", models.UserStatusActive, &start, &end, start, 1, false},
+		{"a third of the period used", models.UserStatusActive, &start, &end, at(10), 2.0 / 3, false},
+		{"half the period used", models.UserStatusActive, &start, &end, at(15), 0.5, false},
+		{"period ended", models.UserStatusActive, &start, &end, end, 0, false},
+		{"after the period", models.UserStatusExpired, &start, &end, at(40), 0, false},
+		{"empty period", models.UserStatusActive, &start, &start, at(-1), 0, false},
+		{"period ending before it starts", models.UserStatusActive, &end, &start, at(10), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio, adminFee := prorateRatio(tt.status, tt.start, tt.end, tt.now)
+			if diff := ratio - tt.wantRatio; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("prorateRatio() ratio = %v, want %v", ratio, tt.wantRatio)
+			}
+			if adminFee != tt.wantAdminFee {
+				t.Errorf("prorateRatio() admin fee = %v, want %v", adminFee, tt.wantAdminFee)
+			}
+		})
+	}
+}
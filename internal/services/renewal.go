@@ -0,0 +1,551 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/ledger"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+	"salome-be/internal/pricing"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("renewal invoice not found")
+	ErrInvoiceNotOpen  = errors.New("renewal invoice is not open")
+	ErrRenewalClosed   = errors.New("membership can no longer be renewed")
+)
+
+// RenewalStats summarizes one run of the renewal engine
+type RenewalStats struct {
+	GroupsClosed      int `json:"groups_closed"`
+	InvoicesCancelled int `json:"invoices_cancelled"`
+	InvoicesCreated   int `json:"invoices_created"`
+	AutoCharged       int `json:"auto_charged"`
+	MembersExpired    int `json:"members_expired"`
+}
+
+// RenewalService runs the billing cycle of paid groups: it invoices members before their period ends,
+// collects renewals from the wallet or a payment link, and expires members who did not pay.
+type RenewalService struct {
+	db           *sql.DB
+	stateMachine *StateMachineService
+	payments     *GroupPaymentService
+	settlement   *PaymentSettlement
+	lifecycle    *GroupLifecycleService
+	config       config.RenewalConfig
+}
+
+func NewRenewalService(db *sql.DB) *RenewalService {
+	return &RenewalService{
+		db:           db,
+		stateMachine: NewStateMachineService(db),
+		payments:     NewGroupPaymentService(db),
+		settlement:   NewPaymentSettlement(db),
+		lifecycle:    NewGroupLifecycleService(db),
+		config:       config.GetConfig().Renewal,
+	}
+}
+
+// Run closes groups whose scheduled closure is due, cancels invoices that can no longer be paid, invoices
+// members whose period ends soon and expires members whose period ended without payment
+func (s *RenewalService) Run(now time.Time) (*RenewalStats, error) {
+	stats := &RenewalStats{}
+
+	closed, err := s.lifecycle.CloseDue(now)
+	stats.GroupsClosed = closed
+	if err != nil {
+		return stats, err
+	}
+
+	cancelled, err := s.CancelStaleInvoices()
+	if err != nil {
+		return stats, err
+	}
+	stats.InvoicesCancelled = cancelled
+
+	created, charged, err := s.CreateDueInvoices(now)
+	stats.InvoicesCreated, stats.AutoCharged = created, charged
+	if err != nil {
+		return stats, err
+	}
+
+	expired, err := s.ExpireLapsed(now)
+	stats.MembersExpired = expired
+	if err != nil {
+		return stats, err
+	}
+
+	fmt.Printf("[SALOME BE] Renewal run: closed=%d cancelled=%d created=%d auto_charged=%d expired=%d\n",
+		stats.GroupsClosed, stats.InvoicesCancelled, stats.InvoicesCreated, stats.AutoCharged, stats.MembersExpired)
+	return stats, nil
+}
+
+// CreateDueInvoices creates an invoice for every active member whose period ends within InvoiceDaysBefore days,
+// except in paused groups and groups scheduled for closure, and pays it from the wallet right away when AutoChargeWallet is set and the balance covers it
+func (s *RenewalService) CreateDueInvoices(now time.Time) (int, int, error) {
+	rows, err := s.db.Query(`
+		SELECT gm.group_id, gm.user_id, gm.subscription_period_end, g.name,
+		       COALESCE(g.price_per_member, 0), COALESCE(g.admin_fee, 0)
+		FROM group_members gm
+		JOIN groups g ON g.id = gm.group_id
+		WHERE gm.user_status = $1
+		  AND gm.subscription_period_end IS NOT NULL
+		  AND gm.subscription_period_end <= $2
+		  AND g.deleted_at IS NULL AND g.group_status NOT IN ($3, $4, $5)
+		  AND NOT EXISTS (
+			SELECT 1 FROM renewal_invoices ri
+			WHERE ri.group_id = gm.group_id AND ri.user_id = gm.user_id AND ri.period_start = gm.subscription_period_end
+		  )
+	`, models.UserStatusActive, now.AddDate(0, 0, s.config.InvoiceDaysBefore), models.GroupStatusClosed,
+		models.GroupStatusPaused, models.GroupStatusClosing)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find members due for renewal: %v", err)
+	}
+
+	type dueMember struct {
+		groupID, userID, groupName string
+		periodEnd                  time.Time
+		price, adminFee            money.Amount
+	}
+	var due []dueMember
+	for rows.Next() {
+		var m dueMember
+		if err := rows.Scan(&m.groupID, &m.userID, &m.periodEnd, &m.groupName, &m.price, &m.adminFee); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan member due for renewal: %v", err)
+		}
+		due = append(due, m)
+	}
+	rows.Close()
+
+	created, charged := 0, 0
+	for _, m := range due {
+		// Invoices may be paid by link and gateways charge whole rupiah, so a fractional price is rounded up
+		// into the admin fee
+		amount := (m.price + m.adminFee).Ceil()
+		adminFee := amount - m.price
+
+		var invoiceID string
+		err := s.db.QueryRow(`
+			INSERT INTO renewal_invoices (group_id, user_id, period_start, period_end, amount, admin_fee, status, due_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $3, NOW(), NOW())
+			ON CONFLICT (group_id, user_id, period_start) DO NOTHING
+			RETURNING id
+		`, m.groupID, m.userID, m.periodEnd, m.periodEnd.AddDate(0, s.config.PeriodMonths, 0),
+			amount, adminFee, models.RenewalInvoiceOpen).Scan(&invoiceID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return created, charged, fmt.Errorf("failed to create renewal invoice: %v", err)
+		}
+		created++
+
+		if s.config.AutoChargeWallet {
+			if _, err := s.PayWithBalance(m.userID, invoiceID); err == nil {
+				charged++
+				continue
+			} else if !errors.Is(err, ledger.ErrInsufficientFunds) {
+				fmt.Printf("[SALOME BE] ERROR: Failed to auto-charge renewal invoice %s: %v\n", invoiceID, err)
+			}
+		}
+
+		if err := notify(s.db, m.userID, "payment", "Perpanjangan langganan",
+			fmt.Sprintf("Langganan grup %s berakhir pada %s. Bayar perpanjangan sebesar %s agar tetap aktif.",
+				m.groupName, m.periodEnd.Format("02 Jan 2006"), amount.Format()),
+			"/payments/renewals", "Bayar Sekarang"); err != nil {
+			fmt.Printf("[SALOME BE] ERROR: %v\n", err)
+		}
+	}
+	return created, charged, nil
+}
+
+// ExpireLapsed moves active members whose period (plus grace days) has ended to expired. Members of paused
+// groups keep their seat until the group resumes.
+func (s *RenewalService) ExpireLapsed(now time.Time) (int, error) {
+	cutoff := now.AddDate(0, 0, -s.config.GraceDays)
+	rows, err := s.db.Query(`
+		SELECT gm.group_id, gm.user_id FROM group_members gm
+		JOIN groups g ON g.id = gm.group_id
+		WHERE gm.user_status = $1 AND gm.subscription_period_end < $2 AND g.group_status <> $3
+	`, models.UserStatusActive, cutoff, models.GroupStatusPaused)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find lapsed members: %v", err)
+	}
+
+	type member struct{ groupID, userID string }
+	var lapsed []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.groupID, &m.userID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan lapsed member: %v", err)
+		}
+		lapsed = append(lapsed, m)
+	}
+	rows.Close()
+
+	expired := 0
+	for _, m := range lapsed {
+		ok, err := s.expireMember(m.groupID, m.userID, cutoff)
+		if err != nil {
+			fmt.Printf("[SALOME BE] ERROR: Failed to expire member %s in group %s: %v\n", m.userID, m.groupID, err)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expireMember expires one member unless a renewal was paid since the member was selected
+func (s *RenewalService) expireMember(groupID, userID string, cutoff time.Time) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var groupName, groupStatus string
+	err = tx.QueryRow(`
+		SELECT g.name, g.group_status FROM group_members gm
+		JOIN groups g ON g.id = gm.group_id
+		WHERE gm.group_id = $1 AND gm.user_id = $2 AND gm.user_status = $3 AND gm.subscription_period_end < $4
+		FOR UPDATE OF gm
+	`, groupID, userID, models.UserStatusActive, cutoff).Scan(&groupName, &groupStatus)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock member: %v", err)
+	}
+
+	if err := s.stateMachine.UpdateUserStatusTx(tx, SystemActor(NewCorrelationID()), userID, groupID, models.UserStatusExpired, "Subscription period ended"); err != nil {
+		return false, err
+	}
+	if groupStatus == models.GroupStatusClosing {
+		err = notify(tx, userID, "group", "Langganan berakhir",
+			fmt.Sprintf("Langganan grup %s telah berakhir. Grup ini akan ditutup dan tidak diperpanjang.", groupName),
+			"/groups/"+groupID, "Lihat Grup")
+	} else {
+		err = notify(tx, userID, "group", "Langganan berakhir",
+			fmt.Sprintf("Langganan grup %s telah berakhir. Bayar tagihan perpanjangan untuk mengaktifkannya kembali.", groupName),
+			"/payments/renewals", "Lihat Tagihan")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Member expired: GroupID=%s, UserID=%s\n", groupID, userID)
+	return true, nil
+}
+
+// CancelStaleInvoices cancels open invoices of members who left or were removed and of groups that are
+// closed, paused or scheduled for closure
+func (s *RenewalService) CancelStaleInvoices() (int, error) {
+	result, err := s.db.Exec(`
+		UPDATE renewal_invoices ri
+		SET status = $1, updated_at = NOW()
+		WHERE ri.status = $2
+		  AND (
+			NOT EXISTS (
+				SELECT 1 FROM group_members gm
+				WHERE gm.group_id = ri.group_id AND gm.user_id = ri.user_id AND gm.user_status IN ($3, $4)
+			)
+			OR EXISTS (
+				SELECT 1 FROM groups g
+				WHERE g.id = ri.group_id AND (g.group_status IN ($5, $6, $7) OR g.deleted_at IS NOT NULL)
+			)
+		  )
+	`, models.RenewalInvoiceCancelled, models.RenewalInvoiceOpen, models.UserStatusActive, models.UserStatusExpired,
+		models.GroupStatusClosed, models.GroupStatusPaused, models.GroupStatusClosing)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel stale renewal invoices: %v", err)
+	}
+	cancelled, _ := result.RowsAffected()
+	return int(cancelled), nil
+}
+
+// GetUserInvoice returns an invoice that belongs to the user
+func (s *RenewalService) GetUserInvoice(userID, invoiceID string) (*models.RenewalInvoice, error) {
+	invoices, err := s.listInvoices(`ri.id = $1 AND ri.user_id = $2`, invoiceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, ErrInvoiceNotFound
+	}
+	return &invoices[0], nil
+}
+
+// ListUserInvoices returns the user's invoices filtered by status (empty for all), newest first
+func (s *RenewalService) ListUserInvoices(userID, status string) ([]models.RenewalInvoice, error) {
+	return s.listInvoices(`ri.user_id = $1 AND ($2 = '' OR ri.status = $2)`, userID, status)
+}
+
+func (s *RenewalService) listInvoices(where string, args ...interface{}) ([]models.RenewalInvoice, error) {
+	rows, err := s.db.Query(`
+		SELECT ri.id, ri.group_id, ri.user_id, ri.period_start, ri.period_end, ri.amount, ri.admin_fee, ri.status,
+		       ri.due_at, ri.transaction_id, ri.paid_at, ri.created_at, ri.updated_at, g.name
+		FROM renewal_invoices ri
+		JOIN groups g ON g.id = ri.group_id
+		WHERE `+where+`
+		ORDER BY ri.created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list renewal invoices: %v", err)
+	}
+	defer rows.Close()
+
+	invoices := []models.RenewalInvoice{}
+	for rows.Next() {
+		var inv models.RenewalInvoice
+		if err := rows.Scan(&inv.ID, &inv.GroupID, &inv.UserID, &inv.PeriodStart, &inv.PeriodEnd, &inv.Amount,
+			&inv.AdminFee, &inv.Status, &inv.DueAt, &inv.TransactionID, &inv.PaidAt, &inv.CreatedAt, &inv.UpdatedAt,
+			&inv.GroupName); err != nil {
+			return nil, fmt.Errorf("failed to scan renewal invoice: %v", err)
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, nil
+}
+
+// PayWithBalance pays an open invoice from the wallet and extends the member's period in one DB transaction
+func (s *RenewalService) PayWithBalance(userID, invoiceID string) (*WalletPaymentResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := lockOpenInvoice(tx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := invoiceBreakdown(inv, pricing.MethodWallet)
+	result, err := s.payments.debitWallet(tx, userID, inv.GroupID, inv.Amount, inv.AdminFee, "completed", nil, &breakdown,
+		fmt.Sprintf("Perpanjangan grup %s dengan saldo", inv.GroupName))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE transactions SET renewal_invoice_id = $1 WHERE id = $2`, invoiceID, result.TransactionID); err != nil {
+		return nil, fmt.Errorf("failed to link renewal payment: %v", err)
+	}
+
+	if err := s.settlement.applyRenewalPayment(tx, UserActor(userID).WithCorrelation(result.TransactionID), invoiceID, result.TransactionID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// StartLinkPayment records a pending payment link transaction for an open invoice, charged in whole rupiah.
+// The invoice is paid when the link settles; a link that settles after the invoice was already paid is
+// refunded to the wallet.
+func (s *RenewalService) StartLinkPayment(userID, invoiceID string, link PaymentLinkDetails) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := lockOpenInvoice(tx, userID, invoiceID)
+	if err != nil {
+		return "", err
+	}
+
+	// Invoices issued before amounts were rounded are brought to the whole rupiah the gateway charges
+	if rounding := inv.Amount.Ceil() - inv.Amount; rounding > 0 {
+		inv.Amount += rounding
+		inv.AdminFee += rounding
+		_, err := tx.Exec(`
+			UPDATE renewal_invoices SET amount = $1, admin_fee = $2, updated_at = NOW() WHERE id = $3
+		`, inv.Amount, inv.AdminFee, invoiceID)
+		if err != nil {
+			return "", fmt.Errorf("failed to round renewal invoice: %v", err)
+		}
+	}
+
+	var transactionID string
+	err = tx.QueryRow(`
+		INSERT INTO transactions (user_id, group_id, type, amount, admin_fee, balance_before, balance_after, description,
+		                          payment_reference, payment_link_id, renewal_invoice_id, price_breakdown, status, created_at, updated_at)
+		VALUES ($1, $2, 'group_payment', $3, $4, 0, 0, $5, $6, $7, $8, $9, 'pending', NOW(), NOW())
+		RETURNING id
+	`, userID, inv.GroupID, inv.Amount, inv.AdminFee, link.Description, link.OrderID, link.PaymentLinkID, invoiceID,
+		invoiceBreakdown(inv, link.PaymentMethod)).Scan(&transactionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return transactionID, nil
+}
+
+// invoiceBreakdown is the price breakdown of a renewal invoice, which keeps the price and fee of the group
+// when it was issued
+func invoiceBreakdown(inv *models.RenewalInvoice, paymentMethod string) pricing.Breakdown {
+	return pricing.Breakdown{
+		PricePerMember: inv.Amount - inv.AdminFee,
+		FixedFee:       inv.AdminFee,
+		AdminFee:       inv.AdminFee,
+		PaymentMethod:  paymentMethod,
+		Total:          inv.Amount,
+	}
+}
+
+// lockOpenInvoice locks an invoice of the user and checks that it can still be paid
+func lockOpenInvoice(tx *sql.Tx, userID, invoiceID string) (*models.RenewalInvoice, error) {
+	var inv models.RenewalInvoice
+	err := tx.QueryRow(`
+		SELECT ri.id, ri.group_id, ri.user_id, ri.amount, ri.admin_fee, ri.status, g.name
+		FROM renewal_invoices ri
+		JOIN groups g ON g.id = ri.group_id
+		WHERE ri.id = $1 AND ri.user_id = $2
+		FOR UPDATE OF ri
+	`, invoiceID, userID).Scan(&inv.ID, &inv.GroupID, &inv.UserID, &inv.Amount, &inv.AdminFee, &inv.Status, &inv.GroupName)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock renewal invoice: %v", err)
+	}
+	if inv.Status != models.RenewalInvoiceOpen {
+		return nil, ErrInvoiceNotOpen
+	}
+
+	var userStatus string
+	err = tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE group_id = $1 AND user_id = $2
+	`, inv.GroupID, userID).Scan(&userStatus)
+	if err == sql.ErrNoRows {
+		return nil, ErrRenewalClosed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member status: %v", err)
+	}
+	if userStatus != models.UserStatusActive && userStatus != models.UserStatusExpired {
+		return nil, ErrRenewalClosed
+	}
+	if userStatus == models.UserStatusExpired {
+		free, err := seatFreeForExpired(tx, inv.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if !free {
+			return nil, ErrGroupFull
+		}
+	}
+	return &inv, nil
+}
+
+// applyRenewalPayment marks an invoice paid by transactionID and extends the member's period. Active members
+// move on to the invoiced period, which starts where their current one ends; expired members are reactivated
+// with a period starting now.
+// A payment that can no longer be applied (invoice already paid or cancelled, member gone) is refunded to the wallet.
+func (s *PaymentSettlement) applyRenewalPayment(tx *sql.Tx, actor Actor, invoiceID, transactionID string) error {
+	var inv models.RenewalInvoice
+	err := tx.QueryRow(`
+		SELECT ri.group_id, ri.user_id, ri.period_start, ri.period_end, ri.status, g.name
+		FROM renewal_invoices ri
+		JOIN groups g ON g.id = ri.group_id
+		WHERE ri.id = $1
+		FOR UPDATE OF ri
+	`, invoiceID).Scan(&inv.GroupID, &inv.UserID, &inv.PeriodStart, &inv.PeriodEnd, &inv.Status, &inv.GroupName)
+	if err != nil {
+		return fmt.Errorf("failed to lock renewal invoice: %v", err)
+	}
+
+	// The group is locked before the member, in the order seats are reserved in
+	if _, err := tx.Exec(`SELECT id FROM groups WHERE id = $1 FOR UPDATE`, inv.GroupID); err != nil {
+		return fmt.Errorf("failed to lock group: %v", err)
+	}
+
+	var userStatus string
+	err = tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE
+	`, inv.GroupID, inv.UserID).Scan(&userStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get member status: %v", err)
+	}
+
+	// An expired member no longer holds a seat, so the group may have filled up since the invoice was paid
+	seatTaken := false
+	if inv.Status == models.RenewalInvoiceOpen && userStatus == models.UserStatusExpired {
+		free, err := seatFreeForExpired(tx, inv.GroupID)
+		if err != nil {
+			return err
+		}
+		seatTaken = !free
+	}
+
+	if seatTaken || inv.Status != models.RenewalInvoiceOpen || (userStatus != models.UserStatusActive && userStatus != models.UserStatusExpired) {
+		fmt.Printf("[SALOME BE] Renewal invoice %s is %s (member %s), refunding payment %s\n", invoiceID, inv.Status, userStatus, transactionID)
+		_, err := s.refundToWallet(tx, transactionID, fmt.Sprintf("Pengembalian dana perpanjangan grup %s", inv.GroupName))
+		return err
+	}
+
+	periodEnd := inv.PeriodEnd
+	if userStatus == models.UserStatusExpired {
+		if err := s.stateMachine.UpdateUserStatusTx(tx, actor, inv.UserID, inv.GroupID, models.UserStatusActive, "Renewal paid"); err != nil {
+			return err
+		}
+		now := time.Now()
+		periodEnd = now.AddDate(0, config.GetConfig().Renewal.PeriodMonths, 0)
+		_, err = tx.Exec(`
+			UPDATE group_members SET subscription_period_start = $1, subscription_period_end = $2
+			WHERE group_id = $3 AND user_id = $4
+		`, now, periodEnd, inv.GroupID, inv.UserID)
+	} else {
+		// The member's period becomes the one this payment paid for, so refunds and disputes are prorated over it
+		// rather than over every period paid so far
+		_, err = tx.Exec(`
+			UPDATE group_members SET subscription_period_start = $1, subscription_period_end = $2
+			WHERE group_id = $3 AND user_id = $4
+		`, inv.PeriodStart, periodEnd, inv.GroupID, inv.UserID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extend subscription period: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE renewal_invoices SET status = $1, transaction_id = $2, paid_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+	`, models.RenewalInvoicePaid, transactionID, invoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to mark renewal invoice paid: %v", err)
+	}
+
+	fmt.Printf("[SALOME BE] Renewal paid: GroupID=%s, UserID=%s, PeriodEnd=%s\n", inv.GroupID, inv.UserID, periodEnd.Format(time.RFC3339))
+	return notify(tx, inv.UserID, "payment", "Langganan diperpanjang",
+		fmt.Sprintf("Langganan grup %s aktif sampai %s.", inv.GroupName, periodEnd.Format("02 Jan 2006")),
+		"/groups/"+inv.GroupID, "Lihat Grup")
+}
+
+// refundToWallet credits a settled group payment back to the payer's wallet as a new refund transaction
+func (s *PaymentSettlement) refundToWallet(tx *sql.Tx, transactionID, description string) (*WalletPaymentResult, error) {
+	var userID string
+	var groupID *string
+	var amount, adminFee money.Amount
+	err := tx.QueryRow(`
+		SELECT user_id, group_id, amount, COALESCE(admin_fee, 0) FROM transactions WHERE id = $1
+	`, transactionID).Scan(&userID, &groupID, &amount, &adminFee)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction to refund: %v", err)
+	}
+	return bookWalletRefund(tx, s.ledger, userID, groupID, transactionID, amount, adminFee, description)
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRatingSelf        = errors.New("users cannot rate themselves")
+	ErrRatingNotAllowed  = errors.New("members can only rate the owner and the owner can only rate members")
+	ErrRatingNotEligible = errors.New("ratings open once a billing period in this group has ended")
+)
+
+// The score starts from the ratings received, averaged towards reputationPriorRating as if every user had
+// reputationPriorWeight ratings of that value, so a single rating cannot make or break anyone. Late
+// payments, removals and disputes upheld against the user as owner are then subtracted.
+const (
+	reputationPriorRating  = 4.0
+	reputationPriorWeight  = 3.0
+	latePaymentPenalty     = 5.0
+	removalPenalty         = 10.0
+	disputePenalty         = 10.0
+	NeutralReputationScore = (reputationPriorRating - 1) * 25 // the score of a user without ratings or penalties
+)
+
+// reputationRemovalReasons are the member removals that count against a user: not paying in time and being
+// removed by an admin. Leaving and the closure of a group do not.
+var reputationRemovalReasons = []string{"Payment timeout", models.RefundReasonAdminRemove}
+
+// OwnerReputationColumns selects the reputation score, owner rating and owner rating count of a group owner
+// from the user_reputation row joined as ur, with the neutral score for owners without one yet
+var OwnerReputationColumns = fmt.Sprintf(
+	`COALESCE(ur.score, %v), ur.owner_rating, COALESCE(ur.owner_rating_count, 0)`, NeutralReputationScore)
+
+// OwnerReputationOrder sorts groups by the reputation of their owner joined as ur, best first
+var OwnerReputationOrder = fmt.Sprintf(
+	`COALESCE(ur.score, %v) DESC, COALESCE(ur.owner_rating_count, 0) DESC`, NeutralReputationScore)
+
+// ReputationService records the ratings members and owners give each other after a billing period, and
+// aggregates them with the payment record of each user into a reputation score.
+type ReputationService struct {
+	db *sql.DB
+}
+
+func NewReputationService(db *sql.DB) *ReputationService {
+	return &ReputationService{db: db}
+}
+
+const ratingColumns = `r.id, r.group_id, r.rater_id, r.ratee_id, r.ratee_role, r.period_end, r.score, r.comment,
+	r.created_at, r.updated_at, g.name, COALESCE(u.full_name, '')`
+
+const ratingJoins = `
+	FROM user_ratings r
+	JOIN groups g ON g.id = r.group_id
+	JOIN users u ON u.id = r.rater_id`
+
+func scanRating(row rowScanner) (*models.UserRating, error) {
+	var r models.UserRating
+	err := row.Scan(&r.ID, &r.GroupID, &r.RaterID, &r.RateeID, &r.RateeRole, &r.PeriodEnd, &r.Score, &r.Comment,
+		&r.CreatedAt, &r.UpdatedAt, &r.GroupName, &r.RaterName)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Rate records the rater's rating of the owner, or of a member when the rater owns the group, for the last
+// ended billing period of the member. Rating again in the same period replaces the earlier rating.
+func (s *ReputationService) Rate(raterID, groupID string, req models.RatingRequest) (*models.UserRating, error) {
+	if raterID == req.RateeID {
+		return nil, ErrRatingSelf
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var ownerID string
+	err = tx.QueryRow(`
+		SELECT owner_id FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+	`, groupID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+
+	// Both directions are about the member's billing period
+	role, memberID := models.RatingRoleOwner, raterID
+	switch {
+	case raterID == ownerID:
+		role, memberID = models.RatingRoleMember, req.RateeID
+	case req.RateeID != ownerID:
+		return nil, ErrRatingNotAllowed
+	}
+
+	periodEnd, err := endedPeriod(tx, groupID, memberID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if periodEnd == nil {
+		return nil, ErrRatingNotEligible
+	}
+
+	var ratingID string
+	err = tx.QueryRow(`
+		INSERT INTO user_ratings (group_id, rater_id, ratee_id, ratee_role, period_end, score, comment, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (group_id, rater_id, ratee_id, period_end)
+		DO UPDATE SET score = EXCLUDED.score, comment = EXCLUDED.comment, updated_at = NOW()
+		RETURNING id
+	`, groupID, raterID, req.RateeID, role, *periodEnd, req.Score, nullIfEmpty(strings.TrimSpace(req.Comment))).Scan(&ratingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save rating: %v", err)
+	}
+	if err := recalculateReputation(tx, req.RateeID); err != nil {
+		return nil, err
+	}
+
+	rating, err := scanRating(tx.QueryRow(`SELECT `+ratingColumns+ratingJoins+` WHERE r.id = $1`, ratingID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] User %s rated %s %s %d/5 in group %s\n", raterID, role, req.RateeID, req.Score, groupID)
+	return rating, nil
+}
+
+// Targets returns who the user can rate in a group: the owner for a member, every member for the owner
+func (s *ReputationService) Targets(userID, groupID string) ([]models.RatingTarget, error) {
+	var ownerID, ownerName string
+	var isMember bool
+	err := s.db.QueryRow(`
+		SELECT g.owner_id, COALESCE(u.full_name, ''),
+		       EXISTS(SELECT 1 FROM group_members WHERE group_id = g.id AND user_id = $2)
+		FROM groups g
+		JOIN users u ON u.id = g.owner_id
+		WHERE g.id = $1 AND (g.is_deleted IS NULL OR g.is_deleted = false)
+	`, groupID, userID).Scan(&ownerID, &ownerName, &isMember)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+
+	now := time.Now()
+	targets := []models.RatingTarget{}
+	if userID != ownerID {
+		if !isMember {
+			return nil, ErrRatingNotAllowed
+		}
+		target := models.RatingTarget{UserID: ownerID, FullName: ownerName, Role: models.RatingRoleOwner}
+		if err := s.fillTarget(&target, groupID, userID, userID, now); err != nil {
+			return nil, err
+		}
+		return append(targets, target), nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT gm.user_id, COALESCE(u.full_name, '')
+		FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.group_id = $1 AND gm.user_id <> $2
+		ORDER BY gm.joined_at ASC
+	`, groupID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %v", err)
+	}
+	for rows.Next() {
+		target := models.RatingTarget{Role: models.RatingRoleMember}
+		if err := rows.Scan(&target.UserID, &target.FullName); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan group member: %v", err)
+		}
+		targets = append(targets, target)
+	}
+	rows.Close()
+
+	for i := range targets {
+		if err := s.fillTarget(&targets[i], groupID, ownerID, targets[i].UserID, now); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// fillTarget sets the ended billing period of memberID and the rating raterID gave for it
+func (s *ReputationService) fillTarget(target *models.RatingTarget, groupID, raterID, memberID string, now time.Time) error {
+	periodEnd, err := endedPeriod(s.db, groupID, memberID, now)
+	if err != nil || periodEnd == nil {
+		return err
+	}
+	target.PeriodEnd = periodEnd
+	target.CanRate = true
+
+	rating, err := scanRating(s.db.QueryRow(`
+		SELECT `+ratingColumns+ratingJoins+`
+		WHERE r.group_id = $1 AND r.rater_id = $2 AND r.ratee_id = $3 AND r.period_end = $4
+	`, groupID, raterID, target.UserID, *periodEnd))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get rating: %v", err)
+	}
+	target.Rating = rating
+	return nil
+}
+
+// Received returns the most recent ratings a user received
+func (s *ReputationService) Received(userID string, limit int) ([]models.UserRating, error) {
+	rows, err := s.db.Query(`
+		SELECT `+ratingColumns+ratingJoins+`
+		WHERE r.ratee_id = $1
+		ORDER BY r.created_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ratings: %v", err)
+	}
+	defer rows.Close()
+
+	ratings := []models.UserRating{}
+	for rows.Next() {
+		rating, err := scanRating(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rating: %v", err)
+		}
+		ratings = append(ratings, *rating)
+	}
+	return ratings, rows.Err()
+}
+
+// Get returns the reputation of a user, neutral when it has not been calculated yet
+func (s *ReputationService) Get(userID string) (*models.UserReputation, error) {
+	reputations, err := s.ForUsers([]string{userID})
+	if err != nil {
+		return nil, err
+	}
+	rep := reputations[userID]
+	return &rep, nil
+}
+
+// ForUsers returns the reputation of each user, neutral for users without one yet
+func (s *ReputationService) ForUsers(userIDs []string) (map[string]models.UserReputation, error) {
+	reputations := make(map[string]models.UserReputation, len(userIDs))
+	for _, id := range userIDs {
+		reputations[id] = models.UserReputation{Score: NeutralReputationScore}
+	}
+	if len(userIDs) == 0 {
+		return reputations, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT user_id, score, owner_rating, owner_rating_count, member_rating, member_rating_count,
+		       late_payments, removals, disputes, updated_at
+		FROM user_reputation
+		WHERE user_id::text = ANY($1)
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reputation: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		var rep models.UserReputation
+		if err := rows.Scan(&userID, &rep.Score, &rep.OwnerRating, &rep.OwnerRatingCount, &rep.MemberRating,
+			&rep.MemberRatingCount, &rep.LatePayments, &rep.Removals, &rep.Disputes, &rep.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reputation: %v", err)
+		}
+		reputations[userID] = rep
+	}
+	return reputations, rows.Err()
+}
+
+// RecalculateAll refreshes the reputation of every user, picking up late payments, removals and disputes
+// since the last run. It returns the number of users updated.
+func (s *ReputationService) RecalculateAll() (int, error) {
+	result, err := s.db.Exec(recalculateReputationQuery, "", latePaymentPenalty, removalPenalty,
+		reputationPriorRating, reputationPriorWeight, pq.Array(reputationRemovalReasons), disputePenalty)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recalculate reputation: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// recalculateReputation refreshes the reputation of one user
+func recalculateReputation(q database.Queryer, userID string) error {
+	_, err := q.Exec(recalculateReputationQuery, userID, latePaymentPenalty, removalPenalty,
+		reputationPriorRating, reputationPriorWeight, pq.Array(reputationRemovalReasons), disputePenalty)
+	if err != nil {
+		return fmt.Errorf("failed to recalculate reputation: %v", err)
+	}
+	return nil
+}
+
+// recalculateReputationQuery upserts the reputation of the user $1, or of every user when $1 is empty.
+// Removals come from the member status history, which keeps every removed_reason even after the member
+// joins the group again. Disputes count against the owner unless an admin dismissed them.
+const recalculateReputationQuery = `
+	WITH signals AS (
+		SELECT u.id AS user_id,
+		       o.rating AS owner_rating, COALESCE(o.count, 0) AS owner_count, COALESCE(o.total, 0) AS owner_total,
+		       m.rating AS member_rating, COALESCE(m.count, 0) AS member_count, COALESCE(m.total, 0) AS member_total,
+		       COALESCE(l.count, 0) AS late_payments, COALESCE(rm.count, 0) AS removals, COALESCE(d.count, 0) AS disputes
+		FROM users u
+		LEFT JOIN (
+			SELECT ratee_id, AVG(score) AS rating, COUNT(*) AS count, SUM(score) AS total
+			FROM user_ratings WHERE ratee_role = 'owner' GROUP BY ratee_id
+		) o ON o.ratee_id = u.id
+		LEFT JOIN (
+			SELECT ratee_id, AVG(score) AS rating, COUNT(*) AS count, SUM(score) AS total
+			FROM user_ratings WHERE ratee_role = 'member' GROUP BY ratee_id
+		) m ON m.ratee_id = u.id
+		LEFT JOIN (
+			SELECT user_id, COUNT(*) AS count FROM renewal_invoices
+			WHERE (status = 'paid' AND paid_at > due_at) OR (status = 'open' AND due_at < NOW())
+			GROUP BY user_id
+		) l ON l.user_id = u.id
+		LEFT JOIN (
+			SELECT user_id, COUNT(*) AS count FROM status_events
+			WHERE entity_type = 'member' AND to_status = 'removed' AND reason = ANY($6)
+			GROUP BY user_id
+		) rm ON rm.user_id = u.id
+		LEFT JOIN (
+			SELECT owner_id, COUNT(*) AS count FROM group_disputes
+			WHERE status = 'resolved' AND resolution <> 'dismissed'
+			GROUP BY owner_id
+		) d ON d.owner_id = u.id
+		WHERE $1 = '' OR u.id::text = $1
+	)
+	INSERT INTO user_reputation (user_id, score, owner_rating, owner_rating_count, member_rating, member_rating_count,
+	                             late_payments, removals, disputes, updated_at)
+	SELECT user_id,
+	       GREATEST(0, LEAST(100,
+	           ((owner_total + member_total + $4::numeric * $5::numeric) / (owner_count + member_count + $5::numeric) - 1) * 25
+	           - late_payments * $2::numeric - removals * $3::numeric - disputes * $7::numeric)),
+	       owner_rating, owner_count, member_rating, member_count, late_payments, removals, disputes, NOW()
+	FROM signals
+	ON CONFLICT (user_id) DO UPDATE SET
+		score = EXCLUDED.score,
+		owner_rating = EXCLUDED.owner_rating,
+		owner_rating_count = EXCLUDED.owner_rating_count,
+		member_rating = EXCLUDED.member_rating,
+		member_rating_count = EXCLUDED.member_rating_count,
+		late_payments = EXCLUDED.late_payments,
+		removals = EXCLUDED.removals,
+		disputes = EXCLUDED.disputes,
+		updated_at = EXCLUDED.updated_at
+`
+
+// endedPeriod returns the end of the member's last billing period in the group that has ended by now, or nil
+// when none has. A paid renewal that has started means the period before it ended.
+func endedPeriod(q database.Queryer, groupID, memberID string, now time.Time) (*time.Time, error) {
+	var periodEnd *time.Time
+	err := q.QueryRow(`
+		SELECT GREATEST(
+			CASE WHEN gm.subscription_period_end <= $3 THEN gm.subscription_period_end END,
+			(SELECT MAX(ri.period_start) FROM renewal_invoices ri
+			 WHERE ri.group_id = gm.group_id AND ri.user_id = gm.user_id AND ri.status = $4 AND ri.period_start <= $3)
+		)
+		FROM group_members gm
+		WHERE gm.group_id = $1 AND gm.user_id = $2
+	`, groupID, memberID, now, models.RenewalInvoicePaid).Scan(&periodEnd)
+	if err == sql.ErrNoRows {
+		return nil, ErrRatingNotAllowed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing period: %v", err)
+	}
+	return periodEnd, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+	"salome-be/internal/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrGroupNotJoinable = errors.New("group is not accepting new members")
+	ErrGroupFull        = errors.New("group is full")
+	ErrAlreadyMember    = errors.New("user is already a member of this group")
+)
+
+// SeatReservation is a pending seat held for a user until they pay or the payment deadline passes
+type SeatReservation struct {
+	MemberID        string       `json:"member_id"`
+	GroupID         string       `json:"group_id"`
+	GroupName       string       `json:"group_name"`
+	UserStatus      string       `json:"user_status"`
+	PaymentAmount   money.Amount `json:"payment_amount"`
+	PaymentDeadline time.Time    `json:"payment_deadline"`
+	GroupStatus     string       `json:"group_status"`
+}
+
+// SeatService hands out group seats. The group row is locked while a seat is taken, so concurrent joins
+// cannot put more members in a group than max_members.
+type SeatService struct {
+	db           *sql.DB
+	stateMachine *StateMachineService
+}
+
+func NewSeatService(db *sql.DB) *SeatService {
+	return &SeatService{
+		db:           db,
+		stateMachine: NewStateMachineService(db),
+	}
+}
+
+// Reserve holds a pending seat in the group for the user. The seat is released by the payment timeout job
+// if it is not paid within models.PaymentTimeoutHours.
+func (s *SeatService) Reserve(userID, groupID string) (*SeatReservation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	reservation, err := s.reserveTx(tx, UserActor(userID), userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+	}
+	return reservation, nil
+}
+
+// reserveTx holds a pending seat in the group within tx. The group row stays locked until tx ends.
+func (s *SeatService) reserveTx(tx *sql.Tx, actor Actor, userID, groupID string) (*SeatReservation, error) {
+	var groupName, groupStatus string
+	var maxMembers int
+	var pricePerMember, adminFee money.Amount
+	err := tx.QueryRow(`
+		SELECT name, group_status, max_members, COALESCE(price_per_member, 0), COALESCE(admin_fee, 0)
+		FROM groups
+		WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+		FOR UPDATE
+	`, groupID).Scan(&groupName, &groupStatus, &maxMembers, &pricePerMember, &adminFee)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock group: %v", err)
+	}
+
+	switch groupStatus {
+	case models.GroupStatusClosed, models.GroupStatusPaused, models.GroupStatusClosing:
+		return nil, ErrGroupNotJoinable
+	}
+
+	// A member who was removed or whose subscription expired keeps their row, so rejoining reuses it
+	var memberID, memberStatus string
+	err = tx.QueryRow(`
+		SELECT id, user_status FROM group_members WHERE group_id = $1 AND user_id = $2
+	`, groupID, userID).Scan(&memberID, &memberStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check membership: %v", err)
+	}
+	if err == nil && memberStatus != models.UserStatusRemoved && memberStatus != models.UserStatusExpired {
+		return nil, ErrAlreadyMember
+	}
+
+	// Users on the waitlist have first claim on free seats: while anyone is waiting, only a user who was offered
+	// a seat can take one, and the group is full for everyone else
+	var offered, queued bool
+	err = tx.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM group_waitlist WHERE group_id = $1 AND user_id = $2 AND status = $3 AND offer_expires_at > NOW()),
+			EXISTS(SELECT 1 FROM group_waitlist WHERE group_id = $1 AND status = $4)
+	`, groupID, userID, models.WaitlistOffered, models.WaitlistWaiting).Scan(&offered, &queued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check waitlist: %v", err)
+	}
+	if queued && !offered {
+		return nil, ErrGroupFull
+	}
+
+	// Taking a seat ends the user's place on the waitlist. A seat offered to them is used for this
+	// reservation, since it no longer counts as held once claimed.
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE group_waitlist SET status = $1, claimed_at = $2, updated_at = $2
+		WHERE group_id = $3 AND user_id = $4 AND status IN ($5, $6)
+	`, models.WaitlistClaimed, now, groupID, userID, models.WaitlistWaiting, models.WaitlistOffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update waitlist: %v", err)
+	}
+
+	seatsHeld, err := countHeldSeats(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if seatsHeld >= maxMembers {
+		return nil, ErrGroupFull
+	}
+
+	reservation := &SeatReservation{
+		GroupID:         groupID,
+		GroupName:       groupName,
+		UserStatus:      models.UserStatusPending,
+		PaymentAmount:   pricePerMember + adminFee,
+		PaymentDeadline: now.Add(time.Duration(models.PaymentTimeoutHours) * time.Hour),
+	}
+
+	if memberID == "" {
+		reservation.MemberID = uuid.New().String()
+		_, err = tx.Exec(`
+			INSERT INTO group_members (id, group_id, user_id, role, joined_at, user_status, payment_amount, payment_deadline)
+			VALUES ($1, $2, $3, 'member', $4, $5, $6, $7)
+		`, reservation.MemberID, groupID, userID, now, models.UserStatusPending, reservation.PaymentAmount,
+			reservation.PaymentDeadline)
+	} else {
+		reservation.MemberID = memberID
+		_, err = tx.Exec(`
+			UPDATE group_members
+			SET role = 'member', joined_at = $1, user_status = $2, payment_amount = $3, payment_deadline = $4,
+			    paid_at = NULL, activated_at = NULL, expired_at = NULL, removed_at = NULL, removed_reason = NULL,
+			    subscription_period_start = NULL, subscription_period_end = NULL
+			WHERE id = $5
+		`, now, models.UserStatusPending, reservation.PaymentAmount, reservation.PaymentDeadline, memberID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve seat: %v", err)
+	}
+	err = recordStatusEvent(tx, actor, statusChange{
+		groupID: groupID, userID: userID, from: memberStatus, to: models.UserStatusPending, reason: "Seat reserved",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.stateMachine.SyncGroupCapacity(tx, actor, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRow(`SELECT group_status FROM groups WHERE id = $1`, groupID).Scan(&reservation.GroupStatus); err != nil {
+		return nil, fmt.Errorf("failed to read group status: %v", err)
+	}
+	return reservation, nil
+}
+
+// countHeldSeats returns the seats of a group that are taken: members who have not been removed and whose
+// subscription has not expired, including pending ones, and seats offered to users on the waitlist whose claim
+// window is still open.
+func countHeldSeats(q database.Queryer, groupID string) (int, error) {
+	var seatsHeld int
+	err := q.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM group_members WHERE group_id = $1 AND user_status NOT IN ($2, $3)) +
+			(SELECT COUNT(*) FROM group_waitlist WHERE group_id = $1 AND status = $4 AND offer_expires_at > NOW())
+	`, groupID, models.UserStatusRemoved, models.UserStatusExpired, models.WaitlistOffered).Scan(&seatsHeld)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group seats: %v", err)
+	}
+	return seatsHeld, nil
+}
+
+// seatFreeForExpired locks the group and reports whether an expired member can take a seat again. Expired
+// members do not hold their seat, so it may have gone to someone else by the time they renew.
+func seatFreeForExpired(tx *sql.Tx, groupID string) (bool, error) {
+	var maxMembers int
+	err := tx.QueryRow(`SELECT max_members FROM groups WHERE id = $1 FOR UPDATE`, groupID).Scan(&maxMembers)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock group: %v", err)
+	}
+	seatsHeld, err := countHeldSeats(tx, groupID)
+	if err != nil {
+		return false, err
+	}
+	return seatsHeld < maxMembers, nil
+}
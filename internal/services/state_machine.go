@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"salome-be/internal/database"
 	"salome-be/internal/models"
+
+	"github.com/lib/pq"
 )
 
 type StateMachineService struct {
@@ -19,12 +22,59 @@ func NewStateMachineService(db *sql.DB) *StateMachineService {
 // User State Machine Methods
 
 // UpdateUserStatus updates user status in a group with validation
-func (s *StateMachineService) UpdateUserStatus(userID, groupID, newStatus, reason string) error {
+func (s *StateMachineService) UpdateUserStatus(actor Actor, userID, groupID, newStatus, reason string) error {
+	return s.UpdateUserStatusTx(s.db, actor, userID, groupID, newStatus, reason)
+}
+
+// UpdateUserStatusTx is UpdateUserStatus running on the given transaction (or *sql.DB). The transition is
+// recorded in status_events on the same transaction.
+func (s *StateMachineService) UpdateUserStatusTx(q database.Queryer, actor Actor, userID, groupID, newStatus, reason string) error {
 	// Validate status transition
-	if !s.isValidUserStatusTransition(userID, groupID, newStatus) {
+	currentStatus, err := s.currentUserStatus(q, userID, groupID)
+	if err != nil || !s.isValidUserStatusTransition(currentStatus, newStatus) {
 		return fmt.Errorf("invalid status transition")
 	}
 
+	if err := s.setUserStatus(q, userID, groupID, newStatus, reason); err != nil {
+		return err
+	}
+	err = recordStatusEvent(q, actor, statusChange{
+		groupID: groupID, userID: userID, from: currentStatus, to: newStatus, reason: reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Check if all users are paid and activate group
+	if newStatus == models.UserStatusPaid {
+		if err := s.checkAndActivateGroup(q, actor, groupID); err != nil {
+			return fmt.Errorf("failed to activate group: %v", err)
+		}
+	}
+
+	// Removed and expired members release their seat; an expired member who renews takes one again
+	switch newStatus {
+	case models.UserStatusRemoved, models.UserStatusExpired, models.UserStatusActive:
+		if err := s.SyncGroupCapacity(q, actor, groupID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// currentUserStatus returns the status of a member
+func (s *StateMachineService) currentUserStatus(q database.Queryer, userID, groupID string) (string, error) {
+	var currentStatus string
+	err := q.QueryRow(`
+		SELECT user_status FROM group_members 
+		WHERE user_id = $1 AND group_id = $2
+	`, userID, groupID).Scan(&currentStatus)
+	return currentStatus, err
+}
+
+// setUserStatus writes a member status and the timestamp that goes with it
+func (s *StateMachineService) setUserStatus(q database.Queryer, userID, groupID, newStatus, reason string) error {
 	now := time.Now()
 	var query string
 	var args []interface{}
@@ -67,31 +117,14 @@ func (s *StateMachineService) UpdateUserStatus(userID, groupID, newStatus, reaso
 		args = []interface{}{newStatus, userID, groupID}
 	}
 
-	_, err := s.db.Exec(query, args...)
-	if err != nil {
+	if _, err := q.Exec(query, args...); err != nil {
 		return fmt.Errorf("failed to update user status: %v", err)
 	}
-
-	// Check if all users are paid and activate group
-	if newStatus == models.UserStatusPaid {
-		s.checkAndActivateGroup(groupID)
-	}
-
 	return nil
 }
 
 // isValidUserStatusTransition validates if status transition is allowed
-func (s *StateMachineService) isValidUserStatusTransition(userID, groupID, newStatus string) bool {
-	var currentStatus string
-	err := s.db.QueryRow(`
-		SELECT user_status FROM group_members 
-		WHERE user_id = $1 AND group_id = $2
-	`, userID, groupID).Scan(&currentStatus)
-
-	if err != nil {
-		return false
-	}
-
+func (s *StateMachineService) isValidUserStatusTransition(currentStatus, newStatus string) bool {
 	// Define valid transitions
 	validTransitions := map[string][]string{
 		models.UserStatusPending: {models.UserStatusPaid, models.UserStatusRemoved},
@@ -114,57 +147,117 @@ func (s *StateMachineService) isValidUserStatusTransition(userID, groupID, newSt
 	return false
 }
 
+// memberSeat is the role and status of a group_members row
+type memberSeat struct {
+	role   string
+	status string
+}
+
+// allSeatsPaid reports whether every member holding a seat has paid and at least one of them is waiting to be
+// activated. The owner's row does not hold a paid seat, and removed or expired members, whose rows stay in
+// group_members, no longer hold one. Members already active keep their seat paid.
+func allSeatsPaid(members []memberSeat) bool {
+	var seats, settled, paid int
+	for _, m := range members {
+		if m.role == "owner" || m.status == models.UserStatusRemoved || m.status == models.UserStatusExpired {
+			continue
+		}
+		seats++
+		switch m.status {
+		case models.UserStatusPaid:
+			paid++
+			settled++
+		case models.UserStatusActive:
+			settled++
+		}
+	}
+	return paid > 0 && seats == settled
+}
+
 // checkAndActivateGroup checks if all members are paid and activates the group
-func (s *StateMachineService) checkAndActivateGroup(groupID string) error {
-	// Count total members and paid members
-	var totalMembers, paidMembers int
-	err := s.db.QueryRow(`
-		SELECT 
-			COUNT(*) as total,
-			COUNT(CASE WHEN user_status = $1 THEN 1 END) as paid
-		FROM group_members 
-		WHERE group_id = $2
-	`, models.UserStatusPaid, groupID).Scan(&totalMembers, &paidMembers)
+func (s *StateMachineService) checkAndActivateGroup(q database.Queryer, actor Actor, groupID string) error {
+	var groupStatus string
+	if err := q.QueryRow(`SELECT group_status FROM groups WHERE id = $1`, groupID).Scan(&groupStatus); err != nil {
+		return err
+	}
 
+	rows, err := q.Query(`
+		SELECT COALESCE(role, 'member'), user_status FROM group_members WHERE group_id = $1
+	`, groupID)
 	if err != nil {
 		return err
 	}
+	var members []memberSeat
+	for rows.Next() {
+		var m memberSeat
+		if err := rows.Scan(&m.role, &m.status); err != nil {
+			rows.Close()
+			return err
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
 	// If all members are paid, activate the group
-	if totalMembers > 0 && totalMembers == paidMembers {
+	if allSeatsPaid(members) {
 		// Use Asia/Jakarta timezone
 		loc, _ := time.LoadLocation("Asia/Jakarta")
 		now := time.Now().In(loc)
 
-		// Update group status
-		_, err = s.db.Exec(`
-			UPDATE groups 
-			SET group_status = $1, all_paid_at = $2
-			WHERE id = $3
-		`, models.GroupStatusPaidGroup, now, groupID)
-
-		if err != nil {
-			return err
+		// Groups taking members become paid groups; paused and closing groups keep their status and their
+		// paid members wait until the group runs again
+		switch groupStatus {
+		case models.GroupStatusPaidGroup:
+		case models.GroupStatusOpen, models.GroupStatusPrivate, models.GroupStatusFull:
+			if err := s.UpdateGroupStatusTx(q, actor, groupID, models.GroupStatusPaidGroup, "All members paid"); err != nil {
+				return err
+			}
+		default:
+			return nil
 		}
 
 		// Activate all members
-		_, err = s.db.Exec(`
+		rows, err := q.Query(`
 			UPDATE group_members 
 			SET user_status = $1, activated_at = $2
 			WHERE group_id = $3 AND user_status = $4
+			RETURNING user_id
 		`, models.UserStatusActive, now, groupID, models.UserStatusPaid)
 
 		if err != nil {
 			return err
 		}
+		var activated []string
+		for rows.Next() {
+			var userID string
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return err
+			}
+			activated = append(activated, userID)
+		}
+		rows.Close()
+		for _, userID := range activated {
+			err := recordStatusEvent(q, actor, statusChange{
+				groupID: groupID, userID: userID, from: models.UserStatusPaid, to: models.UserStatusActive,
+				reason: "All members paid",
+			})
+			if err != nil {
+				return err
+			}
+		}
 
-		// Set subscription period (example: 1 month)
+		// Start the subscription period (1 month) of the members activated now; members already active
+		// keep theirs
 		subscriptionEnd := now.AddDate(0, 1, 0)
-		_, err = s.db.Exec(`
-			UPDATE group_members 
+		_, err = q.Exec(`
+			UPDATE group_members
 			SET subscription_period_start = $1, subscription_period_end = $2
-			WHERE group_id = $3 AND user_status = $4
-		`, now, subscriptionEnd, groupID, models.UserStatusActive)
+			WHERE group_id = $3 AND user_id::text = ANY($4)
+		`, now, subscriptionEnd, groupID, pq.Array(activated))
 
 		return err
 	}
@@ -175,12 +268,36 @@ func (s *StateMachineService) checkAndActivateGroup(groupID string) error {
 // Group State Machine Methods
 
 // UpdateGroupStatus updates group status with validation
-func (s *StateMachineService) UpdateGroupStatus(groupID, newStatus string) error {
+func (s *StateMachineService) UpdateGroupStatus(actor Actor, groupID, newStatus, reason string) error {
+	return s.UpdateGroupStatusTx(s.db, actor, groupID, newStatus, reason)
+}
+
+// UpdateGroupStatusTx is UpdateGroupStatus running on the given transaction (or *sql.DB). The transition is
+// recorded in status_events on the same transaction.
+func (s *StateMachineService) UpdateGroupStatusTx(q database.Queryer, actor Actor, groupID, newStatus, reason string) error {
 	// Validate status transition
-	if !s.isValidGroupStatusTransition(groupID, newStatus) {
+	currentStatus, err := s.currentGroupStatus(q, groupID)
+	if err != nil || !s.isValidGroupStatusTransition(currentStatus, newStatus) {
 		return fmt.Errorf("invalid group status transition")
 	}
 
+	if err := s.setGroupStatus(q, groupID, newStatus); err != nil {
+		return err
+	}
+	return recordStatusEvent(q, actor, statusChange{groupID: groupID, from: currentStatus, to: newStatus, reason: reason})
+}
+
+// currentGroupStatus returns the status of a group
+func (s *StateMachineService) currentGroupStatus(q database.Queryer, groupID string) (string, error) {
+	var currentStatus string
+	err := q.QueryRow(`
+		SELECT group_status FROM groups WHERE id = $1
+	`, groupID).Scan(&currentStatus)
+	return currentStatus, err
+}
+
+// setGroupStatus writes a group status and the timestamp that goes with it
+func (s *StateMachineService) setGroupStatus(q database.Queryer, groupID, newStatus string) error {
 	now := time.Now()
 	var query string
 	var args []interface{}
@@ -189,40 +306,35 @@ func (s *StateMachineService) UpdateGroupStatus(groupID, newStatus string) error
 	case models.GroupStatusPaidGroup:
 		query = `
 			UPDATE groups 
-			SET group_status = $1, all_paid_at = $2
+			SET group_status = $1, all_paid_at = $2, updated_at = $2
 			WHERE id = $3
 		`
 		args = []interface{}{newStatus, now, groupID}
 	default:
 		query = `
 			UPDATE groups 
-			SET group_status = $1
-			WHERE id = $2
+			SET group_status = $1, updated_at = $2
+			WHERE id = $3
 		`
-		args = []interface{}{newStatus, groupID}
+		args = []interface{}{newStatus, now, groupID}
 	}
 
-	_, err := s.db.Exec(query, args...)
-	return err
+	if _, err := q.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to update group status: %v", err)
+	}
+	return nil
 }
 
 // isValidGroupStatusTransition validates if group status transition is allowed
-func (s *StateMachineService) isValidGroupStatusTransition(groupID, newStatus string) bool {
-	var currentStatus string
-	err := s.db.QueryRow(`
-		SELECT group_status FROM groups WHERE id = $1
-	`, groupID).Scan(&currentStatus)
-
-	if err != nil {
-		return false
-	}
-
+func (s *StateMachineService) isValidGroupStatusTransition(currentStatus, newStatus string) bool {
 	// Define valid transitions
 	validTransitions := map[string][]string{
-		models.GroupStatusOpen:      {models.GroupStatusPrivate, models.GroupStatusFull, models.GroupStatusClosed},
-		models.GroupStatusPrivate:   {models.GroupStatusOpen, models.GroupStatusFull, models.GroupStatusClosed},
+		models.GroupStatusOpen:      {models.GroupStatusPrivate, models.GroupStatusFull, models.GroupStatusPaidGroup, models.GroupStatusClosed},
+		models.GroupStatusPrivate:   {models.GroupStatusOpen, models.GroupStatusFull, models.GroupStatusPaidGroup, models.GroupStatusClosed},
 		models.GroupStatusFull:      {models.GroupStatusPaidGroup, models.GroupStatusOpen, models.GroupStatusClosed},
-		models.GroupStatusPaidGroup: {models.GroupStatusOpen, models.GroupStatusClosed},
+		models.GroupStatusPaidGroup: {models.GroupStatusOpen, models.GroupStatusPaused, models.GroupStatusClosing, models.GroupStatusClosed},
+		models.GroupStatusPaused:    {models.GroupStatusPaidGroup, models.GroupStatusClosed},
+		models.GroupStatusClosing:   {models.GroupStatusPaidGroup, models.GroupStatusClosed},
 		models.GroupStatusClosed:    {}, // End state
 	}
 
@@ -239,6 +351,43 @@ func (s *StateMachineService) isValidGroupStatusTransition(groupID, newStatus st
 	return false
 }
 
+// SyncGroupCapacity moves a group that is taking members to full once every seat is held, and a full group
+// back to open when a seat is released. A seat is held by every member who has not been removed or expired,
+// including pending members who have not paid yet, and by open waitlist offers. Call it in the transaction that
+// changed the membership.
+func (s *StateMachineService) SyncGroupCapacity(q database.Queryer, actor Actor, groupID string) error {
+	var status string
+	var maxMembers int
+	err := q.QueryRow(`SELECT group_status, max_members FROM groups WHERE id = $1`, groupID).Scan(&status, &maxMembers)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get group status: %v", err)
+	}
+	seatsHeld, err := countHeldSeats(q, groupID)
+	if err != nil {
+		return err
+	}
+
+	var newStatus string
+	switch {
+	case (status == models.GroupStatusOpen || status == models.GroupStatusPrivate) && seatsHeld >= maxMembers:
+		newStatus = models.GroupStatusFull
+	case status == models.GroupStatusFull && seatsHeld < maxMembers:
+		newStatus = models.GroupStatusOpen
+	default:
+		return nil
+	}
+
+	reason := fmt.Sprintf("%d/%d seats held", seatsHeld, maxMembers)
+	if err := s.UpdateGroupStatusTx(q, actor, groupID, newStatus, reason); err != nil {
+		return fmt.Errorf("failed to update group status to %s: %v", newStatus, err)
+	}
+	fmt.Printf("[SALOME BE] Group %s is now %s (%d/%d seats held)\n", groupID, newStatus, seatsHeld, maxMembers)
+	return nil
+}
+
 // Admin Methods
 
 // IsAdmin checks if user is admin
@@ -251,7 +400,8 @@ func (s *StateMachineService) IsAdmin(userID string) (bool, error) {
 	return isAdmin, err
 }
 
-// AdminUpdateUserStatus allows admin to update any user status
+// AdminUpdateUserStatus allows admin to update any user status. Changes the state machine would not allow
+// are recorded as overrides.
 func (s *StateMachineService) AdminUpdateUserStatus(adminID, userID, groupID, newStatus, reason string) error {
 	// Check if admin
 	isAdmin, err := s.IsAdmin(adminID)
@@ -262,55 +412,47 @@ func (s *StateMachineService) AdminUpdateUserStatus(adminID, userID, groupID, ne
 		return fmt.Errorf("unauthorized: admin access required")
 	}
 
-	// Admin can set any status
-	now := time.Now()
-	var query string
-	var args []interface{}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-	switch newStatus {
-	case models.UserStatusPaid:
-		query = `
-			UPDATE group_members 
-			SET user_status = $1, paid_at = $2, payment_deadline = NULL
-			WHERE user_id = $3 AND group_id = $4
-		`
-		args = []interface{}{newStatus, now, userID, groupID}
-	case models.UserStatusActive:
-		query = `
-			UPDATE group_members 
-			SET user_status = $1, activated_at = $2
-			WHERE user_id = $3 AND group_id = $4
-		`
-		args = []interface{}{newStatus, now, userID, groupID}
-	case models.UserStatusExpired:
-		query = `
-			UPDATE group_members 
-			SET user_status = $1, expired_at = $2
-			WHERE user_id = $3 AND group_id = $4
-		`
-		args = []interface{}{newStatus, now, userID, groupID}
-	case models.UserStatusRemoved:
-		query = `
-			UPDATE group_members 
-			SET user_status = $1, removed_at = $2, removed_reason = $3
-			WHERE user_id = $4 AND group_id = $5
-		`
-		args = []interface{}{newStatus, now, reason, userID, groupID}
-	default:
-		query = `
-			UPDATE group_members 
-			SET user_status = $1
-			WHERE user_id = $2 AND group_id = $3
-		`
-		args = []interface{}{newStatus, userID, groupID}
+	var currentStatus string
+	err = tx.QueryRow(`
+		SELECT user_status FROM group_members WHERE user_id = $1 AND group_id = $2 FOR UPDATE
+	`, userID, groupID).Scan(&currentStatus)
+	if err == sql.ErrNoRows {
+		return ErrNotGroupMember
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user status: %v", err)
 	}
 
-	_, err = s.db.Exec(query, args...)
-	return err
+	// Admin can set any status
+	if err := s.setUserStatus(tx, userID, groupID, newStatus, reason); err != nil {
+		return err
+	}
+	actor := AdminActor(adminID).WithCorrelation(NewCorrelationID())
+	err = recordStatusEvent(tx, actor, statusChange{
+		groupID: groupID, userID: userID, from: currentStatus, to: newStatus, reason: reason,
+		override: !s.isValidUserStatusTransition(currentStatus, newStatus),
+	})
+	if err != nil {
+		return err
+	}
+	switch newStatus {
+	case models.UserStatusRemoved, models.UserStatusExpired, models.UserStatusActive:
+		if err := s.SyncGroupCapacity(tx, actor, groupID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// AdminUpdateGroupStatus allows admin to update any group status
-func (s *StateMachineService) AdminUpdateGroupStatus(adminID, groupID, newStatus string) error {
+// AdminUpdateGroupStatus allows admin to update any group status. Changes the state machine would not allow
+// are recorded as overrides.
+func (s *StateMachineService) AdminUpdateGroupStatus(adminID, groupID, newStatus, reason string) error {
 	// Check if admin
 	isAdmin, err := s.IsAdmin(adminID)
 	if err != nil {
@@ -320,30 +462,41 @@ func (s *StateMachineService) AdminUpdateGroupStatus(adminID, groupID, newStatus
 		return fmt.Errorf("unauthorized: admin access required")
 	}
 
-	// Admin can set any status
-	now := time.Now()
-	var query string
-	var args []interface{}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-	switch newStatus {
-	case models.GroupStatusPaidGroup:
-		query = `
-			UPDATE groups 
-			SET group_status = $1, all_paid_at = $2
-			WHERE id = $3
-		`
-		args = []interface{}{newStatus, now, groupID}
-	default:
-		query = `
-			UPDATE groups 
-			SET group_status = $1
-			WHERE id = $2
-		`
-		args = []interface{}{newStatus, groupID}
+	if err := s.AdminSetGroupStatusTx(tx, AdminActor(adminID).WithCorrelation(NewCorrelationID()), groupID, newStatus, reason); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	_, err = s.db.Exec(query, args...)
-	return err
+// AdminSetGroupStatusTx sets a group status without validating the transition and records it, as an
+// override when the state machine would not allow it. Setting the current status again records nothing.
+func (s *StateMachineService) AdminSetGroupStatusTx(q database.Queryer, actor Actor, groupID, newStatus, reason string) error {
+	var currentStatus string
+	err := q.QueryRow(`SELECT group_status FROM groups WHERE id = $1 FOR UPDATE`, groupID).Scan(&currentStatus)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get group status: %v", err)
+	}
+	if currentStatus == newStatus {
+		return nil
+	}
+
+	// Admin can set any status
+	if err := s.setGroupStatus(q, groupID, newStatus); err != nil {
+		return err
+	}
+	return recordStatusEvent(q, actor, statusChange{
+		groupID: groupID, from: currentStatus, to: newStatus, reason: reason,
+		override: !s.isValidGroupStatusTransition(currentStatus, newStatus),
+	})
 }
 
 // SetPaymentDeadline sets payment deadline for pending users
@@ -359,8 +512,23 @@ func (s *StateMachineService) SetPaymentDeadline(userID, groupID string) error {
 	return err
 }
 
+// AssignMissingPaymentDeadlines gives pending members without a deadline one counted from when they joined
+func (s *StateMachineService) AssignMissingPaymentDeadlines() (int, error) {
+	result, err := s.db.Exec(`
+		UPDATE group_members
+		SET payment_deadline = joined_at + make_interval(hours => $1)
+		WHERE user_status = $2 AND payment_deadline IS NULL
+	`, models.PaymentTimeoutHours, models.UserStatusPending)
+	if err != nil {
+		return 0, err
+	}
+
+	assigned, _ := result.RowsAffected()
+	return int(assigned), nil
+}
+
 // CheckExpiredPayments checks for expired payments and removes users
-func (s *StateMachineService) CheckExpiredPayments() error {
+func (s *StateMachineService) CheckExpiredPayments() (int, error) {
 	now := time.Now()
 
 	// Find users with expired payment deadlines
@@ -370,20 +538,75 @@ func (s *StateMachineService) CheckExpiredPayments() error {
 	`, models.UserStatusPending, now)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer rows.Close()
 
-	// Remove expired users
+	type member struct{ userID, groupID string }
+	var expired []member
 	for rows.Next() {
-		var userID, groupID string
-		if err := rows.Scan(&userID, &groupID); err != nil {
+		var m member
+		if err := rows.Scan(&m.userID, &m.groupID); err != nil {
 			continue
 		}
+		expired = append(expired, m)
+	}
+	rows.Close()
 
-		// Remove user
-		s.UpdateUserStatus(userID, groupID, models.UserStatusRemoved, "Payment timeout")
+	// Remove expired users
+	removed := 0
+	refunds := NewRefundService(s.db)
+	waitlist := NewWaitlistService(s.db)
+	for _, m := range expired {
+		ok, err := s.removeUnpaidMember(refunds, waitlist, m.userID, m.groupID, now)
+		if err != nil {
+			fmt.Printf("[SALOME BE] ERROR: Failed to remove member %s from group %s: %v\n", m.userID, m.groupID, err)
+			continue
+		}
+		if ok {
+			removed++
+		}
 	}
 
-	return nil
+	return removed, nil
+}
+
+// removeUnpaidMember removes a member whose payment deadline passed, unless the member paid in the meantime.
+// Balance held by an unfinished mixed payment is returned to the wallet and the seat is offered to the
+// waitlist.
+func (s *StateMachineService) removeUnpaidMember(refunds *RefundService, waitlist *WaitlistService, userID, groupID string, now time.Time) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The group is locked before the member, in the order seats are reserved in
+	if _, err := tx.Exec(`SELECT id FROM groups WHERE id = $1 FOR UPDATE`, groupID); err != nil {
+		return false, err
+	}
+
+	var stillPending bool
+	err = tx.QueryRow(`
+		SELECT user_status = $1 AND payment_deadline < $2 FROM group_members
+		WHERE user_id = $3 AND group_id = $4
+		FOR UPDATE
+	`, models.UserStatusPending, now, userID, groupID).Scan(&stillPending)
+	if err == sql.ErrNoRows || (err == nil && !stillPending) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := refunds.refundMemberTx(tx, userID, groupID, models.RefundReasonPaymentTimeout, "", false, now); err != nil {
+		return false, err
+	}
+	actor := SystemActor(NewCorrelationID())
+	if err := s.UpdateUserStatusTx(tx, actor, userID, groupID, models.UserStatusRemoved, "Payment timeout"); err != nil {
+		return false, err
+	}
+	if _, err := waitlist.promoteTx(tx, actor, groupID, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
 }
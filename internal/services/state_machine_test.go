@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"salome-be/internal/models"
+)
+
+func TestAllSeatsPaid(t *testing.T) {
+	owner := memberSeat{role: "owner", status: models.UserStatusActive}
+	member := func(status string) memberSeat { return memberSeat{role: "member", status: status} }
+
+	tests := []struct {
+		name    string
+		members []memberSeat
+		want    bool
+	}{
+		{"no members", nil, false},
+		{"owner only", []memberSeat{owner}, false},
+		{"owner and paid members", []memberSeat{owner, member(models.UserStatusPaid), member(models.UserStatusPaid)}, true},
+		{"owner row is not counted as unpaid", []memberSeat{owner, member(models.UserStatusPaid)}, true},
+		{"pending member blocks", []memberSeat{owner, member(models.UserStatusPaid), member(models.UserStatusPending)}, false},
+		{"removed rows are ignored", []memberSeat{owner, member(models.UserStatusPaid), member(models.UserStatusRemoved)}, true},
+		{"expired rows are ignored", []memberSeat{owner, member(models.UserStatusPaid), member(models.UserStatusExpired)}, true},
+		{"only removed members", []memberSeat{owner, member(models.UserStatusRemoved)}, false},
+		{"new member joins an active group", []memberSeat{owner, member(models.UserStatusActive), member(models.UserStatusPaid)}, true},
+		{"nobody waiting for activation", []memberSeat{owner, member(models.UserStatusActive)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allSeatsPaid(tt.members); got != tt.want {
+				t.Errorf("allSeatsPaid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
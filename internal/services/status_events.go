@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Actor is who caused a status transition. CorrelationID ties together the events of one request, payment
+// or job run.
+type Actor struct {
+	Type          string
+	ID            string
+	CorrelationID string
+}
+
+// UserActor is a user acting on their own membership or group
+func UserActor(userID string) Actor {
+	return Actor{Type: models.ActorUser, ID: userID}
+}
+
+// AdminActor is an admin acting on someone else's membership or group
+func AdminActor(adminID string) Actor {
+	return Actor{Type: models.ActorAdmin, ID: adminID}
+}
+
+// SystemActor is a background job or a consequence the platform applies by itself
+func SystemActor(correlationID string) Actor {
+	return Actor{Type: models.ActorSystem, CorrelationID: correlationID}
+}
+
+// WebhookActor is a payment provider notification
+func WebhookActor(correlationID string) Actor {
+	return Actor{Type: models.ActorWebhook, CorrelationID: correlationID}
+}
+
+// WithCorrelation returns the actor with correlationID, unless it already has one
+func (a Actor) WithCorrelation(correlationID string) Actor {
+	if a.CorrelationID == "" {
+		a.CorrelationID = correlationID
+	}
+	return a
+}
+
+// statusChange is one transition to record. An empty from is the creation of the entity; an empty userID
+// makes it a group event.
+type statusChange struct {
+	groupID, userID string
+	from, to        string
+	reason          string
+	override        bool
+}
+
+// recordStatusEvent appends a transition to status_events. Call it in the transaction that made the change,
+// so the history and the status columns cannot disagree.
+func recordStatusEvent(q database.Queryer, actor Actor, change statusChange) error {
+	entity := models.StatusEntityGroup
+	if change.userID != "" {
+		entity = models.StatusEntityMember
+	}
+	_, err := q.Exec(`
+		INSERT INTO status_events (entity_type, group_id, user_id, from_status, to_status, actor_type, actor_id,
+		                           reason, correlation_id, is_override, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+	`, entity, change.groupID, nullIfEmpty(change.userID), nullIfEmpty(change.from), change.to, actor.Type,
+		nullIfEmpty(actor.ID), nullIfEmpty(change.reason), nullIfEmpty(actor.CorrelationID), change.override)
+	if err != nil {
+		return fmt.Errorf("failed to record status event: %v", err)
+	}
+	return nil
+}
+
+// RecordGroupCreated records the initial status of a new group and of its owner's membership, if the owner
+// holds a seat
+func RecordGroupCreated(q database.Queryer, actor Actor, groupID, status, ownerID, ownerStatus string) error {
+	if err := recordStatusEvent(q, actor, statusChange{groupID: groupID, to: status, reason: "Group created"}); err != nil {
+		return err
+	}
+	if ownerID == "" {
+		return nil
+	}
+	return recordStatusEvent(q, actor, statusChange{groupID: groupID, userID: ownerID, to: ownerStatus, reason: "Group owner"})
+}
+
+// RecordMemberAdded records a member added to a group outside the seat reservation flow
+func RecordMemberAdded(q database.Queryer, actor Actor, groupID, userID, status string) error {
+	return recordStatusEvent(q, actor, statusChange{groupID: groupID, userID: userID, to: status, reason: "Added by admin"})
+}
+
+// RecordMembersRemoved records the removal of every member of a group that is being closed, before their
+// rows are deleted
+func RecordMembersRemoved(q database.Queryer, actor Actor, groupID, reason string) error {
+	rows, err := q.Query(`
+		SELECT user_id, user_status FROM group_members WHERE group_id = $1 AND user_status <> $2
+	`, groupID, models.UserStatusRemoved)
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %v", err)
+	}
+	var changes []statusChange
+	for rows.Next() {
+		change := statusChange{groupID: groupID, to: models.UserStatusRemoved, reason: reason}
+		if err := rows.Scan(&change.userID, &change.from); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan group member: %v", err)
+		}
+		changes = append(changes, change)
+	}
+	rows.Close()
+
+	for _, change := range changes {
+		if err := recordStatusEvent(q, actor, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewCorrelationID returns an ID for events that are not caused by a payment
+func NewCorrelationID() string {
+	return uuid.New().String()
+}
+
+const statusEventColumns = `e.id, e.entity_type, e.group_id, e.user_id, e.from_status, e.to_status, e.actor_type,
+	e.actor_id, e.reason, e.correlation_id, e.is_override, e.created_at,
+	COALESCE(g.name, ''), COALESCE(u.full_name, ''), COALESCE(a.full_name, '')`
+
+const statusEventJoins = `
+	FROM status_events e
+	LEFT JOIN groups g ON g.id = e.group_id
+	LEFT JOIN users u ON u.id = e.user_id
+	LEFT JOIN users a ON a.id = e.actor_id`
+
+// GroupHistory returns the transitions of a group and its members, newest first
+func (s *StateMachineService) GroupHistory(groupID string, limit, offset int) ([]models.StatusEvent, int, error) {
+	return s.StatusTimeline(models.StatusEventFilter{GroupID: groupID}, limit, offset)
+}
+
+// StatusTimeline returns the transitions matching filter, newest first, with the total number of matches
+func (s *StateMachineService) StatusTimeline(filter models.StatusEventFilter, limit, offset int) ([]models.StatusEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if filter.GroupID != "" {
+		add("e.group_id = ?", filter.GroupID)
+	}
+	if filter.UserID != "" {
+		add("e.user_id = ?", filter.UserID)
+	}
+	if filter.EntityType != "" {
+		add("e.entity_type = ?", filter.EntityType)
+	}
+	if filter.ActorType != "" {
+		add("e.actor_type = ?", filter.ActorType)
+	}
+	if filter.CorrelationID != "" {
+		add("e.correlation_id = ?", filter.CorrelationID)
+	}
+	if filter.Since != nil {
+		add("e.created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		add("e.created_at < ?", *filter.Until)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM status_events e`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count status events: %v", err)
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(`SELECT `+statusEventColumns+statusEventJoins+where+
+		fmt.Sprintf(` ORDER BY e.created_at DESC, e.id LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query status events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.StatusEvent{}
+	for rows.Next() {
+		var e models.StatusEvent
+		err := rows.Scan(&e.ID, &e.EntityType, &e.GroupID, &e.UserID, &e.FromStatus, &e.ToStatus, &e.ActorType,
+			&e.ActorID, &e.Reason, &e.CorrelationID, &e.IsOverride, &e.CreatedAt, &e.GroupName, &e.UserName, &e.ActorName)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan status event: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
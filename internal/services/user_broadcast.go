@@ -0,0 +1,158 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrBroadcastNotFound    = errors.New("broadcast not found")
+	ErrBroadcastNotSendable = errors.New("broadcast can only be sent if status is draft or scheduled")
+)
+
+// BroadcastSendResult is the outcome of sending a user broadcast
+type BroadcastSendResult struct {
+	SuccessCount int `json:"success_count"`
+	ErrorCount   int `json:"error_count"`
+	TotalTargets int `json:"total_targets"`
+}
+
+// ScheduledBroadcastStats summarizes one pass over due scheduled broadcasts
+type ScheduledBroadcastStats struct {
+	Sent   int `json:"sent"`
+	Failed int `json:"failed"`
+}
+
+// UserBroadcastService sends user broadcasts, immediately or when their scheduled time comes
+type UserBroadcastService struct {
+	db *sql.DB
+}
+
+func NewUserBroadcastService(db *sql.DB) *UserBroadcastService {
+	return &UserBroadcastService{db: db}
+}
+
+// Send marks a draft or scheduled broadcast as sent and delivers it to its targets. The broadcast row is
+// locked so a broadcast is never sent twice.
+func (s *UserBroadcastService) Send(broadcastID string) (*BroadcastSendResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var targetType, status string
+	err = tx.QueryRow(`
+		SELECT target_type, status FROM user_broadcast WHERE id = $1 FOR UPDATE
+	`, broadcastID).Scan(&targetType, &status)
+	if err == sql.ErrNoRows {
+		return nil, ErrBroadcastNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch broadcast: %v", err)
+	}
+	if status != "draft" && status != "scheduled" {
+		return nil, ErrBroadcastNotSendable
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE user_broadcast
+		SET status = 'sent', sent_at = $1, updated_at = $1
+		WHERE id = $2
+	`, now, broadcastID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update broadcast status: %v", err)
+	}
+
+	// Get target users
+	query := "SELECT id FROM users WHERE is_admin = false"
+	args := []interface{}{}
+	if targetType != "all" {
+		query = "SELECT user_id FROM user_broadcast_targets WHERE broadcast_id = $1"
+		args = append(args, broadcastID)
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch target users: %v", err)
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+
+	result := &BroadcastSendResult{TotalTargets: len(userIDs)}
+	for _, userID := range userIDs {
+		// Broadcasts are shown on the user dashboard; selected targets are marked as delivered
+		if targetType == "selected" {
+			_, err = tx.Exec(`
+				UPDATE user_broadcast_targets
+				SET status = 'sent', sent_at = $1
+				WHERE broadcast_id = $2 AND user_id = $3
+			`, now, broadcastID, userID)
+			if err != nil {
+				result.ErrorCount++
+				continue
+			}
+		}
+		result.SuccessCount++
+	}
+
+	_, err = tx.Exec(`
+		UPDATE user_broadcast
+		SET success_count = $1, error_count = $2, updated_at = $3
+		WHERE id = $4
+	`, result.SuccessCount, result.ErrorCount, now, broadcastID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update broadcast results: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// SendScheduled sends every scheduled broadcast whose scheduled_at has passed
+func (s *UserBroadcastService) SendScheduled(now time.Time) (*ScheduledBroadcastStats, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM user_broadcast
+		WHERE status = 'scheduled' AND scheduled_at <= $1
+		ORDER BY scheduled_at ASC
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch scheduled broadcasts: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan scheduled broadcast: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	stats := &ScheduledBroadcastStats{}
+	for _, id := range ids {
+		if _, err := s.Send(id); err != nil {
+			// Cancelled or sent by an admin in the meantime
+			if errors.Is(err, ErrBroadcastNotSendable) {
+				continue
+			}
+			fmt.Printf("[SALOME BE] ERROR: Failed to send scheduled broadcast %s: %v\n", id, err)
+			stats.Failed++
+			continue
+		}
+		stats.Sent++
+	}
+	return stats, nil
+}
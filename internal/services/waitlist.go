@@ -0,0 +1,571 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salome-be/internal/config"
+	"salome-be/internal/database"
+	"salome-be/internal/models"
+)
+
+var (
+	ErrWaitlistNotFound       = errors.New("user is not on the waitlist of this group")
+	ErrWaitlistEntryNotFound  = errors.New("waitlist entry not found")
+	ErrAlreadyWaitlisted      = errors.New("user is already on the waitlist of this group")
+	ErrWaitlistFull           = errors.New("waitlist of this group is full")
+	ErrWaitlistSeatsAvailable = errors.New("group has free seats, join it directly")
+	ErrWaitlistNoOffer        = errors.New("no seat has been offered to this user")
+	ErrWaitlistOwnerOnly      = errors.New("only the group owner can manage the waitlist")
+	ErrInviteCodeRequired     = errors.New("a valid invite code is required for this group")
+)
+
+// WaitlistStats summarizes one pass over the waitlists
+type WaitlistStats struct {
+	Cancelled int `json:"cancelled"`
+	Expired   int `json:"expired"`
+	Offered   int `json:"offered"`
+	Failed    int `json:"failed"`
+}
+
+// WaitlistService queues users for seats in full groups. When a seat is released it is offered to the first
+// waiting user and held for them during the claim window; an offer that is not claimed in time passes to
+// the next user in line.
+type WaitlistService struct {
+	db           *sql.DB
+	config       config.WaitlistConfig
+	seats        *SeatService
+	stateMachine *StateMachineService
+}
+
+func NewWaitlistService(db *sql.DB) *WaitlistService {
+	return &WaitlistService{
+		db:           db,
+		config:       config.GetConfig().Waitlist,
+		seats:        NewSeatService(db),
+		stateMachine: NewStateMachineService(db),
+	}
+}
+
+const waitlistColumns = `w.id, w.group_id, w.user_id, w.position, w.status, w.offered_at, w.offer_expires_at,
+	w.claimed_at, w.created_at, w.updated_at, g.name, COALESCE(u.full_name, ''), u.email`
+
+func scanWaitlistEntry(row rowScanner) (*models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	err := row.Scan(&e.ID, &e.GroupID, &e.UserID, &e.Position, &e.Status, &e.OfferedAt, &e.OfferExpiresAt,
+		&e.ClaimedAt, &e.CreatedAt, &e.UpdatedAt, &e.GroupName, &e.UserName, &e.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Join puts the user at the end of the group's waitlist. Groups that are not public can only be joined
+// with one of their usable invite links.
+func (s *WaitlistService) Join(userID, groupID, inviteCode string) (*models.WaitlistEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var isPublic bool
+	var maxMembers int
+	err = tx.QueryRow(`
+		SELECT group_status, COALESCE(is_public, false), max_members
+		FROM groups
+		WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+		FOR UPDATE
+	`, groupID).Scan(&status, &isPublic, &maxMembers)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock group: %v", err)
+	}
+	if status == models.GroupStatusClosed {
+		return nil, ErrGroupNotJoinable
+	}
+	if !isPublic {
+		if inviteCode == "" {
+			return nil, ErrInviteCodeRequired
+		}
+		invite, err := validateInviteTx(tx, inviteCode, userID, false)
+		if err != nil && !errors.Is(err, ErrInviteNotFound) {
+			return nil, err
+		}
+		if invite == nil || invite.GroupID != groupID {
+			return nil, ErrInviteCodeRequired
+		}
+	}
+
+	var isMember bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2 AND user_status NOT IN ($3, $4))
+	`, groupID, userID, models.UserStatusRemoved, models.UserStatusExpired).Scan(&isMember)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %v", err)
+	}
+	if isMember {
+		return nil, ErrAlreadyMember
+	}
+
+	var queued, waiting, lastPosition int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE user_id = $2), COUNT(*) FILTER (WHERE status = $3), COALESCE(MAX(position), 0)
+		FROM group_waitlist
+		WHERE group_id = $1 AND status IN ($3, $4)
+	`, groupID, userID, models.WaitlistWaiting, models.WaitlistOffered).Scan(&queued, &waiting, &lastPosition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waitlist: %v", err)
+	}
+	if queued > 0 {
+		return nil, ErrAlreadyWaitlisted
+	}
+	if s.config.MaxSize > 0 && waiting >= s.config.MaxSize {
+		return nil, ErrWaitlistFull
+	}
+
+	// Nobody needs to queue while a seat is free and nobody is ahead
+	seatsHeld, err := countHeldSeats(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if waiting == 0 && seatsHeld < maxMembers {
+		return nil, ErrWaitlistSeatsAvailable
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO group_waitlist (group_id, user_id, position, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`, groupID, userID, lastPosition+1, models.WaitlistWaiting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join waitlist: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return s.Status(userID, groupID)
+}
+
+// Status returns the user's current waitlist entry of a group
+func (s *WaitlistService) Status(userID, groupID string) (*models.WaitlistEntry, error) {
+	entry, err := scanWaitlistEntry(s.db.QueryRow(`
+		SELECT `+waitlistColumns+`
+		FROM group_waitlist w
+		JOIN groups g ON g.id = w.group_id
+		JOIN users u ON u.id = w.user_id
+		WHERE w.group_id = $1 AND w.user_id = $2 AND w.status IN ($3, $4)
+	`, groupID, userID, models.WaitlistWaiting, models.WaitlistOffered))
+	if err == sql.ErrNoRows {
+		return nil, ErrWaitlistNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist entry: %v", err)
+	}
+	if entry.Status == models.WaitlistWaiting {
+		err = s.db.QueryRow(`
+			SELECT COUNT(*) + 1 FROM group_waitlist
+			WHERE group_id = $1 AND status = $2 AND (position, created_at) < ($3, $4)
+		`, groupID, models.WaitlistWaiting, entry.Position, entry.CreatedAt).Scan(&entry.Place)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get waitlist place: %v", err)
+		}
+	}
+	return entry, nil
+}
+
+// WaitingCount returns how many users are waiting for a seat in a group
+func (s *WaitlistService) WaitingCount(groupID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM group_waitlist WHERE group_id = $1 AND status = $2
+	`, groupID, models.WaitlistWaiting).Scan(&count)
+	return count, err
+}
+
+// List returns the queue of a group for its owner: open offers first, then the waiting users in order
+func (s *WaitlistService) List(ownerID, groupID string) ([]models.WaitlistEntry, error) {
+	if err := s.checkOwner(s.db, ownerID, groupID, false); err != nil {
+		return nil, err
+	}
+	return s.list(s.db, groupID)
+}
+
+func (s *WaitlistService) list(q database.Queryer, groupID string) ([]models.WaitlistEntry, error) {
+	rows, err := q.Query(`
+		SELECT `+waitlistColumns+`
+		FROM group_waitlist w
+		JOIN groups g ON g.id = w.group_id
+		JOIN users u ON u.id = w.user_id
+		WHERE w.group_id = $1 AND w.status IN ($2, $3)
+		ORDER BY w.status = $2 DESC, w.position ASC, w.created_at ASC
+	`, groupID, models.WaitlistOffered, models.WaitlistWaiting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waitlist: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	place := 0
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist entry: %v", err)
+		}
+		if entry.Status == models.WaitlistWaiting {
+			place++
+			entry.Place = place
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Reorder puts the listed waiting entries at the front of the queue in the given order. Entries that are
+// not listed keep their relative order behind them.
+func (s *WaitlistService) Reorder(ownerID, groupID string, entryIDs []string) ([]models.WaitlistEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.checkOwner(tx, ownerID, groupID, true); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id FROM group_waitlist
+		WHERE group_id = $1 AND status = $2
+		ORDER BY position ASC, created_at ASC
+	`, groupID, models.WaitlistWaiting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waitlist: %v", err)
+	}
+	var current []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan waitlist entry: %v", err)
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+
+	waiting := make(map[string]bool, len(current))
+	for _, id := range current {
+		waiting[id] = true
+	}
+	listed := make(map[string]bool, len(entryIDs))
+	order := make([]string, 0, len(current))
+	for _, id := range entryIDs {
+		if !waiting[id] || listed[id] {
+			return nil, ErrWaitlistEntryNotFound
+		}
+		listed[id] = true
+		order = append(order, id)
+	}
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
+	}
+
+	for i, id := range order {
+		if _, err := tx.Exec(`
+			UPDATE group_waitlist SET position = $1, updated_at = NOW() WHERE id = $2
+		`, i+1, id); err != nil {
+			return nil, fmt.Errorf("failed to reorder waitlist: %v", err)
+		}
+	}
+
+	entries, err := s.list(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Printf("[SALOME BE] Waitlist of group %s reordered by owner %s\n", groupID, ownerID)
+	return entries, nil
+}
+
+// Leave takes the user off the waitlist. A seat offered to them goes to the next user in line.
+func (s *WaitlistService) Leave(userID, groupID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var entryID, status string
+	err = tx.QueryRow(`
+		SELECT id, status FROM group_waitlist
+		WHERE group_id = $1 AND user_id = $2 AND status IN ($3, $4)
+		FOR UPDATE
+	`, groupID, userID, models.WaitlistWaiting, models.WaitlistOffered).Scan(&entryID, &status)
+	if err == sql.ErrNoRows {
+		return ErrWaitlistNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get waitlist entry: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE group_waitlist SET status = $1, updated_at = NOW() WHERE id = $2
+	`, models.WaitlistCancelled, entryID); err != nil {
+		return fmt.Errorf("failed to leave waitlist: %v", err)
+	}
+
+	if status == models.WaitlistOffered {
+		if _, err := s.promoteTx(tx, UserActor(userID), groupID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Claim turns the seat offered to the user into a pending seat they can pay for
+func (s *WaitlistService) Claim(userID, groupID string) (*SeatReservation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var offered bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM group_waitlist
+			WHERE group_id = $1 AND user_id = $2 AND status = $3 AND offer_expires_at > NOW()
+		)
+	`, groupID, userID, models.WaitlistOffered).Scan(&offered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check waitlist offer: %v", err)
+	}
+	if !offered {
+		return nil, ErrWaitlistNoOffer
+	}
+
+	reservation, err := s.seats.reserveTx(tx, UserActor(userID), userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit seat reservation: %v", err)
+	}
+	fmt.Printf("[SALOME BE] User %s claimed a waitlist seat in group %s\n", userID, groupID)
+	return reservation, nil
+}
+
+// Run cancels the waitlists of closed and deleted groups, expires unclaimed offers and offers the free seats
+// of groups to the users waiting for them
+func (s *WaitlistService) Run(now time.Time) (*WaitlistStats, error) {
+	stats := &WaitlistStats{}
+
+	result, err := s.db.Exec(`
+		UPDATE group_waitlist w SET status = $1, updated_at = $2
+		FROM groups g
+		WHERE g.id = w.group_id AND w.status IN ($3, $4)
+		  AND (g.group_status = $5 OR COALESCE(g.is_deleted, false))
+	`, models.WaitlistCancelled, now, models.WaitlistWaiting, models.WaitlistOffered, models.GroupStatusClosed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel waitlists of closed groups: %v", err)
+	}
+	cancelled, _ := result.RowsAffected()
+	stats.Cancelled = int(cancelled)
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT group_id FROM group_waitlist
+		WHERE status = $1 OR (status = $2 AND offer_expires_at <= $3)
+	`, models.WaitlistWaiting, models.WaitlistOffered, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waitlists: %v", err)
+	}
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan waitlist group: %v", err)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+
+	for _, groupID := range groupIDs {
+		expired, offered, err := s.process(groupID, now)
+		if err != nil {
+			fmt.Printf("❌ [ERROR] Failed to process waitlist of group %s: %v\n", groupID, err)
+			stats.Failed++
+			continue
+		}
+		stats.Expired += expired
+		stats.Offered += offered
+	}
+	return stats, nil
+}
+
+// process expires the unclaimed offers of a group and passes its free seats on
+func (s *WaitlistService) process(groupID string, now time.Time) (expired, offered int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockGroup(tx, groupID); err != nil {
+		return 0, 0, err
+	}
+
+	rows, err := tx.Query(`
+		UPDATE group_waitlist w SET status = $1, updated_at = $2
+		FROM groups g
+		WHERE g.id = w.group_id AND w.group_id = $3 AND w.status = $4 AND w.offer_expires_at <= $2
+		RETURNING w.user_id, g.name
+	`, models.WaitlistExpired, now, groupID, models.WaitlistOffered)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to expire waitlist offers: %v", err)
+	}
+	type expiredOffer struct{ userID, groupName string }
+	var offers []expiredOffer
+	for rows.Next() {
+		var o expiredOffer
+		if err := rows.Scan(&o.userID, &o.groupName); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan expired offer: %v", err)
+		}
+		offers = append(offers, o)
+	}
+	rows.Close()
+
+	for _, o := range offers {
+		err := notify(tx, o.userID, "waitlist", "Penawaran kursi berakhir",
+			fmt.Sprintf("Kursi di grup %s yang ditawarkan kepada Anda tidak diambil tepat waktu dan diberikan ke antrean berikutnya.", o.groupName),
+			"/groups/"+groupID, "Lihat Grup")
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	offered, err = s.promoteTx(tx, SystemActor(NewCorrelationID()), groupID, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(offers), offered, tx.Commit()
+}
+
+// promoteTx offers each free seat of a group to the next waiting user and notifies them. It returns the
+// number of seats offered.
+func (s *WaitlistService) promoteTx(tx *sql.Tx, actor Actor, groupID string, now time.Time) (int, error) {
+	var groupName, status string
+	var maxMembers int
+	err := tx.QueryRow(`
+		SELECT name, group_status, max_members FROM groups
+		WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)
+		FOR UPDATE
+	`, groupID).Scan(&groupName, &status, &maxMembers)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock group: %v", err)
+	}
+	if status == models.GroupStatusClosed {
+		return 0, nil
+	}
+
+	seatsHeld, err := countHeldSeats(tx, groupID)
+	if err != nil {
+		return 0, err
+	}
+	free := maxMembers - seatsHeld
+	if free <= 0 {
+		return 0, nil
+	}
+
+	expiresAt := now.Add(time.Duration(s.config.ClaimWindowHours) * time.Hour)
+	rows, err := tx.Query(`
+		UPDATE group_waitlist SET status = $1, offered_at = $2, offer_expires_at = $3, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM group_waitlist
+			WHERE group_id = $4 AND status = $5
+			ORDER BY position ASC, created_at ASC
+			LIMIT $6
+		)
+		RETURNING user_id
+	`, models.WaitlistOffered, now, expiresAt, groupID, models.WaitlistWaiting, free)
+	if err != nil {
+		return 0, fmt.Errorf("failed to offer seats: %v", err)
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan offered user: %v", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	deadline := expiresAt.In(loc).Format("02 Jan 2006 15:04") + " WIB"
+	for _, userID := range userIDs {
+		err := notify(tx, userID, "waitlist", "Kursi tersedia",
+			fmt.Sprintf("Ada kursi kosong di grup %s untuk Anda. Ambil kursi sebelum %s, setelah itu kursi diberikan ke antrean berikutnya.", groupName, deadline),
+			"/groups/"+groupID, "Ambil Kursi")
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The offered seats are held, so the group stays full while they can be claimed
+	if err := s.stateMachine.SyncGroupCapacity(tx, actor, groupID); err != nil {
+		return 0, err
+	}
+	fmt.Printf("[SALOME BE] Offered %d seat(s) of group %s to the waitlist\n", len(userIDs), groupID)
+	return len(userIDs), nil
+}
+
+// lockGroup locks the group row so seats are not taken while the waitlist changes
+func (s *WaitlistService) lockGroup(tx *sql.Tx, groupID string) error {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM groups WHERE id = $1 FOR UPDATE`, groupID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock group: %v", err)
+	}
+	return nil
+}
+
+// checkOwner returns ErrWaitlistOwnerOnly unless userID owns the group, optionally locking the group row
+func (s *WaitlistService) checkOwner(q database.Queryer, userID, groupID string, lock bool) error {
+	query := `SELECT owner_id FROM groups WHERE id = $1 AND (is_deleted IS NULL OR is_deleted = false)`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var ownerID string
+	err := q.QueryRow(query, groupID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get group owner: %v", err)
+	}
+	if ownerID != userID {
+		return ErrWaitlistOwnerOnly
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"salome-be/internal/models"
+	"salome-be/internal/orderref"
+)
+
+// WebhookResult describes what happened to a webhook event
+type WebhookResult struct {
+	EventID          string `json:"event_id"`
+	PaymentReference string `json:"payment_reference"`
+	Status           string `json:"status"`
+	Duplicate        bool   `json:"duplicate"`
+	Applied          bool   `json:"applied"`
+}
+
+// WebhookProcessor stores payment notifications in the webhook_events inbox and applies each one exactly once
+type WebhookProcessor struct {
+	db         *sql.DB
+	settlement *PaymentSettlement
+}
+
+func NewWebhookProcessor(db *sql.DB) *WebhookProcessor {
+	return &WebhookProcessor{
+		db:         db,
+		settlement: NewPaymentSettlement(db),
+	}
+}
+
+// MapTransactionStatus maps a provider transaction_status to our transactions.status
+func MapTransactionStatus(transactionStatus string) string {
+	switch transactionStatus {
+	case "capture", "settlement":
+		return "success"
+	case "pending":
+		return "pending"
+	default:
+		return "failed"
+	}
+}
+
+// Receive stores the notification and applies it if it has not been applied yet.
+// Re-deliveries of an already processed event are acknowledged without side effects.
+func (p *WebhookProcessor) Receive(n WebhookNotification, paymentReference string) (*WebhookResult, error) {
+	event, duplicate, err := p.record(n, paymentReference)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.Status == models.WebhookEventProcessed {
+		fmt.Printf("[SALOME BE] Webhook event %s already processed, skipping (provider=%s order_id=%s status=%s)\n",
+			event.ID, event.Provider, event.OrderID, event.TransactionStatus)
+		return &WebhookResult{
+			EventID:          event.ID,
+			PaymentReference: paymentReference,
+			Status:           MapTransactionStatus(event.TransactionStatus),
+			Duplicate:        true,
+		}, nil
+	}
+
+	result, err := p.Process(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Duplicate = duplicate
+	return result, nil
+}
+
+// record inserts the raw event into the inbox, returning the existing row when it was already received
+func (p *WebhookProcessor) record(n WebhookNotification, paymentReference string) (*models.WebhookEvent, bool, error) {
+	// Test payloads from the dashboard may not carry a transaction_id, fall back to order_id
+	transactionID := n.TransactionID
+	if transactionID == "" {
+		transactionID = n.OrderID
+	}
+
+	payload := n.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	var eventID string
+	err := p.db.QueryRow(`
+		INSERT INTO webhook_events (provider, transaction_id, transaction_status, order_id, payment_reference, payload, status, received_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (provider, transaction_id, transaction_status) DO NOTHING
+		RETURNING id
+	`, n.Provider, transactionID, n.TransactionStatus, n.OrderID, paymentReference, string(payload), models.WebhookEventReceived).Scan(&eventID)
+
+	duplicate := false
+	if err == sql.ErrNoRows {
+		duplicate = true
+		err = p.db.QueryRow(`
+			SELECT id FROM webhook_events
+			WHERE provider = $1 AND transaction_id = $2 AND transaction_status = $3
+		`, n.Provider, transactionID, n.TransactionStatus).Scan(&eventID)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store webhook event: %v", err)
+	}
+
+	event, err := p.GetEvent(eventID)
+	if err != nil {
+		return nil, false, err
+	}
+	return event, duplicate, nil
+}
+
+// Process applies a stored event inside a single DB transaction. Failures are recorded on the event so it can be re-driven.
+func (p *WebhookProcessor) Process(eventID string) (*WebhookResult, error) {
+	result, err := p.process(eventID)
+	if err != nil {
+		fmt.Printf("[SALOME BE] ERROR: Failed to process webhook event %s: %v\n", eventID, err)
+		if _, markErr := p.db.Exec(`
+			UPDATE webhook_events
+			SET status = $1, attempts = attempts + 1, last_error = $2, updated_at = NOW()
+			WHERE id = $3 AND status <> $4
+		`, models.WebhookEventFailed, err.Error(), eventID, models.WebhookEventProcessed); markErr != nil {
+			fmt.Printf("[SALOME BE] ERROR: Failed to mark webhook event %s as failed: %v\n", eventID, markErr)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *WebhookProcessor) process(eventID string) (*WebhookResult, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the event so concurrent deliveries and admin retries serialize on it
+	var event models.WebhookEvent
+	var payload []byte
+	err = tx.QueryRow(`
+		SELECT id, provider, transaction_status, order_id, payment_reference, payload, status
+		FROM webhook_events
+		WHERE id = $1
+		FOR UPDATE
+	`, eventID).Scan(&event.ID, &event.Provider, &event.TransactionStatus, &event.OrderID,
+		&event.PaymentReference, &payload, &event.Status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook event: %v", err)
+	}
+
+	paymentReference := orderref.Resolve(event.OrderID)
+	if event.PaymentReference != nil && *event.PaymentReference != "" {
+		paymentReference = *event.PaymentReference
+	}
+
+	result := &WebhookResult{
+		EventID:          event.ID,
+		PaymentReference: paymentReference,
+		Status:           MapTransactionStatus(event.TransactionStatus),
+	}
+
+	if event.Status == models.WebhookEventProcessed {
+		result.Duplicate = true
+		return result, nil
+	}
+
+	var body struct {
+		PaymentType string `json:"payment_type"`
+	}
+	_ = json.Unmarshal(payload, &body)
+
+	settled, err := p.settlement.Apply(tx, WebhookActor(paymentReference), paymentReference, result.Status, body.PaymentType)
+	if err != nil {
+		return nil, err
+	}
+	result.Applied = settled.Applied
+
+	_, err = tx.Exec(`
+		UPDATE webhook_events
+		SET status = $1, attempts = attempts + 1, last_error = NULL, processed_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, models.WebhookEventProcessed, event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark webhook event as processed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit webhook event: %v", err)
+	}
+
+	fmt.Printf("[SALOME BE] Webhook event %s processed: reference=%s status=%s applied=%t\n",
+		event.ID, paymentReference, result.Status, result.Applied)
+	return result, nil
+}
+
+// GetEvent returns a single webhook event
+func (p *WebhookProcessor) GetEvent(eventID string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	var payload []byte
+	err := p.db.QueryRow(`
+		SELECT id, provider, transaction_id, transaction_status, order_id, payment_reference, payload,
+		       status, attempts, last_error, received_at, processed_at, updated_at
+		FROM webhook_events
+		WHERE id = $1
+	`, eventID).Scan(&event.ID, &event.Provider, &event.TransactionID, &event.TransactionStatus, &event.OrderID,
+		&event.PaymentReference, &payload, &event.Status, &event.Attempts, &event.LastError,
+		&event.ReceivedAt, &event.ProcessedAt, &event.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = json.RawMessage(payload)
+	return &event, nil
+}
+
+// ListEvents returns webhook events filtered by status (empty for all), newest first
+func (p *WebhookProcessor) ListEvents(status string, limit, offset int) ([]models.WebhookEvent, int, error) {
+	var total int
+	err := p.db.QueryRow(`
+		SELECT COUNT(*) FROM webhook_events WHERE ($1 = '' OR status = $1)
+	`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook events: %v", err)
+	}
+
+	rows, err := p.db.Query(`
+		SELECT id, provider, transaction_id, transaction_status, order_id, payment_reference, payload,
+		       status, attempts, last_error, received_at, processed_at, updated_at
+		FROM webhook_events
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY received_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.WebhookEvent{}
+	for rows.Next() {
+		var event models.WebhookEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Provider, &event.TransactionID, &event.TransactionStatus, &event.OrderID,
+			&event.PaymentReference, &payload, &event.Status, &event.Attempts, &event.LastError,
+			&event.ReceivedAt, &event.ProcessedAt, &event.UpdatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook event: %v", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"salome-be/internal/config"
+	"salome-be/internal/money"
+	"salome-be/internal/service"
+)
+
+const (
+	WebhookProviderCloudfren = "cloudfren"
+	WebhookProviderMidtrans  = "midtrans"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrAmountMismatch   = errors.New("gross amount does not match transaction amount")
+)
+
+// WebhookNotification holds the fields of a payment notification that take part in verification and processing
+type WebhookNotification struct {
+	Provider          string
+	OrderID           string
+	TransactionID     string
+	TransactionStatus string
+	PaymentType       string
+	StatusCode        string
+	GrossAmount       string
+	SignatureKey      string
+	RemoteIP          string
+	Payload           []byte
+}
+
+// WebhookVerifier checks payment notifications before they are allowed to touch transactions
+type WebhookVerifier struct {
+	db   *sql.DB
+	keys []string
+}
+
+func NewWebhookVerifier(db *sql.DB) *WebhookVerifier {
+	cfg := config.GetConfig()
+
+	// Cloudfren Core re-signs relayed notifications with the shared webhook secret,
+	// direct Midtrans notifications are signed with the server key
+	var keys []string
+	for _, key := range []string{cfg.Midtrans.Webhook.SecretKey, cfg.Midtrans.ServerKey} {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return &WebhookVerifier{db: db, keys: keys}
+}
+
+// VerifySignature validates signature_key = SHA512(order_id + status_code + gross_amount + key)
+func (v *WebhookVerifier) VerifySignature(n WebhookNotification) error {
+	for _, key := range v.keys {
+		if service.VerifySignatureKey(n.OrderID, n.StatusCode, n.GrossAmount, n.SignatureKey, key) {
+			return nil
+		}
+	}
+
+	v.logRejection(n, "invalid_signature", "signature_key does not match any configured key")
+	return ErrInvalidSignature
+}
+
+// VerifyAmount cross-checks gross_amount against transactions.amount for the given payment reference
+func (v *WebhookVerifier) VerifyAmount(n WebhookNotification, paymentReference string) error {
+	var amount money.Amount
+	err := v.db.QueryRow(`
+		SELECT amount FROM transactions WHERE payment_reference = $1
+	`, paymentReference).Scan(&amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			v.logRejection(n, "transaction_not_found", fmt.Sprintf("payment_reference %s not found", paymentReference))
+		}
+		return err
+	}
+
+	grossAmount, err := money.Parse(n.GrossAmount)
+	if err != nil || grossAmount != amount {
+		v.logRejection(n, "amount_mismatch", fmt.Sprintf("gross_amount %q, transaction amount %s", n.GrossAmount, amount))
+		return ErrAmountMismatch
+	}
+
+	return nil
+}
+
+// logRejection writes a rejected webhook to webhook_audit_logs
+func (v *WebhookVerifier) logRejection(n WebhookNotification, reason, detail string) {
+	fmt.Printf("[SALOME BE] WEBHOOK REJECTED: provider=%s order_id=%s reason=%s ip=%s detail=%s\n",
+		n.Provider, n.OrderID, reason, n.RemoteIP, detail)
+
+	var payload interface{}
+	if len(n.Payload) > 0 {
+		payload = string(n.Payload)
+	}
+
+	_, err := v.db.Exec(`
+		INSERT INTO webhook_audit_logs (provider, order_id, reason, detail, remote_ip, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, n.Provider, n.OrderID, reason, detail, n.RemoteIP, payload)
+	if err != nil {
+		fmt.Printf("[SALOME BE] ERROR: Failed to write webhook audit log: %v\n", err)
+	}
+}
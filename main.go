@@ -9,6 +9,7 @@ import (
 	"salome-be/internal/handlers"
 	"salome-be/internal/middleware"
 	"salome-be/internal/routes"
+	"salome-be/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
 )
@@ -45,6 +46,10 @@ func main() {
 	// CORS middleware
 	r.Use(middleware.CORS())
 
+	// Background jobs; the admin handler lists and triggers the same scheduler
+	appConfig := config.GetConfig()
+	jobScheduler := scheduler.NewDefault(db)
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db)
 	groupHandler := handlers.NewGroupHandler(db)
@@ -56,7 +61,7 @@ func main() {
 	otpHandler := handlers.NewOTPHandler(db)
 	accountCredentialsHandler := handlers.NewAccountCredentialsHandler(db)
 	emailSubmissionHandler := handlers.NewEmailSubmissionHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, jobScheduler)
 	midtransHandler := handlers.NewMidtransHandler(db)
 	paymentLinkHandler := handlers.NewPaymentLinkHandler(db)
 	webhookHandler := handlers.NewWebhookHandler(db)
@@ -64,12 +69,19 @@ func main() {
 	broadcastHandler := handlers.NewBroadcastHandler(db)
 	chatHandler := handlers.NewChatHandler(db)
 	userBroadcastHandler := handlers.NewUserBroadcastHandler(db)
+	payoutHandler := handlers.NewPayoutHandler(db)
+	promoHandler := handlers.NewPromoHandler(db)
 
 	// Setup routes
-	routes.SetupRoutes(r, authHandler, groupHandler, subscriptionHandler, paymentHandler, appHandler, messageHandler, transactionHandler, otpHandler, accountCredentialsHandler, emailSubmissionHandler, adminHandler, midtransHandler, paymentLinkHandler, webhookHandler, notificationHandler, broadcastHandler, chatHandler, userBroadcastHandler, db)
+	routes.SetupRoutes(r, authHandler, groupHandler, subscriptionHandler, paymentHandler, appHandler, messageHandler, transactionHandler, otpHandler, accountCredentialsHandler, emailSubmissionHandler, adminHandler, midtransHandler, paymentLinkHandler, webhookHandler, notificationHandler, broadcastHandler, chatHandler, userBroadcastHandler, payoutHandler, promoHandler, db)
+
+	// Start background jobs
+	if appConfig.Scheduler.Enabled {
+		jobScheduler.Start()
+		defer jobScheduler.Stop()
+	}
 
 	// Start server
-	appConfig := config.GetConfig()
 	port := fmt.Sprintf("%d", appConfig.Server.Port)
 	host := appConfig.Server.Host
 
@@ -0,0 +1,21 @@
+-- Create webhook_audit_logs table to keep track of rejected payment notifications
+CREATE TABLE IF NOT EXISTS webhook_audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(30) NOT NULL, -- 'cloudfren', 'midtrans'
+    order_id VARCHAR(100),
+    reason VARCHAR(50) NOT NULL, -- 'invalid_signature', 'amount_mismatch', 'transaction_not_found'
+    detail TEXT,
+    remote_ip VARCHAR(64),
+    payload JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_webhook_audit_logs_order_id ON webhook_audit_logs(order_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_audit_logs_reason ON webhook_audit_logs(reason);
+CREATE INDEX IF NOT EXISTS idx_webhook_audit_logs_created_at ON webhook_audit_logs(created_at);
+
+-- Add comments
+COMMENT ON TABLE webhook_audit_logs IS 'Payment webhooks rejected by signature or amount verification';
+COMMENT ON COLUMN webhook_audit_logs.reason IS 'Why the webhook was rejected';
+COMMENT ON COLUMN webhook_audit_logs.payload IS 'Raw webhook body as received';
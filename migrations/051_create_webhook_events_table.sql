@@ -0,0 +1,27 @@
+-- Create webhook_events table (inbox) so every payment notification is stored and applied exactly once
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(30) NOT NULL, -- 'cloudfren', 'midtrans'
+    transaction_id VARCHAR(100) NOT NULL, -- provider transaction id (falls back to order_id)
+    transaction_status VARCHAR(30) NOT NULL, -- raw provider status: 'settlement', 'expire', ...
+    order_id VARCHAR(100) NOT NULL,
+    payment_reference VARCHAR(100), -- resolved transactions.payment_reference
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'received', -- 'received', 'processed', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_webhook_event UNIQUE (provider, transaction_id, transaction_status),
+    CONSTRAINT check_webhook_event_status CHECK (status IN ('received', 'processed', 'failed'))
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status ON webhook_events(status);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_order_id ON webhook_events(order_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received_at ON webhook_events(received_at);
+
+-- Add comments
+COMMENT ON TABLE webhook_events IS 'Inbox of verified payment webhooks, de-duplicated on (provider, transaction_id, transaction_status)';
+COMMENT ON COLUMN webhook_events.status IS 'received = stored, processed = applied to transactions, failed = apply error (can be re-driven by admin)';
@@ -0,0 +1,83 @@
+-- Create double-entry ledger tables. Amounts are stored in minor units (1/100 rupiah).
+-- Every entry's postings sum to zero; an account's balance is the sum of its postings.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_type VARCHAR(30) NOT NULL, -- 'user_wallet', 'group_escrow', 'platform_revenue', 'admin_fee_income', 'payment_clearing'
+    owner_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- user/group id, nil uuid for platform accounts
+    name VARCHAR(255) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    balance BIGINT NOT NULL DEFAULT 0, -- cached sum of postings, updated in the same transaction
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_ledger_account UNIQUE (account_type, owner_id)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entry_type VARCHAR(30) NOT NULL, -- 'top_up', 'group_payment', 'withdrawal', 'refund', 'opening_balance'
+    transaction_id UUID REFERENCES transactions(id) ON DELETE RESTRICT,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entry_id UUID NOT NULL REFERENCES ledger_entries(id) ON DELETE RESTRICT,
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id) ON DELETE RESTRICT,
+    amount BIGINT NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_entries_transaction_type ON ledger_entries(transaction_id, entry_type) WHERE transaction_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user_id ON ledger_entries(user_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry_id ON ledger_postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account_id ON ledger_postings(account_id);
+
+-- Platform accounts
+INSERT INTO ledger_accounts (account_type, name) VALUES
+    ('payment_clearing', 'Payment gateway clearing'),
+    ('platform_revenue', 'Platform revenue'),
+    ('admin_fee_income', 'Admin fee income')
+ON CONFLICT (account_type, owner_id) DO NOTHING;
+
+-- Open a wallet for every user with a non-zero users.balance so the ledger starts from the current balances
+DO $$
+DECLARE
+    u RECORD;
+    v_clearing_id UUID;
+    v_wallet_id UUID;
+    v_entry_id UUID;
+    v_amount BIGINT;
+BEGIN
+    SELECT id INTO v_clearing_id FROM ledger_accounts WHERE account_type = 'payment_clearing';
+
+    FOR u IN
+        SELECT id, balance FROM users
+        WHERE COALESCE(balance, 0) <> 0
+          AND NOT EXISTS (
+              SELECT 1 FROM ledger_accounts a WHERE a.account_type = 'user_wallet' AND a.owner_id = users.id
+          )
+    LOOP
+        v_amount := ROUND(u.balance * 100);
+
+        INSERT INTO ledger_accounts (account_type, owner_id, name, balance)
+        VALUES ('user_wallet', u.id, 'User wallet', v_amount)
+        RETURNING id INTO v_wallet_id;
+
+        INSERT INTO ledger_entries (entry_type, user_id, description)
+        VALUES ('opening_balance', u.id, 'Opening balance migrated from users.balance')
+        RETURNING id INTO v_entry_id;
+
+        INSERT INTO ledger_postings (entry_id, account_id, amount)
+        VALUES (v_entry_id, v_wallet_id, v_amount), (v_entry_id, v_clearing_id, -v_amount);
+
+        UPDATE ledger_accounts SET balance = balance - v_amount WHERE id = v_clearing_id;
+    END LOOP;
+END $$;
+
+-- Add comments
+COMMENT ON TABLE ledger_accounts IS 'Ledger accounts; users.balance is a cache of the user_wallet balance';
+COMMENT ON TABLE ledger_entries IS 'Journal entries; postings of an entry always sum to zero';
+COMMENT ON COLUMN ledger_postings.amount IS 'Signed amount in minor units (1/100 rupiah)';
@@ -0,0 +1,16 @@
+-- Support paying for a group seat from the wallet, fully or mixed with a payment link
+
+-- Link a wallet leg of a mixed payment to its payment link transaction (also used for refunds)
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS related_transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL;
+
+-- Part of amount booked as admin fee income
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS admin_fee DECIMAL(15,2) NOT NULL DEFAULT 0;
+
+-- Add indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_transactions_related_transaction_id ON transactions(related_transaction_id);
+
+-- Add comments
+COMMENT ON COLUMN transactions.related_transaction_id IS 'Payment link transaction this wallet leg belongs to, or original payment of a refund';
+COMMENT ON COLUMN transactions.admin_fee IS 'Portion of amount booked as admin fee income';
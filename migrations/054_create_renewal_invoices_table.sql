@@ -0,0 +1,33 @@
+-- Create renewal_invoices table: one invoice per member per billing period of a paid group
+CREATE TABLE IF NOT EXISTS renewal_invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period_start TIMESTAMP NOT NULL, -- start of the period being paid for (end of the current one)
+    period_end TIMESTAMP NOT NULL,
+    amount DECIMAL(15,2) NOT NULL, -- seat price including admin fee
+    admin_fee DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- 'open', 'paid', 'cancelled'
+    due_at TIMESTAMP NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- transaction that paid the invoice
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_renewal_invoice_period UNIQUE (group_id, user_id, period_start),
+    CONSTRAINT check_renewal_invoice_status CHECK (status IN ('open', 'paid', 'cancelled'))
+);
+
+-- Link renewal payments to their invoice
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS renewal_invoice_id UUID REFERENCES renewal_invoices(id) ON DELETE SET NULL;
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_renewal_invoices_user_id ON renewal_invoices(user_id);
+CREATE INDEX IF NOT EXISTS idx_renewal_invoices_status_due_at ON renewal_invoices(status, due_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_renewal_invoice_id ON transactions(renewal_invoice_id);
+CREATE INDEX IF NOT EXISTS idx_group_members_period_end ON group_members(user_status, subscription_period_end);
+
+-- Add comments
+COMMENT ON TABLE renewal_invoices IS 'Per-member renewal invoices, created a few days before subscription_period_end';
+COMMENT ON COLUMN renewal_invoices.status IS 'open = awaiting payment, paid = period extended, cancelled = member left or group closed';
+COMMENT ON COLUMN transactions.renewal_invoice_id IS 'Renewal invoice paid by this group_payment transaction';
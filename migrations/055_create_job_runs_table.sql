@@ -0,0 +1,22 @@
+-- Create job_runs table: one row per execution of a background job
+CREATE TABLE IF NOT EXISTS job_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_name VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- 'running', 'succeeded', 'failed'
+    triggered_by VARCHAR(20) NOT NULL DEFAULT 'schedule', -- 'schedule', 'manual'
+    instance VARCHAR(255), -- host that ran the job
+    result JSONB,
+    error TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    duration_ms BIGINT,
+    CONSTRAINT check_job_run_status CHECK (status IN ('running', 'succeeded', 'failed'))
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_started_at ON job_runs(job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_job_runs_status ON job_runs(status);
+
+-- Add comments
+COMMENT ON TABLE job_runs IS 'History of background scheduler jobs; only one replica runs a job at a time (advisory lock)';
+COMMENT ON COLUMN job_runs.result IS 'Job specific summary, e.g. number of members removed';
@@ -0,0 +1,9 @@
+-- Order IDs are generated as SALO-<type code>-<ULID> (33 characters)
+-- Legacy SALO-TOPUP-/SALO-GRP-/SALO-RNW- references may contain collisions and are left out of the constraint
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_order_reference_unique
+ON transactions(payment_reference)
+WHERE payment_reference ~ '^SALO-[A-Z]-[0-9A-HJKMNP-TV-Z]{26}$';
+
+-- Add comments
+COMMENT ON COLUMN transactions.payment_reference IS 'Order ID sent to the payment gateway: SALO-<type code>-<ULID>, or a legacy SALO-TOPUP-/SALO-GRP-/SALO-RNW- reference';
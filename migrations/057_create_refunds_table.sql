@@ -0,0 +1,38 @@
+-- Create refunds table: money returned to a member who left, was removed or paid for a seat they no longer hold
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID REFERENCES groups(id) ON DELETE SET NULL,
+    original_transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE, -- payment being refunded
+    refund_transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- 'refund' transaction once executed
+    reason VARCHAR(30) NOT NULL, -- 'leave', 'admin_remove', 'payment_timeout', 'late_payment'
+    method VARCHAR(20) NOT NULL DEFAULT 'wallet', -- 'wallet' or 'gateway'
+    amount DECIMAL(15,2) NOT NULL,
+    admin_fee DECIMAL(15,2) NOT NULL DEFAULT 0, -- part of amount taken back from admin fee income
+    paid_amount DECIMAL(15,2) NOT NULL, -- amount of the original payment
+    prorate_ratio DECIMAL(7,6) NOT NULL DEFAULT 1, -- unused share of the subscription period
+    period_start TIMESTAMP,
+    period_end TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending_approval', -- 'pending_approval', 'completed', 'rejected'
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_refund_status CHECK (status IN ('pending_approval', 'completed', 'rejected')),
+    CONSTRAINT check_refund_method CHECK (method IN ('wallet', 'gateway')),
+    CONSTRAINT check_refund_amount CHECK (amount > 0 AND admin_fee >= 0 AND admin_fee <= amount)
+);
+
+-- A payment is refunded at most once (rejected refunds do not count)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_original_transaction_unique
+ON refunds(original_transaction_id) WHERE status <> 'rejected';
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_refunds_user_id ON refunds(user_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds(status, created_at);
+
+-- Add comments
+COMMENT ON TABLE refunds IS 'Prorated refunds of group payments, executed to the wallet or through the payment gateway';
+COMMENT ON COLUMN refunds.status IS 'pending_approval = above the approval threshold, waiting for an admin; completed = money returned; rejected = declined by an admin';
@@ -0,0 +1,53 @@
+-- Create payout_accounts table: bank accounts users withdraw money to
+CREATE TABLE IF NOT EXISTS payout_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bank_code VARCHAR(20) NOT NULL, -- e.g. 'bca', 'bni', 'mandiri'
+    bank_name VARCHAR(100) NOT NULL,
+    account_number VARCHAR(50) NOT NULL,
+    account_holder VARCHAR(255) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE, -- deleted accounts stay referenced by old payouts
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_accounts_user_id ON payout_accounts(user_id) WHERE is_active = TRUE;
+
+-- At most one default account per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payout_accounts_default
+ON payout_accounts(user_id) WHERE is_default = TRUE AND is_active = TRUE;
+
+-- Create payouts table: withdrawal requests reviewed by an admin
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID REFERENCES groups(id) ON DELETE SET NULL, -- set when an owner withdraws group earnings instead of wallet balance
+    payout_account_id UUID NOT NULL REFERENCES payout_accounts(id),
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- 'withdrawal' transaction
+    amount DECIMAL(15,2) NOT NULL, -- taken from the wallet / group escrow
+    fee DECIMAL(15,2) NOT NULL DEFAULT 0,
+    net_amount DECIMAL(15,2) NOT NULL, -- transferred to the bank account
+    -- Bank details at request time, the account may change later
+    bank_name VARCHAR(100) NOT NULL,
+    account_number VARCHAR(50) NOT NULL,
+    account_holder VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'requested', -- 'requested', 'approved', 'paid', 'rejected'
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    paid_at TIMESTAMP,
+    paid_reference VARCHAR(255), -- bank transfer reference entered by the admin
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_payout_status CHECK (status IN ('requested', 'approved', 'paid', 'rejected')),
+    CONSTRAINT check_payout_amount CHECK (amount > 0 AND fee >= 0 AND net_amount > 0 AND net_amount + fee = amount)
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_payouts_user_id ON payouts(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payouts_status ON payouts(status, created_at);
+
+-- Add comments
+COMMENT ON TABLE payouts IS 'Withdrawals of wallet balance or group earnings to a bank account, approved and paid out by an admin';
+COMMENT ON COLUMN payouts.status IS 'requested = money held, waiting for review; approved = waiting for the bank transfer; paid = transferred; rejected = money returned';
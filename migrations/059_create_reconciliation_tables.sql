@@ -0,0 +1,46 @@
+-- Create reconciliation_reports table: one run comparing our gateway transactions with the provider
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    range_from TIMESTAMP NOT NULL, -- transactions created in [range_from, range_to)
+    range_to TIMESTAMP NOT NULL,
+    auto_fix BOOLEAN NOT NULL DEFAULT TRUE,
+    triggered_by UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL when run by the scheduler
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- 'running', 'completed', 'failed'
+    checked INTEGER NOT NULL DEFAULT 0,
+    matched INTEGER NOT NULL DEFAULT 0,
+    discrepancies INTEGER NOT NULL DEFAULT 0,
+    fixed INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    CONSTRAINT check_reconciliation_report_status CHECK (status IN ('running', 'completed', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_started_at ON reconciliation_reports(started_at DESC);
+
+-- Create reconciliation_items table: one discrepancy found in a report
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES reconciliation_reports(id) ON DELETE CASCADE,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    payment_reference VARCHAR(255),
+    transaction_type VARCHAR(30),
+    kind VARCHAR(30) NOT NULL, -- 'status_mismatch', 'amount_mismatch', 'method_mismatch', 'not_found', 'provider_error'
+    local_status VARCHAR(20),
+    provider_status VARCHAR(30),
+    local_amount DECIMAL(15,2),
+    provider_amount DECIMAL(15,2),
+    local_method VARCHAR(50),
+    provider_method VARCHAR(50),
+    fixed BOOLEAN NOT NULL DEFAULT FALSE,
+    action TEXT, -- what the auto-fix did, or why it was left for an admin
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_report_id ON reconciliation_items(report_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_transaction_id ON reconciliation_items(transaction_id);
+
+-- Add comments
+COMMENT ON TABLE reconciliation_reports IS 'Runs of the payment reconciliation against the payment gateway';
+COMMENT ON TABLE reconciliation_items IS 'Discrepancies between transactions and the payment gateway; fixed = corrected automatically';
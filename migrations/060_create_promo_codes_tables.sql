@@ -0,0 +1,52 @@
+-- Create promo_codes table: voucher codes that discount a group seat at checkout
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(50) NOT NULL, -- stored upper case
+    description TEXT,
+    discount_type VARCHAR(20) NOT NULL, -- 'percentage', 'fixed'
+    discount_value DECIMAL(15,2) NOT NULL, -- percent (0-100] or rupiah
+    max_discount DECIMAL(15,2), -- cap for percentage discounts
+    min_amount DECIMAL(15,2) NOT NULL DEFAULT 0, -- minimum seat price the code applies to
+    usage_limit INTEGER, -- redemptions across all users, NULL = unlimited
+    per_user_limit INTEGER DEFAULT 1, -- redemptions per user, NULL = unlimited
+    app_ids VARCHAR(50)[], -- restrict to groups of these apps, NULL = any app
+    first_time_only BOOLEAN NOT NULL DEFAULT FALSE, -- only users without a settled group payment
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_promo_discount_type CHECK (discount_type IN ('percentage', 'fixed')),
+    CONSTRAINT check_promo_discount_value CHECK (discount_value > 0 AND (discount_type = 'fixed' OR discount_value <= 100)),
+    CONSTRAINT check_promo_limits CHECK ((usage_limit IS NULL OR usage_limit > 0) AND (per_user_limit IS NULL OR per_user_limit > 0)),
+    CONSTRAINT check_promo_window CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_codes_code ON promo_codes(UPPER(code));
+
+-- Create promo_redemptions table: one use of a promo code on a group payment
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID REFERENCES groups(id) ON DELETE SET NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE, -- the payment link or wallet transaction
+    original_amount DECIMAL(15,2) NOT NULL, -- seat price before the discount
+    discount_amount DECIMAL(15,2) NOT NULL,
+    fee_discount DECIMAL(15,2) NOT NULL DEFAULT 0, -- part of the discount taken from the admin fee
+    subsidy_amount DECIMAL(15,2) NOT NULL DEFAULT 0, -- part paid by the platform into the group escrow
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'redeemed', 'released'
+    redeemed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_promo_redemption_status CHECK (status IN ('pending', 'redeemed', 'released')),
+    CONSTRAINT check_promo_redemption_amount CHECK (discount_amount > 0 AND fee_discount + subsidy_amount = discount_amount)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_redemptions_transaction_id ON promo_redemptions(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_code_user ON promo_redemptions(promo_code_id, user_id) WHERE status <> 'released';
+
+-- Add comments
+COMMENT ON TABLE promo_codes IS 'Voucher codes applied to group seat payments; usage counts pending and redeemed redemptions';
+COMMENT ON COLUMN promo_redemptions.status IS 'pending = payment link not settled yet; redeemed = payment settled; released = payment failed or expired, the use is given back';
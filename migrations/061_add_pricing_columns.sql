@@ -0,0 +1,15 @@
+-- Per-app admin fee settings; NULL falls back to the pricing configuration
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS admin_fee_fixed DECIMAL(15,2);
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS admin_fee_min DECIMAL(15,2);
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS admin_fee_max DECIMAL(15,2);
+
+-- How the seat price of a group and the amount of a payment were computed
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS price_breakdown JSONB;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS price_breakdown JSONB;
+
+-- Add comments
+COMMENT ON COLUMN apps.admin_fee_fixed IS 'Fixed admin fee added to the percentage fee; NULL = pricing.fixed_fee';
+COMMENT ON COLUMN apps.admin_fee_min IS 'Minimum admin fee per seat; NULL = pricing.min_fee';
+COMMENT ON COLUMN apps.admin_fee_max IS 'Maximum admin fee per seat; NULL = pricing.max_fee';
+COMMENT ON COLUMN groups.price_breakdown IS 'Seat price breakdown from the pricing engine when the group was created or repriced';
+COMMENT ON COLUMN transactions.price_breakdown IS 'Seat price breakdown of a group payment, including discount and payment method surcharge';
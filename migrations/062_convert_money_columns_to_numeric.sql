@@ -0,0 +1,12 @@
+-- Money is read and written as exact decimal major units (see internal/money).
+-- Databases that ran 003 or 049 before the DECIMAL migrations still have INTEGER columns, which
+-- reject amounts such as '150000.00'; convert them so every money column has the same type.
+-- The ledger keeps BIGINT minor units and is not affected.
+ALTER TABLE users ALTER COLUMN balance TYPE DECIMAL(15,2);
+ALTER TABLE users ALTER COLUMN total_spent TYPE DECIMAL(15,2);
+ALTER TABLE apps ALTER COLUMN total_price TYPE DECIMAL(15,2);
+
+-- Add comments
+COMMENT ON COLUMN users.balance IS 'Cached wallet balance in IDR major units; the ledger is authoritative';
+COMMENT ON COLUMN users.total_spent IS 'Total amount spent by user in IDR major units';
+COMMENT ON COLUMN apps.total_price IS 'Subscription price of the app in IDR major units';
@@ -0,0 +1,25 @@
+-- Create invoice_sequences table: last invoice number handed out per year
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year INTEGER PRIMARY KEY,
+    last_number INTEGER NOT NULL DEFAULT 0
+);
+
+-- Create invoices table: one numbered receipt per successful payment or top-up
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_number VARCHAR(40) NOT NULL UNIQUE, -- e.g. INV/2026/000042
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE RESTRICT,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    emailed_at TIMESTAMP, -- confirmation email with the PDF attached was sent
+    email_attempts INTEGER NOT NULL DEFAULT 0,
+    email_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_user_id ON invoices(user_id, issued_at DESC);
+CREATE INDEX IF NOT EXISTS idx_invoices_unsent ON invoices(issued_at) WHERE emailed_at IS NULL;
+
+-- Add comments
+COMMENT ON TABLE invoices IS 'Sequentially numbered receipts for successful transactions, rendered as PDF on request';
+COMMENT ON COLUMN invoices.email_attempts IS 'Number of failed attempts to send the confirmation email';
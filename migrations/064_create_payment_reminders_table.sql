@@ -0,0 +1,24 @@
+-- Create payment_reminders table: reminders sent to pending members before their payment deadline
+CREATE TABLE IF NOT EXISTS payment_reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payment_deadline TIMESTAMP NOT NULL, -- deadline the reminder was for; a new deadline gets new reminders
+    reminder VARCHAR(20) NOT NULL, -- time before the deadline from the dunning policy, e.g. '12h'
+    status VARCHAR(20) NOT NULL DEFAULT 'sending', -- 'sending', 'sent', 'skipped', 'failed'
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- payment link transaction included
+    payment_url TEXT,
+    link_created BOOLEAN NOT NULL DEFAULT FALSE, -- a fresh link replaced an expired one
+    emailed BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    CONSTRAINT uq_payment_reminders UNIQUE (group_id, user_id, payment_deadline, reminder),
+    CONSTRAINT check_payment_reminder_status CHECK (status IN ('sending', 'sent', 'skipped', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_reminders_user_id ON payment_reminders(user_id, created_at DESC);
+
+-- Add comments
+COMMENT ON TABLE payment_reminders IS 'Dunning reminders; the unique key makes sure each reminder is sent at most once';
+COMMENT ON COLUMN payment_reminders.status IS 'skipped = a later reminder was already due, only the most urgent one is sent';
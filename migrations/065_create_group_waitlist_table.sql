@@ -0,0 +1,24 @@
+-- Create group_waitlist table: users queueing for a seat in a full group
+CREATE TABLE IF NOT EXISTS group_waitlist (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, -- lower is earlier; owners can reorder the queue
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting', -- 'waiting', 'offered', 'claimed', 'expired', 'cancelled'
+    offered_at TIMESTAMP,
+    offer_expires_at TIMESTAMP, -- end of the claim window of an offered seat
+    claimed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_group_waitlist_status CHECK (status IN ('waiting', 'offered', 'claimed', 'expired', 'cancelled'))
+);
+
+-- A user is queued at most once per group
+CREATE UNIQUE INDEX IF NOT EXISTS uq_group_waitlist_active ON group_waitlist(group_id, user_id)
+    WHERE status IN ('waiting', 'offered');
+CREATE INDEX IF NOT EXISTS idx_group_waitlist_queue ON group_waitlist(group_id, status, position);
+CREATE INDEX IF NOT EXISTS idx_group_waitlist_offer_expires_at ON group_waitlist(offer_expires_at) WHERE status = 'offered';
+
+-- Add comments
+COMMENT ON TABLE group_waitlist IS 'Waitlist of full groups; a freed seat is offered to the first waiting user';
+COMMENT ON COLUMN group_waitlist.status IS 'offered = a seat is held for the user until offer_expires_at';
@@ -0,0 +1,8 @@
+-- Groups created by matchmaking are owned by the platform account instead of a user
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS is_platform_owned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Matchmaking looks up open public groups of an app
+CREATE INDEX IF NOT EXISTS idx_groups_matchmaking ON groups(app_id, group_status) WHERE is_public = true;
+
+-- Add comments
+COMMENT ON COLUMN groups.is_platform_owned IS 'Created by matchmaking; the owner is the platform account and holds no seat';
@@ -0,0 +1,39 @@
+-- Create status_events table: append-only history of member and group status transitions
+CREATE TABLE IF NOT EXISTS status_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_type VARCHAR(20) NOT NULL, -- 'member' or 'group'
+    group_id UUID NOT NULL, -- no foreign key, the history outlives deleted groups and members
+    user_id UUID, -- the member of a member event
+    from_status VARCHAR(20), -- NULL when the entity was created
+    to_status VARCHAR(20) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL, -- 'user', 'admin', 'system' or 'webhook'
+    actor_id UUID, -- user or admin who made the change
+    reason TEXT,
+    correlation_id VARCHAR(100), -- ties events caused by the same request, payment or job run together
+    is_override BOOLEAN NOT NULL DEFAULT FALSE, -- admin change the state machine would not allow
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_status_event_entity CHECK (entity_type IN ('member', 'group')),
+    CONSTRAINT check_status_event_actor CHECK (actor_type IN ('user', 'admin', 'system', 'webhook')),
+    CONSTRAINT check_status_event_member CHECK (entity_type = 'group' OR user_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_events_group_id ON status_events(group_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_status_events_user_id ON status_events(user_id, created_at DESC) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_status_events_created_at ON status_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_status_events_correlation_id ON status_events(correlation_id) WHERE correlation_id IS NOT NULL;
+
+-- Events are never changed or removed
+CREATE OR REPLACE FUNCTION prevent_status_event_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'status_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_status_events_append_only ON status_events;
+CREATE TRIGGER trg_status_events_append_only
+    BEFORE UPDATE OR DELETE ON status_events
+    FOR EACH ROW EXECUTE FUNCTION prevent_status_event_changes();
+
+-- Add comments
+COMMENT ON TABLE status_events IS 'Append-only history of member and group status transitions, written in the transaction that made them';
+COMMENT ON COLUMN status_events.is_override IS 'TRUE when an admin set a status outside the allowed transitions';
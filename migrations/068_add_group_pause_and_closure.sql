@@ -0,0 +1,16 @@
+-- Owners can pause a paid group between billing cycles and schedule its closure at period end
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS check_group_status;
+ALTER TABLE groups ADD CONSTRAINT check_group_status
+  CHECK (group_status IN ('open', 'private', 'full', 'paid_group', 'paused', 'closing', 'closed'));
+
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS pause_reason TEXT;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS closes_at TIMESTAMP;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS closure_reason TEXT;
+
+-- The renewal job closes groups whose scheduled closure is due
+CREATE INDEX IF NOT EXISTS idx_groups_closes_at ON groups(closes_at) WHERE group_status = 'closing';
+
+-- Add comments
+COMMENT ON COLUMN groups.paused_at IS 'When the group was paused; members keep their seat and are not billed until it resumes';
+COMMENT ON COLUMN groups.closes_at IS 'When a group in closing status is closed, the end of the last paid period';
@@ -0,0 +1,40 @@
+-- Create group_invites table: invite links owners hand out, each with its own expiry, usage cap and target
+CREATE TABLE IF NOT EXISTS group_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    code VARCHAR(10) NOT NULL UNIQUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    target_email VARCHAR(255), -- only this user can join through the link
+    max_uses INTEGER, -- NULL for unlimited
+    use_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP, -- NULL for no expiry
+    revoked_at TIMESTAMP,
+    replaced_by UUID REFERENCES group_invites(id) ON DELETE SET NULL, -- link created when this one was regenerated
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_group_invites_max_uses CHECK (max_uses IS NULL OR max_uses > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_invites_group_id ON group_invites(group_id, created_at DESC);
+
+-- Create group_invite_uses table: who joined through which link
+CREATE TABLE IF NOT EXISTS group_invite_uses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invite_id UUID NOT NULL REFERENCES group_invites(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_invite_uses_invite_id ON group_invite_uses(invite_id, used_at DESC);
+
+-- The existing invite code of every group becomes its first invite link, so it keeps working and can be revoked
+INSERT INTO group_invites (group_id, code, created_by, created_at, updated_at)
+SELECT id, invite_code, owner_id, created_at, NOW()
+FROM groups
+WHERE invite_code IS NOT NULL
+ON CONFLICT (code) DO NOTHING;
+
+-- Add comments
+COMMENT ON TABLE group_invites IS 'Invite links of a group; groups.invite_code is the code of the link created with the group';
+COMMENT ON COLUMN group_invites.use_count IS 'Seats taken through the link, compared against max_uses';
@@ -0,0 +1,29 @@
+-- Create group_invitations table: invitations an owner sends to an email address to join a group
+CREATE TABLE IF NOT EXISTS group_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    email VARCHAR(255) NOT NULL, -- lower case
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- the invited user, once known
+    token VARCHAR(64) NOT NULL UNIQUE, -- identifies the invitation in the sign-up-and-join link
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'accepted', 'declined', 'expired'
+    delivery VARCHAR(20) NOT NULL, -- 'notification' for existing users, 'email' for unknown addresses
+    email_sent_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    responded_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_group_invitations_status CHECK (status IN ('pending', 'accepted', 'declined', 'expired')),
+    CONSTRAINT check_group_invitations_delivery CHECK (delivery IN ('notification', 'email'))
+);
+
+-- An address has at most one pending invitation per group
+CREATE UNIQUE INDEX IF NOT EXISTS uq_group_invitations_pending ON group_invitations(group_id, email)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_group_invitations_group_id ON group_invitations(group_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_group_invitations_email ON group_invitations(email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_group_invitations_expires_at ON group_invitations(expires_at) WHERE status = 'pending';
+
+-- Add comments
+COMMENT ON TABLE group_invitations IS 'Email invitations to join a group; accepting one reserves a seat';
+COMMENT ON COLUMN group_invitations.user_id IS 'Set when the invitation is sent to an existing user or accepted after sign-up';
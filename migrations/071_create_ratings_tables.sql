@@ -0,0 +1,37 @@
+-- Create user_ratings table: after a billing period members rate the owner and the owner rates members
+CREATE TABLE IF NOT EXISTS user_ratings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    rater_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ratee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ratee_role VARCHAR(10) NOT NULL, -- 'owner' when a member rates the owner, 'member' when the owner rates a member
+    period_end TIMESTAMP NOT NULL, -- end of the member's billing period being rated
+    score SMALLINT NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_user_rating_period UNIQUE (group_id, rater_id, ratee_id, period_end),
+    CONSTRAINT check_user_ratings_role CHECK (ratee_role IN ('owner', 'member')),
+    CONSTRAINT check_user_ratings_score CHECK (score BETWEEN 1 AND 5),
+    CONSTRAINT check_user_ratings_not_self CHECK (rater_id <> ratee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_ratings_ratee_id ON user_ratings(ratee_id, created_at DESC);
+
+-- Create user_reputation table: aggregated ratings and payment signals of a user, recalculated periodically
+CREATE TABLE IF NOT EXISTS user_reputation (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    score DECIMAL(5,2) NOT NULL, -- 0 to 100
+    owner_rating DECIMAL(3,2), -- average rating received as owner, NULL when never rated
+    owner_rating_count INTEGER NOT NULL DEFAULT 0,
+    member_rating DECIMAL(3,2), -- average rating received as member, NULL when never rated
+    member_rating_count INTEGER NOT NULL DEFAULT 0,
+    late_payments INTEGER NOT NULL DEFAULT 0, -- renewal invoices paid after, or still open past, their due date
+    removals INTEGER NOT NULL DEFAULT 0, -- removals from a group for not paying or by an admin
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_reputation_score ON user_reputation(score DESC);
+
+-- Add comments
+COMMENT ON TABLE user_reputation IS 'Reputation shown on profiles and group listings; see ReputationService for the score formula';
@@ -0,0 +1,42 @@
+-- Create group_disputes table: a member reports that the shared subscription did not work for a billing period,
+-- the owner responds and an admin resolves it. The owner's earnings for the period are held while it is open.
+CREATE TABLE IF NOT EXISTS group_disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    member_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- the member who opened the dispute
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- owner of the group when it was opened
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL, -- the disputed billing period of the member
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL, -- the payment for the period, NULL when none was found
+    category VARCHAR(30) NOT NULL, -- 'credentials_not_shared', 'account_revoked', 'wrong_plan', 'other'
+    evidence TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- 'open', 'responded', 'resolved', 'withdrawn'
+    owner_response TEXT,
+    responded_at TIMESTAMP,
+    held_amount DECIMAL(15,2) NOT NULL DEFAULT 0, -- moved from the group escrow to the dispute hold account
+    resolution VARCHAR(20), -- 'refund', 'remove_member', 'suspend_owner', 'dismissed'
+    resolution_note TEXT,
+    refunded_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_group_disputes_category CHECK (category IN ('credentials_not_shared', 'account_revoked', 'wrong_plan', 'other')),
+    CONSTRAINT check_group_disputes_status CHECK (status IN ('open', 'responded', 'resolved', 'withdrawn')),
+    CONSTRAINT check_group_disputes_resolution CHECK (resolution IS NULL OR resolution IN ('refund', 'remove_member', 'suspend_owner', 'dismissed')),
+    CONSTRAINT check_group_disputes_held_amount CHECK (held_amount >= 0)
+);
+
+-- A billing period can be disputed once; a withdrawn dispute may be opened again
+CREATE UNIQUE INDEX IF NOT EXISTS uq_group_disputes_period ON group_disputes(group_id, member_id, period_end)
+    WHERE status <> 'withdrawn';
+CREATE INDEX IF NOT EXISTS idx_group_disputes_group_id ON group_disputes(group_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_group_disputes_status ON group_disputes(status, created_at) WHERE status IN ('open', 'responded');
+
+-- Disputes upheld against a user as owner lower their reputation
+ALTER TABLE user_reputation ADD COLUMN IF NOT EXISTS disputes INTEGER NOT NULL DEFAULT 0;
+
+-- Add comments
+COMMENT ON TABLE group_disputes IS 'Member disputes about a billing period of a group; see DisputeService';
+COMMENT ON COLUMN group_disputes.held_amount IS 'Held in the dispute_hold ledger account of the dispute until it is resolved or withdrawn';
+COMMENT ON COLUMN user_reputation.disputes IS 'Disputes about groups the user owns that an admin resolved in favour of the member';
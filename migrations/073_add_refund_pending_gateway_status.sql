@@ -0,0 +1,9 @@
+-- Gateway refunds are booked with the member's refund and paid out by the payment gateway after it commits
+ALTER TABLE refunds DROP CONSTRAINT IF EXISTS check_refund_status;
+ALTER TABLE refunds ADD CONSTRAINT check_refund_status
+  CHECK (status IN ('pending_approval', 'pending_gateway', 'completed', 'rejected'));
+
+-- Refunds waiting for the payment gateway are picked up by the gateway refunds job
+CREATE INDEX IF NOT EXISTS idx_refunds_pending_gateway ON refunds(created_at) WHERE status = 'pending_gateway';
+
+COMMENT ON COLUMN refunds.status IS 'pending_approval = above the approval threshold, waiting for an admin; pending_gateway = booked, waiting for the payment gateway; completed = money returned; rejected = declined by an admin';